@@ -4,3 +4,8 @@ import "embed"
 
 //go:embed public
 var PublicFS embed.FS
+
+// TemplatesFS 服务端渲染的页面模板
+//
+//go:embed templates
+var TemplatesFS embed.FS
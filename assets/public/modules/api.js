@@ -8,13 +8,16 @@ export const API = {
     return h;
   },
 
-  async request(method, path, body = null) {
+  async request(method, path, body = null, retried = false) {
     const opts = { method, headers: this.headers() };
     if (body) opts.body = JSON.stringify(body);
 
     try {
       const res = await fetch(API_BASE + path, opts);
 
+      if (res.status === 401 && !retried && (await this.refresh())) {
+        return this.request(method, path, body, true);
+      }
       if (res.status === 401) {
         // Dispatch event for Auth module to handle
         window.dispatchEvent(new CustomEvent("auth:unauthorized"));
@@ -36,6 +39,30 @@ export const API = {
     }
   },
 
+  // Exchange the stored refresh token for a new access token, at most one request at a time
+  refresh() {
+    if (!Store.refreshToken) return Promise.resolve(false);
+    if (!this.refreshing) {
+      this.refreshing = fetch(API_BASE + "/auth/refresh", {
+        method: "POST",
+        headers: { "Content-Type": "application/json" },
+        body: JSON.stringify({ refreshToken: Store.refreshToken }),
+      })
+        .then(async (res) => {
+          if (!res.ok) return false;
+          const { data } = await res.json();
+          Store.token = data.accessToken;
+          Store.refreshToken = data.refreshToken;
+          return true;
+        })
+        .catch(() => false)
+        .finally(() => {
+          this.refreshing = null;
+        });
+    }
+    return this.refreshing;
+  },
+
   get: (path) => API.request("GET", path),
   post: (path, body) => API.request("POST", path, body),
   put: (path, body) => API.request("PUT", path, body),
@@ -1,6 +1,7 @@
 import { API } from "./api.js";
 import { Store } from "./store.js";
 import { UI } from "./ui.js";
+import { Passkey } from "./webauthn.js";
 
 export const Auth = {
   init() {
@@ -9,7 +10,11 @@ export const Auth = {
       this.logout("Session expired")
     );
 
-    if (Store.token) {
+    this.initSSO();
+
+    if (this.handleSSOCallback() || this.handleEmailLink()) {
+      // Email link flows take over the initial view
+    } else if (Store.token) {
       this.showMain();
       this.fetchProfile();
     } else {
@@ -37,7 +42,7 @@ export const Auth = {
 
       try {
         const res = await API.post("/auth/login", { email, password });
-        this.login(res.accessToken, res.user);
+        await this.completeLogin(res);
       } catch (err) {
         UI.showToast(err.message, "error");
       }
@@ -55,17 +60,150 @@ export const Auth = {
           email,
           password,
         });
-        this.login(res.accessToken, res.user);
+        this.login(res.accessToken, res.user, res.refreshToken);
       } catch (err) {
         UI.showToast(err.message, "error");
       }
     };
 
-    UI.logoutBtn.onclick = () => this.logout();
+    UI.logoutBtn.onclick = async () => {
+      // Revoke the server-side session; clear local state even if that fails
+      await API.post("/auth/logout").catch(() => {});
+      this.logout();
+    };
+
+    if (Passkey.supported()) {
+      UI.toggleVisibility(UI.passkeyLoginBtn, true);
+      UI.toggleVisibility(UI.addPasskeyBtn, true);
+
+      UI.passkeyLoginBtn.onclick = async () => {
+        try {
+          await this.completeLogin(await Passkey.login());
+        } catch (err) {
+          UI.showToast(err.message, "error");
+        }
+      };
+
+      UI.addPasskeyBtn.onclick = async () => {
+        const name = window.prompt("Name this passkey", "My device");
+        if (name === null) return;
+        try {
+          await Passkey.register(name);
+          UI.showToast("Passkey added");
+        } catch (err) {
+          UI.showToast(err.message, "error");
+        }
+      };
+    }
+  },
+
+  // Show the SSO button when single sign-on is configured on the server
+  async initSSO() {
+    try {
+      const sso = await API.get("/auth/oidc");
+      if (sso.enabled) {
+        UI.ssoLoginBtn.textContent = `Sign in with ${sso.name}`;
+        UI.toggleVisibility(UI.ssoLoginBtn, true);
+      }
+    } catch (e) {
+      // SSO not enabled
+    }
+  },
+
+  // The SSO callback redirects to /oidc/callback with tokens (or a two-factor challenge) in the URL fragment
+  handleSSOCallback() {
+    if (window.location.pathname !== "/oidc/callback") return false;
+
+    const params = new URLSearchParams(window.location.hash.slice(1));
+    window.history.replaceState(null, "", "/");
+
+    const accessToken = params.get("accessToken");
+    const mfaToken = params.get("mfaToken");
+    if (mfaToken) {
+      this.showAuth();
+      this.completeLogin({
+        mfaRequired: true,
+        mfaToken,
+        mfaSetupRequired: params.get("mfaSetupRequired") === "true",
+      }).catch((err) => UI.showToast(err.message, "error"));
+    } else if (accessToken) {
+      this.login(accessToken, null, params.get("refreshToken"));
+      this.fetchProfile();
+    } else {
+      this.showAuth();
+      UI.showToast(params.get("error") || "SSO login failed", "error");
+    }
+    return true;
+  },
+
+  // Handle links sent by email: /verify-email, /reset-password, /magic-link
+  handleEmailLink() {
+    const path = window.location.pathname;
+    const token = new URLSearchParams(window.location.search).get("token");
+    const flows = {
+      "/verify-email": async () => {
+        await API.post("/auth/verify-email", { token });
+        UI.showToast("Email verified");
+      },
+      "/reset-password": async () => {
+        const newPassword = window.prompt("New password (at least 6 characters)");
+        if (!newPassword) return;
+        await API.post("/auth/password/reset", { token, newPassword });
+        UI.showToast("Password reset, please log in");
+      },
+      "/magic-link": async () => {
+        const res = await API.post("/auth/magic-link/verify", { token });
+        await this.completeLogin(res);
+      },
+    };
+    if (!token || !flows[path]) return false;
+
+    window.history.replaceState(null, "", "/");
+    if (Store.token) {
+      this.showMain();
+      this.fetchProfile();
+    } else {
+      this.showAuth();
+    }
+    flows[path]().catch((err) => UI.showToast(err.message, "error"));
+    return true;
+  },
+
+  // Finish a login response, running the two-factor step when the server asks for it
+  async completeLogin(res) {
+    if (!res.mfaRequired) {
+      this.login(res.accessToken, res.user, res.refreshToken);
+      return;
+    }
+
+    const mfaToken = res.mfaToken;
+    if (res.mfaSetupRequired) {
+      const setup = await API.post("/auth/2fa/setup", { mfaToken });
+      const code = window.prompt(
+        `Two-factor authentication is required.\nAdd this key to your authenticator app:\n${setup.secret}\n\nThen enter the 6-digit code:`
+      );
+      if (!code) return;
+      const result = await API.post("/auth/2fa/enable", { mfaToken, code });
+      window.alert(
+        `Save these recovery codes, each can be used once:\n\n${result.recoveryCodes.join("\n")}`
+      );
+      this.login(
+        result.auth.accessToken,
+        result.auth.user,
+        result.auth.refreshToken
+      );
+      return;
+    }
+
+    const code = window.prompt("Enter the code from your authenticator app or a recovery code");
+    if (!code) return;
+    const auth = await API.post("/auth/2fa/verify", { mfaToken, code });
+    this.login(auth.accessToken, auth.user, auth.refreshToken);
   },
 
-  login(token, user) {
+  login(token, user, refreshToken) {
     Store.token = token;
+    Store.refreshToken = refreshToken;
     Store.user = user;
     this.updateProfileUI(user);
     this.showMain();
@@ -283,7 +283,7 @@ export const Chat = {
       });
 
       // 3. Start SSE
-      this.startSSE(Store.currentConversationId, content);
+      await this.startSSE(Store.currentConversationId, content);
     } catch (e) {
       UI.showToast(e.message, "error");
       Store.isGenerating = false;
@@ -299,6 +299,8 @@ export const Chat = {
     div.className = `flex w-full ${
       isUser ? "justify-end" : "justify-start"
     } animate-fade-in`;
+    // Anchor for jumping to a message from search results
+    if (msg.id) div.id = `message-${msg.id}`;
 
     const bubble = document.createElement("div");
     bubble.className = isUser ? "message-user" : "message-assistant";
@@ -333,12 +335,15 @@ export const Chat = {
         `;
   },
 
-  startSSE(conversationId, prompt) {
+  async startSSE(conversationId, prompt) {
     if (Store.eventSource) Store.eventSource.close();
 
+    // EventSource cannot send headers, so trade the access token for a single-use ticket
+    const { ticket } = await API.post("/ai/stream-tickets", { conversationId });
+
     const params = new URLSearchParams();
     if (prompt) params.append("prompt", prompt);
-    if (Store.token) params.append("token", Store.token);
+    params.append("ticket", ticket);
     params.append(
       "thinking",
       UI.thinkingToggle.checked ? "enabled" : "disabled"
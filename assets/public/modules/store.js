@@ -1,6 +1,7 @@
 export const Store = {
   state: {
     token: localStorage.getItem("token"),
+    refreshToken: localStorage.getItem("refreshToken"),
     currentConversationId: null,
     user: null,
     eventSource: null,
@@ -16,6 +17,15 @@ export const Store = {
     else localStorage.removeItem("token");
   },
 
+  get refreshToken() {
+    return this.state.refreshToken;
+  },
+  set refreshToken(val) {
+    this.state.refreshToken = val;
+    if (val) localStorage.setItem("refreshToken", val);
+    else localStorage.removeItem("refreshToken");
+  },
+
   get user() {
     return this.state.user;
   },
@@ -47,6 +57,7 @@ export const Store = {
   // Helper to reset sensitive state on logout
   reset() {
     this.token = null;
+    this.refreshToken = null;
     this.user = null;
     this.currentConversationId = null;
     this.isGenerating = false;
@@ -38,6 +38,15 @@ export const UI = {
   get loginPassword() {
     return getEl("login-password");
   },
+  get ssoLoginBtn() {
+    return getEl("sso-login-btn");
+  },
+  get passkeyLoginBtn() {
+    return getEl("passkey-login-btn");
+  },
+  get addPasskeyBtn() {
+    return getEl("add-passkey-btn");
+  },
   get regName() {
     return getEl("reg-name");
   },
@@ -0,0 +1,75 @@
+import { API } from "./api.js";
+
+// The server speaks base64url for binary fields; the browser API wants ArrayBuffers
+const toBuffer = (value) => {
+  const base64 = value.replace(/-/g, "+").replace(/_/g, "/");
+  const padded = base64 + "=".repeat((4 - (base64.length % 4)) % 4);
+  return Uint8Array.from(atob(padded), (c) => c.charCodeAt(0)).buffer;
+};
+
+const toBase64URL = (buffer) => {
+  const bytes = new Uint8Array(buffer);
+  let binary = "";
+  bytes.forEach((b) => (binary += String.fromCharCode(b)));
+  return btoa(binary).replace(/\+/g, "-").replace(/\//g, "_").replace(/=+$/, "");
+};
+
+const encodeCredential = (cred) => {
+  const response = {
+    clientDataJSON: toBase64URL(cred.response.clientDataJSON),
+  };
+  if (cred.response.attestationObject) {
+    response.attestationObject = toBase64URL(cred.response.attestationObject);
+    response.transports = cred.response.getTransports?.() || [];
+  }
+  if (cred.response.authenticatorData) {
+    response.authenticatorData = toBase64URL(cred.response.authenticatorData);
+    response.signature = toBase64URL(cred.response.signature);
+    if (cred.response.userHandle) {
+      response.userHandle = toBase64URL(cred.response.userHandle);
+    }
+  }
+  return {
+    id: cred.id,
+    rawId: toBase64URL(cred.rawId),
+    type: cred.type,
+    authenticatorAttachment: cred.authenticatorAttachment,
+    response,
+  };
+};
+
+export const Passkey = {
+  supported() {
+    return !!window.PublicKeyCredential;
+  },
+
+  // Register a new passkey for the signed-in user
+  async register(name) {
+    const { options, sessionToken } = await API.post("/auth/webauthn/register/begin");
+    const publicKey = options.publicKey;
+    publicKey.challenge = toBuffer(publicKey.challenge);
+    publicKey.user.id = toBuffer(publicKey.user.id);
+    (publicKey.excludeCredentials || []).forEach((c) => (c.id = toBuffer(c.id)));
+
+    const cred = await navigator.credentials.create({ publicKey });
+    return API.post("/auth/webauthn/register/finish", {
+      sessionToken,
+      name,
+      credential: encodeCredential(cred),
+    });
+  },
+
+  // Sign in with a discoverable passkey, returns the login response
+  async login() {
+    const { options, sessionToken } = await API.post("/auth/webauthn/login/begin");
+    const publicKey = options.publicKey;
+    publicKey.challenge = toBuffer(publicKey.challenge);
+    (publicKey.allowCredentials || []).forEach((c) => (c.id = toBuffer(c.id)));
+
+    const cred = await navigator.credentials.get({ publicKey });
+    return API.post("/auth/webauthn/login/finish", {
+      sessionToken,
+      credential: encodeCredential(cred),
+    });
+  },
+};
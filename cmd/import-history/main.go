@@ -0,0 +1,72 @@
+// import-history 从 ChatGPT、Claude 或本系统的导出文件导入历史会话，适合接口上传受限的大文件
+//
+// 用法: go run ./cmd/import-history -email user@example.com [-workspace 1] [-source auto] conversations.json
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+
+	"ai-chat/config"
+	"ai-chat/internal/policy"
+	"ai-chat/internal/repository"
+	"ai-chat/internal/service"
+)
+
+func main() {
+	email := flag.String("email", "", "导入到该邮箱对应的用户")
+	workspaceID := flag.Uint("workspace", 0, "导入到指定工作区，默认导入个人空间")
+	source := flag.String("source", service.HistorySourceAuto, "来源: auto chatgpt claude native")
+	flag.Parse()
+
+	if *email == "" || flag.NArg() != 1 {
+		fmt.Fprintln(os.Stderr, "用法: import-history -email <邮箱> [-workspace <工作区ID>] [-source auto|chatgpt|claude|native] <导出文件>")
+		os.Exit(2)
+	}
+
+	cfg, err := config.Load()
+	if err != nil {
+		log.Fatal("Failed to load config:", err)
+	}
+
+	db, err := repository.NewDB(cfg)
+	if err != nil {
+		log.Fatal("Failed to connect database:", err)
+	}
+
+	var user repository.User
+	if err := db.Where("email = ?", *email).First(&user).Error; err != nil {
+		log.Fatalf("查找用户 %s 失败: %v", *email, err)
+	}
+
+	principal := policy.Principal{UserID: user.ID, Role: user.Role}
+	if *workspaceID != 0 {
+		id := *workspaceID
+		principal.WorkspaceID = &id
+	}
+
+	data, err := os.ReadFile(flag.Arg(0))
+	if err != nil {
+		log.Fatal("读取导出文件失败:", err)
+	}
+
+	report, err := service.NewHistoryImportService(db).Import(principal, data, *source, service.MaxHistoryUnzippedSize,
+		func(done, total int, item service.HistoryImportItem) {
+			line := fmt.Sprintf("[%d/%d] %s %q (%d 条消息)", done, total, item.Status, item.Title, item.Messages)
+			if item.Error != "" {
+				line += ": " + item.Error
+			}
+			fmt.Println(line)
+		})
+	if err != nil {
+		log.Fatal("导入失败:", err)
+	}
+
+	fmt.Printf("来源 %s: 共 %d 个会话，导入 %d，跳过 %d，失败 %d\n",
+		report.Source, report.Total, report.Imported, report.Skipped, report.Failed)
+	if report.Failed > 0 {
+		os.Exit(1)
+	}
+}
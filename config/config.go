@@ -1,6 +1,7 @@
 package config
 
 import (
+	"net/url"
 	"os"
 	"strconv"
 	"strings"
@@ -28,9 +29,116 @@ type Config struct {
 	BaseURL   string
 	Model     string
 
+	// 支持图片输入的模型，支持 * 通配符，不区分大小写
+	VisionModels []string
+
+	// 工作区可以自定义的模型接口主机，为空时工作区只能设置密钥和默认模型
+	WorkspaceProviderHosts []string
+
+	// 语义检索，EmbeddingProvider 为空时不启用
+	EmbeddingProvider      string // openai 为兼容 /embeddings 的接口，local 为本地哈希向量（仅用于开发测试）
+	EmbeddingBaseURL       string
+	EmbeddingAPIKey        string
+	EmbeddingModel         string
+	EmbeddingIndexInterval int64 // 后台索引新消息的间隔（秒）
+
+	// 消息附件
+	AttachmentStore           string   // local 或 s3
+	AttachmentLocalDir        string   // local 存储的根目录
+	AttachmentMaxSize         int64    // 单个文件大小上限（字节）
+	AttachmentAllowedTypes    []string // 允许的 MIME 类型，按文件内容识别
+	AttachmentUserQuota       int64    // 每个用户的附件总容量（字节），0 表示不限制
+	AttachmentURLTTL          int64    // 下载链接有效期（秒）
+	AttachmentCleanupInterval int64    // 后台清理已删除消息附件的间隔（秒）
+
+	// S3 兼容对象存储（AttachmentStore 为 s3 时生效），MinIO 需要开启 S3PathStyle
+	S3Endpoint  string
+	S3Region    string
+	S3Bucket    string
+	S3AccessKey string
+	S3SecretKey string
+	S3PathStyle bool
+
+	// 文档解析
+	DocumentMaxSize       int64 // 上传文档大小上限（字节）
+	DocumentChunkTokens   int   // 每个片段的 token 数
+	DocumentChunkOverlap  int   // 相邻片段重叠的 token 数
+	DocumentContextTokens int   // 对话中引用文档时最多加入的 token 数
+
+	// 长期记忆
+	MemoryExtractInterval int64  // 后台从会话中提取记忆的间隔（秒），0 表示不自动提取
+	MemoryIdleMinutes     int    // 会话空闲多久后提取记忆（分钟）
+	MemoryModel           string // 提取记忆使用的模型，为空时使用默认模型
+	MemoryMaxItems        int    // 每个用户最多保存的记忆条数
+	MemoryContextItems    int    // 每轮对话最多加入的记忆条数
+
 	// Rate Limit
 	RateLimitTTL   int64
 	RateLimitLimit int
+
+	// 站点外部访问地址，用于生成邮件中的链接
+	AppBaseURL string
+
+	// Mail
+	MailDriver   string // smtp 或 log
+	MailFrom     string
+	MailLogFile  string // log 驱动下可选，追加写入邮件内容
+	SMTPHost     string
+	SMTPPort     int
+	SMTPUsername string
+	SMTPPassword string
+
+	// 邮件令牌有效期（秒）
+	EmailVerifyTokenTTL   int64
+	PasswordResetTokenTTL int64
+	MagicLinkTokenTTL     int64
+
+	// 邮件相关接口限流：窗口期（秒）内同一IP对每个接口最多请求次数
+	MailRateLimitTTL   int64
+	MailRateLimitLimit int
+	// 同一用户同一类邮件的最短发送间隔（秒），防止轮换IP向同一邮箱轰炸
+	MailCooldown int64
+
+	// 登录防暴力破解：连续失败达到阈值后锁定（秒）
+	LoginLockoutThreshold   int // 同一账户
+	LoginIPLockoutThreshold int // 同一IP
+	LoginLockoutTTL         int64
+
+	// OpenID Connect 单点登录，OIDCIssuer 为空时不启用
+	OIDCIssuer        string
+	OIDCClientID      string
+	OIDCClientSecret  string
+	OIDCRedirectURL   string
+	OIDCScopes        string
+	OIDCProviderName  string // 登录按钮上显示的名称
+	OIDCAutoProvision bool   // 首次登录时是否自动创建用户
+	OIDCGroupsClaim   string
+	OIDCRoleMapping   string // 组到角色的映射，格式: group1=admin,group2=user
+	OIDCSkip2FA       bool   // 身份提供方已负责多因素认证时，单点登录不再要求本系统的两步验证
+
+	// 登录认证链，按顺序尝试，可选 local、ldap
+	AuthProviders []string
+
+	// LDAP
+	LDAPURL                string // ldap://host:389 或 ldaps://host:636
+	LDAPStartTLS           bool
+	LDAPInsecureSkipVerify bool
+	LDAPBindDN             string // 用于查找用户的服务账号，为空时匿名查找
+	LDAPBindPassword       string
+	LDAPBaseDN             string
+	LDAPUserFilter         string // {username} 会被替换为登录名
+	LDAPIDAttribute        string // 不随改名变化的唯一标识，OpenLDAP 为 entryUUID，AD 为 objectGUID
+	LDAPEmailAttribute     string
+	LDAPNameAttribute      string
+	LDAPGroupAttribute     string
+	LDAPAutoProvision      bool
+	LDAPTrustEmail         bool   // 是否信任目录中的邮箱，按邮箱关联已有的本地账户
+	LDAPRoleMapping        string // 组（CN 或完整 DN）到角色的映射，DN 中含逗号时用分号分隔: cn=admins,dc=example,dc=com=admin;staff=user
+
+	// WebAuthn 通行密钥
+	WebAuthnRPID    string   // 依赖方ID，默认为 APP_BASE_URL 的主机名
+	WebAuthnRPName  string   // 验证器中显示的站点名称
+	WebAuthnOrigins []string // 允许的来源，默认为 APP_BASE_URL
 }
 
 func Load() (*Config, error) {
@@ -57,8 +165,122 @@ func Load() (*Config, error) {
 		BaseURL:   getEnv("AI_BASE_URL", getEnv("OPENAI_BASE_URL", "https://api.openai.com/v1")),
 		Model:     getEnv("AI_MODEL", getEnv("OPENAI_MODEL", "gpt-3.5-turbo")),
 
+		VisionModels: getEnvAsList("VISION_MODELS", []string{
+			"gpt-4o*", "gpt-4.1*", "gpt-4-turbo*", "gpt-5*", "o1*", "o3*", "o4*",
+			"glm-4v*", "glm-4.1v*", "glm-4.5v*", "qwen-vl*", "qwen2.5-vl*", "claude-*", "gemini-*", "llava*",
+		}),
+
+		WorkspaceProviderHosts: getEnvAsList("WORKSPACE_PROVIDER_HOSTS", nil),
+
+		EmbeddingProvider:      getEnv("EMBEDDING_PROVIDER", ""),
+		EmbeddingBaseURL:       strings.TrimRight(getEnv("EMBEDDING_BASE_URL", ""), "/"),
+		EmbeddingAPIKey:        getEnv("EMBEDDING_API_KEY", ""),
+		EmbeddingModel:         getEnv("EMBEDDING_MODEL", "text-embedding-3-small"),
+		EmbeddingIndexInterval: getEnvAsInt64("EMBEDDING_INDEX_INTERVAL", 30),
+
+		AttachmentStore:    getEnv("ATTACHMENT_STORE", "local"),
+		AttachmentLocalDir: getEnv("ATTACHMENT_LOCAL_DIR", "./data/attachments"),
+		AttachmentMaxSize:  getEnvAsInt64("ATTACHMENT_MAX_SIZE", 20<<20), // 20MB
+		AttachmentAllowedTypes: getEnvAsList("ATTACHMENT_ALLOWED_TYPES", []string{
+			"image/png", "image/jpeg", "image/gif", "image/webp", "application/pdf",
+			"text/plain", "text/markdown", "text/csv", "application/json",
+		}),
+		AttachmentUserQuota:       getEnvAsInt64("ATTACHMENT_USER_QUOTA", 500<<20), // 500MB
+		AttachmentURLTTL:          getEnvAsInt64("ATTACHMENT_URL_TTL", 3600),
+		AttachmentCleanupInterval: getEnvAsInt64("ATTACHMENT_CLEANUP_INTERVAL", 600),
+
+		S3Endpoint:  strings.TrimRight(getEnv("S3_ENDPOINT", ""), "/"),
+		S3Region:    getEnv("S3_REGION", "us-east-1"),
+		S3Bucket:    getEnv("S3_BUCKET", ""),
+		S3AccessKey: getEnv("S3_ACCESS_KEY", ""),
+		S3SecretKey: getEnv("S3_SECRET_KEY", ""),
+		S3PathStyle: getEnvAsBool("S3_PATH_STYLE", false),
+
+		DocumentMaxSize:       getEnvAsInt64("DOCUMENT_MAX_SIZE", 20<<20), // 20MB
+		DocumentChunkTokens:   getEnvAsInt("DOCUMENT_CHUNK_TOKENS", 500),
+		DocumentChunkOverlap:  getEnvAsInt("DOCUMENT_CHUNK_OVERLAP", 50),
+		DocumentContextTokens: getEnvAsInt("DOCUMENT_CONTEXT_TOKENS", 6000),
+
+		MemoryExtractInterval: getEnvAsInt64("MEMORY_EXTRACT_INTERVAL", 300),
+		MemoryIdleMinutes:     getEnvAsInt("MEMORY_IDLE_MINUTES", 30),
+		MemoryModel:           getEnv("MEMORY_MODEL", ""),
+		MemoryMaxItems:        getEnvAsInt("MEMORY_MAX_ITEMS", 200),
+		MemoryContextItems:    getEnvAsInt("MEMORY_CONTEXT_ITEMS", 20),
+
 		RateLimitTTL:   getEnvAsInt64("RATE_LIMIT_TTL", 60),
 		RateLimitLimit: getEnvAsInt("RATE_LIMIT_LIMIT", 60),
+
+		AppBaseURL: strings.TrimRight(getEnv("APP_BASE_URL", "http://localhost:8080"), "/"),
+
+		MailDriver:   getEnv("MAIL_DRIVER", "log"),
+		MailFrom:     getEnv("MAIL_FROM", "AI Chat <no-reply@localhost>"),
+		MailLogFile:  getEnv("MAIL_LOG_FILE", ""),
+		SMTPHost:     getEnv("SMTP_HOST", ""),
+		SMTPPort:     getEnvAsInt("SMTP_PORT", 587),
+		SMTPUsername: getEnv("SMTP_USERNAME", ""),
+		SMTPPassword: getEnv("SMTP_PASSWORD", ""),
+
+		EmailVerifyTokenTTL:   getEnvAsInt64("EMAIL_VERIFY_TOKEN_TTL", 86400),  // 24小时
+		PasswordResetTokenTTL: getEnvAsInt64("PASSWORD_RESET_TOKEN_TTL", 1800), // 30分钟
+		MagicLinkTokenTTL:     getEnvAsInt64("MAGIC_LINK_TOKEN_TTL", 900),      // 15分钟
+
+		MailRateLimitTTL:   getEnvAsInt64("MAIL_RATE_LIMIT_TTL", 900),
+		MailRateLimitLimit: getEnvAsInt("MAIL_RATE_LIMIT_LIMIT", 5),
+		MailCooldown:       getEnvAsInt64("MAIL_COOLDOWN", 60),
+
+		LoginLockoutThreshold:   getEnvAsInt("LOGIN_LOCKOUT_THRESHOLD", 10),
+		LoginIPLockoutThreshold: getEnvAsInt("LOGIN_IP_LOCKOUT_THRESHOLD", 50),
+		LoginLockoutTTL:         getEnvAsInt64("LOGIN_LOCKOUT_TTL", 900), // 15分钟
+
+		OIDCIssuer:        strings.TrimRight(getEnv("OIDC_ISSUER", ""), "/"),
+		OIDCClientID:      getEnv("OIDC_CLIENT_ID", ""),
+		OIDCClientSecret:  getEnv("OIDC_CLIENT_SECRET", ""),
+		OIDCRedirectURL:   getEnv("OIDC_REDIRECT_URL", ""),
+		OIDCScopes:        getEnv("OIDC_SCOPES", "openid profile email"),
+		OIDCProviderName:  getEnv("OIDC_PROVIDER_NAME", "SSO"),
+		OIDCAutoProvision: getEnvAsBool("OIDC_AUTO_PROVISION", false),
+		OIDCGroupsClaim:   getEnv("OIDC_GROUPS_CLAIM", "groups"),
+		OIDCRoleMapping:   getEnv("OIDC_ROLE_MAPPING", ""),
+		OIDCSkip2FA:       getEnvAsBool("OIDC_SKIP_2FA", false),
+
+		AuthProviders: getEnvAsList("AUTH_PROVIDERS", []string{"local"}),
+
+		LDAPURL:                getEnv("LDAP_URL", ""),
+		LDAPStartTLS:           getEnvAsBool("LDAP_START_TLS", false),
+		LDAPInsecureSkipVerify: getEnvAsBool("LDAP_INSECURE_SKIP_VERIFY", false),
+		LDAPBindDN:             getEnv("LDAP_BIND_DN", ""),
+		LDAPBindPassword:       getEnv("LDAP_BIND_PASSWORD", ""),
+		LDAPBaseDN:             getEnv("LDAP_BASE_DN", ""),
+		LDAPUserFilter:         getEnv("LDAP_USER_FILTER", "(&(objectClass=person)(|(uid={username})(mail={username})))"),
+		LDAPIDAttribute:        getEnv("LDAP_ID_ATTRIBUTE", "entryUUID"),
+		LDAPEmailAttribute:     getEnv("LDAP_EMAIL_ATTRIBUTE", "mail"),
+		LDAPNameAttribute:      getEnv("LDAP_NAME_ATTRIBUTE", "cn"),
+		LDAPGroupAttribute:     getEnv("LDAP_GROUP_ATTRIBUTE", "memberOf"),
+		LDAPAutoProvision:      getEnvAsBool("LDAP_AUTO_PROVISION", true),
+		LDAPTrustEmail:         getEnvAsBool("LDAP_TRUST_EMAIL", false),
+		LDAPRoleMapping:        getEnv("LDAP_ROLE_MAPPING", ""),
+
+		WebAuthnRPID:    getEnv("WEBAUTHN_RP_ID", ""),
+		WebAuthnRPName:  getEnv("WEBAUTHN_RP_NAME", "AI Chat"),
+		WebAuthnOrigins: getEnvAsList("WEBAUTHN_ORIGINS", nil),
+	}
+
+	if cfg.EmbeddingBaseURL == "" {
+		cfg.EmbeddingBaseURL = strings.TrimRight(cfg.BaseURL, "/")
+	}
+	if cfg.EmbeddingAPIKey == "" {
+		cfg.EmbeddingAPIKey = cfg.OpenAIKey
+	}
+	if cfg.OIDCRedirectURL == "" {
+		cfg.OIDCRedirectURL = cfg.AppBaseURL + "/api/v1/auth/oidc/callback"
+	}
+	if len(cfg.WebAuthnOrigins) == 0 {
+		cfg.WebAuthnOrigins = []string{cfg.AppBaseURL}
+	}
+	if cfg.WebAuthnRPID == "" {
+		if u, err := url.Parse(cfg.AppBaseURL); err == nil {
+			cfg.WebAuthnRPID = u.Hostname()
+		}
 	}
 
 	return cfg, nil
@@ -87,6 +309,37 @@ func getEnvAsInt64(name string, defaultValue int64) int64 {
 	return defaultValue
 }
 
+func getEnvAsBool(name string, defaultValue bool) bool {
+	valueStr := getEnv(name, "")
+	if value, err := strconv.ParseBool(valueStr); err == nil {
+		return value
+	}
+	return defaultValue
+}
+
+func getEnvAsList(name string, defaultValue []string) []string {
+	var values []string
+	for _, v := range strings.Split(getEnv(name, ""), ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			values = append(values, v)
+		}
+	}
+	if len(values) == 0 {
+		return defaultValue
+	}
+	return values
+}
+
+// SemanticSearchEnabled 是否启用了语义检索
+func (c *Config) SemanticSearchEnabled() bool {
+	return c.EmbeddingProvider != ""
+}
+
+// OIDCEnabled 是否启用了 OpenID Connect 单点登录
+func (c *Config) OIDCEnabled() bool {
+	return c.OIDCIssuer != "" && c.OIDCClientID != ""
+}
+
 func (c *Config) DatabaseURL() string {
 	// 优先使用完整连接字符串
 	if c.DatabaseDSN != "" {
@@ -0,0 +1,48 @@
+package blobstore
+
+import (
+	"ai-chat/config"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// ErrNotFound 对象不存在
+var ErrNotFound = errors.New("对象不存在")
+
+// Store 二进制对象存储接口，key 由调用方生成，不包含用户输入
+type Store interface {
+	Put(ctx context.Context, key string, r io.Reader, size int64, contentType string) error
+	Get(ctx context.Context, key string) (io.ReadCloser, error)
+	Delete(ctx context.Context, key string) error
+}
+
+// Presigner 可以直接签发下载链接的存储，未实现时由应用代理下载
+type Presigner interface {
+	PresignGet(key, fileName string, ttl time.Duration) (string, error)
+}
+
+// New 根据配置创建对象存储
+func New(cfg *config.Config) (Store, error) {
+	switch strings.ToLower(cfg.AttachmentStore) {
+	case "", "local":
+		return NewLocalStore(cfg.AttachmentLocalDir)
+	case "s3":
+		if cfg.S3Endpoint == "" || cfg.S3Bucket == "" {
+			return nil, fmt.Errorf("ATTACHMENT_STORE=s3 时必须配置 S3_ENDPOINT 和 S3_BUCKET")
+		}
+		return NewS3Store(S3Config{
+			Endpoint:  cfg.S3Endpoint,
+			Region:    cfg.S3Region,
+			Bucket:    cfg.S3Bucket,
+			AccessKey: cfg.S3AccessKey,
+			SecretKey: cfg.S3SecretKey,
+			PathStyle: cfg.S3PathStyle,
+		})
+	default:
+		return nil, fmt.Errorf("不支持的附件存储: %s", cfg.AttachmentStore)
+	}
+}
@@ -1,3 +1,18 @@
 package common
 
 const TimeLayout = "2006-01-02 15:04:05"
+
+// 用户角色
+const (
+	RoleUser    = "user"
+	RoleAdmin   = "admin"
+	RoleAuditor = "auditor" // 只读访问管理后台和审计日志
+)
+
+// 工作区成员角色
+const (
+	WorkspaceRoleOwner  = "owner"  // 创建者，可删除工作区
+	WorkspaceRoleAdmin  = "admin"  // 管理成员和工作区设置
+	WorkspaceRoleMember = "member" // 使用和编辑共享内容
+	WorkspaceRoleViewer = "viewer" // 只读
+)
@@ -0,0 +1,137 @@
+package docparse
+
+import (
+	"math"
+	"strings"
+	"unicode"
+)
+
+// Chunk 文档片段，Offset 和 Length 为片段在所在页文本中的字符（rune）位置
+type Chunk struct {
+	Page    int
+	Offset  int
+	Length  int
+	Content string
+	Tokens  int
+}
+
+// EstimateTokens 估算文本的 token 数：中日韩字符按每字 1 个，其他字符按每 4 个 1 个
+// 不同模型的分词器差异较大，只用于预算控制
+func EstimateTokens(text string) int {
+	var tokens float64
+	for _, r := range text {
+		tokens += runeTokens(r)
+	}
+	return int(math.Ceil(tokens))
+}
+
+func runeTokens(r rune) float64 {
+	if unicode.In(r, unicode.Han, unicode.Hiragana, unicode.Katakana, unicode.Hangul) {
+		return 1
+	}
+	return 0.25
+}
+
+// Split 将文档按页切分为不超过 size 个 token 的片段，相邻片段重叠约 overlap 个 token
+// 切分点优先选在段落、换行、句末和空白处，片段不跨页
+func Split(doc *Document, size, overlap int) []Chunk {
+	if size <= 0 {
+		size = 500
+	}
+	if overlap < 0 || overlap >= size {
+		overlap = size / 10
+	}
+
+	var chunks []Chunk
+	for _, page := range doc.Pages {
+		runes := []rune(page.Text)
+		for start := 0; start < len(runes); {
+			end, tokens := start, 0.0
+			for end < len(runes) && tokens+runeTokens(runes[end]) <= float64(size) {
+				tokens += runeTokens(runes[end])
+				end++
+			}
+			if end < len(runes) {
+				end = breakPoint(runes, start, end)
+			}
+
+			// 位置对应去掉首尾空白后的内容，相邻片段可以据此去掉重叠部分
+			lo, hi := start, end
+			for lo < hi && unicode.IsSpace(runes[lo]) {
+				lo++
+			}
+			for hi > lo && unicode.IsSpace(runes[hi-1]) {
+				hi--
+			}
+			if lo < hi {
+				content := string(runes[lo:hi])
+				chunks = append(chunks, Chunk{
+					Page:    page.Number,
+					Offset:  lo,
+					Length:  hi - lo,
+					Content: content,
+					Tokens:  EstimateTokens(content),
+				})
+			}
+			if end >= len(runes) {
+				break
+			}
+
+			next := overlapStart(runes, start, end, overlap)
+			if next <= start {
+				next = end
+			}
+			start = next
+		}
+	}
+	return chunks
+}
+
+// breakPoint 在片段后 30% 的范围内寻找最合适的切分点，找不到时在 end 处硬切
+func breakPoint(runes []rune, start, end int) int {
+	floor := start + (end-start)*7/10
+	find := func(match func(i int) bool) int {
+		for i := end; i > floor; i-- {
+			if match(i) {
+				return i
+			}
+		}
+		return -1
+	}
+
+	candidates := []func(i int) bool{
+		func(i int) bool { return runes[i-1] == '\n' && i >= 2 && runes[i-2] == '\n' },
+		func(i int) bool { return runes[i-1] == '\n' },
+		func(i int) bool {
+			return strings.ContainsRune("。！？；.!?;", runes[i-1]) && (i == len(runes) || !unicode.IsLetter(runes[i]))
+		},
+		func(i int) bool { return unicode.IsSpace(runes[i-1]) || strings.ContainsRune("，、,", runes[i-1]) },
+	}
+	for _, match := range candidates {
+		if i := find(match); i > 0 {
+			return i
+		}
+	}
+	return end
+}
+
+// overlapStart 下一个片段的起点：从 end 向前回退约 overlap 个 token，并对齐到空白之后，避免切断单词
+func overlapStart(runes []rune, start, end, overlap int) int {
+	i, tokens := end, 0.0
+	for i > start && tokens+runeTokens(runes[i-1]) <= float64(overlap) {
+		tokens += runeTokens(runes[i-1])
+		i--
+	}
+	for j := i; j < end; j++ {
+		if j == i && (j == 0 || unicode.IsSpace(runes[j-1])) {
+			return j
+		}
+		if unicode.IsSpace(runes[j]) {
+			return j + 1
+		}
+		if runeTokens(runes[j]) == 1 {
+			return j // 中日韩文本没有空白，直接从字符处开始
+		}
+	}
+	return i
+}
@@ -0,0 +1,165 @@
+// Package docparse 将上传的文档解析为纯文本，并按 token 预算切分为带重叠的片段
+package docparse
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"unicode/utf8"
+)
+
+// 文档格式
+const (
+	FormatPDF      = "pdf"
+	FormatDOCX     = "docx"
+	FormatHTML     = "html"
+	FormatMarkdown = "markdown"
+	FormatCode     = "code"
+	FormatText     = "text"
+)
+
+// ErrUnsupportedFormat 不支持的文档格式
+var ErrUnsupportedFormat = errors.New("不支持的文档格式")
+
+// ErrNoText 文档中没有可提取的文本（如扫描版 PDF）
+var ErrNoText = errors.New("文档中没有可提取的文本")
+
+// Page 文档的一页，不分页的格式只有一页且 Number 为 0
+type Page struct {
+	Number int
+	Text   string
+}
+
+// Document 解析结果
+type Document struct {
+	Format string
+	Pages  []Page
+}
+
+// Paginated 文档是否有页码
+func (d *Document) Paginated() bool {
+	return len(d.Pages) > 0 && d.Pages[0].Number > 0
+}
+
+// markdownExts Markdown 文件扩展名
+var markdownExts = map[string]bool{".md": true, ".markdown": true, ".mdx": true}
+
+// textExts 按纯文本处理的非代码文件扩展名
+var textExts = map[string]bool{".txt": true, ".text": true, ".log": true, ".csv": true, ".tsv": true, ".rst": true, ".adoc": true}
+
+// codeExts 源代码和配置文件扩展名
+var codeExts = map[string]bool{
+	".go": true, ".py": true, ".js": true, ".mjs": true, ".cjs": true, ".ts": true, ".tsx": true, ".jsx": true,
+	".java": true, ".kt": true, ".scala": true, ".c": true, ".h": true, ".cc": true, ".cpp": true, ".hpp": true,
+	".cs": true, ".rb": true, ".rs": true, ".php": true, ".swift": true, ".m": true, ".lua": true, ".r": true,
+	".sh": true, ".bash": true, ".ps1": true, ".sql": true, ".vue": true, ".svelte": true, ".css": true, ".scss": true,
+	".json": true, ".yaml": true, ".yml": true, ".toml": true, ".ini": true, ".xml": true, ".proto": true,
+	".gradle": true, ".dockerfile": true, ".mk": true,
+}
+
+// DetectFormat 根据文件名和内容判断文档格式，无法识别时返回空字符串
+func DetectFormat(fileName string, data []byte) string {
+	ext := strings.ToLower(filepath.Ext(fileName))
+	switch {
+	case bytes.HasPrefix(data, []byte("%PDF-")):
+		return FormatPDF
+	case ext == ".docx":
+		return FormatDOCX
+	case ext == ".html" || ext == ".htm" || ext == ".xhtml":
+		return FormatHTML
+	case markdownExts[ext]:
+		return FormatMarkdown
+	case codeExts[ext]:
+		return FormatCode
+	case textExts[ext]:
+		return FormatText
+	}
+
+	switch strings.ToLower(filepath.Base(fileName)) {
+	case "dockerfile", "makefile", "readme", "license":
+		return FormatText
+	}
+	if ext == "" && looksLikeText(data) {
+		return FormatText
+	}
+	return ""
+}
+
+// Parse 解析文档为纯文本，fileName 用于判断格式
+func Parse(fileName string, data []byte) (*Document, error) {
+	format := DetectFormat(fileName, data)
+
+	var pages []Page
+	var err error
+	switch format {
+	case FormatPDF:
+		pages, err = parsePDF(data)
+	case FormatDOCX:
+		pages, err = parseDOCX(data)
+	case FormatHTML:
+		var text string
+		text, err = parseHTML(data)
+		pages = []Page{{Text: text}}
+	case FormatMarkdown, FormatCode, FormatText:
+		if !looksLikeText(data) {
+			return nil, fmt.Errorf("%w: 文件不是 UTF-8 文本", ErrUnsupportedFormat)
+		}
+		pages = []Page{{Text: string(bytes.TrimPrefix(data, []byte("\xef\xbb\xbf")))}}
+	default:
+		return nil, fmt.Errorf("%w: %s", ErrUnsupportedFormat, filepath.Ext(fileName))
+	}
+	if err != nil {
+		return nil, fmt.Errorf("解析 %s 失败: %w", format, err)
+	}
+
+	empty := true
+	for i := range pages {
+		pages[i].Text = normalizeText(pages[i].Text)
+		if pages[i].Text != "" {
+			empty = false
+		}
+	}
+	if empty {
+		return nil, ErrNoText
+	}
+	return &Document{Format: format, Pages: pages}, nil
+}
+
+// looksLikeText 内容是否为 UTF-8 文本
+func looksLikeText(data []byte) bool {
+	head := data
+	if len(head) > 8192 {
+		head = head[:8192]
+		// 截断处可能是不完整的多字节字符
+		for i := 0; i < utf8.UTFMax && !utf8.Valid(head); i++ {
+			head = head[:len(head)-1]
+		}
+	}
+	return utf8.Valid(head) && bytes.IndexByte(head, 0) < 0
+}
+
+// normalizeText 统一换行符，去掉行尾空白和多余的空行
+func normalizeText(text string) string {
+	text = strings.ReplaceAll(text, "\r\n", "\n")
+	text = strings.ReplaceAll(text, "\r", "\n")
+	text = strings.ToValidUTF8(text, "")
+
+	lines := strings.Split(text, "\n")
+	out := lines[:0]
+	blank := 0
+	for _, line := range lines {
+		line = strings.TrimRight(line, " \t\u00a0\u3000")
+		if line == "" {
+			blank++
+			if blank > 1 {
+				continue
+			}
+		} else {
+			blank = 0
+		}
+		out = append(out, line)
+	}
+	return strings.Trim(strings.Join(out, "\n"), "\n")
+}
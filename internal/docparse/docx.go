@@ -0,0 +1,118 @@
+package docparse
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// maxDOCXBodySize word/document.xml 解压后的大小上限，防止压缩炸弹
+const maxDOCXBodySize = 64 << 20
+
+// parseDOCX 提取 DOCX 正文文本
+// 文件本身不记录分页，优先使用 Word 上次排版时写入的 lastRenderedPageBreak 标记，没有时使用手动分页符；都没有时不分页
+func parseDOCX(data []byte) ([]Page, error) {
+	archive, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return nil, fmt.Errorf("不是有效的 DOCX 文件: %w", err)
+	}
+
+	var body []byte
+	for _, file := range archive.File {
+		if file.Name != "word/document.xml" {
+			continue
+		}
+		r, err := file.Open()
+		if err != nil {
+			return nil, err
+		}
+		body, err = io.ReadAll(io.LimitReader(r, maxDOCXBodySize+1))
+		r.Close()
+		if err != nil {
+			return nil, err
+		}
+		if len(body) > maxDOCXBodySize {
+			return nil, fmt.Errorf("文档内容过大")
+		}
+	}
+	if body == nil {
+		return nil, fmt.Errorf("DOCX 中没有找到 word/document.xml")
+	}
+
+	renderedBreaks := bytes.Contains(body, []byte("lastRenderedPageBreak"))
+	paginated := false
+	var pages []Page
+	var b strings.Builder
+	inText := false
+	newPage := func() {
+		paginated = true
+		pages = append(pages, Page{Text: b.String()})
+		b.Reset()
+	}
+
+	decoder := xml.NewDecoder(bytes.NewReader(body))
+	for {
+		tok, err := decoder.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("解析 DOCX 失败: %w", err)
+		}
+
+		switch t := tok.(type) {
+		case xml.StartElement:
+			switch t.Name.Local {
+			case "t":
+				inText = true
+			case "tab":
+				b.WriteByte('\t')
+			case "cr":
+				b.WriteByte('\n')
+			case "br":
+				if !renderedBreaks && attr(t, "type") == "page" {
+					newPage()
+				} else {
+					b.WriteByte('\n')
+				}
+			case "lastRenderedPageBreak":
+				newPage()
+			}
+		case xml.EndElement:
+			switch t.Name.Local {
+			case "t":
+				inText = false
+			case "p", "tr":
+				b.WriteByte('\n')
+			case "tc":
+				b.WriteByte('\t')
+			}
+		case xml.CharData:
+			if inText {
+				b.Write(t)
+			}
+		}
+	}
+	pages = append(pages, Page{Text: b.String()})
+
+	if !paginated {
+		return pages, nil
+	}
+	for i := range pages {
+		pages[i].Number = i + 1
+	}
+	return pages, nil
+}
+
+// attr 元素的属性值，忽略命名空间
+func attr(e xml.StartElement, name string) string {
+	for _, a := range e.Attr {
+		if a.Name.Local == name {
+			return a.Value
+		}
+	}
+	return ""
+}
@@ -0,0 +1,89 @@
+package docparse
+
+import (
+	"bytes"
+	"strings"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// htmlSkipped 不包含正文的元素
+var htmlSkipped = map[atom.Atom]bool{
+	atom.Head: true, atom.Script: true, atom.Style: true, atom.Noscript: true,
+	atom.Template: true, atom.Svg: true, atom.Iframe: true, atom.Object: true,
+}
+
+// htmlBlocks 前后需要换行的块级元素
+var htmlBlocks = map[atom.Atom]bool{
+	atom.P: true, atom.Div: true, atom.Br: true, atom.Li: true, atom.Tr: true, atom.Pre: true,
+	atom.H1: true, atom.H2: true, atom.H3: true, atom.H4: true, atom.H5: true, atom.H6: true,
+	atom.Section: true, atom.Article: true, atom.Header: true, atom.Footer: true, atom.Blockquote: true,
+	atom.Table: true, atom.Ul: true, atom.Ol: true, atom.Dl: true, atom.Dt: true, atom.Dd: true, atom.Hr: true,
+}
+
+// parseHTML 提取 HTML 的可见文本，块级元素之间换行
+func parseHTML(data []byte) (string, error) {
+	tokenizer := html.NewTokenizer(bytes.NewReader(data))
+	var b strings.Builder
+	skip := 0
+	pre := 0
+
+	for {
+		switch tokenizer.Next() {
+		case html.ErrorToken:
+			return b.String(), nil
+		case html.StartTagToken, html.SelfClosingTagToken, html.EndTagToken:
+			tok := tokenizer.Token()
+			start := tok.Type != html.EndTagToken
+			if htmlSkipped[tok.DataAtom] && tok.Type != html.SelfClosingTagToken {
+				if start {
+					skip++
+				} else if skip > 0 {
+					skip--
+				}
+				continue
+			}
+			if tok.DataAtom == atom.Pre && tok.Type != html.SelfClosingTagToken {
+				if start {
+					pre++
+				} else if pre > 0 {
+					pre--
+				}
+			}
+			if skip == 0 && htmlBlocks[tok.DataAtom] {
+				b.WriteByte('\n')
+			} else if skip == 0 && (tok.DataAtom == atom.Td || tok.DataAtom == atom.Th) && !start {
+				b.WriteByte('\t')
+			}
+		case html.TextToken:
+			if skip > 0 {
+				continue
+			}
+			text := string(tokenizer.Text())
+			if pre == 0 {
+				text = collapseSpace(text)
+			}
+			b.WriteString(text)
+		}
+	}
+}
+
+// collapseSpace 将连续空白合并为一个空格
+func collapseSpace(s string) string {
+	fields := strings.Fields(s)
+	if len(fields) == 0 {
+		if s != "" {
+			return " "
+		}
+		return ""
+	}
+	out := strings.Join(fields, " ")
+	if strings.TrimLeft(s[:1], " \t\r\n") == "" {
+		out = " " + out
+	}
+	if strings.TrimRight(s[len(s)-1:], " \t\r\n") == "" {
+		out += " "
+	}
+	return out
+}
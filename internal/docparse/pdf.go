@@ -0,0 +1,940 @@
+package docparse
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/ascii85"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode/utf16"
+)
+
+// ErrEncryptedPDF 加密的 PDF 无法提取文本
+var ErrEncryptedPDF = errors.New("不支持加密的 PDF")
+
+// ErrPDFTooLarge PDF 中的流解压后超过大小上限
+var ErrPDFTooLarge = errors.New("PDF 解压后的内容过大")
+
+// errMalformedPDF 解析过程中出现意外错误（越界等）的 PDF
+var errMalformedPDF = errors.New("无法解析的 PDF 文件")
+
+// maxPDFDecodedSize 一个 PDF 中全部流解压后的总大小上限，防止压缩炸弹
+const maxPDFDecodedSize = 256 << 20
+
+// 只实现文本提取需要的 PDF 子集：
+// 按 "N G obj" 扫描全部对象（包括对象流中的对象），不依赖交叉引用表，因此也能处理增量更新和轻微损坏的文件；
+// 按页面树顺序解释内容流中的文本操作符，字体有 ToUnicode 映射时按映射解码，否则按单字节编码处理。
+
+type (
+	pdfName    string
+	pdfKeyword string
+	pdfRef     struct{ num, gen int }
+	pdfDict    map[string]interface{}
+	pdfStream  struct {
+		dict pdfDict
+		data []byte
+	}
+)
+
+// pdfObjectHeader 对象开头的 "N G obj"
+var pdfObjectHeader = regexp.MustCompile(`(\d+)\s+(\d+)\s+obj\b`)
+
+// pdfDocument 已解析的 PDF 对象
+type pdfDocument struct {
+	objects     map[int]interface{}
+	decoded     map[*pdfStream][]byte // 已解码的流，字体等多页共用的对象只解码一次
+	decodedSize int                   // 已解码的总字节数
+	tooLarge    bool                  // 解码总量超过 maxPDFDecodedSize
+}
+
+// parsePDF 提取 PDF 每页的文本
+// 文件内容不可信，解析中的越界等意外错误转换为解析失败，不影响服务
+func parsePDF(data []byte) (pages []Page, err error) {
+	if !bytes.HasPrefix(bytes.TrimLeft(data, " \t\r\n"), []byte("%PDF-")) {
+		return nil, fmt.Errorf("不是有效的 PDF 文件")
+	}
+	defer func() {
+		if r := recover(); r != nil {
+			pages, err = nil, fmt.Errorf("%w: %v", errMalformedPDF, r)
+		}
+	}()
+
+	doc := &pdfDocument{objects: make(map[int]interface{}), decoded: make(map[*pdfStream][]byte)}
+	for _, match := range pdfObjectHeader.FindAllSubmatchIndex(data, -1) {
+		num, _ := strconv.Atoi(string(data[match[2]:match[3]]))
+		obj, ok := doc.parseIndirect(data, match[1])
+		if ok {
+			doc.objects[num] = obj
+		}
+	}
+	doc.expandObjectStreams()
+
+	catalog := doc.catalog()
+	if catalog == nil {
+		return nil, fmt.Errorf("PDF 中没有找到文档目录")
+	}
+	if doc.hasEncryption(data) {
+		return nil, ErrEncryptedPDF
+	}
+
+	visited := make(map[int]bool)
+	var walk func(node pdfDict, resources pdfDict)
+	walk = func(node pdfDict, resources pdfDict) {
+		if r, ok := doc.resolve(node["Resources"]).(pdfDict); ok {
+			resources = r
+		}
+		if kids, ok := doc.resolve(node["Kids"]).([]interface{}); ok {
+			for _, kid := range kids {
+				if ref, ok := kid.(pdfRef); ok {
+					if visited[ref.num] {
+						continue
+					}
+					visited[ref.num] = true
+				}
+				if child, ok := doc.resolve(kid).(pdfDict); ok {
+					walk(child, resources)
+				}
+			}
+			return
+		}
+		if node["Type"] == pdfName("Page") || node["Contents"] != nil {
+			text := doc.pageText(node, resources)
+			pages = append(pages, Page{Number: len(pages) + 1, Text: text})
+		}
+	}
+
+	root, ok := doc.resolve(catalog["Pages"]).(pdfDict)
+	if !ok {
+		return nil, fmt.Errorf("PDF 中没有找到页面")
+	}
+	walk(root, nil)
+	if doc.tooLarge {
+		return nil, ErrPDFTooLarge
+	}
+	return pages, nil
+}
+
+// parseIndirect 解析 "obj" 之后的对象，字典之后紧跟 stream 时读取流数据
+func (d *pdfDocument) parseIndirect(data []byte, pos int) (interface{}, bool) {
+	lex := &pdfLexer{data: data, pos: pos}
+	obj, err := lex.object()
+	if err != nil {
+		return nil, false
+	}
+
+	dict, isDict := obj.(pdfDict)
+	if !isDict {
+		return obj, true
+	}
+
+	save := lex.pos
+	if tok, err := lex.token(); err != nil || tok != pdfKeyword("stream") {
+		lex.pos = save
+		return dict, true
+	}
+
+	start := lex.pos
+	if start < len(data) && data[start] == '\r' {
+		start++
+	}
+	if start < len(data) && data[start] == '\n' {
+		start++
+	}
+
+	end := -1
+	if length, ok := dict["Length"].(float64); ok && length >= 0 && length <= float64(len(data)-start) {
+		candidate := start + int(length)
+		if bytes.HasPrefix(bytes.TrimLeft(data[candidate:min(candidate+32, len(data))], " \r\n"), []byte("endstream")) {
+			end = candidate
+		}
+	}
+	if end < 0 {
+		i := bytes.Index(data[start:], []byte("endstream"))
+		if i < 0 {
+			return nil, false
+		}
+		end = start + i
+		for end > start && (data[end-1] == '\n' || data[end-1] == '\r') {
+			end--
+		}
+	}
+	return &pdfStream{dict: dict, data: data[start:end]}, true
+}
+
+// expandObjectStreams 解析对象流（PDF 1.5 压缩对象）中的对象，文件中直接定义的对象优先
+func (d *pdfDocument) expandObjectStreams() {
+	for _, obj := range d.objects {
+		stream, ok := obj.(*pdfStream)
+		if !ok || stream.dict["Type"] != pdfName("ObjStm") {
+			continue
+		}
+		data, err := d.decodeStream(stream)
+		if err != nil {
+			continue
+		}
+		n, _ := stream.dict["N"].(float64)
+		first, _ := stream.dict["First"].(float64)
+		if first < 0 || first > float64(len(data)) {
+			continue
+		}
+
+		header := &pdfLexer{data: data[:int(first)]}
+		for i := 0; i < int(n); i++ {
+			numTok, err1 := header.object()
+			offTok, err2 := header.object()
+			num, ok1 := numTok.(float64)
+			off, ok2 := offTok.(float64)
+			if err1 != nil || err2 != nil || !ok1 || !ok2 {
+				break
+			}
+			if off < 0 || off >= float64(len(data))-first {
+				continue
+			}
+			if _, exists := d.objects[int(num)]; exists {
+				continue
+			}
+			lex := &pdfLexer{data: data, pos: int(first) + int(off)}
+			if value, err := lex.object(); err == nil {
+				d.objects[int(num)] = value
+			}
+		}
+	}
+}
+
+// catalog 文档目录
+func (d *pdfDocument) catalog() pdfDict {
+	for _, obj := range d.objects {
+		if dict, ok := d.dictOf(obj); ok && dict["Type"] == pdfName("Catalog") {
+			return dict
+		}
+	}
+	return nil
+}
+
+// hasEncryption 交叉引用流或 trailer 中是否声明了加密
+func (d *pdfDocument) hasEncryption(data []byte) bool {
+	for _, obj := range d.objects {
+		if stream, ok := obj.(*pdfStream); ok && stream.dict["Type"] == pdfName("XRef") && stream.dict["Encrypt"] != nil {
+			return true
+		}
+	}
+	i := bytes.LastIndex(data, []byte("trailer"))
+	return i >= 0 && bytes.Contains(data[i:], []byte("/Encrypt"))
+}
+
+// resolve 解析间接引用
+func (d *pdfDocument) resolve(obj interface{}) interface{} {
+	for i := 0; i < 32; i++ {
+		ref, ok := obj.(pdfRef)
+		if !ok {
+			return obj
+		}
+		obj = d.objects[ref.num]
+	}
+	return nil
+}
+
+// dictOf 对象的字典，流对象返回流字典
+func (d *pdfDocument) dictOf(obj interface{}) (pdfDict, bool) {
+	switch v := d.resolve(obj).(type) {
+	case pdfDict:
+		return v, true
+	case *pdfStream:
+		return v.dict, true
+	}
+	return nil, false
+}
+
+// decodeStream 按 Filter 解码流数据，只支持文本相关的常见过滤器
+// 全部流解码后的总大小不超过 maxPDFDecodedSize
+func (d *pdfDocument) decodeStream(stream *pdfStream) ([]byte, error) {
+	if data, ok := d.decoded[stream]; ok {
+		return data, nil
+	}
+	data, err := d.decodeFilters(stream)
+	if err != nil {
+		if errors.Is(err, ErrPDFTooLarge) {
+			d.tooLarge = true
+		}
+		return nil, err
+	}
+	d.decoded[stream] = data
+	d.decodedSize += len(data)
+	return data, nil
+}
+
+// decodeFilters 依次应用流的过滤器和预测器
+func (d *pdfDocument) decodeFilters(stream *pdfStream) ([]byte, error) {
+	var filters []interface{}
+	switch f := d.resolve(stream.dict["Filter"]).(type) {
+	case pdfName:
+		filters = []interface{}{f}
+	case []interface{}:
+		filters = f
+	}
+
+	data := stream.data
+	for _, filter := range filters {
+		var err error
+		switch d.resolve(filter) {
+		case pdfName("FlateDecode"), pdfName("Fl"):
+			data, err = inflate(data, maxPDFDecodedSize-d.decodedSize)
+		case pdfName("ASCIIHexDecode"), pdfName("AHx"):
+			data, err = hex.DecodeString(strings.Map(func(r rune) rune {
+				if r == '>' || r == ' ' || r == '\n' || r == '\r' || r == '\t' {
+					return -1
+				}
+				return r
+			}, string(data)))
+		case pdfName("ASCII85Decode"), pdfName("A85"):
+			data = bytes.TrimSuffix(bytes.TrimSpace(data), []byte("~>"))
+			out := make([]byte, 4*len(data))
+			var n int
+			n, _, err = ascii85.Decode(out, data, true)
+			data = out[:n]
+		default:
+			return nil, fmt.Errorf("不支持的流过滤器: %v", filter)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if params, ok := d.resolve(stream.dict["DecodeParms"]).(pdfDict); ok {
+		if predictor, _ := params["Predictor"].(float64); predictor >= 10 {
+			columns := 1.0
+			if c, ok := params["Columns"].(float64); ok {
+				columns = c
+			}
+			return unpredictPNG(data, int(columns))
+		}
+	}
+	return data, nil
+}
+
+// inflate 解压 zlib 数据，数据截断时返回已解压的部分，解压后超过 limit 字节时返回 ErrPDFTooLarge
+func inflate(data []byte, limit int) ([]byte, error) {
+	r, err := zlib.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	out, err := io.ReadAll(io.LimitReader(r, int64(max(limit, 0))+1))
+	if len(out) > limit {
+		return nil, ErrPDFTooLarge
+	}
+	if err != nil && len(out) == 0 {
+		return nil, err
+	}
+	return out, nil
+}
+
+// unpredictPNG 还原 PNG 预测器编码的数据（常见于交叉引用流）
+func unpredictPNG(data []byte, columns int) ([]byte, error) {
+	rowSize := columns + 1
+	if columns <= 0 || columns >= len(data) || len(data)%rowSize != 0 {
+		return nil, fmt.Errorf("无效的预测器数据")
+	}
+	out := make([]byte, 0, len(data)/rowSize*columns)
+	prev := make([]byte, columns)
+	for i := 0; i < len(data); i += rowSize {
+		filter, row := data[i], append([]byte(nil), data[i+1:i+rowSize]...)
+		for j := range row {
+			var left, upLeft byte
+			if j > 0 {
+				left, upLeft = row[j-1], prev[j-1]
+			}
+			switch filter {
+			case 1:
+				row[j] += left
+			case 2:
+				row[j] += prev[j]
+			case 3:
+				row[j] += byte((int(left) + int(prev[j])) / 2)
+			case 4:
+				row[j] += paeth(left, prev[j], upLeft)
+			}
+		}
+		out = append(out, row...)
+		prev = row
+	}
+	return out, nil
+}
+
+// paeth PNG Paeth 预测
+func paeth(a, b, c byte) byte {
+	p := int(a) + int(b) - int(c)
+	pa, pb, pc := abs(p-int(a)), abs(p-int(b)), abs(p-int(c))
+	switch {
+	case pa <= pb && pa <= pc:
+		return a
+	case pb <= pc:
+		return b
+	}
+	return c
+}
+
+func abs(x int) int {
+	if x < 0 {
+		return -x
+	}
+	return x
+}
+
+// pageText 解释页面内容流中的文本操作符
+func (d *pdfDocument) pageText(page pdfDict, resources pdfDict) string {
+	var content []byte
+	switch c := d.resolve(page["Contents"]).(type) {
+	case *pdfStream:
+		content, _ = d.decodeStream(c)
+	case []interface{}:
+		for _, part := range c {
+			if stream, ok := d.resolve(part).(*pdfStream); ok {
+				if data, err := d.decodeStream(stream); err == nil {
+					content = append(content, data...)
+					content = append(content, '\n')
+				}
+			}
+		}
+	}
+
+	fonts := make(map[string]*pdfFont)
+	if fontDict, ok := d.resolve(resources["Font"]).(pdfDict); ok {
+		for name, ref := range fontDict {
+			if dict, ok := d.resolve(ref).(pdfDict); ok {
+				fonts[name] = d.loadFont(dict)
+			}
+		}
+	}
+
+	return interpretContent(content, fonts)
+}
+
+// pdfFont 字体的文本解码方式
+type pdfFont struct {
+	codeBytes int               // 每个字符编码的字节数
+	toUnicode map[string]string // 编码到文本，为空时按单字节编码处理
+}
+
+// loadFont 读取字体的 ToUnicode 映射
+func (d *pdfDocument) loadFont(dict pdfDict) *pdfFont {
+	font := &pdfFont{codeBytes: 1}
+	if dict["Subtype"] == pdfName("Type0") {
+		font.codeBytes = 2
+	}
+	if stream, ok := d.resolve(dict["ToUnicode"]).(*pdfStream); ok {
+		if data, err := d.decodeStream(stream); err == nil {
+			font.toUnicode, font.codeBytes = parseCMap(data, font.codeBytes)
+		}
+	}
+	return font
+}
+
+// decode 将字符串中的编码转换为文本
+func (f *pdfFont) decode(s []byte) string {
+	if f == nil || f.toUnicode == nil {
+		if f != nil && f.codeBytes == 2 {
+			return "" // 没有映射的复合字体无法得到文本
+		}
+		return decodeSingleByte(s)
+	}
+
+	var b strings.Builder
+	for i := 0; i < len(s); {
+		n := min(f.codeBytes, len(s)-i)
+		if text, ok := f.toUnicode[string(s[i:i+n])]; ok {
+			b.WriteString(text)
+		} else if n > 1 {
+			// 变长编码空间中可能存在单字节编码
+			if text, ok := f.toUnicode[string(s[i:i+1])]; ok {
+				b.WriteString(text)
+				n = 1
+			}
+		}
+		i += n
+	}
+	return b.String()
+}
+
+// decodeSingleByte 按 Latin-1 解码单字节字符串，UTF-16 BOM 开头时按 UTF-16 解码
+func decodeSingleByte(s []byte) string {
+	if len(s) >= 2 && s[0] == 0xFE && s[1] == 0xFF {
+		return decodeUTF16BE(s[2:])
+	}
+	runes := make([]rune, 0, len(s))
+	for _, c := range s {
+		if c >= 0x20 || c == '\t' || c == '\n' {
+			runes = append(runes, rune(c))
+		}
+	}
+	return string(runes)
+}
+
+// decodeUTF16BE 解码 UTF-16 大端序字节
+func decodeUTF16BE(s []byte) string {
+	units := make([]uint16, 0, len(s)/2)
+	for i := 0; i+1 < len(s); i += 2 {
+		units = append(units, uint16(s[i])<<8|uint16(s[i+1]))
+	}
+	return string(utf16.Decode(units))
+}
+
+// parseCMap 解析 ToUnicode CMap 的 bfchar 和 bfrange，返回映射和编码字节数
+func parseCMap(data []byte, codeBytes int) (map[string]string, int) {
+	mapping := make(map[string]string)
+	lex := &pdfLexer{data: data}
+	var operands []interface{}
+
+	for {
+		tok, err := lex.object()
+		if err != nil {
+			break
+		}
+		keyword, isKeyword := tok.(pdfKeyword)
+		if !isKeyword {
+			operands = append(operands, tok)
+			continue
+		}
+
+		switch keyword {
+		case "endcodespacerange":
+			if len(operands) >= 1 {
+				if low, ok := operands[0].([]byte); ok && len(low) > 0 {
+					codeBytes = len(low)
+				}
+			}
+		case "endbfchar":
+			for i := 0; i+1 < len(operands); i += 2 {
+				src, ok1 := operands[i].([]byte)
+				dst, ok2 := operands[i+1].([]byte)
+				if ok1 && ok2 {
+					mapping[string(src)] = decodeUTF16BE(dst)
+				}
+			}
+		case "endbfrange":
+			for i := 0; i+2 < len(operands); i += 3 {
+				low, ok1 := operands[i].([]byte)
+				high, ok2 := operands[i+1].([]byte)
+				if !ok1 || !ok2 || len(low) != len(high) {
+					continue
+				}
+				lo, hi := bytesToInt(low), bytesToInt(high)
+				if hi < lo || hi-lo > 0xFFFF {
+					continue
+				}
+				switch dst := operands[i+2].(type) {
+				case []byte:
+					base := []rune(decodeUTF16BE(dst))
+					if len(base) == 0 {
+						continue
+					}
+					for code := lo; code <= hi; code++ {
+						text := append([]rune(nil), base...)
+						text[len(text)-1] += rune(code - lo)
+						mapping[string(intToBytes(code, len(low)))] = string(text)
+					}
+				case []interface{}:
+					for j, item := range dst {
+						if s, ok := item.([]byte); ok && lo+j <= hi {
+							mapping[string(intToBytes(lo+j, len(low)))] = decodeUTF16BE(s)
+						}
+					}
+				}
+			}
+		}
+		operands = operands[:0]
+	}
+	return mapping, codeBytes
+}
+
+func bytesToInt(b []byte) int {
+	n := 0
+	for _, c := range b {
+		n = n<<8 | int(c)
+	}
+	return n
+}
+
+func intToBytes(n, size int) []byte {
+	b := make([]byte, size)
+	for i := size - 1; i >= 0; i-- {
+		b[i] = byte(n)
+		n >>= 8
+	}
+	return b
+}
+
+// interpretContent 从内容流中提取文本，根据定位操作推断换行和空格
+func interpretContent(content []byte, fonts map[string]*pdfFont) string {
+	var b strings.Builder
+	var font *pdfFont
+	var operands []interface{}
+	lastY, haveY := 0.0, false
+
+	newline := func() {
+		if b.Len() > 0 && !strings.HasSuffix(b.String(), "\n") {
+			b.WriteByte('\n')
+		}
+	}
+	space := func() {
+		if s := b.String(); len(s) > 0 && !strings.HasSuffix(s, " ") && !strings.HasSuffix(s, "\n") {
+			b.WriteByte(' ')
+		}
+	}
+	number := func(i int) float64 {
+		if i < 0 || i >= len(operands) {
+			return 0
+		}
+		v, _ := operands[i].(float64)
+		return v
+	}
+
+	lex := &pdfLexer{data: content}
+	for {
+		tok, err := lex.object()
+		if err != nil {
+			break
+		}
+		op, isOp := tok.(pdfKeyword)
+		if !isOp {
+			operands = append(operands, tok)
+			continue
+		}
+
+		switch op {
+		case "BI":
+			lex.skipInlineImage()
+		case "Tf":
+			if len(operands) >= 2 {
+				if name, ok := operands[0].(pdfName); ok {
+					font = fonts[string(name)]
+				}
+			}
+		case "Tj", "'", "\"":
+			if op != "Tj" {
+				newline()
+			}
+			if len(operands) > 0 {
+				if s, ok := operands[len(operands)-1].([]byte); ok {
+					b.WriteString(font.decode(s))
+				}
+			}
+		case "TJ":
+			if len(operands) > 0 {
+				if items, ok := operands[len(operands)-1].([]interface{}); ok {
+					for _, item := range items {
+						switch v := item.(type) {
+						case []byte:
+							b.WriteString(font.decode(v))
+						case float64:
+							// 较大的负偏移通常表示单词间距
+							if v < -200 {
+								space()
+							}
+						}
+					}
+				}
+			}
+		case "Td", "TD":
+			if ty := number(1); ty != 0 {
+				newline()
+			} else if tx := number(0); tx > 0 {
+				space()
+			}
+		case "T*":
+			newline()
+		case "Tm":
+			y := number(5)
+			if haveY && y != lastY {
+				newline()
+			} else if haveY {
+				space()
+			}
+			lastY, haveY = y, true
+		case "ET":
+			space()
+		}
+		operands = operands[:0]
+	}
+	return b.String()
+}
+
+// pdfLexer PDF 词法和对象解析
+type pdfLexer struct {
+	data  []byte
+	pos   int
+	depth int // 当前数组和字典的嵌套层数
+}
+
+// maxPDFNesting 数组和字典的最大嵌套层数，防止构造的深层嵌套耗尽栈空间
+const maxPDFNesting = 256
+
+// errPDFNesting 嵌套层数过深
+var errPDFNesting = errors.New("pdf: nesting too deep")
+
+// errPDFEnd 数据结束
+var errPDFEnd = errors.New("pdf: unexpected end")
+
+func isPDFWhitespace(c byte) bool {
+	return c == ' ' || c == '\n' || c == '\r' || c == '\t' || c == '\f' || c == 0
+}
+
+func isPDFDelimiter(c byte) bool {
+	return strings.IndexByte("()<>[]{}/%", c) >= 0
+}
+
+// skipSpace 跳过空白和注释
+func (l *pdfLexer) skipSpace() {
+	for l.pos < len(l.data) {
+		c := l.data[l.pos]
+		if isPDFWhitespace(c) {
+			l.pos++
+		} else if c == '%' {
+			for l.pos < len(l.data) && l.data[l.pos] != '\n' && l.data[l.pos] != '\r' {
+				l.pos++
+			}
+		} else {
+			return
+		}
+	}
+}
+
+// skipInlineImage 跳过内联图片数据，直到空白之后的 EI
+func (l *pdfLexer) skipInlineImage() {
+	i := bytes.Index(l.data[l.pos:], []byte("ID"))
+	if i < 0 {
+		l.pos = len(l.data)
+		return
+	}
+	for j := l.pos + i + 2; j+2 <= len(l.data); j++ {
+		if l.data[j] == 'E' && l.data[j+1] == 'I' && j > 0 && isPDFWhitespace(l.data[j-1]) &&
+			(j+2 == len(l.data) || isPDFWhitespace(l.data[j+2])) {
+			l.pos = j + 2
+			return
+		}
+	}
+	l.pos = len(l.data)
+}
+
+// token 读取一个基本单元，数组和字典的边界作为关键字返回
+func (l *pdfLexer) token() (interface{}, error) {
+	l.skipSpace()
+	if l.pos >= len(l.data) {
+		return nil, errPDFEnd
+	}
+
+	c := l.data[l.pos]
+	switch {
+	case c == '(':
+		return l.literalString(), nil
+	case c == '<':
+		if l.pos+1 < len(l.data) && l.data[l.pos+1] == '<' {
+			l.pos += 2
+			return pdfKeyword("<<"), nil
+		}
+		return l.hexString(), nil
+	case c == '>':
+		if l.pos+1 < len(l.data) && l.data[l.pos+1] == '>' {
+			l.pos += 2
+			return pdfKeyword(">>"), nil
+		}
+		l.pos++
+		return l.token()
+	case c == '[' || c == ']' || c == '{' || c == '}':
+		l.pos++
+		return pdfKeyword(string(c)), nil
+	case c == '/':
+		l.pos++
+		return pdfName(l.regular(true)), nil
+	}
+
+	word := l.regular(false)
+	if word == "" {
+		l.pos++
+		return l.token()
+	}
+	if n, err := strconv.ParseFloat(word, 64); err == nil && (word[0] == '-' || word[0] == '+' || word[0] == '.' || (word[0] >= '0' && word[0] <= '9')) {
+		return n, nil
+	}
+	switch word {
+	case "true":
+		return true, nil
+	case "false":
+		return false, nil
+	case "null":
+		return nil, nil
+	}
+	return pdfKeyword(word), nil
+}
+
+// regular 读取连续的普通字符，名称中的 #xx 转义会被还原
+func (l *pdfLexer) regular(name bool) string {
+	start := l.pos
+	for l.pos < len(l.data) && !isPDFWhitespace(l.data[l.pos]) && !isPDFDelimiter(l.data[l.pos]) {
+		l.pos++
+	}
+	word := string(l.data[start:l.pos])
+	if name && strings.Contains(word, "#") {
+		var b strings.Builder
+		for i := 0; i < len(word); i++ {
+			if word[i] == '#' && i+2 < len(word) {
+				if v, err := strconv.ParseUint(word[i+1:i+3], 16, 8); err == nil {
+					b.WriteByte(byte(v))
+					i += 2
+					continue
+				}
+			}
+			b.WriteByte(word[i])
+		}
+		word = b.String()
+	}
+	return word
+}
+
+// literalString 读取 (...) 字符串，处理转义和嵌套括号
+func (l *pdfLexer) literalString() []byte {
+	l.pos++ // (
+	var out []byte
+	depth := 1
+	for l.pos < len(l.data) {
+		c := l.data[l.pos]
+		l.pos++
+		switch c {
+		case '(':
+			depth++
+		case ')':
+			depth--
+			if depth == 0 {
+				return out
+			}
+		case '\\':
+			if l.pos >= len(l.data) {
+				return out
+			}
+			e := l.data[l.pos]
+			l.pos++
+			switch e {
+			case 'n':
+				c = '\n'
+			case 'r':
+				c = '\r'
+			case 't':
+				c = '\t'
+			case 'b':
+				c = '\b'
+			case 'f':
+				c = '\f'
+			case '\r':
+				if l.pos < len(l.data) && l.data[l.pos] == '\n' {
+					l.pos++
+				}
+				continue
+			case '\n':
+				continue
+			default:
+				if e >= '0' && e <= '7' {
+					v := int(e - '0')
+					for k := 0; k < 2 && l.pos < len(l.data) && l.data[l.pos] >= '0' && l.data[l.pos] <= '7'; k++ {
+						v = v*8 + int(l.data[l.pos]-'0')
+						l.pos++
+					}
+					c = byte(v)
+				} else {
+					c = e
+				}
+			}
+		}
+		out = append(out, c)
+	}
+	return out
+}
+
+// hexString 读取 <...> 十六进制字符串
+func (l *pdfLexer) hexString() []byte {
+	l.pos++ // <
+	var digits []byte
+	for l.pos < len(l.data) && l.data[l.pos] != '>' {
+		if c := l.data[l.pos]; !isPDFWhitespace(c) {
+			digits = append(digits, c)
+		}
+		l.pos++
+	}
+	l.pos++ // >
+	if len(digits)%2 == 1 {
+		digits = append(digits, '0')
+	}
+	out, _ := hex.DecodeString(string(digits))
+	return out
+}
+
+// object 读取一个完整对象，"N G R" 解析为间接引用
+func (l *pdfLexer) object() (interface{}, error) {
+	tok, err := l.token()
+	if err != nil {
+		return nil, err
+	}
+
+	if tok == pdfKeyword("[") || tok == pdfKeyword("<<") {
+		if l.depth >= maxPDFNesting {
+			return nil, errPDFNesting
+		}
+		l.depth++
+		defer func() { l.depth-- }()
+	}
+
+	switch tok {
+	case pdfKeyword("["):
+		var items []interface{}
+		for {
+			l.skipSpace()
+			if l.pos < len(l.data) && l.data[l.pos] == ']' {
+				l.pos++
+				return items, nil
+			}
+			item, err := l.object()
+			if err != nil {
+				return items, err
+			}
+			items = append(items, item)
+		}
+	case pdfKeyword("<<"):
+		dict := make(pdfDict)
+		for {
+			key, err := l.token()
+			if err != nil {
+				return dict, err
+			}
+			if key == pdfKeyword(">>") {
+				return dict, nil
+			}
+			name, ok := key.(pdfName)
+			if !ok {
+				continue
+			}
+			value, err := l.object()
+			if err != nil {
+				return dict, err
+			}
+			dict[string(name)] = value
+		}
+	}
+
+	// 数字后可能是 "G R" 形式的引用
+	if num, ok := tok.(float64); ok {
+		save := l.pos
+		if gen, err := l.token(); err == nil {
+			if g, ok := gen.(float64); ok {
+				if r, err := l.token(); err == nil && r == pdfKeyword("R") {
+					return pdfRef{num: int(num), gen: int(g)}, nil
+				}
+			}
+		}
+		l.pos = save
+	}
+	return tok, nil
+}
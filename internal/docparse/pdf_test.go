@@ -0,0 +1,133 @@
+package docparse
+
+import (
+	"bytes"
+	"compress/zlib"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// buildPDF 按顺序拼接对象生成 PDF，第 i 个对象编号为 i+1
+func buildPDF(objects ...string) []byte {
+	var b strings.Builder
+	b.WriteString("%PDF-1.7\n")
+	for i, obj := range objects {
+		fmt.Fprintf(&b, "%d 0 obj\n%s\nendobj\n", i+1, obj)
+	}
+	b.WriteString("trailer\n<< /Root 1 0 R >>\n%%EOF\n")
+	return []byte(b.String())
+}
+
+// stream 生成带 Length 的流对象
+func stream(dict, data string) string {
+	return fmt.Sprintf("<< %s /Length %d >>\nstream\n%s\nendstream", dict, len(data), data)
+}
+
+// deflate 压缩数据
+func deflate(t *testing.T, data []byte) []byte {
+	t.Helper()
+	var b bytes.Buffer
+	w := zlib.NewWriter(&b)
+	if _, err := w.Write(data); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return b.Bytes()
+}
+
+func TestParsePDFText(t *testing.T) {
+	data := buildPDF(
+		"<< /Type /Catalog /Pages 2 0 R >>",
+		"<< /Type /Pages /Kids [3 0 R] /Count 1 >>",
+		"<< /Type /Page /Parent 2 0 R /Contents 4 0 R >>",
+		stream("", "BT /F1 12 Tf (Hello PDF) Tj ET"),
+	)
+
+	pages, err := parsePDF(data)
+	if err != nil {
+		t.Fatalf("parsePDF: %v", err)
+	}
+	if len(pages) != 1 || !strings.Contains(pages[0].Text, "Hello PDF") {
+		t.Fatalf("pages = %+v", pages)
+	}
+}
+
+func TestParsePDFMalformed(t *testing.T) {
+	catalog := "<< /Type /Catalog /Pages 2 0 R >>"
+	pages := "<< /Type /Pages /Kids [3 0 R] /Count 1 >>"
+
+	tests := []struct {
+		name string
+		data []byte
+	}{
+		{"negative First", buildPDF(stream("/Type /ObjStm /N 1 /First -5", "1 0 << /Type /Catalog >>"))},
+		{"First beyond data", buildPDF(stream("/Type /ObjStm /N 1 /First 9999", "1 0"))},
+		{"negative object offset", buildPDF(stream("/Type /ObjStm /N 1 /First 5", "1 -50 << /Type /Catalog >>"))},
+		{"object offset beyond data", buildPDF(stream("/Type /ObjStm /N 1 /First 5", "1 999 << /Type /Catalog >>"))},
+		{"negative Length", buildPDF(catalog, pages,
+			"<< /Type /Page /Contents 4 0 R >>",
+			"<< /Length -100 >>\nstream\nBT (x) Tj ET\nendstream")},
+		{"Length beyond data", buildPDF(catalog, pages,
+			"<< /Type /Page /Contents 4 0 R >>",
+			"<< /Length 99999999 >>\nstream\nBT (x) Tj ET\nendstream")},
+		{"huge predictor columns", buildPDF(catalog, pages,
+			"<< /Type /Page /Contents 4 0 R >>",
+			stream("/DecodeParms << /Predictor 12 /Columns 1e15 >>", "BT (x) Tj ET"))},
+		{"deep nesting", buildPDF(strings.Repeat("[", 100000))},
+		{"deep dictionary nesting", buildPDF(strings.Repeat("<< /A ", 100000))},
+		{"self referencing pages", buildPDF(catalog, "<< /Type /Pages /Kids [2 0 R] >>")},
+		{"truncated stream", []byte("%PDF-1.7\n1 0 obj\n<< /Length 10 >>\nstream\nab")},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// 能否提取到文本取决于损坏程度，但不应依赖 recover 兜底
+			if _, err := parsePDF(tt.data); errors.Is(err, errMalformedPDF) {
+				t.Fatalf("parsePDF panicked: %v", err)
+			}
+		})
+	}
+}
+
+func TestParsePDFDecompressionLimit(t *testing.T) {
+	bomb := deflate(t, make([]byte, 1<<20))
+
+	if _, err := inflate(bomb, 1<<10); !errors.Is(err, ErrPDFTooLarge) {
+		t.Fatalf("inflate over limit: err = %v, want ErrPDFTooLarge", err)
+	}
+	out, err := inflate(bomb, 1<<20)
+	if err != nil || len(out) != 1<<20 {
+		t.Fatalf("inflate at limit: len = %d, err = %v", len(out), err)
+	}
+
+	// 解码总量计入整个文档：已接近上限时再解压的流会被拒绝
+	data := buildPDF(
+		"<< /Type /Catalog /Pages 2 0 R >>",
+		"<< /Type /Pages /Kids [3 0 R] /Count 1 >>",
+		"<< /Type /Page /Contents 4 0 R >>",
+		stream("/Filter /FlateDecode", string(bomb)),
+	)
+	doc := &pdfDocument{objects: map[int]interface{}{}, decoded: map[*pdfStream][]byte{}, decodedSize: maxPDFDecodedSize - 1<<10}
+	if _, err := doc.decodeStream(&pdfStream{dict: pdfDict{"Filter": pdfName("FlateDecode")}, data: bomb}); !errors.Is(err, ErrPDFTooLarge) {
+		t.Fatalf("decodeStream over budget: err = %v, want ErrPDFTooLarge", err)
+	}
+	if !doc.tooLarge {
+		t.Fatal("decodeStream over budget should mark the document as too large")
+	}
+
+	if _, err := parsePDF(data); err != nil {
+		t.Fatalf("parsePDF under limit: %v", err)
+	}
+}
+
+func TestUnpredictPNGInvalidColumns(t *testing.T) {
+	for _, columns := range []int{0, -1, 1 << 40} {
+		if _, err := unpredictPNG([]byte{0, 1, 2}, columns); err == nil {
+			t.Errorf("columns %d: expected error", columns)
+		}
+	}
+}
@@ -2,7 +2,8 @@ package dto
 
 // CreateConversationRequest 创建对话请求
 type CreateConversationRequest struct {
-	Name string `json:"name" binding:"required,min=1,max=255"`
+	Name        string `json:"name" binding:"required,min=1,max=255"`
+	AssistantID *uint  `json:"assistantId,omitempty"` // 选择的助手，须在当前空间中
 }
 
 // UpdateConversationRequest 更新对话请求
@@ -15,6 +16,7 @@ type ConversationResponse struct {
 	ID           uint     `json:"id"`
 	Name         string   `json:"name"`
 	UserID       uint     `json:"userId"`
+	WorkspaceID  *uint    `json:"workspaceId"`
 	IsActive     bool     `json:"isActive"`
 	SystemPrompt *string  `json:"systemPrompt,omitempty"`
 	Model        *string  `json:"model,omitempty"`
@@ -22,5 +24,7 @@ type ConversationResponse struct {
 	CreatedAt    string   `json:"createdAt"`
 	UpdatedAt    string   `json:"updatedAt"`
 	Messages     int64    `json:"messageCount"`
+	Metadata     *string  `json:"metadata,omitempty"` // 导入来源等附加信息
+	AssistantID  *uint    `json:"assistantId,omitempty"`
 }
 
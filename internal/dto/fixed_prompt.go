@@ -2,25 +2,75 @@ package dto
 
 // CreateFixedPromptRequest 创建固定提示词请求
 type CreateFixedPromptRequest struct {
-	Name    string `json:"name" binding:"required,min=1,max=255"`
-	Content string `json:"content" binding:"required"`
+	Name      string           `json:"name" binding:"required,min=1,max=255"`
+	Content   string           `json:"content" binding:"required"`
+	Variables []PromptVariable `json:"variables,omitempty" binding:"omitempty,max=20,dive"`
 }
 
 // UpdateFixedPromptRequest 更新固定提示词请求
 type UpdateFixedPromptRequest struct {
-	Name     *string `json:"name,omitempty"`
-	Content  *string `json:"content,omitempty"`
-	IsActive *bool   `json:"isActive,omitempty"`
+	Name      *string           `json:"name,omitempty"`
+	Content   *string           `json:"content,omitempty"`
+	Variables *[]PromptVariable `json:"variables,omitempty" binding:"omitempty,max=20,dive"`
+	IsActive  *bool             `json:"isActive,omitempty"`
+}
+
+// PromptVariable 提示词模板变量，内容中以 {{name}} 引用
+type PromptVariable struct {
+	Name        string   `json:"name" binding:"required,max=64"`
+	Label       string   `json:"label,omitempty" binding:"max=255"`
+	Description string   `json:"description,omitempty" binding:"max=500"`
+	Type        string   `json:"type" binding:"required,oneof=text number select"`
+	Options     []string `json:"options,omitempty" binding:"omitempty,max=50,dive,min=1,max=255"` // select 类型的可选值
+	Default     *string  `json:"default,omitempty"`
+	Required    bool     `json:"required"`
 }
 
 // FixedPromptResponse 固定提示词响应
 type FixedPromptResponse struct {
-	ID        uint   `json:"id"`
-	Name      string `json:"name"`
-	Content   string `json:"content"`
-	IsActive  bool   `json:"isActive"`
-	CreatedAt string `json:"createdAt"`
-	UpdatedAt string `json:"updatedAt"`
+	ID          uint             `json:"id"`
+	Name        string           `json:"name"`
+	Content     string           `json:"content"`
+	Variables   []PromptVariable `json:"variables"`
+	Version     int              `json:"version"`
+	WorkspaceID *uint            `json:"workspaceId"`
+	IsActive    bool             `json:"isActive"`
+	CreatedAt   string           `json:"createdAt"`
+	UpdatedAt   string           `json:"updatedAt"`
+}
+
+// FixedPromptVersionResponse 固定提示词版本响应
+type FixedPromptVersionResponse struct {
+	Version   int              `json:"version"`
+	Content   string           `json:"content"`
+	Variables []PromptVariable `json:"variables"`
+	UserID    uint             `json:"userId"`
+	Current   bool             `json:"current"`
+	CreatedAt string           `json:"createdAt"`
+}
+
+// FixedPromptDiffRequest 版本对比请求，to 为空时与当前版本对比，from 为空时取 to 的上一版本
+type FixedPromptDiffRequest struct {
+	From int `form:"from" binding:"omitempty,min=1"`
+	To   int `form:"to" binding:"omitempty,min=1"`
+}
+
+// FixedPromptDiffLine 逐行对比结果，op 为 equal、insert 或 delete
+type FixedPromptDiffLine struct {
+	Op   string `json:"op"`
+	Text string `json:"text"`
+}
+
+// FixedPromptDiffResponse 两个版本的对比结果
+type FixedPromptDiffResponse struct {
+	From             int                   `json:"from"`
+	To               int                   `json:"to"`
+	Lines            []FixedPromptDiffLine `json:"lines"`
+	Added            int                   `json:"added"`
+	Removed          int                   `json:"removed"`
+	VariablesChanged bool                  `json:"variablesChanged"`
+	FromVariables    []PromptVariable      `json:"fromVariables"`
+	ToVariables      []PromptVariable      `json:"toVariables"`
 }
 
 // GetFixedPromptsRequest 获取固定提示词列表请求
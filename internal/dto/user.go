@@ -5,6 +5,8 @@ type UserResponse struct {
 	ID        uint   `json:"id"`
 	Email     string `json:"email"`
 	Username  string `json:"username"`
+	Role      string `json:"role"`
+	IsActive  bool   `json:"isActive"`
 	CreatedAt string `json:"createdAt"`
 	UpdatedAt string `json:"updatedAt"`
 }
@@ -28,6 +30,8 @@ type GetUsersRequest struct {
 	Page     int    `form:"page,default=1" binding:"min=1" example:"1"`
 	PageSize int    `form:"page_size,default=10" binding:"min=1,max=100" example:"10"`
 	Keyword  string `form:"keyword" example:"john"`
+	Role     string `form:"role" binding:"omitempty,oneof=user admin auditor" example:"admin"`
+	Status   string `form:"status" binding:"omitempty,oneof=active disabled" example:"active"`
 }
 
 // GetUsersResponse 获取用户列表响应
@@ -0,0 +1,31 @@
+package embedding
+
+import (
+	"ai-chat/config"
+	"context"
+	"fmt"
+	"strings"
+)
+
+// Provider 文本向量化接口
+type Provider interface {
+	// Embed 批量计算文本向量，返回顺序与输入一致
+	Embed(ctx context.Context, texts []string) ([][]float32, error)
+	// Model 向量模型名称，不同模型的向量不能混用
+	Model() string
+}
+
+// New 根据配置创建向量化提供方
+func New(cfg *config.Config) (Provider, error) {
+	switch strings.ToLower(cfg.EmbeddingProvider) {
+	case "openai":
+		if cfg.EmbeddingBaseURL == "" || cfg.EmbeddingModel == "" {
+			return nil, fmt.Errorf("EMBEDDING_PROVIDER=openai 时必须配置接口地址和 EMBEDDING_MODEL")
+		}
+		return NewOpenAIProvider(cfg.EmbeddingBaseURL, cfg.EmbeddingAPIKey, cfg.EmbeddingModel), nil
+	case "local":
+		return NewLocalProvider(), nil
+	default:
+		return nil, fmt.Errorf("不支持的向量化提供方: %s", cfg.EmbeddingProvider)
+	}
+}
@@ -0,0 +1,97 @@
+package embedding
+
+import (
+	"context"
+	"hash/fnv"
+	"math"
+	"strings"
+	"unicode"
+)
+
+// localDimensions 本地哈希向量的维度
+const localDimensions = 256
+
+// LocalProvider 本地哈希向量，不依赖外部接口，用于开发测试
+// 把英文单词和中文相邻字对哈希到固定维度，只能匹配字面相近的内容，无法识别同义改写
+type LocalProvider struct{}
+
+// NewLocalProvider 创建本地哈希向量提供方
+func NewLocalProvider() *LocalProvider {
+	return &LocalProvider{}
+}
+
+// Model 向量模型名称
+func (p *LocalProvider) Model() string {
+	return "local-hash-256"
+}
+
+// Embed 批量计算文本向量
+func (p *LocalProvider) Embed(ctx context.Context, texts []string) ([][]float32, error) {
+	vectors := make([][]float32, len(texts))
+	for i, text := range texts {
+		vectors[i] = hashVector(text)
+	}
+	return vectors, nil
+}
+
+// hashVector 计算单个文本的归一化哈希向量
+func hashVector(text string) []float32 {
+	vector := make([]float32, localDimensions)
+	for _, feature := range localFeatures(strings.ToLower(text)) {
+		h := fnv.New32a()
+		h.Write([]byte(feature))
+		sum := h.Sum32()
+		// 最高位决定符号，减少哈希冲突带来的偏差
+		if sum&(1<<31) != 0 {
+			vector[sum%localDimensions]--
+		} else {
+			vector[sum%localDimensions]++
+		}
+	}
+
+	var norm float64
+	for _, v := range vector {
+		norm += float64(v) * float64(v)
+	}
+	if norm > 0 {
+		scale := float32(1 / math.Sqrt(norm))
+		for i := range vector {
+			vector[i] *= scale
+		}
+	}
+	return vector
+}
+
+// localFeatures 提取特征：连续的字母数字作为一个词，中日韩字符取单字和相邻字对
+func localFeatures(text string) []string {
+	var features []string
+	var word []rune
+	var prevCJK rune
+
+	flush := func() {
+		if len(word) > 0 {
+			features = append(features, string(word))
+			word = word[:0]
+		}
+	}
+
+	for _, r := range text {
+		switch {
+		case unicode.In(r, unicode.Han, unicode.Hiragana, unicode.Katakana, unicode.Hangul):
+			flush()
+			features = append(features, string(r))
+			if prevCJK != 0 {
+				features = append(features, string([]rune{prevCJK, r}))
+			}
+			prevCJK = r
+			continue
+		case unicode.IsLetter(r) || unicode.IsDigit(r):
+			word = append(word, r)
+		default:
+			flush()
+		}
+		prevCJK = 0
+	}
+	flush()
+	return features
+}
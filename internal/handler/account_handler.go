@@ -0,0 +1,172 @@
+package handler
+
+import (
+	"ai-chat/internal/middleware"
+	"ai-chat/internal/service"
+	"errors"
+	"log"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// AccountHandler 账户处理器：邮箱验证、找回密码、魔法链接登录
+type AccountHandler struct {
+	accountService *service.AccountService
+}
+
+// NewAccountHandler 创建账户处理器
+func NewAccountHandler(accountService *service.AccountService) *AccountHandler {
+	return &AccountHandler{accountService: accountService}
+}
+
+// EmailRequest 仅包含邮箱的请求
+type EmailRequest struct {
+	Email string `json:"email" binding:"required,email"`
+}
+
+// TokenRequest 仅包含令牌的请求
+type TokenRequest struct {
+	Token string `json:"token" binding:"required"`
+}
+
+// SendVerificationEmail 发送邮箱验证邮件
+func (h *AccountHandler) SendVerificationEmail(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+
+	if err := h.accountService.SendVerificationEmail(userID); err != nil {
+		status := http.StatusBadRequest
+		if errors.Is(err, service.ErrMailCooldown) {
+			status = http.StatusTooManyRequests
+		}
+		c.JSON(status, gin.H{
+			"error":   "发送验证邮件失败",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "验证邮件已发送",
+	})
+}
+
+// VerifyEmail 验证邮箱
+func (h *AccountHandler) VerifyEmail(c *gin.Context) {
+	var req TokenRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "请求参数错误",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	if err := h.accountService.VerifyEmail(req.Token); err != nil {
+		h.tokenError(c, "邮箱验证失败", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "邮箱验证成功",
+	})
+}
+
+// ForgotPassword 申请重置密码
+func (h *AccountHandler) ForgotPassword(c *gin.Context) {
+	var req EmailRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "请求参数错误",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	// 无论邮箱是否存在都返回相同结果，避免泄露注册信息
+	if err := h.accountService.RequestPasswordReset(req.Email); err != nil {
+		log.Printf("发送密码重置邮件失败: %v", err)
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "如果该邮箱已注册，你将收到一封重置密码的邮件",
+	})
+}
+
+// ResetPassword 重置密码
+func (h *AccountHandler) ResetPassword(c *gin.Context) {
+	var req service.ResetPasswordRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "请求参数错误",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	if err := h.accountService.ResetPassword(&req); err != nil {
+		h.tokenError(c, "重置密码失败", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "密码已重置，请使用新密码登录",
+	})
+}
+
+// RequestMagicLink 申请魔法链接登录
+func (h *AccountHandler) RequestMagicLink(c *gin.Context) {
+	var req EmailRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "请求参数错误",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	// 无论邮箱是否存在都返回相同结果，避免泄露注册信息
+	if err := h.accountService.RequestMagicLink(req.Email); err != nil {
+		log.Printf("发送魔法链接邮件失败: %v", err)
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "如果该邮箱已注册，你将收到一封登录邮件",
+	})
+}
+
+// LoginWithMagicLink 使用魔法链接登录
+func (h *AccountHandler) LoginWithMagicLink(c *gin.Context) {
+	var req TokenRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "请求参数错误",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	result, err := h.accountService.LoginWithMagicLink(req.Token, clientInfo(c))
+	if err != nil {
+		h.tokenError(c, "登录失败", err)
+		return
+	}
+
+	writeLoginResult(c, result)
+}
+
+// tokenError 统一处理令牌相关错误
+func (h *AccountHandler) tokenError(c *gin.Context, msg string, err error) {
+	if errors.Is(err, service.ErrInvalidToken) {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   msg,
+			"details": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusInternalServerError, gin.H{
+		"error":   msg,
+		"details": err.Error(),
+	})
+}
@@ -0,0 +1,244 @@
+package handler
+
+import (
+	"ai-chat/internal/middleware"
+	"ai-chat/internal/service"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// AdminHandler 管理后台处理器
+type AdminHandler struct {
+	adminService *service.AdminService
+}
+
+// NewAdminHandler 创建管理后台处理器
+func NewAdminHandler(adminService *service.AdminService) *AdminHandler {
+	return &AdminHandler{adminService: adminService}
+}
+
+// ImpersonateRequest 模拟登录请求
+type ImpersonateRequest struct {
+	Reason string `json:"reason" binding:"required,max=500"` // 模拟原因，记入审计日志
+}
+
+// CreateUser 创建用户
+func (h *AdminHandler) CreateUser(c *gin.Context) {
+	adminID := middleware.GetUserID(c)
+
+	var req service.CreateUserRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "请求参数错误",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	user, err := h.adminService.CreateUser(adminID, &req, c.ClientIP())
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "创建用户失败",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"data": userToUserResponse(user),
+	})
+}
+
+// DisableUser 禁用用户
+func (h *AdminHandler) DisableUser(c *gin.Context) {
+	h.setActive(c, false)
+}
+
+// EnableUser 启用用户
+func (h *AdminHandler) EnableUser(c *gin.Context) {
+	h.setActive(c, true)
+}
+
+// setActive 启用或禁用用户
+func (h *AdminHandler) setActive(c *gin.Context, active bool) {
+	adminID := middleware.GetUserID(c)
+
+	userID, ok := parseUserIDParam(c)
+	if !ok {
+		return
+	}
+
+	user, err := h.adminService.SetActive(adminID, userID, active, c.ClientIP())
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "更新用户状态失败",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data": userToUserResponse(user),
+	})
+}
+
+// UpdateRole 修改用户角色
+func (h *AdminHandler) UpdateRole(c *gin.Context) {
+	adminID := middleware.GetUserID(c)
+
+	userID, ok := parseUserIDParam(c)
+	if !ok {
+		return
+	}
+
+	var req service.UpdateRoleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "请求参数错误",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	user, err := h.adminService.UpdateRole(adminID, userID, req.Role, c.ClientIP())
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "修改角色失败",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data": userToUserResponse(user),
+	})
+}
+
+// ResetPassword 重置用户密码
+func (h *AdminHandler) ResetPassword(c *gin.Context) {
+	adminID := middleware.GetUserID(c)
+
+	userID, ok := parseUserIDParam(c)
+	if !ok {
+		return
+	}
+
+	var req service.ResetPasswordByAdminRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "请求参数错误",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	if err := h.adminService.ResetPassword(adminID, userID, req.Password, c.ClientIP()); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "重置密码失败",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "密码已重置，该用户的所有登录会话已注销",
+	})
+}
+
+// GetUsage 获取用户使用量
+func (h *AdminHandler) GetUsage(c *gin.Context) {
+	userID, ok := parseUserIDParam(c)
+	if !ok {
+		return
+	}
+
+	usage, err := h.adminService.Usage(userID)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "获取使用量失败",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data": usage,
+	})
+}
+
+// Impersonate 以目标用户身份登录
+func (h *AdminHandler) Impersonate(c *gin.Context) {
+	adminID := middleware.GetUserID(c)
+
+	userID, ok := parseUserIDParam(c)
+	if !ok {
+		return
+	}
+
+	var req ImpersonateRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "请求参数错误",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	result, err := h.adminService.Impersonate(adminID, userID, req.Reason, clientInfo(c))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "模拟登录失败",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data": newAuthResponse(result),
+	})
+}
+
+// GetAuditLogs 查询审计日志
+func (h *AdminHandler) GetAuditLogs(c *gin.Context) {
+	var query service.AuditLogQuery
+	if err := c.ShouldBindQuery(&query); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "请求参数错误",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	logs, total, err := h.adminService.ListAuditLogs(&query)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "获取审计日志失败",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data": gin.H{
+			"items":    logs,
+			"total":    total,
+			"page":     query.Page,
+			"pageSize": query.PageSize,
+		},
+	})
+}
+
+// parseUserIDParam 解析路径中的用户ID，失败时直接写入错误响应
+func parseUserIDParam(c *gin.Context) (uint, bool) {
+	userID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "无效的用户ID",
+			"details": err.Error(),
+		})
+		return 0, false
+	}
+	return uint(userID), true
+}
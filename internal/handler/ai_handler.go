@@ -1,9 +1,10 @@
 package handler
 
 import (
-	"ai-chat/internal/dto"
 	"ai-chat/internal/middleware"
+	"ai-chat/internal/policy"
 	"ai-chat/internal/service"
+	"context"
 	"encoding/json"
 	"log"
 	"net/http"
@@ -19,6 +20,12 @@ type AIHandler struct {
 	conversationService *service.ConversationService
 	messageService      *service.MessageService
 	fixedPromptService  *service.FixedPromptService
+	workspaceService    *service.WorkspaceService
+	attachmentService   *service.AttachmentService
+	documentService     *service.DocumentService
+	knowledgeService    *service.KnowledgeBaseService
+	memoryService       *service.MemoryService
+	assistantService    *service.AssistantService
 }
 
 // NewAIHandler 创建AI处理器
@@ -27,12 +34,24 @@ func NewAIHandler(
 	conversationService *service.ConversationService,
 	messageService *service.MessageService,
 	fixedPromptService *service.FixedPromptService,
+	workspaceService *service.WorkspaceService,
+	attachmentService *service.AttachmentService,
+	documentService *service.DocumentService,
+	knowledgeService *service.KnowledgeBaseService,
+	memoryService *service.MemoryService,
+	assistantService *service.AssistantService,
 ) *AIHandler {
 	return &AIHandler{
 		aiService:           aiService,
 		conversationService: conversationService,
 		messageService:      messageService,
 		fixedPromptService:  fixedPromptService,
+		workspaceService:    workspaceService,
+		attachmentService:   attachmentService,
+		documentService:     documentService,
+		knowledgeService:    knowledgeService,
+		memoryService:       memoryService,
+		assistantService:    assistantService,
 	}
 }
 
@@ -46,6 +65,21 @@ type ChatRequest struct {
 	Thinking       *struct {
 		Type string `json:"type"`
 	} `json:"thinking,omitempty"`
+	// 附带的图片，https 地址或 data:image/...;base64，需要所选模型支持图片输入
+	Images []service.ImageInput `json:"images,omitempty" binding:"omitempty,dive"`
+	// 引用的文档，按 token 预算加入本次请求的上下文，不随消息保存
+	DocumentIDs []uint `json:"documentIds,omitempty"`
+	// 新会话使用的助手，已有会话使用创建时选择的助手
+	AssistantID *uint `json:"assistantId,omitempty"`
+	// 固定提示词模板变量的取值，未填写的变量使用默认值
+	Variables map[string]string `json:"variables,omitempty"`
+}
+
+// chatImages 聊天请求中已校验的图片
+type chatImages struct {
+	parts  []service.ContentPart
+	inline []*service.InlineImage // 保存为用户消息的附件
+	remote []string               // 记录在用户消息的元数据中
 }
 
 // StreamChatRequest 流式聊天请求
@@ -70,6 +104,8 @@ type ChatResponse struct {
 		TotalTokens      int `json:"total_tokens"`
 	} `json:"usage,omitempty"`
 	PromptFilterResults []PromptFilterResult `json:"prompt_filter_results,omitempty"`
+	// 回答引用的知识库片段，与回答中的 [编号] 对应
+	Citations []service.Citation `json:"citations,omitempty"`
 }
 
 // PromptFilterResult 提示过滤结果
@@ -89,7 +125,32 @@ func (h *AIHandler) SendMessage(c *gin.Context) {
 		return
 	}
 
-	userID := middleware.GetUserID(c)
+	principal := middleware.GetPrincipal(c)
+
+	// 已有会话先校验写权限，再读取会话的助手和模型配置
+	if req.ConversationID != nil && !h.authorizeConversation(c, principal, *req.ConversationID) {
+		return
+	}
+
+	assistant, ok := h.prepareAssistant(c, principal, &req)
+	if !ok {
+		return
+	}
+
+	// 所选模型不支持图片输入时在创建会话前拒绝
+	images, ok := h.prepareImages(c, principal, &req, assistant)
+	if !ok {
+		return
+	}
+	documents, ok := h.prepareDocuments(c, principal, &req)
+	if !ok {
+		return
+	}
+	// 模板变量无效时在创建会话前拒绝
+	systemPrompt, fixedPrompt, ok := h.prepareFixedPrompt(c, principal, &req, req.FixedPromptID != nil)
+	if !ok {
+		return
+	}
 
 	// 创建或获取会话
 	var conversationID uint
@@ -97,12 +158,12 @@ func (h *AIHandler) SendMessage(c *gin.Context) {
 		conversationID = *req.ConversationID
 	} else {
 		conversationReq := &service.CreateConversationRequest{
-			Name:   req.Message[:min(len(req.Message), 50)],
-			UserID: userID,
+			Name:        req.Message[:min(len(req.Message), 50)],
+			AssistantID: req.AssistantID,
 		}
-		conversation, err := h.conversationService.Create(conversationReq)
+		conversation, err := h.conversationService.Create(principal, conversationReq)
 		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{
+			c.JSON(assistantErrorStatus(err, http.StatusInternalServerError), gin.H{
 				"error":   "创建会话失败",
 				"details": err.Error(),
 			})
@@ -111,32 +172,36 @@ func (h *AIHandler) SendMessage(c *gin.Context) {
 		conversationID = conversation.ID
 	}
 
-	// 获取固定提示词内容
-	var systemPrompt string
-	if req.FixedPromptID != nil {
-		fixedPrompt, err := h.fixedPromptService.FindByID(userID, *req.FixedPromptID)
-		if err == nil && fixedPrompt.IsActive {
-			systemPrompt = fixedPrompt.Content
-		}
-	}
-
-	// 构建消息列表
-	chatMessages, err := h.buildChatMessages(userID, conversationID, systemPrompt, req.Message)
+	// 工作区会话使用工作区的默认模型和接口配置
+	endpoint, err := h.workspaceService.AIEndpoint(conversationID)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{
-			"error":   "获取消息历史失败",
+			"error":   "获取模型配置失败",
 			"details": err.Error(),
 		})
 		return
 	}
 
-	// 调用AI服务
 	chatReq := &service.ChatRequest{
-		Messages:    chatMessages,
 		Model:       req.Model,
 		Temperature: req.Temperature,
+		Endpoint:    endpoint,
 	}
+	assistant.Apply(chatReq)
 
+	// 构建消息列表
+	var citations []service.Citation
+	chatReq.Messages, citations, err = h.buildChatMessages(c.Request.Context(), principal, conversationID, assistant, fixedPrompt.PromptID(), systemPrompt, req.Message, images.parts, h.aiService.SupportsVision(chatReq))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "获取消息历史失败",
+			"details": err.Error(),
+		})
+		return
+	}
+	chatReq.Messages = withDocuments(chatReq.Messages, documents)
+
+	// 调用AI服务
 	result, err := h.aiService.ChatCompletion(chatReq)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{
@@ -151,8 +216,12 @@ func (h *AIHandler) SendMessage(c *gin.Context) {
 		ConversationID: conversationID,
 		Content:        req.Message,
 		Type:           "user",
+		Metadata:       service.ImageMetadata(images.remote),
+	}
+	savedMessage, err := h.messageService.Create(principal, userMessage)
+	if err == nil {
+		err = h.attachmentService.AttachImages(c.Request.Context(), principal, savedMessage.ID, images.inline)
 	}
-	_, err = h.messageService.Create(userID, userMessage)
 	if err != nil {
 		// 即使保存消息失败，也返回AI回复
 		c.JSON(http.StatusOK, gin.H{
@@ -160,7 +229,7 @@ func (h *AIHandler) SendMessage(c *gin.Context) {
 				ID:      "resp_" + time.Now().Format("20060102150405"),
 				Object:  "chat.completion",
 				Created: time.Now().Unix(),
-				Model:   *req.Model,
+				Model:   *chatReq.Model,
 				Choices: result.Choices,
 				Usage:   result.Usage,
 			},
@@ -174,16 +243,17 @@ func (h *AIHandler) SendMessage(c *gin.Context) {
 			ConversationID: conversationID,
 			Content:        result.Choices[0].Message.Content,
 			Type:           "assistant",
-			Model:          req.Model,
+			Model:          chatReq.Model,
+			Metadata:       service.AssistantMetadata(citations, fixedPrompt),
 		}
-		_, err = h.messageService.Create(userID, assistantMessage)
+		_, err = h.messageService.Create(principal, assistantMessage)
 		if err != nil {
 			c.JSON(http.StatusOK, gin.H{
 				"data": ChatResponse{
 					ID:      "resp_" + time.Now().Format("20060102150405"),
 					Object:  "chat.completion",
 					Created: time.Now().Unix(),
-					Model:   *req.Model,
+					Model:   *chatReq.Model,
 					Choices: result.Choices,
 					Usage:   result.Usage,
 				},
@@ -194,12 +264,13 @@ func (h *AIHandler) SendMessage(c *gin.Context) {
 
 	c.JSON(http.StatusOK, gin.H{
 		"data": ChatResponse{
-			ID:      "resp_" + time.Now().Format("20060102150405"),
-			Object:  "chat.completion",
-			Created: time.Now().Unix(),
-			Model:   *req.Model,
-			Choices: result.Choices,
-			Usage:   result.Usage,
+			ID:        "resp_" + time.Now().Format("20060102150405"),
+			Object:    "chat.completion",
+			Created:   time.Now().Unix(),
+			Model:     *chatReq.Model,
+			Choices:   result.Choices,
+			Usage:     result.Usage,
+			Citations: citations,
 		},
 	})
 }
@@ -215,7 +286,32 @@ func (h *AIHandler) StreamChat(c *gin.Context) {
 		return
 	}
 
-	userID := middleware.GetUserID(c)
+	principal := middleware.GetPrincipal(c)
+
+	// 已有会话先校验写权限，再读取会话的助手和模型配置
+	if req.ConversationID != nil && !h.authorizeConversation(c, principal, *req.ConversationID) {
+		return
+	}
+
+	assistant, ok := h.prepareAssistant(c, principal, &req.ChatRequest)
+	if !ok {
+		return
+	}
+
+	// 所选模型不支持图片输入时在创建会话前拒绝
+	images, ok := h.prepareImages(c, principal, &req.ChatRequest, assistant)
+	if !ok {
+		return
+	}
+	documents, ok := h.prepareDocuments(c, principal, &req.ChatRequest)
+	if !ok {
+		return
+	}
+	// 模板变量无效时在创建会话前拒绝
+	systemPrompt, fixedPrompt, ok := h.prepareFixedPrompt(c, principal, &req.ChatRequest, req.UseFixedPrompt)
+	if !ok {
+		return
+	}
 
 	// 创建或获取会话
 	var conversationID uint
@@ -223,12 +319,12 @@ func (h *AIHandler) StreamChat(c *gin.Context) {
 		conversationID = *req.ConversationID
 	} else {
 		conversationReq := &service.CreateConversationRequest{
-			Name:   req.Message[:min(len(req.Message), 50)],
-			UserID: userID,
+			Name:        req.Message[:min(len(req.Message), 50)],
+			AssistantID: req.AssistantID,
 		}
-		conversation, err := h.conversationService.Create(conversationReq)
+		conversation, err := h.conversationService.Create(principal, conversationReq)
 		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{
+			c.JSON(assistantErrorStatus(err, http.StatusInternalServerError), gin.H{
 				"error":   "创建会话失败",
 				"details": err.Error(),
 			})
@@ -237,13 +333,14 @@ func (h *AIHandler) StreamChat(c *gin.Context) {
 		conversationID = conversation.ID
 	}
 
-	// 保存用户消息
+	// 保存用户消息，图片随消息保存后从历史中读取
 	userMessage := &service.CreateMessageRequest{
 		ConversationID: conversationID,
 		Content:        req.Message,
 		Type:           "user",
+		Metadata:       service.ImageMetadata(images.remote),
 	}
-	_, err := h.messageService.Create(userID, userMessage)
+	savedMessage, err := h.messageService.Create(principal, userMessage)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"error":   "保存用户消息失败",
@@ -251,37 +348,47 @@ func (h *AIHandler) StreamChat(c *gin.Context) {
 		})
 		return
 	}
-
-	// 获取系统提示词
-	var systemPrompt string
-	if req.UseFixedPrompt && req.FixedPromptID != nil {
-		var fixedPrompt *dto.FixedPromptResponse
-		fixedPrompt, err = h.fixedPromptService.FindByID(userID, *req.FixedPromptID)
-		if err == nil && fixedPrompt.IsActive {
-			systemPrompt = fixedPrompt.Content
-		}
+	if err := h.attachmentService.AttachImages(c.Request.Context(), principal, savedMessage.ID, images.inline); err != nil {
+		c.JSON(attachmentErrorStatus(err, http.StatusInternalServerError), gin.H{
+			"error":   "保存图片失败",
+			"details": err.Error(),
+		})
+		return
 	}
 
-	// 构建消息列表
-	chatMessages, err := h.buildChatMessages(userID, conversationID, systemPrompt, req.Message)
+	// 工作区会话使用工作区的默认模型和接口配置
+	endpoint, err := h.workspaceService.AIEndpoint(conversationID)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{
-			"error":   "获取消息历史失败",
+			"error":   "获取模型配置失败",
 			"details": err.Error(),
 		})
 		return
 	}
 
-	// 流式处理AI响应
 	chatReq := &service.ChatRequest{
-		Messages:    chatMessages,
 		Model:       req.Model,
 		Temperature: req.Temperature,
 		Stream:      true,
 		Thinking:    req.Thinking,
+		Endpoint:    endpoint,
 	}
+	assistant.Apply(chatReq)
 
-	h.processStreamResponse(c, userID, conversationID, chatReq, "message")
+	// 构建消息列表
+	var citations []service.Citation
+	chatReq.Messages, citations, err = h.buildChatMessages(c.Request.Context(), principal, conversationID, assistant, fixedPrompt.PromptID(), systemPrompt, req.Message, nil, h.aiService.SupportsVision(chatReq))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "获取消息历史失败",
+			"details": err.Error(),
+		})
+		return
+	}
+	chatReq.Messages = withDocuments(chatReq.Messages, documents)
+
+	// 流式处理AI响应
+	h.processStreamResponse(c, principal, conversationID, chatReq, citations, fixedPrompt, "message")
 }
 
 // GetModels 获取可用模型列表
@@ -319,13 +426,25 @@ func (h *AIHandler) StreamChatByConversationID(c *gin.Context) {
 	// 获取提示词（可选）
 	prompt := c.Query("prompt")
 
-	userID := middleware.GetUserID(c)
+	principal := middleware.GetPrincipal(c)
+	if !h.authorizeConversation(c, principal, uint(conversationID)) {
+		return
+	}
 
-	// 构建消息列表
-	chatMessages, err := h.buildChatMessages(userID, uint(conversationID), "", prompt)
+	// 工作区会话使用工作区的默认模型和接口配置
+	endpoint, err := h.workspaceService.AIEndpoint(uint(conversationID))
+	if err != nil {
+		c.JSON(errorStatus(err, http.StatusInternalServerError), gin.H{
+			"error":   "获取模型配置失败",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	assistant, err := h.assistantService.ForConversation(uint(conversationID))
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{
-			"error":   "获取消息历史失败",
+			"error":   "获取会话助手失败",
 			"details": err.Error(),
 		})
 		return
@@ -333,10 +452,22 @@ func (h *AIHandler) StreamChatByConversationID(c *gin.Context) {
 
 	// 流式处理AI响应
 	chatReq := &service.ChatRequest{
-		Messages:    chatMessages,
 		Model:       nil,
 		Temperature: nil,
 		Stream:      true,
+		Endpoint:    endpoint,
+	}
+	assistant.Apply(chatReq)
+
+	// 构建消息列表
+	var citations []service.Citation
+	chatReq.Messages, citations, err = h.buildChatMessages(c.Request.Context(), principal, uint(conversationID), assistant, nil, "", prompt, nil, h.aiService.SupportsVision(chatReq))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "获取消息历史失败",
+			"details": err.Error(),
+		})
+		return
 	}
 
 	// 从Query中获取thinking
@@ -349,7 +480,7 @@ func (h *AIHandler) StreamChatByConversationID(c *gin.Context) {
 		}
 	}
 
-	h.processStreamResponse(c, userID, uint(conversationID), chatReq, "token")
+	h.processStreamResponse(c, principal, uint(conversationID), chatReq, citations, nil, "token")
 }
 
 // Helper functions
@@ -360,16 +491,170 @@ func min(a, b int) int {
 	return b
 }
 
-// buildChatMessages 构建聊天消息列表
-func (h *AIHandler) buildChatMessages(userID, conversationID uint, systemPrompt string, currentMessage string) ([]service.Message, error) {
+// authorizeConversation 校验会话写权限，失败时直接写入错误响应
+func (h *AIHandler) authorizeConversation(c *gin.Context, principal policy.Principal, conversationID uint) bool {
+	if err := h.conversationService.Authorize(principal, policy.ActionWrite, conversationID); err != nil {
+		c.JSON(errorStatus(err, http.StatusInternalServerError), gin.H{
+			"error":   "无权访问该会话",
+			"details": err.Error(),
+		})
+		return false
+	}
+	return true
+}
+
+// prepareAssistant 读取已有会话选择的助手，或校验新会话选择的助手，失败时直接写入错误响应
+func (h *AIHandler) prepareAssistant(c *gin.Context, principal policy.Principal, req *ChatRequest) (*service.AssistantSettings, bool) {
+	var assistant *service.AssistantSettings
+	var err error
+	if req.ConversationID != nil {
+		assistant, err = h.assistantService.ForConversation(*req.ConversationID)
+	} else if req.AssistantID != nil {
+		assistant, err = h.assistantService.Select(principal, *req.AssistantID)
+	}
+	if err != nil {
+		c.JSON(assistantErrorStatus(err, http.StatusInternalServerError), gin.H{
+			"error":   "获取助手失败",
+			"details": err.Error(),
+		})
+		return nil, false
+	}
+	return assistant, true
+}
+
+// prepareFixedPrompt 读取请求选择的固定提示词并渲染模板变量，返回系统提示词和所用版本，use 为 false 或提示词不可用时不使用
+// 变量缺失或取值无效时直接写入错误响应
+func (h *AIHandler) prepareFixedPrompt(c *gin.Context, principal policy.Principal, req *ChatRequest, use bool) (string, *service.PromptVersionRef, bool) {
+	if !use || req.FixedPromptID == nil {
+		return "", nil, true
+	}
+	fixedPrompt, err := h.fixedPromptService.FindByID(principal, *req.FixedPromptID)
+	if err != nil || !fixedPrompt.IsActive {
+		return "", nil, true
+	}
+
+	systemPrompt, err := h.fixedPromptService.Render(principal, fixedPrompt, req.Variables)
+	if err != nil {
+		c.JSON(fixedPromptErrorStatus(err, http.StatusInternalServerError), gin.H{
+			"error":   "渲染提示词失败",
+			"details": err.Error(),
+		})
+		return "", nil, false
+	}
+	return systemPrompt, &service.PromptVersionRef{ID: fixedPrompt.ID, Version: fixedPrompt.Version}, true
+}
+
+// prepareImages 校验聊天请求中的图片，所选模型不支持图片输入时拒绝，失败时直接写入错误响应
+func (h *AIHandler) prepareImages(c *gin.Context, principal policy.Principal, req *ChatRequest, assistant *service.AssistantSettings) (*chatImages, bool) {
+	images := &chatImages{}
+	if len(req.Images) == 0 {
+		return images, true
+	}
+
+	// 新会话创建在当前工作区中，使用当前工作区的配置判断模型
+	var endpoint *service.AIEndpoint
+	var err error
+	if req.ConversationID != nil {
+		endpoint, err = h.workspaceService.AIEndpoint(*req.ConversationID)
+	} else {
+		endpoint, err = h.workspaceService.WorkspaceAIEndpoint(principal.WorkspaceID)
+	}
+	if err != nil {
+		c.JSON(errorStatus(err, http.StatusInternalServerError), gin.H{
+			"error":   "获取模型配置失败",
+			"details": err.Error(),
+		})
+		return nil, false
+	}
+
+	visionReq := &service.ChatRequest{Model: req.Model, Endpoint: endpoint}
+	assistant.Apply(visionReq)
+	if !h.aiService.SupportsVision(visionReq) {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "模型不支持图片输入",
+			"details": service.ErrVisionNotSupported.Error(),
+		})
+		return nil, false
+	}
+
+	images.parts, images.inline, images.remote, err = h.attachmentService.ParseImages(principal, req.Images)
+	if err != nil {
+		c.JSON(attachmentErrorStatus(err, http.StatusBadRequest), gin.H{
+			"error":   "图片无效",
+			"details": err.Error(),
+		})
+		return nil, false
+	}
+	return images, true
+}
+
+// prepareDocuments 读取聊天请求引用的文档内容，无权访问时直接写入错误响应
+func (h *AIHandler) prepareDocuments(c *gin.Context, principal policy.Principal, req *ChatRequest) (*service.DocumentContext, bool) {
+	documents, err := h.documentService.Context(principal, req.DocumentIDs, req.Message)
+	if err != nil {
+		c.JSON(errorStatus(err, http.StatusBadRequest), gin.H{
+			"error":   "读取引用的文档失败",
+			"details": err.Error(),
+		})
+		return nil, false
+	}
+	return documents, true
+}
+
+// withDocuments 将引用的文档内容作为系统消息插入到当前用户消息之前
+func withDocuments(messages []service.Message, documents *service.DocumentContext) []service.Message {
+	if documents == nil {
+		return messages
+	}
+	return withSystemMessage(messages, documents.Content)
+}
+
+// withSystemMessage 将系统消息插入到最后一条消息（当前用户消息）之前
+func withSystemMessage(messages []service.Message, content string) []service.Message {
+	if len(messages) == 0 {
+		return messages
+	}
+	last := len(messages) - 1
+	result := make([]service.Message, 0, len(messages)+1)
+	result = append(result, messages[:last]...)
+	result = append(result, service.Message{Role: "system", Content: content})
+	return append(result, messages[last])
+}
+
+// buildChatMessages 构建聊天消息列表，vision 为 false 时不发送历史消息中的图片
+// assistant 为会话选择的助手，其系统提示词放在 systemPrompt 之前，并决定是否使用记忆和知识库
+// 用户开启了记忆时加入相关的长期记忆；会话、助手或 fixedPromptID 关联了知识库时，按当前用户消息检索相关片段插入到该消息之前，并返回引用来源
+func (h *AIHandler) buildChatMessages(ctx context.Context, principal policy.Principal, conversationID uint, assistant *service.AssistantSettings, fixedPromptID *uint, systemPrompt string, currentMessage string, currentImages []service.ContentPart, vision bool) ([]service.Message, []service.Citation, error) {
 	// 构建消息历史
-	messages, err := h.messageService.FindByConversationID(userID, conversationID)
+	messages, err := h.messageService.FindByConversationID(principal, conversationID)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
+	}
+
+	var images map[uint][]service.ContentPart
+	if vision {
+		images, err = h.attachmentService.HistoryImages(ctx, messages)
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+
+	// 本轮的问题，没有当前消息时（如重新生成）使用最后一条用户消息
+	question := currentMessage
+	for i := len(messages) - 1; question == "" && i >= 0; i-- {
+		if messages[i].Type == "user" {
+			question = messages[i].Content
+		}
 	}
 
 	// 构造消息列表用于OpenAI
 	var chatMessages []service.Message
+	if assistant != nil && assistant.SystemPrompt != "" {
+		chatMessages = append(chatMessages, service.Message{
+			Role:    "system",
+			Content: assistant.SystemPrompt,
+		})
+	}
 	if systemPrompt != "" {
 		chatMessages = append(chatMessages, service.Message{
 			Role:    "system",
@@ -377,11 +662,27 @@ func (h *AIHandler) buildChatMessages(userID, conversationID uint, systemPrompt
 		})
 	}
 
+	// 加入用户的长期记忆，读取失败不影响对话
+	var memories string
+	if assistant.Allows(service.ToolMemory) {
+		memories, err = h.memoryService.Context(principal.UserID, conversationID, question)
+		if err != nil {
+			log.Printf("读取长期记忆失败: %v", err)
+		}
+	}
+	if memories != "" {
+		chatMessages = append(chatMessages, service.Message{
+			Role:    "system",
+			Content: memories,
+		})
+	}
+
 	// 添加历史消息
 	for _, msg := range messages {
 		chatMessages = append(chatMessages, service.Message{
 			Role:    msg.Type, // 使用消息的实际类型
 			Content: msg.Content,
+			Parts:   images[msg.ID],
 		})
 	}
 
@@ -390,14 +691,30 @@ func (h *AIHandler) buildChatMessages(userID, conversationID uint, systemPrompt
 		chatMessages = append(chatMessages, service.Message{
 			Role:    "user",
 			Content: currentMessage,
+			Parts:   currentImages,
 		})
 	}
 
-	return chatMessages, nil
+	// 检索关联的知识库
+	if question == "" || !assistant.Allows(service.ToolKnowledge) {
+		return chatMessages, nil, nil
+	}
+	retrieval, err := h.knowledgeService.Retrieve(conversationID, fixedPromptID, question)
+	if err != nil {
+		// 检索失败不影响对话
+		log.Printf("知识库检索失败: %v", err)
+		return chatMessages, nil, nil
+	}
+	if retrieval == nil {
+		return chatMessages, nil, nil
+	}
+	return withSystemMessage(chatMessages, retrieval.Content), retrieval.Citations, nil
 }
 
 // processStreamResponse 处理流式响应通用逻辑
-func (h *AIHandler) processStreamResponse(c *gin.Context, userID, conversationID uint, chatReq *service.ChatRequest, messageType string) {
+// citations 为本轮检索到的知识库片段，在回答开始前发送，并随助手消息保存
+// fixedPrompt 为本轮使用的固定提示词版本，记录在助手消息的元数据中
+func (h *AIHandler) processStreamResponse(c *gin.Context, principal policy.Principal, conversationID uint, chatReq *service.ChatRequest, citations []service.Citation, fixedPrompt *service.PromptVersionRef, messageType string) {
 	// 设置SSE响应头
 	c.Header("Content-Type", "text/event-stream")
 	c.Header("Cache-Control", "no-cache")
@@ -413,6 +730,20 @@ func (h *AIHandler) processStreamResponse(c *gin.Context, userID, conversationID
 	c.Writer.Write([]byte("data: {\"conversationId\":" + strconv.Itoa(int(conversationID)) + "}\n\n"))
 	flusher.Flush()
 
+	// 发送引用来源，便于界面在生成过程中关联 [编号] 标记
+	if len(citations) > 0 {
+		citationData := map[string]interface{}{
+			"type":           "citations",
+			"conversationId": conversationID,
+			"citations":      citations,
+		}
+		jsonCitationData, _ := json.Marshal(citationData)
+		c.Writer.Write([]byte("data: "))
+		c.Writer.Write(jsonCitationData)
+		c.Writer.Write([]byte("\n\n"))
+		flusher.Flush()
+	}
+
 	var fullContent string
 	var fullReasoningContent string
 	chunkCount := 0
@@ -432,9 +763,10 @@ func (h *AIHandler) processStreamResponse(c *gin.Context, userID, conversationID
 			ReasoningContent: fullReasoningContent,
 			Type:             "assistant",
 			Model:            chatReq.Model,
+			Metadata:         service.AssistantMetadata(citations, fixedPrompt),
 		}
 
-		_, err := h.messageService.Create(userID, msgReq)
+		_, err := h.messageService.Create(principal, msgReq)
 		if err != nil {
 			log.Printf("保存AI回答失败: %v", err)
 		}
@@ -514,6 +846,9 @@ Loop:
 		"content":        fullContent,
 		"chunkCount":     chunkCount,
 	}
+	if len(citations) > 0 {
+		finishData["citations"] = citations
+	}
 	jsonFinishData, _ := json.Marshal(finishData)
 	c.Writer.Write([]byte("data: "))
 	c.Writer.Write(jsonFinishData)
@@ -0,0 +1,241 @@
+package handler
+
+import (
+	"ai-chat/internal/middleware"
+	"ai-chat/internal/service"
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// AssistantHandler 助手处理器
+type AssistantHandler struct {
+	assistantService     *service.AssistantService
+	knowledgeBaseService *service.KnowledgeBaseService
+}
+
+// NewAssistantHandler 创建助手处理器
+func NewAssistantHandler(assistantService *service.AssistantService, knowledgeBaseService *service.KnowledgeBaseService) *AssistantHandler {
+	return &AssistantHandler{
+		assistantService:     assistantService,
+		knowledgeBaseService: knowledgeBaseService,
+	}
+}
+
+// assistantListQuery 助手列表查询参数
+type assistantListQuery struct {
+	Q        string `form:"q"`
+	Page     int    `form:"page,default=1" binding:"min=1"`
+	PageSize int    `form:"page_size,default=20" binding:"min=1,max=100"`
+}
+
+// Create 创建助手
+func (h *AssistantHandler) Create(c *gin.Context) {
+	var req service.CreateAssistantRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "请求参数错误",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	assistant, err := h.assistantService.Create(middleware.GetPrincipal(c), &req)
+	if err != nil {
+		c.JSON(errorStatus(err, http.StatusInternalServerError), gin.H{
+			"error":   "创建助手失败",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"data": assistant,
+	})
+}
+
+// GetList 获取当前空间的助手列表
+func (h *AssistantHandler) GetList(c *gin.Context) {
+	var query assistantListQuery
+	if err := c.ShouldBindQuery(&query); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "请求参数错误",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	assistants, total, err := h.assistantService.FindAll(middleware.GetPrincipal(c), query.Page, query.PageSize, query.Q)
+	if err != nil {
+		c.JSON(errorStatus(err, http.StatusInternalServerError), gin.H{
+			"error":   "获取助手列表失败",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data": gin.H{
+			"items":    assistants,
+			"total":    total,
+			"page":     query.Page,
+			"pageSize": query.PageSize,
+		},
+	})
+}
+
+// GetByID 获取助手
+func (h *AssistantHandler) GetByID(c *gin.Context) {
+	id, ok := parseIDParam(c, "无效的助手ID")
+	if !ok {
+		return
+	}
+
+	assistant, err := h.assistantService.FindByID(middleware.GetPrincipal(c), id)
+	if err != nil {
+		c.JSON(errorStatus(err, http.StatusInternalServerError), gin.H{
+			"error":   "获取助手失败",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data": assistant,
+	})
+}
+
+// Update 更新助手
+func (h *AssistantHandler) Update(c *gin.Context) {
+	id, ok := parseIDParam(c, "无效的助手ID")
+	if !ok {
+		return
+	}
+
+	var req service.UpdateAssistantRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "请求参数错误",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	assistant, err := h.assistantService.Update(middleware.GetPrincipal(c), id, &req)
+	if err != nil {
+		c.JSON(errorStatus(err, http.StatusInternalServerError), gin.H{
+			"error":   "更新助手失败",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data": assistant,
+	})
+}
+
+// Delete 删除助手
+func (h *AssistantHandler) Delete(c *gin.Context) {
+	id, ok := parseIDParam(c, "无效的助手ID")
+	if !ok {
+		return
+	}
+
+	if err := h.assistantService.Delete(middleware.GetPrincipal(c), id); err != nil {
+		c.JSON(errorStatus(err, http.StatusInternalServerError), gin.H{
+			"error":   "删除助手失败",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "助手已删除",
+	})
+}
+
+// Copy 将助手复制到当前空间
+func (h *AssistantHandler) Copy(c *gin.Context) {
+	id, ok := parseIDParam(c, "无效的助手ID")
+	if !ok {
+		return
+	}
+
+	assistant, err := h.assistantService.Copy(middleware.GetPrincipal(c), id)
+	if err != nil {
+		c.JSON(errorStatus(err, http.StatusInternalServerError), gin.H{
+			"error":   "复制助手失败",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"data": assistant,
+	})
+}
+
+// GetKnowledgeBases 获取助手关联的知识库
+func (h *AssistantHandler) GetKnowledgeBases(c *gin.Context) {
+	id, ok := parseIDParam(c, "无效的助手ID")
+	if !ok {
+		return
+	}
+
+	knowledgeBases, err := h.knowledgeBaseService.AssistantKnowledgeBases(middleware.GetPrincipal(c), id)
+	if err != nil {
+		c.JSON(errorStatus(err, http.StatusInternalServerError), gin.H{
+			"error":   "获取助手知识库失败",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data": gin.H{
+			"items": knowledgeBases,
+		},
+	})
+}
+
+// SetKnowledgeBases 设置助手关联的知识库
+func (h *AssistantHandler) SetKnowledgeBases(c *gin.Context) {
+	id, ok := parseIDParam(c, "无效的助手ID")
+	if !ok {
+		return
+	}
+
+	var req service.LinkKnowledgeBasesRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "请求参数错误",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	knowledgeBases, err := h.knowledgeBaseService.SetAssistantKnowledgeBases(middleware.GetPrincipal(c), id, req.KnowledgeBaseIDs)
+	if err != nil {
+		c.JSON(knowledgeBaseErrorStatus(err, http.StatusInternalServerError), gin.H{
+			"error":   "设置助手知识库失败",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data": gin.H{
+			"items": knowledgeBases,
+		},
+	})
+}
+
+// assistantErrorStatus 助手错误对应的状态码
+func assistantErrorStatus(err error, fallback int) int {
+	if errors.Is(err, service.ErrAssistantSpace) {
+		return http.StatusBadRequest
+	}
+	return errorStatus(err, fallback)
+}
@@ -0,0 +1,217 @@
+package handler
+
+import (
+	"ai-chat/internal/middleware"
+	"ai-chat/internal/service"
+	"errors"
+	"mime"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// AttachmentHandler 消息附件处理器
+type AttachmentHandler struct {
+	attachmentService *service.AttachmentService
+	maxSize           int64
+}
+
+// NewAttachmentHandler 创建消息附件处理器，maxSize 为单个文件大小上限
+func NewAttachmentHandler(attachmentService *service.AttachmentService, maxSize int64) *AttachmentHandler {
+	return &AttachmentHandler{
+		attachmentService: attachmentService,
+		maxSize:           maxSize,
+	}
+}
+
+// Upload 为用户消息上传附件，multipart 表单中的 files 字段可包含多个文件
+func (h *AttachmentHandler) Upload(c *gin.Context) {
+	messageID, ok := parseIDParam(c, "无效的消息ID")
+	if !ok {
+		return
+	}
+
+	// 预留 1MB 给表单的其他部分
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, h.maxSize*service.MaxAttachmentsPerMessage+1<<20)
+	form, err := c.MultipartForm()
+	if err != nil {
+		c.JSON(attachmentErrorStatus(err, http.StatusBadRequest), gin.H{
+			"error":   "请上传文件",
+			"details": err.Error(),
+		})
+		return
+	}
+	defer form.RemoveAll()
+
+	attachments, err := h.attachmentService.Upload(c.Request.Context(), middleware.GetPrincipal(c), messageID, form.File["files"])
+	if err != nil {
+		c.JSON(attachmentErrorStatus(err, http.StatusBadRequest), gin.H{
+			"error":   "上传附件失败",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"data": gin.H{
+			"items": attachments,
+		},
+	})
+}
+
+// GetByMessage 获取消息的附件列表
+func (h *AttachmentHandler) GetByMessage(c *gin.Context) {
+	messageID, ok := parseIDParam(c, "无效的消息ID")
+	if !ok {
+		return
+	}
+
+	attachments, err := h.attachmentService.ListByMessage(middleware.GetPrincipal(c), messageID)
+	if err != nil {
+		c.JSON(errorStatus(err, http.StatusInternalServerError), gin.H{
+			"error":   "获取附件列表失败",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data": gin.H{
+			"items": attachments,
+		},
+	})
+}
+
+// GetByConversation 获取会话中全部消息的附件列表
+func (h *AttachmentHandler) GetByConversation(c *gin.Context) {
+	conversationID, ok := parseConversationIDParam(c)
+	if !ok {
+		return
+	}
+
+	attachments, err := h.attachmentService.ListByConversation(middleware.GetPrincipal(c), conversationID)
+	if err != nil {
+		c.JSON(errorStatus(err, http.StatusInternalServerError), gin.H{
+			"error":   "获取附件列表失败",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data": gin.H{
+			"items": attachments,
+		},
+	})
+}
+
+// GetUsage 获取当前用户的附件存储用量
+func (h *AttachmentHandler) GetUsage(c *gin.Context) {
+	usage, err := h.attachmentService.Usage(middleware.GetUserID(c))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "获取存储用量失败",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data": usage,
+	})
+}
+
+// GetURL 获取有时效的附件下载链接
+func (h *AttachmentHandler) GetURL(c *gin.Context) {
+	id, ok := parseIDParam(c, "无效的附件ID")
+	if !ok {
+		return
+	}
+
+	link, err := h.attachmentService.SignedURL(middleware.GetPrincipal(c), id)
+	if err != nil {
+		c.JSON(errorStatus(err, http.StatusInternalServerError), gin.H{
+			"error":   "获取下载链接失败",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data": link,
+	})
+}
+
+// Download 通过签名链接下载附件，无需登录
+func (h *AttachmentHandler) Download(c *gin.Context) {
+	id, ok := parseIDParam(c, "无效的附件ID")
+	if !ok {
+		return
+	}
+
+	attachment, body, err := h.attachmentService.Open(c.Request.Context(), id, c.Query("expires"), c.Query("signature"))
+	if err != nil {
+		c.JSON(attachmentErrorStatus(err, http.StatusInternalServerError), gin.H{
+			"error":   "下载附件失败",
+			"details": err.Error(),
+		})
+		return
+	}
+	defer body.Close()
+
+	// 始终作为下载处理，避免上传的 HTML、SVG 等在站点域名下被浏览器执行
+	c.DataFromReader(http.StatusOK, attachment.Size, attachment.ContentType, body, map[string]string{
+		"Content-Disposition":     mime.FormatMediaType("attachment", map[string]string{"filename": attachment.FileName}),
+		"Content-Security-Policy": "default-src 'none'; sandbox",
+		"X-Content-Type-Options":  "nosniff",
+		"Cache-Control":           "private, no-store",
+	})
+}
+
+// Delete 删除附件
+func (h *AttachmentHandler) Delete(c *gin.Context) {
+	id, ok := parseIDParam(c, "无效的附件ID")
+	if !ok {
+		return
+	}
+
+	if err := h.attachmentService.Delete(c.Request.Context(), middleware.GetPrincipal(c), id); err != nil {
+		c.JSON(errorStatus(err, http.StatusInternalServerError), gin.H{
+			"error":   "删除附件失败",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "附件已删除",
+	})
+}
+
+// attachmentErrorStatus 附件错误对应的状态码
+func attachmentErrorStatus(err error, fallback int) int {
+	var maxBytesErr *http.MaxBytesError
+	switch {
+	case errors.Is(err, service.ErrAttachmentTooLarge), errors.Is(err, service.ErrAttachmentQuotaExceeded), errors.As(err, &maxBytesErr):
+		return http.StatusRequestEntityTooLarge
+	case errors.Is(err, service.ErrAttachmentTypeNotAllowed):
+		return http.StatusUnsupportedMediaType
+	case errors.Is(err, service.ErrAttachmentLinkInvalid):
+		return http.StatusForbidden
+	}
+	return errorStatus(err, fallback)
+}
+
+// parseIDParam 解析路径中的 id，失败时直接写入错误响应
+func parseIDParam(c *gin.Context, message string) (uint, bool) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   message,
+			"details": err.Error(),
+		})
+		return 0, false
+	}
+	return uint(id), true
+}
@@ -4,7 +4,10 @@ import (
 	"ai-chat/internal/common"
 	"ai-chat/internal/middleware"
 	"ai-chat/internal/service"
+	"errors"
+	"math"
 	"net/http"
+	"strconv"
 
 	"github.com/gin-gonic/gin"
 )
@@ -28,7 +31,7 @@ type RegisterRequest struct {
 
 // LoginRequest 登录请求
 type LoginRequest struct {
-	Email    string `json:"email" binding:"required,email"`
+	Email    string `json:"email" binding:"required,max=255"` // 邮箱，LDAP 账户也可以使用用户名
 	Password string `json:"password" binding:"required,min=6"`
 }
 
@@ -54,6 +57,57 @@ type AuthResponse struct {
 	ExpiresAt    int64        `json:"expiresAt"`
 }
 
+// MFAChallengeResponse 需要两步验证时的登录响应
+type MFAChallengeResponse struct {
+	MFARequired      bool   `json:"mfaRequired"`
+	MFAToken         string `json:"mfaToken"`
+	MFASetupRequired bool   `json:"mfaSetupRequired"` // 管理员要求启用两步验证但用户尚未设置
+	ExpiresAt        int64  `json:"expiresAt"`
+}
+
+// newAuthResponse 将服务层登录结果转换为响应
+func newAuthResponse(result *service.AuthResponse) AuthResponse {
+	return AuthResponse{
+		User: UserResponse{
+			ID:        result.User.ID,
+			Email:     result.User.Email,
+			Username:  result.User.Name,
+			CreatedAt: result.User.CreatedAt.Format(common.TimeLayout),
+			UpdatedAt: result.User.UpdatedAt.Format(common.TimeLayout),
+		},
+		AccessToken:  result.Token.AccessToken,
+		RefreshToken: result.Token.RefreshToken,
+		ExpiresAt:    result.Token.ExpiresAt.Unix(),
+	}
+}
+
+// clientInfo 获取请求方的客户端信息，记录到登录会话
+func clientInfo(c *gin.Context) service.ClientInfo {
+	return service.ClientInfo{
+		UserAgent: c.Request.UserAgent(),
+		IP:        c.ClientIP(),
+	}
+}
+
+// writeLoginResult 输出登录结果，需要两步验证时返回挑战信息
+func writeLoginResult(c *gin.Context, result *service.AuthResponse) {
+	if result.MFA != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"data": MFAChallengeResponse{
+				MFARequired:      true,
+				MFAToken:         result.MFA.Token,
+				MFASetupRequired: result.MFA.SetupRequired,
+				ExpiresAt:        result.MFA.ExpiresAt.Unix(),
+			},
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data": newAuthResponse(result),
+	})
+}
+
 // Register 用户注册
 func (h *AuthHandler) Register(c *gin.Context) {
 	var req RegisterRequest
@@ -69,6 +123,7 @@ func (h *AuthHandler) Register(c *gin.Context) {
 		Name:     req.Username,
 		Email:    req.Email,
 		Password: req.Password,
+		Client:   clientInfo(c),
 	}
 	result, err := h.authService.Register(serviceReq)
 	if err != nil {
@@ -80,18 +135,7 @@ func (h *AuthHandler) Register(c *gin.Context) {
 	}
 
 	c.JSON(http.StatusCreated, gin.H{
-		"data": AuthResponse{
-			User: UserResponse{
-				ID:        result.User.ID,
-				Email:     result.User.Email,
-				Username:  result.User.Name,
-				CreatedAt: result.User.CreatedAt.Format(common.TimeLayout),
-				UpdatedAt: result.User.UpdatedAt.Format(common.TimeLayout),
-			},
-			AccessToken:  result.Token.AccessToken,
-			RefreshToken: result.Token.RefreshToken,
-			ExpiresAt:    result.Token.ExpiresAt.Unix(),
-		},
+		"data": newAuthResponse(result),
 	})
 }
 
@@ -109,9 +153,19 @@ func (h *AuthHandler) Login(c *gin.Context) {
 	serviceReq := &service.LoginRequest{
 		Email:    req.Email,
 		Password: req.Password,
+		Client:   clientInfo(c),
 	}
 	result, err := h.authService.Login(serviceReq)
 	if err != nil {
+		var throttled *service.LoginThrottledError
+		if errors.As(err, &throttled) {
+			c.Header("Retry-After", strconv.Itoa(int(math.Ceil(throttled.RetryAfter.Seconds()))))
+			c.JSON(http.StatusTooManyRequests, gin.H{
+				"error":   "登录失败",
+				"details": throttled.Error(),
+			})
+			return
+		}
 		c.JSON(http.StatusUnauthorized, gin.H{
 			"error":   "登录失败",
 			"details": "邮箱或密码错误",
@@ -119,20 +173,7 @@ func (h *AuthHandler) Login(c *gin.Context) {
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{
-		"data": AuthResponse{
-			User: UserResponse{
-				ID:        result.User.ID,
-				Email:     result.User.Email,
-				Username:  result.User.Name,
-				CreatedAt: result.User.CreatedAt.Format(common.TimeLayout),
-				UpdatedAt: result.User.UpdatedAt.Format(common.TimeLayout),
-			},
-			AccessToken:  result.Token.AccessToken,
-			RefreshToken: result.Token.RefreshToken,
-			ExpiresAt:    result.Token.ExpiresAt.Unix(),
-		},
-	})
+	writeLoginResult(c, result)
 }
 
 // RefreshToken 刷新令牌
@@ -146,7 +187,7 @@ func (h *AuthHandler) RefreshToken(c *gin.Context) {
 		return
 	}
 
-	result, err := h.authService.RefreshToken(req.RefreshToken)
+	result, err := h.authService.RefreshToken(req.RefreshToken, clientInfo(c))
 	if err != nil {
 		c.JSON(http.StatusUnauthorized, gin.H{
 			"error":   "刷新令牌失败",
@@ -187,8 +228,45 @@ func (h *AuthHandler) GetProfile(c *gin.Context) {
 	})
 }
 
-// Logout 用户登出
+// UnlockAccount 管理员解除用户的登录锁定
+func (h *AuthHandler) UnlockAccount(c *gin.Context) {
+	adminID := middleware.GetUserID(c)
+
+	userID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "无效的用户ID",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	if err := h.authService.UnlockAccount(adminID, uint(userID), c.ClientIP()); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "解除锁定失败",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "已解除锁定",
+	})
+}
+
+// Logout 用户登出，注销当前会话
 func (h *AuthHandler) Logout(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+	sessionID := middleware.GetSessionID(c)
+
+	if err := h.authService.Logout(userID, sessionID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "登出失败",
+			"details": err.Error(),
+		})
+		return
+	}
+
 	c.JSON(http.StatusOK, gin.H{
 		"message": "登出成功",
 	})
@@ -0,0 +1,18 @@
+package handler
+
+import (
+	"ai-chat/internal/policy"
+	"errors"
+	"net/http"
+)
+
+// errorStatus 权限错误映射为 404/403，其他错误使用 fallback
+func errorStatus(err error, fallback int) int {
+	switch {
+	case errors.Is(err, policy.ErrNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, policy.ErrForbidden):
+		return http.StatusForbidden
+	}
+	return fallback
+}
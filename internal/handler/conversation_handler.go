@@ -5,10 +5,14 @@ import (
 	"ai-chat/internal/dto"
 	"ai-chat/internal/middleware"
 	"ai-chat/internal/service"
+	"encoding/json"
+	"fmt"
+	"io"
 	"net/http"
 	"strconv"
 
 	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
 )
 
 // ConversationHandler 对话处理器
@@ -39,16 +43,16 @@ func (h *ConversationHandler) Create(c *gin.Context) {
 		return
 	}
 
-	userID := middleware.GetUserID(c)
+	principal := middleware.GetPrincipal(c)
 
 	conversationReq := &service.CreateConversationRequest{
-		Name:   req.Name,
-		UserID: userID,
+		Name:        req.Name,
+		AssistantID: req.AssistantID,
 	}
 
-	conversation, err := h.conversationService.Create(conversationReq)
+	conversation, err := h.conversationService.Create(principal, conversationReq)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
+		c.JSON(assistantErrorStatus(err, http.StatusInternalServerError), gin.H{
 			"error":   "创建对话失败",
 			"details": err.Error(),
 		})
@@ -60,6 +64,7 @@ func (h *ConversationHandler) Create(c *gin.Context) {
 			ID:           conversation.ID,
 			Name:         conversation.Name,
 			UserID:       conversation.UserID,
+			WorkspaceID:  conversation.WorkspaceID,
 			IsActive:     conversation.IsActive,
 			SystemPrompt: conversation.SystemPrompt,
 			Model:        conversation.Model,
@@ -67,18 +72,20 @@ func (h *ConversationHandler) Create(c *gin.Context) {
 			CreatedAt:    conversation.CreatedAt.Format(common.TimeLayout),
 			UpdatedAt:    conversation.UpdatedAt.Format(common.TimeLayout),
 			Messages:     conversation.Messages,
+			Metadata:     conversation.Metadata,
+			AssistantID:  conversation.AssistantID,
 		},
 	})
 }
 
 // GetList 获取对话列表
 func (h *ConversationHandler) GetList(c *gin.Context) {
-	userID := middleware.GetUserID(c)
+	principal := middleware.GetPrincipal(c)
 	search := c.Query("search")
 
-	result, err := h.conversationService.FindByUserID(userID, search)
+	result, err := h.conversationService.List(principal, search)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
+		c.JSON(errorStatus(err, http.StatusInternalServerError), gin.H{
 			"error":   "获取对话列表失败",
 			"details": err.Error(),
 		})
@@ -92,6 +99,7 @@ func (h *ConversationHandler) GetList(c *gin.Context) {
 			ID:           item.ID,
 			Name:         item.Name,
 			UserID:       item.UserID,
+			WorkspaceID:  item.WorkspaceID,
 			IsActive:     item.IsActive,
 			SystemPrompt: item.SystemPrompt,
 			Model:        item.Model,
@@ -99,6 +107,8 @@ func (h *ConversationHandler) GetList(c *gin.Context) {
 			CreatedAt:    item.CreatedAt.Format(common.TimeLayout),
 			UpdatedAt:    item.UpdatedAt.Format(common.TimeLayout),
 			Messages:     item.Messages,
+			Metadata:     item.Metadata,
+			AssistantID:  item.AssistantID,
 		}
 	}
 
@@ -120,9 +130,9 @@ func (h *ConversationHandler) GetByID(c *gin.Context) {
 		return
 	}
 
-	userID := middleware.GetUserID(c)
+	principal := middleware.GetPrincipal(c)
 
-	conversation, err := h.conversationService.FindByID(userID, uint(conversationID))
+	conversation, err := h.conversationService.FindByID(principal, uint(conversationID))
 	if err != nil {
 		c.JSON(http.StatusNotFound, gin.H{
 			"error": "对话不存在",
@@ -131,10 +141,10 @@ func (h *ConversationHandler) GetByID(c *gin.Context) {
 	}
 
 	// 获取消息列表
-	messages, err := h.messageService.FindByConversationID(userID, uint(conversationID))
+	messages, err := h.messageService.FindByConversationID(principal, uint(conversationID))
 
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
+		c.JSON(errorStatus(err, http.StatusInternalServerError), gin.H{
 			"error":   "获取消息列表失败",
 			"details": err.Error(),
 		})
@@ -147,6 +157,7 @@ func (h *ConversationHandler) GetByID(c *gin.Context) {
 				ID:           conversation.ID,
 				Name:         conversation.Name,
 				UserID:       conversation.UserID,
+				WorkspaceID:  conversation.WorkspaceID,
 				IsActive:     conversation.IsActive,
 				SystemPrompt: conversation.SystemPrompt,
 				Model:        conversation.Model,
@@ -154,12 +165,121 @@ func (h *ConversationHandler) GetByID(c *gin.Context) {
 				CreatedAt:    conversation.CreatedAt.Format(common.TimeLayout),
 				UpdatedAt:    conversation.UpdatedAt.Format(common.TimeLayout),
 				Messages:     conversation.Messages,
+				Metadata:     conversation.Metadata,
+				AssistantID:  conversation.AssistantID,
 			},
 			"messages": messages,
 		},
 	})
 }
 
+// maxImportFileSize 导入文件大小上限
+const maxImportFileSize = 10 << 20
+
+// Import 导入会话，支持 JSON 请求体（来源会话ID或会话内容）和 multipart 上传的 JSON 文件
+func (h *ConversationHandler) Import(c *gin.Context) {
+	var req service.ImportConversationRequest
+	if c.ContentType() == binding.MIMEMultipartPOSTForm {
+		imported, err := readImportFile(c)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error":   "导入文件无效",
+				"details": err.Error(),
+			})
+			return
+		}
+		req.Conversation = imported
+		if name := c.PostForm("name"); name != "" {
+			req.Name = &name
+		}
+	} else if err := bindImportJSON(c, &req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "请求参数错误",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	conversation, err := h.conversationService.Import(middleware.GetPrincipal(c), &req)
+	if err != nil {
+		c.JSON(errorStatus(err, http.StatusBadRequest), gin.H{
+			"error":   "导入对话失败",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"data": dto.ConversationResponse{
+			ID:           conversation.ID,
+			Name:         conversation.Name,
+			UserID:       conversation.UserID,
+			WorkspaceID:  conversation.WorkspaceID,
+			IsActive:     conversation.IsActive,
+			SystemPrompt: conversation.SystemPrompt,
+			Model:        conversation.Model,
+			Temperature:  conversation.Temperature,
+			CreatedAt:    conversation.CreatedAt.Format(common.TimeLayout),
+			UpdatedAt:    conversation.UpdatedAt.Format(common.TimeLayout),
+			Messages:     conversation.Messages,
+			Metadata:     conversation.Metadata,
+			AssistantID:  conversation.AssistantID,
+		},
+	})
+}
+
+// bindImportJSON 解析导入请求体，除 {"conversation": ...} 外也接受直接提交的导出 JSON
+func bindImportJSON(c *gin.Context, req *service.ImportConversationRequest) error {
+	body, err := io.ReadAll(io.LimitReader(c.Request.Body, maxImportFileSize+1))
+	if err != nil {
+		return err
+	}
+	if len(body) > maxImportFileSize {
+		return fmt.Errorf("请求体不能超过 %d MB", maxImportFileSize>>20)
+	}
+	if err := json.Unmarshal(body, req); err != nil {
+		return err
+	}
+
+	if req.ConversationID == nil && req.Conversation == nil {
+		var imported service.ImportedConversation
+		if err := json.Unmarshal(body, &imported); err != nil {
+			return err
+		}
+		if imported.Messages != nil {
+			// 导出 JSON 顶层的 name 是会话名称，不作为重命名参数
+			req.Conversation, req.Name = &imported, nil
+		}
+	}
+	return binding.Validator.ValidateStruct(req)
+}
+
+// readImportFile 读取并校验上传的会话 JSON 文件
+func readImportFile(c *gin.Context) (*service.ImportedConversation, error) {
+	header, err := c.FormFile("file")
+	if err != nil {
+		return nil, err
+	}
+	if header.Size > maxImportFileSize {
+		return nil, fmt.Errorf("文件不能超过 %d MB", maxImportFileSize>>20)
+	}
+
+	file, err := header.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var imported service.ImportedConversation
+	if err := json.NewDecoder(io.LimitReader(file, maxImportFileSize)).Decode(&imported); err != nil {
+		return nil, fmt.Errorf("解析 JSON 失败: %w", err)
+	}
+	if err := binding.Validator.ValidateStruct(&imported); err != nil {
+		return nil, err
+	}
+	return &imported, nil
+}
+
 // Update 更新对话
 func (h *ConversationHandler) Update(c *gin.Context) {
 	idParam := c.Param("id")
@@ -180,15 +300,15 @@ func (h *ConversationHandler) Update(c *gin.Context) {
 		return
 	}
 
-	userID := middleware.GetUserID(c)
+	principal := middleware.GetPrincipal(c)
 
 	// 更新对话
 	updateReq := &service.UpdateConversationRequest{
 		Name: req.Name,
 	}
-	conversation, err := h.conversationService.Update(userID, uint(conversationID), updateReq)
+	conversation, err := h.conversationService.Update(principal, uint(conversationID), updateReq)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
+		c.JSON(errorStatus(err, http.StatusInternalServerError), gin.H{
 			"error":   "更新对话失败",
 			"details": err.Error(),
 		})
@@ -200,6 +320,7 @@ func (h *ConversationHandler) Update(c *gin.Context) {
 			ID:           conversation.ID,
 			Name:         conversation.Name,
 			UserID:       conversation.UserID,
+			WorkspaceID:  conversation.WorkspaceID,
 			IsActive:     conversation.IsActive,
 			SystemPrompt: conversation.SystemPrompt,
 			Model:        conversation.Model,
@@ -207,6 +328,8 @@ func (h *ConversationHandler) Update(c *gin.Context) {
 			CreatedAt:    conversation.CreatedAt.Format(common.TimeLayout),
 			UpdatedAt:    conversation.UpdatedAt.Format(common.TimeLayout),
 			Messages:     conversation.Messages,
+			Metadata:     conversation.Metadata,
+			AssistantID:  conversation.AssistantID,
 		},
 	})
 }
@@ -222,12 +345,12 @@ func (h *ConversationHandler) Delete(c *gin.Context) {
 		return
 	}
 
-	userID := middleware.GetUserID(c)
+	principal := middleware.GetPrincipal(c)
 
 	// 删除对话
-	err = h.conversationService.Delete(userID, uint(conversationID))
+	err = h.conversationService.Delete(principal, uint(conversationID))
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
+		c.JSON(errorStatus(err, http.StatusInternalServerError), gin.H{
 			"error":   "删除对话失败",
 			"details": err.Error(),
 		})
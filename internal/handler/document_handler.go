@@ -0,0 +1,243 @@
+package handler
+
+import (
+	"ai-chat/internal/docparse"
+	"ai-chat/internal/middleware"
+	"ai-chat/internal/service"
+	"errors"
+	"io"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// DocumentHandler 文档处理器
+type DocumentHandler struct {
+	documentService *service.DocumentService
+	maxSize         int64
+}
+
+// NewDocumentHandler 创建文档处理器，maxSize 为上传文档大小上限
+func NewDocumentHandler(documentService *service.DocumentService, maxSize int64) *DocumentHandler {
+	return &DocumentHandler{
+		documentService: documentService,
+		maxSize:         maxSize,
+	}
+}
+
+// documentListQuery 文档列表查询参数
+type documentListQuery struct {
+	Q        string `form:"q"`
+	Page     int    `form:"page,default=1" binding:"min=1"`
+	PageSize int    `form:"page_size,default=20" binding:"min=1,max=100"`
+}
+
+// documentChunksQuery 文档片段查询参数，page_number 为文档页码
+type documentChunksQuery struct {
+	PageNumber int `form:"page_number" binding:"min=0"`
+	Page       int `form:"page,default=1" binding:"min=1"`
+	PageSize   int `form:"page_size,default=50" binding:"min=1,max=200"`
+}
+
+// Upload 上传文档，multipart 表单中的 file 字段为文档文件，解析完成后返回
+func (h *DocumentHandler) Upload(c *gin.Context) {
+	// 预留 1MB 给表单的其他部分
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, h.maxSize+1<<20)
+	file, err := c.FormFile("file")
+	if err != nil {
+		c.JSON(documentErrorStatus(err, http.StatusBadRequest), gin.H{
+			"error":   "请上传文档",
+			"details": err.Error(),
+		})
+		return
+	}
+	if file.Size > h.maxSize {
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{
+			"error":   "上传文档失败",
+			"details": service.ErrDocumentTooLarge.Error(),
+		})
+		return
+	}
+
+	f, err := file.Open()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "读取文档失败",
+			"details": err.Error(),
+		})
+		return
+	}
+	defer f.Close()
+
+	data, err := io.ReadAll(f)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "读取文档失败",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	document, err := h.documentService.Upload(middleware.GetPrincipal(c), file.Filename, data)
+	if err != nil {
+		c.JSON(documentErrorStatus(err, http.StatusInternalServerError), gin.H{
+			"error":   "上传文档失败",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"data": document,
+	})
+}
+
+// GetList 获取当前空间的文档列表
+func (h *DocumentHandler) GetList(c *gin.Context) {
+	var query documentListQuery
+	if err := c.ShouldBindQuery(&query); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "请求参数错误",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	documents, total, err := h.documentService.FindAll(middleware.GetPrincipal(c), query.Page, query.PageSize, query.Q)
+	if err != nil {
+		c.JSON(errorStatus(err, http.StatusInternalServerError), gin.H{
+			"error":   "获取文档列表失败",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data": gin.H{
+			"items":    documents,
+			"total":    total,
+			"page":     query.Page,
+			"pageSize": query.PageSize,
+		},
+	})
+}
+
+// GetByID 获取文档信息
+func (h *DocumentHandler) GetByID(c *gin.Context) {
+	id, ok := parseIDParam(c, "无效的文档ID")
+	if !ok {
+		return
+	}
+
+	document, err := h.documentService.FindByID(middleware.GetPrincipal(c), id)
+	if err != nil {
+		c.JSON(errorStatus(err, http.StatusInternalServerError), gin.H{
+			"error":   "获取文档失败",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data": document,
+	})
+}
+
+// GetChunks 按顺序获取文档片段
+func (h *DocumentHandler) GetChunks(c *gin.Context) {
+	id, ok := parseIDParam(c, "无效的文档ID")
+	if !ok {
+		return
+	}
+
+	var query documentChunksQuery
+	if err := c.ShouldBindQuery(&query); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "请求参数错误",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	chunks, total, err := h.documentService.FindChunks(middleware.GetPrincipal(c), id, query.PageNumber, query.Page, query.PageSize)
+	if err != nil {
+		c.JSON(errorStatus(err, http.StatusInternalServerError), gin.H{
+			"error":   "获取文档片段失败",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data": gin.H{
+			"items":    chunks,
+			"total":    total,
+			"page":     query.Page,
+			"pageSize": query.PageSize,
+		},
+	})
+}
+
+// Search 检索当前空间的文档片段
+func (h *DocumentHandler) Search(c *gin.Context) {
+	var query service.DocumentSearchQuery
+	if err := c.ShouldBindQuery(&query); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "请求参数错误",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	results, total, err := h.documentService.Search(middleware.GetPrincipal(c), &query)
+	if err != nil {
+		c.JSON(errorStatus(err, http.StatusInternalServerError), gin.H{
+			"error":   "检索文档失败",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data": gin.H{
+			"items":    results,
+			"total":    total,
+			"page":     query.Page,
+			"pageSize": query.PageSize,
+		},
+	})
+}
+
+// Delete 删除文档
+func (h *DocumentHandler) Delete(c *gin.Context) {
+	id, ok := parseIDParam(c, "无效的文档ID")
+	if !ok {
+		return
+	}
+
+	if err := h.documentService.Delete(middleware.GetPrincipal(c), id); err != nil {
+		c.JSON(errorStatus(err, http.StatusInternalServerError), gin.H{
+			"error":   "删除文档失败",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "文档已删除",
+	})
+}
+
+// documentErrorStatus 文档错误对应的状态码
+func documentErrorStatus(err error, fallback int) int {
+	var maxBytesErr *http.MaxBytesError
+	switch {
+	case errors.Is(err, service.ErrDocumentTooLarge), errors.As(err, &maxBytesErr):
+		return http.StatusRequestEntityTooLarge
+	case errors.Is(err, docparse.ErrUnsupportedFormat):
+		return http.StatusUnsupportedMediaType
+	case errors.Is(err, service.ErrDocumentParse):
+		return http.StatusUnprocessableEntity
+	}
+	return errorStatus(err, fallback)
+}
@@ -0,0 +1,83 @@
+package handler
+
+import (
+	"ai-chat/internal/middleware"
+	"ai-chat/internal/service"
+	"errors"
+	"mime"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ExportHandler 会话导出处理器
+type ExportHandler struct {
+	exportService *service.ExportService
+}
+
+// NewExportHandler 创建会话导出处理器
+func NewExportHandler(exportService *service.ExportService) *ExportHandler {
+	return &ExportHandler{exportService: exportService}
+}
+
+// Export 导出单个会话，format 可选 md json html jsonl，include_reasoning 控制是否包含思考内容
+func (h *ExportHandler) Export(c *gin.Context) {
+	conversationID, ok := parseConversationIDParam(c)
+	if !ok {
+		return
+	}
+
+	var opts service.ExportOptions
+	if err := c.ShouldBindQuery(&opts); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "请求参数错误",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	file, err := h.exportService.Export(middleware.GetPrincipal(c), conversationID, &opts)
+	if err != nil {
+		status := errorStatus(err, http.StatusInternalServerError)
+		if errors.Is(err, service.ErrNoFineTuningData) {
+			status = http.StatusBadRequest
+		}
+		c.JSON(status, gin.H{
+			"error":   "导出对话失败",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	sendExportFile(c, file)
+}
+
+// ExportAccount 将当前用户的全部会话导出为 zip
+func (h *ExportHandler) ExportAccount(c *gin.Context) {
+	var opts service.ExportOptions
+	if err := c.ShouldBindQuery(&opts); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "请求参数错误",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	file, err := h.exportService.ExportAccount(middleware.GetPrincipal(c), &opts)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "导出账户数据失败",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	sendExportFile(c, file)
+}
+
+// sendExportFile 以附件形式返回导出文件
+func sendExportFile(c *gin.Context, file *service.ExportFile) {
+	c.Header("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": file.Name}))
+	c.Header("X-Content-Type-Options", "nosniff")
+	c.Data(http.StatusOK, file.ContentType, file.Data)
+}
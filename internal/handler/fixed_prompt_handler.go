@@ -4,6 +4,7 @@ import (
 	"ai-chat/internal/dto"
 	"ai-chat/internal/middleware"
 	"ai-chat/internal/service"
+	"errors"
 	"net/http"
 	"strconv"
 
@@ -33,10 +34,10 @@ func (h *FixedPromptHandler) Create(c *gin.Context) {
 		return
 	}
 
-	userID := middleware.GetUserID(c)
-	response, err := h.fixedPromptService.Create(userID, &req)
+	principal := middleware.GetPrincipal(c)
+	response, err := h.fixedPromptService.Create(principal, &req)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
+		c.JSON(fixedPromptErrorStatus(err, http.StatusInternalServerError), gin.H{
 			"code":  500,
 			"error": "创建固定提示词失败: " + err.Error(),
 		})
@@ -60,10 +61,10 @@ func (h *FixedPromptHandler) GetList(c *gin.Context) {
 		return
 	}
 
-	userID := middleware.GetUserID(c)
-	result, err := h.fixedPromptService.FindAll(userID, req.Page, req.PageSize, req.Search)
+	principal := middleware.GetPrincipal(c)
+	result, err := h.fixedPromptService.FindAll(principal, req.Page, req.PageSize, req.Search)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
+		c.JSON(errorStatus(err, http.StatusInternalServerError), gin.H{
 			"code":  500,
 			"error": "获取固定提示词列表失败: " + err.Error(),
 		})
@@ -88,8 +89,8 @@ func (h *FixedPromptHandler) GetByID(c *gin.Context) {
 		return
 	}
 
-	userID := middleware.GetUserID(c)
-	response, err := h.fixedPromptService.FindByID(userID, uint(id))
+	principal := middleware.GetPrincipal(c)
+	response, err := h.fixedPromptService.FindByID(principal, uint(id))
 	if err != nil {
 		c.JSON(http.StatusNotFound, gin.H{
 			"code":  404,
@@ -125,10 +126,10 @@ func (h *FixedPromptHandler) Update(c *gin.Context) {
 		return
 	}
 
-	userID := middleware.GetUserID(c)
-	response, err := h.fixedPromptService.Update(userID, uint(id), &req)
+	principal := middleware.GetPrincipal(c)
+	response, err := h.fixedPromptService.Update(principal, uint(id), &req)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
+		c.JSON(fixedPromptErrorStatus(err, http.StatusInternalServerError), gin.H{
 			"code":  500,
 			"error": "更新固定提示词失败或无权更新: " + err.Error(),
 		})
@@ -153,10 +154,10 @@ func (h *FixedPromptHandler) Delete(c *gin.Context) {
 		return
 	}
 
-	userID := middleware.GetUserID(c)
-	err = h.fixedPromptService.Delete(userID, uint(id))
+	principal := middleware.GetPrincipal(c)
+	err = h.fixedPromptService.Delete(principal, uint(id))
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
+		c.JSON(errorStatus(err, http.StatusInternalServerError), gin.H{
 			"code":  500,
 			"error": "删除固定提示词失败或无权删除: " + err.Error(),
 		})
@@ -168,3 +169,143 @@ func (h *FixedPromptHandler) Delete(c *gin.Context) {
 		"message": "删除成功",
 	})
 }
+
+// GetVersions 获取固定提示词的版本历史
+func (h *FixedPromptHandler) GetVersions(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code":  400,
+			"error": "无效的ID格式",
+		})
+		return
+	}
+
+	principal := middleware.GetPrincipal(c)
+	versions, err := h.fixedPromptService.Versions(principal, uint(id))
+	if err != nil {
+		c.JSON(errorStatus(err, http.StatusInternalServerError), gin.H{
+			"code":  500,
+			"error": "获取提示词版本失败: " + err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"code": 200,
+		"data": gin.H{
+			"items": versions,
+		},
+	})
+}
+
+// GetVersion 获取固定提示词的指定版本
+func (h *FixedPromptHandler) GetVersion(c *gin.Context) {
+	id, version, ok := parseFixedPromptVersion(c)
+	if !ok {
+		return
+	}
+
+	principal := middleware.GetPrincipal(c)
+	response, err := h.fixedPromptService.Version(principal, id, version)
+	if err != nil {
+		c.JSON(errorStatus(err, http.StatusInternalServerError), gin.H{
+			"code":  500,
+			"error": "获取提示词版本失败: " + err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"code": 200,
+		"data": response,
+	})
+}
+
+// Diff 对比固定提示词的两个版本
+func (h *FixedPromptHandler) Diff(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code":  400,
+			"error": "无效的ID格式",
+		})
+		return
+	}
+
+	var req dto.FixedPromptDiffRequest
+	if err = c.ShouldBindQuery(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code":  400,
+			"error": "请求参数错误: " + err.Error(),
+		})
+		return
+	}
+
+	principal := middleware.GetPrincipal(c)
+	response, err := h.fixedPromptService.Diff(principal, uint(id), req.From, req.To)
+	if err != nil {
+		c.JSON(errorStatus(err, http.StatusInternalServerError), gin.H{
+			"code":  500,
+			"error": "对比提示词版本失败: " + err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"code": 200,
+		"data": response,
+	})
+}
+
+// Rollback 将固定提示词回滚到指定版本
+func (h *FixedPromptHandler) Rollback(c *gin.Context) {
+	id, version, ok := parseFixedPromptVersion(c)
+	if !ok {
+		return
+	}
+
+	principal := middleware.GetPrincipal(c)
+	response, err := h.fixedPromptService.Rollback(principal, id, version)
+	if err != nil {
+		c.JSON(errorStatus(err, http.StatusInternalServerError), gin.H{
+			"code":  500,
+			"error": "回滚固定提示词失败或无权更新: " + err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"code": 200,
+		"data": response,
+	})
+}
+
+// parseFixedPromptVersion 解析路径中的提示词ID和版本号，无效时直接写入错误响应
+func parseFixedPromptVersion(c *gin.Context) (uint, int, bool) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code":  400,
+			"error": "无效的ID格式",
+		})
+		return 0, 0, false
+	}
+	version, err := strconv.Atoi(c.Param("version"))
+	if err != nil || version < 1 {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code":  400,
+			"error": "无效的版本号",
+		})
+		return 0, 0, false
+	}
+	return uint(id), version, true
+}
+
+// fixedPromptErrorStatus 固定提示词错误对应的状态码
+func fixedPromptErrorStatus(err error, fallback int) int {
+	if errors.Is(err, service.ErrPromptTemplate) {
+		return http.StatusBadRequest
+	}
+	return errorStatus(err, fallback)
+}
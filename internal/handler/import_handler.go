@@ -0,0 +1,85 @@
+package handler
+
+import (
+	"ai-chat/internal/middleware"
+	"ai-chat/internal/service"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// maxHistoryImportSize 历史记录导入文件大小上限，更大的导出请使用 cmd/import-history 命令行工具
+const maxHistoryImportSize = 100 << 20
+
+// maxHistoryImportUnzippedSize 压缩包解压后的总大小上限，导入在请求内同步完成，限制单个请求占用的内存
+const maxHistoryImportUnzippedSize = 256 << 20
+
+// ImportHandler 历史记录导入处理器
+type ImportHandler struct {
+	historyImportService *service.HistoryImportService
+}
+
+// NewImportHandler 创建历史记录导入处理器
+func NewImportHandler(historyImportService *service.HistoryImportService) *ImportHandler {
+	return &ImportHandler{historyImportService: historyImportService}
+}
+
+// ImportHistoryRequest 历史记录导入请求，文件通过 multipart 的 file 字段上传
+type ImportHistoryRequest struct {
+	Source string `form:"source,default=auto" binding:"oneof=auto chatgpt claude native"`
+}
+
+// Import 导入 ChatGPT、Claude 或本系统的导出文件，返回每个会话的导入结果
+func (h *ImportHandler) Import(c *gin.Context) {
+	var req ImportHistoryRequest
+	if err := c.ShouldBind(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "请求参数错误",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	data, err := readHistoryFile(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "导入文件无效",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	report, err := h.historyImportService.Import(middleware.GetPrincipal(c), data, req.Source, maxHistoryImportUnzippedSize, nil)
+	if err != nil {
+		c.JSON(errorStatus(err, http.StatusBadRequest), gin.H{
+			"error":   "导入历史记录失败",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data": report,
+	})
+}
+
+// readHistoryFile 读取上传的导出文件
+func readHistoryFile(c *gin.Context) ([]byte, error) {
+	header, err := c.FormFile("file")
+	if err != nil {
+		return nil, err
+	}
+	if header.Size > maxHistoryImportSize {
+		return nil, fmt.Errorf("文件不能超过 %d MB", maxHistoryImportSize>>20)
+	}
+
+	file, err := header.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	return io.ReadAll(io.LimitReader(file, maxHistoryImportSize))
+}
@@ -0,0 +1,388 @@
+package handler
+
+import (
+	"ai-chat/internal/middleware"
+	"ai-chat/internal/service"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// KnowledgeBaseHandler 知识库处理器
+type KnowledgeBaseHandler struct {
+	knowledgeBaseService *service.KnowledgeBaseService
+}
+
+// NewKnowledgeBaseHandler 创建知识库处理器
+func NewKnowledgeBaseHandler(knowledgeBaseService *service.KnowledgeBaseService) *KnowledgeBaseHandler {
+	return &KnowledgeBaseHandler{
+		knowledgeBaseService: knowledgeBaseService,
+	}
+}
+
+// knowledgeBaseListQuery 知识库列表查询参数
+type knowledgeBaseListQuery struct {
+	Q        string `form:"q"`
+	Page     int    `form:"page,default=1" binding:"min=1"`
+	PageSize int    `form:"page_size,default=20" binding:"min=1,max=100"`
+}
+
+// Create 创建知识库
+func (h *KnowledgeBaseHandler) Create(c *gin.Context) {
+	var req service.CreateKnowledgeBaseRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "请求参数错误",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	knowledgeBase, err := h.knowledgeBaseService.Create(middleware.GetPrincipal(c), &req)
+	if err != nil {
+		c.JSON(errorStatus(err, http.StatusInternalServerError), gin.H{
+			"error":   "创建知识库失败",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"data": knowledgeBase,
+	})
+}
+
+// GetList 获取当前空间的知识库列表
+func (h *KnowledgeBaseHandler) GetList(c *gin.Context) {
+	var query knowledgeBaseListQuery
+	if err := c.ShouldBindQuery(&query); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "请求参数错误",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	knowledgeBases, total, err := h.knowledgeBaseService.FindAll(middleware.GetPrincipal(c), query.Page, query.PageSize, query.Q)
+	if err != nil {
+		c.JSON(errorStatus(err, http.StatusInternalServerError), gin.H{
+			"error":   "获取知识库列表失败",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data": gin.H{
+			"items":    knowledgeBases,
+			"total":    total,
+			"page":     query.Page,
+			"pageSize": query.PageSize,
+		},
+	})
+}
+
+// GetByID 获取知识库
+func (h *KnowledgeBaseHandler) GetByID(c *gin.Context) {
+	id, ok := parseIDParam(c, "无效的知识库ID")
+	if !ok {
+		return
+	}
+
+	knowledgeBase, err := h.knowledgeBaseService.FindByID(middleware.GetPrincipal(c), id)
+	if err != nil {
+		c.JSON(errorStatus(err, http.StatusInternalServerError), gin.H{
+			"error":   "获取知识库失败",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data": knowledgeBase,
+	})
+}
+
+// Update 更新知识库
+func (h *KnowledgeBaseHandler) Update(c *gin.Context) {
+	id, ok := parseIDParam(c, "无效的知识库ID")
+	if !ok {
+		return
+	}
+
+	var req service.UpdateKnowledgeBaseRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "请求参数错误",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	knowledgeBase, err := h.knowledgeBaseService.Update(middleware.GetPrincipal(c), id, &req)
+	if err != nil {
+		c.JSON(errorStatus(err, http.StatusInternalServerError), gin.H{
+			"error":   "更新知识库失败",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data": knowledgeBase,
+	})
+}
+
+// Delete 删除知识库
+func (h *KnowledgeBaseHandler) Delete(c *gin.Context) {
+	id, ok := parseIDParam(c, "无效的知识库ID")
+	if !ok {
+		return
+	}
+
+	if err := h.knowledgeBaseService.Delete(middleware.GetPrincipal(c), id); err != nil {
+		c.JSON(errorStatus(err, http.StatusInternalServerError), gin.H{
+			"error":   "删除知识库失败",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "知识库已删除",
+	})
+}
+
+// GetDocuments 获取知识库中的文档
+func (h *KnowledgeBaseHandler) GetDocuments(c *gin.Context) {
+	id, ok := parseIDParam(c, "无效的知识库ID")
+	if !ok {
+		return
+	}
+
+	documents, err := h.knowledgeBaseService.Documents(middleware.GetPrincipal(c), id)
+	if err != nil {
+		c.JSON(errorStatus(err, http.StatusInternalServerError), gin.H{
+			"error":   "获取知识库文档失败",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data": gin.H{
+			"items": documents,
+		},
+	})
+}
+
+// AddDocuments 向知识库添加文档
+func (h *KnowledgeBaseHandler) AddDocuments(c *gin.Context) {
+	id, ok := parseIDParam(c, "无效的知识库ID")
+	if !ok {
+		return
+	}
+
+	var req service.KnowledgeBaseDocumentsRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "请求参数错误",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	documents, err := h.knowledgeBaseService.AddDocuments(middleware.GetPrincipal(c), id, req.DocumentIDs)
+	if err != nil {
+		c.JSON(knowledgeBaseErrorStatus(err, http.StatusInternalServerError), gin.H{
+			"error":   "添加知识库文档失败",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data": gin.H{
+			"items": documents,
+		},
+	})
+}
+
+// RemoveDocument 从知识库中移除文档
+func (h *KnowledgeBaseHandler) RemoveDocument(c *gin.Context) {
+	id, ok := parseIDParam(c, "无效的知识库ID")
+	if !ok {
+		return
+	}
+	documentID, err := strconv.ParseUint(c.Param("documentId"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "无效的文档ID",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	if err := h.knowledgeBaseService.RemoveDocument(middleware.GetPrincipal(c), id, uint(documentID)); err != nil {
+		c.JSON(errorStatus(err, http.StatusInternalServerError), gin.H{
+			"error":   "移除知识库文档失败",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "文档已从知识库移除",
+	})
+}
+
+// Retrieve 按问题检索知识库，返回对话中会引用的片段，用于调试检索效果
+func (h *KnowledgeBaseHandler) Retrieve(c *gin.Context) {
+	id, ok := parseIDParam(c, "无效的知识库ID")
+	if !ok {
+		return
+	}
+
+	q := c.Query("q")
+	if q == "" {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "请输入检索内容",
+		})
+		return
+	}
+
+	citations, err := h.knowledgeBaseService.Preview(middleware.GetPrincipal(c), id, q)
+	if err != nil {
+		c.JSON(errorStatus(err, http.StatusInternalServerError), gin.H{
+			"error":   "检索知识库失败",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data": gin.H{
+			"items": citations,
+		},
+	})
+}
+
+// GetConversationKnowledgeBases 获取会话关联的知识库
+func (h *KnowledgeBaseHandler) GetConversationKnowledgeBases(c *gin.Context) {
+	id, ok := parseIDParam(c, "无效的会话ID")
+	if !ok {
+		return
+	}
+
+	knowledgeBases, err := h.knowledgeBaseService.ConversationKnowledgeBases(middleware.GetPrincipal(c), id)
+	if err != nil {
+		c.JSON(errorStatus(err, http.StatusInternalServerError), gin.H{
+			"error":   "获取会话知识库失败",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data": gin.H{
+			"items": knowledgeBases,
+		},
+	})
+}
+
+// SetConversationKnowledgeBases 设置会话关联的知识库
+func (h *KnowledgeBaseHandler) SetConversationKnowledgeBases(c *gin.Context) {
+	id, ok := parseIDParam(c, "无效的会话ID")
+	if !ok {
+		return
+	}
+
+	var req service.LinkKnowledgeBasesRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "请求参数错误",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	knowledgeBases, err := h.knowledgeBaseService.SetConversationKnowledgeBases(middleware.GetPrincipal(c), id, req.KnowledgeBaseIDs)
+	if err != nil {
+		c.JSON(knowledgeBaseErrorStatus(err, http.StatusInternalServerError), gin.H{
+			"error":   "设置会话知识库失败",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data": gin.H{
+			"items": knowledgeBases,
+		},
+	})
+}
+
+// GetFixedPromptKnowledgeBases 获取固定提示词关联的知识库
+func (h *KnowledgeBaseHandler) GetFixedPromptKnowledgeBases(c *gin.Context) {
+	id, ok := parseIDParam(c, "无效的固定提示词ID")
+	if !ok {
+		return
+	}
+
+	knowledgeBases, err := h.knowledgeBaseService.FixedPromptKnowledgeBases(middleware.GetPrincipal(c), id)
+	if err != nil {
+		c.JSON(errorStatus(err, http.StatusInternalServerError), gin.H{
+			"error":   "获取提示词知识库失败",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data": gin.H{
+			"items": knowledgeBases,
+		},
+	})
+}
+
+// SetFixedPromptKnowledgeBases 设置固定提示词关联的知识库
+func (h *KnowledgeBaseHandler) SetFixedPromptKnowledgeBases(c *gin.Context) {
+	id, ok := parseIDParam(c, "无效的固定提示词ID")
+	if !ok {
+		return
+	}
+
+	var req service.LinkKnowledgeBasesRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "请求参数错误",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	knowledgeBases, err := h.knowledgeBaseService.SetFixedPromptKnowledgeBases(middleware.GetPrincipal(c), id, req.KnowledgeBaseIDs)
+	if err != nil {
+		c.JSON(knowledgeBaseErrorStatus(err, http.StatusInternalServerError), gin.H{
+			"error":   "设置提示词知识库失败",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data": gin.H{
+			"items": knowledgeBases,
+		},
+	})
+}
+
+// knowledgeBaseErrorStatus 知识库错误对应的状态码
+func knowledgeBaseErrorStatus(err error, fallback int) int {
+	if errors.Is(err, service.ErrKnowledgeBaseSpace) {
+		return http.StatusBadRequest
+	}
+	return errorStatus(err, fallback)
+}
@@ -0,0 +1,252 @@
+package handler
+
+import (
+	"ai-chat/internal/middleware"
+	"ai-chat/internal/service"
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// MemoryHandler 长期记忆处理器
+type MemoryHandler struct {
+	memoryService *service.MemoryService
+}
+
+// NewMemoryHandler 创建长期记忆处理器
+func NewMemoryHandler(memoryService *service.MemoryService) *MemoryHandler {
+	return &MemoryHandler{
+		memoryService: memoryService,
+	}
+}
+
+// memoryListQuery 记忆列表查询参数
+type memoryListQuery struct {
+	Q        string `form:"q"`
+	Page     int    `form:"page,default=1" binding:"min=1"`
+	PageSize int    `form:"page_size,default=50" binding:"min=1,max=200"`
+}
+
+// GetSettings 获取当前用户的记忆设置
+func (h *MemoryHandler) GetSettings(c *gin.Context) {
+	settings, err := h.memoryService.Settings(middleware.GetUserID(c))
+	if err != nil {
+		c.JSON(errorStatus(err, http.StatusInternalServerError), gin.H{
+			"error":   "获取记忆设置失败",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data": settings,
+	})
+}
+
+// UpdateSettings 开启或关闭记忆
+func (h *MemoryHandler) UpdateSettings(c *gin.Context) {
+	var req service.MemorySettingsRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "请求参数错误",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	settings, err := h.memoryService.UpdateSettings(middleware.GetUserID(c), &req)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "更新记忆设置失败",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data": settings,
+	})
+}
+
+// GetList 获取当前用户的记忆
+func (h *MemoryHandler) GetList(c *gin.Context) {
+	var query memoryListQuery
+	if err := c.ShouldBindQuery(&query); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "请求参数错误",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	memories, total, err := h.memoryService.FindAll(middleware.GetUserID(c), query.Page, query.PageSize, query.Q)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "获取记忆列表失败",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data": gin.H{
+			"items":    memories,
+			"total":    total,
+			"page":     query.Page,
+			"pageSize": query.PageSize,
+		},
+	})
+}
+
+// Create 手动保存记忆
+func (h *MemoryHandler) Create(c *gin.Context) {
+	var req service.MemoryRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "请求参数错误",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	memory, err := h.memoryService.Create(middleware.GetUserID(c), &req)
+	if err != nil {
+		c.JSON(memoryErrorStatus(err, http.StatusInternalServerError), gin.H{
+			"error":   "保存记忆失败",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"data": memory,
+	})
+}
+
+// Update 修改记忆
+func (h *MemoryHandler) Update(c *gin.Context) {
+	id, ok := parseIDParam(c, "无效的记忆ID")
+	if !ok {
+		return
+	}
+
+	var req service.MemoryRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "请求参数错误",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	memory, err := h.memoryService.Update(middleware.GetUserID(c), id, &req)
+	if err != nil {
+		c.JSON(errorStatus(err, http.StatusInternalServerError), gin.H{
+			"error":   "修改记忆失败",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data": memory,
+	})
+}
+
+// Delete 删除记忆
+func (h *MemoryHandler) Delete(c *gin.Context) {
+	id, ok := parseIDParam(c, "无效的记忆ID")
+	if !ok {
+		return
+	}
+
+	if err := h.memoryService.Delete(middleware.GetUserID(c), id); err != nil {
+		c.JSON(errorStatus(err, http.StatusInternalServerError), gin.H{
+			"error":   "删除记忆失败",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "记忆已删除",
+	})
+}
+
+// Clear 清空当前用户的全部记忆
+func (h *MemoryHandler) Clear(c *gin.Context) {
+	deleted, err := h.memoryService.Clear(middleware.GetUserID(c))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "清空记忆失败",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data": gin.H{
+			"deleted": deleted,
+		},
+	})
+}
+
+// GetConversationSettings 获取会话是否使用记忆
+func (h *MemoryHandler) GetConversationSettings(c *gin.Context) {
+	id, ok := parseIDParam(c, "无效的会话ID")
+	if !ok {
+		return
+	}
+
+	settings, err := h.memoryService.ConversationSettings(middleware.GetPrincipal(c), id)
+	if err != nil {
+		c.JSON(errorStatus(err, http.StatusInternalServerError), gin.H{
+			"error":   "获取会话记忆设置失败",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data": settings,
+	})
+}
+
+// UpdateConversationSettings 设置会话是否使用记忆
+func (h *MemoryHandler) UpdateConversationSettings(c *gin.Context) {
+	id, ok := parseIDParam(c, "无效的会话ID")
+	if !ok {
+		return
+	}
+
+	var req service.MemorySettingsRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "请求参数错误",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	settings, err := h.memoryService.UpdateConversationSettings(middleware.GetPrincipal(c), id, &req)
+	if err != nil {
+		c.JSON(errorStatus(err, http.StatusInternalServerError), gin.H{
+			"error":   "更新会话记忆设置失败",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data": settings,
+	})
+}
+
+// memoryErrorStatus 记忆错误对应的状态码
+func memoryErrorStatus(err error, fallback int) int {
+	if errors.Is(err, service.ErrMemoryLimit) {
+		return http.StatusConflict
+	}
+	return errorStatus(err, fallback)
+}
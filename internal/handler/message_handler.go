@@ -77,10 +77,10 @@ func (h *MessageHandler) Create(c *gin.Context) {
 		Type:           req.Type,
 	}
 
-	userID := middleware.GetUserID(c)
-	message, err := h.messageService.Create(userID, messageReq)
+	principal := middleware.GetPrincipal(c)
+	message, err := h.messageService.Create(principal, messageReq)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
+		c.JSON(errorStatus(err, http.StatusInternalServerError), gin.H{
 			"error":   "创建消息失败",
 			"details": err.Error(),
 		})
@@ -102,10 +102,10 @@ func (h *MessageHandler) Create(c *gin.Context) {
 
 // GetList 获取消息列表
 func (h *MessageHandler) GetList(c *gin.Context) {
-	userID := middleware.GetUserID(c)
-	result, err := h.messageService.FindAll(userID)
+	principal := middleware.GetPrincipal(c)
+	result, err := h.messageService.FindAll(principal)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
+		c.JSON(errorStatus(err, http.StatusInternalServerError), gin.H{
 			"error":   "获取消息列表失败",
 			"details": err.Error(),
 		})
@@ -137,10 +137,10 @@ func (h *MessageHandler) GetByConversationID(c *gin.Context) {
 		return
 	}
 
-	userID := middleware.GetUserID(c)
-	result, err := h.messageService.FindByConversationID(userID, uint(convID))
+	principal := middleware.GetPrincipal(c)
+	result, err := h.messageService.FindByConversationID(principal, uint(convID))
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
+		c.JSON(errorStatus(err, http.StatusInternalServerError), gin.H{
 			"error":   "获取消息列表失败",
 			"details": err.Error(),
 		})
@@ -166,8 +166,8 @@ func (h *MessageHandler) GetByID(c *gin.Context) {
 		return
 	}
 
-	userID := middleware.GetUserID(c)
-	message, err := h.messageService.FindByID(userID, uint(messageID))
+	principal := middleware.GetPrincipal(c)
+	message, err := h.messageService.FindByID(principal, uint(messageID))
 	if err != nil {
 		c.JSON(http.StatusNotFound, gin.H{
 			"error": "消息不存在",
@@ -203,10 +203,10 @@ func (h *MessageHandler) Update(c *gin.Context) {
 	updateReq := &service.UpdateMessageRequest{
 		Content: req.Content,
 	}
-	userID := middleware.GetUserID(c)
-	message, err := h.messageService.Update(userID, uint(messageID), updateReq)
+	principal := middleware.GetPrincipal(c)
+	message, err := h.messageService.Update(principal, uint(messageID), updateReq)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
+		c.JSON(errorStatus(err, http.StatusInternalServerError), gin.H{
 			"error":   "更新消息失败",
 			"details": err.Error(),
 		})
@@ -237,10 +237,10 @@ func (h *MessageHandler) Delete(c *gin.Context) {
 		return
 	}
 
-	userID := middleware.GetUserID(c)
-	err = h.messageService.Delete(userID, uint(messageID))
+	principal := middleware.GetPrincipal(c)
+	err = h.messageService.Delete(principal, uint(messageID))
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
+		c.JSON(errorStatus(err, http.StatusInternalServerError), gin.H{
 			"error":   "删除消息失败",
 			"details": err.Error(),
 		})
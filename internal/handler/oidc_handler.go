@@ -0,0 +1,102 @@
+package handler
+
+import (
+	"ai-chat/internal/service"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// oidcFlowCookie 保存授权流程状态的 Cookie 名称
+const oidcFlowCookie = "oidc_flow"
+
+// OIDCHandler OpenID Connect 单点登录处理器
+type OIDCHandler struct {
+	oidcService *service.OIDCService
+	appBaseURL  string
+}
+
+// NewOIDCHandler 创建 OIDC 处理器
+func NewOIDCHandler(oidcService *service.OIDCService, appBaseURL string) *OIDCHandler {
+	return &OIDCHandler{
+		oidcService: oidcService,
+		appBaseURL:  appBaseURL,
+	}
+}
+
+// GetConfig 获取单点登录配置，前端据此决定是否显示 SSO 登录按钮
+func (h *OIDCHandler) GetConfig(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{
+		"data": gin.H{
+			"enabled":  true,
+			"name":     h.oidcService.ProviderName(),
+			"loginUrl": "/api/v1/auth/oidc/login",
+		},
+	})
+}
+
+// Login 发起单点登录，跳转到身份源授权页面
+func (h *OIDCHandler) Login(c *gin.Context) {
+	result, err := h.oidcService.BeginLogin()
+	if err != nil {
+		log.Printf("发起 OIDC 登录失败: %v", err)
+		c.JSON(http.StatusBadGateway, gin.H{
+			"error":   "发起单点登录失败",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	c.SetSameSite(http.SameSiteLaxMode)
+	c.SetCookie(oidcFlowCookie, result.FlowToken, 600, "/api/v1/auth/oidc", "", h.secureCookie(), true)
+	c.Redirect(http.StatusFound, result.AuthURL)
+}
+
+// Callback 身份源回调，完成登录后携带令牌或两步验证挑战跳转回前端
+// 令牌放在 URL fragment 中，不会被发送到服务器或记录在访问日志里
+func (h *OIDCHandler) Callback(c *gin.Context) {
+	flowToken, _ := c.Cookie(oidcFlowCookie)
+	c.SetSameSite(http.SameSiteLaxMode)
+	c.SetCookie(oidcFlowCookie, "", -1, "/api/v1/auth/oidc", "", h.secureCookie(), true)
+
+	if errCode := c.Query("error"); errCode != "" {
+		h.redirectWithFragment(c, url.Values{"error": {errCode + ": " + c.Query("error_description")}})
+		return
+	}
+
+	result, err := h.oidcService.CompleteLogin(flowToken, c.Query("state"), c.Query("code"), clientInfo(c))
+	if err != nil {
+		log.Printf("OIDC 登录失败: %v", err)
+		h.redirectWithFragment(c, url.Values{"error": {err.Error()}})
+		return
+	}
+
+	// 需要两步验证时携带挑战令牌跳转，由前端完成第二步
+	if result.MFA != nil {
+		h.redirectWithFragment(c, url.Values{
+			"mfaToken":         {result.MFA.Token},
+			"mfaSetupRequired": {strconv.FormatBool(result.MFA.SetupRequired)},
+		})
+		return
+	}
+
+	h.redirectWithFragment(c, url.Values{
+		"accessToken":  {result.Token.AccessToken},
+		"refreshToken": {result.Token.RefreshToken},
+		"expiresAt":    {strconv.FormatInt(result.Token.ExpiresAt.Unix(), 10)},
+	})
+}
+
+// redirectWithFragment 跳转回前端单点登录回调页面
+func (h *OIDCHandler) redirectWithFragment(c *gin.Context, values url.Values) {
+	c.Redirect(http.StatusFound, h.appBaseURL+"/oidc/callback#"+values.Encode())
+}
+
+// secureCookie 站点使用 HTTPS 时 Cookie 仅通过 HTTPS 发送
+func (h *OIDCHandler) secureCookie() bool {
+	return strings.HasPrefix(h.appBaseURL, "https://")
+}
@@ -0,0 +1,87 @@
+package handler
+
+import (
+	"ai-chat/internal/middleware"
+	"ai-chat/internal/service"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// SearchHandler 消息检索处理器
+type SearchHandler struct {
+	searchService         *service.SearchService
+	semanticSearchService *service.SemanticSearchService // 未启用语义检索时为 nil
+}
+
+// NewSearchHandler 创建消息检索处理器
+func NewSearchHandler(searchService *service.SearchService, semanticSearchService *service.SemanticSearchService) *SearchHandler {
+	return &SearchHandler{
+		searchService:         searchService,
+		semanticSearchService: semanticSearchService,
+	}
+}
+
+// SearchMessages 全文检索当前空间的消息
+func (h *SearchHandler) SearchMessages(c *gin.Context) {
+	var query service.MessageSearchQuery
+	if err := c.ShouldBindQuery(&query); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "请求参数错误",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	results, total, err := h.searchService.Search(middleware.GetPrincipal(c), &query)
+	if err != nil {
+		c.JSON(errorStatus(err, http.StatusInternalServerError), gin.H{
+			"error":   "检索消息失败",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data": gin.H{
+			"items":    results,
+			"total":    total,
+			"page":     query.Page,
+			"pageSize": query.PageSize,
+		},
+	})
+}
+
+// SemanticSearch 语义检索当前空间的消息
+func (h *SearchHandler) SemanticSearch(c *gin.Context) {
+	if h.semanticSearchService == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{
+			"error": "语义检索未启用",
+		})
+		return
+	}
+
+	var query service.SemanticSearchQuery
+	if err := c.ShouldBindQuery(&query); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "请求参数错误",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	results, err := h.semanticSearchService.Search(c.Request.Context(), middleware.GetPrincipal(c), &query)
+	if err != nil {
+		c.JSON(errorStatus(err, http.StatusInternalServerError), gin.H{
+			"error":   "语义检索失败",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data": gin.H{
+			"items": results,
+		},
+	})
+}
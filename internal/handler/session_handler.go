@@ -0,0 +1,109 @@
+package handler
+
+import (
+	"ai-chat/internal/common"
+	"ai-chat/internal/middleware"
+	"ai-chat/internal/service"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// SessionHandler 登录会话处理器
+type SessionHandler struct {
+	sessionService *service.SessionService
+}
+
+// NewSessionHandler 创建登录会话处理器
+func NewSessionHandler(sessionService *service.SessionService) *SessionHandler {
+	return &SessionHandler{sessionService: sessionService}
+}
+
+// SessionResponse 登录会话响应
+type SessionResponse struct {
+	ID         uint   `json:"id"`
+	UserAgent  string `json:"userAgent"`
+	IP         string `json:"ip"`
+	Current    bool   `json:"current"` // 是否为发起本次请求的会话
+	CreatedAt  string `json:"createdAt"`
+	LastSeenAt string `json:"lastSeenAt"`
+}
+
+// GetList 获取当前用户的登录会话
+func (h *SessionHandler) GetList(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+	currentID := middleware.GetSessionID(c)
+
+	sessions, err := h.sessionService.List(userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "获取会话列表失败",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	responses := make([]SessionResponse, len(sessions))
+	for i, session := range sessions {
+		responses[i] = SessionResponse{
+			ID:         session.ID,
+			UserAgent:  session.UserAgent,
+			IP:         session.IP,
+			Current:    session.ID == currentID,
+			CreatedAt:  session.CreatedAt.Format(common.TimeLayout),
+			LastSeenAt: session.LastSeenAt.Format(common.TimeLayout),
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data": responses,
+	})
+}
+
+// Revoke 注销指定会话
+func (h *SessionHandler) Revoke(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+
+	sessionID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "无效的会话ID",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	if err := h.sessionService.Revoke(userID, uint(sessionID)); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error":   "注销会话失败",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "会话已注销",
+	})
+}
+
+// RevokeOthers 注销除当前会话外的所有会话
+func (h *SessionHandler) RevokeOthers(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+	currentID := middleware.GetSessionID(c)
+
+	count, err := h.sessionService.RevokeOthers(userID, currentID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "注销会话失败",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data": gin.H{
+			"revoked": count,
+		},
+	})
+}
@@ -0,0 +1,62 @@
+package handler
+
+import (
+	"ai-chat/internal/middleware"
+	"ai-chat/internal/service"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// SettingHandler 系统设置处理器（仅管理员）
+type SettingHandler struct {
+	settingService *service.SettingService
+}
+
+// NewSettingHandler 创建系统设置处理器
+func NewSettingHandler(settingService *service.SettingService) *SettingHandler {
+	return &SettingHandler{settingService: settingService}
+}
+
+// Get 获取系统设置
+func (h *SettingHandler) Get(c *gin.Context) {
+	settings, err := h.settingService.Get()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "获取系统设置失败",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data": settings,
+	})
+}
+
+// Update 更新系统设置
+func (h *SettingHandler) Update(c *gin.Context) {
+	adminID := middleware.GetUserID(c)
+
+	var req service.UpdateSettingsRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "请求参数错误",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	settings, err := h.settingService.Update(adminID, &req)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "更新系统设置失败",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data": settings,
+	})
+}
@@ -0,0 +1,194 @@
+package handler
+
+import (
+	"ai-chat/assets"
+	"ai-chat/internal/middleware"
+	"ai-chat/internal/service"
+	"errors"
+	"html/template"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// SharePasswordHeader 访问设置了密码的分享时通过该请求头提供密码
+const SharePasswordHeader = "X-Share-Password"
+
+// ShareHandler 会话分享处理器
+type ShareHandler struct {
+	shareService *service.ShareService
+	page         *template.Template
+}
+
+// NewShareHandler 创建会话分享处理器
+func NewShareHandler(shareService *service.ShareService) *ShareHandler {
+	return &ShareHandler{
+		shareService: shareService,
+		page:         template.Must(template.ParseFS(assets.TemplatesFS, "templates/share.html")),
+	}
+}
+
+// sharePage 分享页面的模板数据
+type sharePage struct {
+	Conversation     *service.SharedConversation
+	PasswordRequired bool
+	Error            string
+}
+
+// Create 创建会话分享链接
+func (h *ShareHandler) Create(c *gin.Context) {
+	conversationID, ok := parseConversationIDParam(c)
+	if !ok {
+		return
+	}
+
+	var req service.CreateShareRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "请求参数错误",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	share, err := h.shareService.Create(middleware.GetPrincipal(c), conversationID, &req)
+	if err != nil {
+		c.JSON(errorStatus(err, http.StatusBadRequest), gin.H{
+			"error":   "创建分享失败",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"data": share,
+	})
+}
+
+// GetList 获取会话的分享列表
+func (h *ShareHandler) GetList(c *gin.Context) {
+	conversationID, ok := parseConversationIDParam(c)
+	if !ok {
+		return
+	}
+
+	shares, err := h.shareService.List(middleware.GetPrincipal(c), conversationID)
+	if err != nil {
+		c.JSON(errorStatus(err, http.StatusInternalServerError), gin.H{
+			"error":   "获取分享列表失败",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data": gin.H{
+			"items": shares,
+		},
+	})
+}
+
+// Revoke 撤销分享
+func (h *ShareHandler) Revoke(c *gin.Context) {
+	conversationID, ok := parseConversationIDParam(c)
+	if !ok {
+		return
+	}
+	shareID, err := strconv.ParseUint(c.Param("shareId"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "无效的分享ID",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	if err := h.shareService.Revoke(middleware.GetPrincipal(c), conversationID, uint(shareID)); err != nil {
+		c.JSON(errorStatus(err, http.StatusInternalServerError), gin.H{
+			"error":   "撤销分享失败",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "已撤销分享",
+	})
+}
+
+// GetShared 以 JSON 格式获取分享内容，无需登录
+func (h *ShareHandler) GetShared(c *gin.Context) {
+	conversation, err := h.shareService.View(c.Param("token"), c.GetHeader(SharePasswordHeader))
+	if err != nil {
+		c.JSON(shareErrorStatus(err), gin.H{
+			"error":   "获取分享失败",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	c.Header("X-Robots-Tag", "noindex, nofollow")
+	c.JSON(http.StatusOK, gin.H{
+		"data": conversation,
+	})
+}
+
+// Page 渲染分享页面，无需登录；设置了密码时通过表单提交密码
+func (h *ShareHandler) Page(c *gin.Context) {
+	// 页面只包含转义后的纯文本和内联样式，禁止脚本和外部资源
+	c.Header("Content-Security-Policy", "default-src 'none'; style-src 'unsafe-inline'; form-action 'self'; frame-ancestors 'none'")
+	c.Header("Referrer-Policy", "no-referrer")
+	c.Header("X-Content-Type-Options", "nosniff")
+	c.Header("X-Robots-Tag", "noindex, nofollow")
+
+	conversation, err := h.shareService.View(c.Param("token"), c.PostForm("password"))
+	if err != nil {
+		page := sharePage{Error: err.Error()}
+		switch {
+		case errors.Is(err, service.ErrSharePasswordRequired):
+			page = sharePage{PasswordRequired: true}
+		case errors.Is(err, service.ErrSharePasswordInvalid):
+			page.PasswordRequired = true
+		case !errors.Is(err, service.ErrShareNotFound):
+			page.Error = "加载分享失败"
+		}
+		h.render(c, shareErrorStatus(err), page)
+		return
+	}
+
+	h.render(c, http.StatusOK, sharePage{Conversation: conversation})
+}
+
+// render 渲染分享页面模板
+func (h *ShareHandler) render(c *gin.Context, status int, page sharePage) {
+	c.Status(status)
+	c.Header("Content-Type", "text/html; charset=utf-8")
+	if err := h.page.Execute(c.Writer, page); err != nil {
+		c.Error(err)
+	}
+}
+
+// shareErrorStatus 分享访问错误对应的状态码
+func shareErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, service.ErrShareNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, service.ErrSharePasswordRequired), errors.Is(err, service.ErrSharePasswordInvalid):
+		return http.StatusUnauthorized
+	}
+	return http.StatusInternalServerError
+}
+
+// parseConversationIDParam 解析路径中的会话ID，失败时直接写入错误响应
+func parseConversationIDParam(c *gin.Context) (uint, bool) {
+	conversationID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "无效的对话ID",
+			"details": err.Error(),
+		})
+		return 0, false
+	}
+	return uint(conversationID), true
+}
@@ -0,0 +1,51 @@
+package handler
+
+import (
+	"ai-chat/internal/middleware"
+	"ai-chat/internal/service"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// StreamTicketHandler 流式票据处理器
+type StreamTicketHandler struct {
+	streamTicketService *service.StreamTicketService
+}
+
+// NewStreamTicketHandler 创建流式票据处理器
+func NewStreamTicketHandler(streamTicketService *service.StreamTicketService) *StreamTicketHandler {
+	return &StreamTicketHandler{streamTicketService: streamTicketService}
+}
+
+// CreateStreamTicketRequest 申请流式票据请求
+type CreateStreamTicketRequest struct {
+	ConversationID uint `json:"conversationId" binding:"required"`
+}
+
+// Create 申请流式票据，用于建立 EventSource 连接
+func (h *StreamTicketHandler) Create(c *gin.Context) {
+	principal := middleware.GetPrincipal(c)
+
+	var req CreateStreamTicketRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "请求参数错误",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	ticket, err := h.streamTicketService.Issue(principal, req.ConversationID)
+	if err != nil {
+		c.JSON(errorStatus(err, http.StatusBadRequest), gin.H{
+			"error":   "申请流式票据失败",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"data": ticket,
+	})
+}
@@ -0,0 +1,241 @@
+package handler
+
+import (
+	"ai-chat/internal/middleware"
+	"ai-chat/internal/service"
+	"errors"
+	"math"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// TwoFactorHandler 两步验证处理器
+type TwoFactorHandler struct {
+	twoFactorService *service.TwoFactorService
+}
+
+// NewTwoFactorHandler 创建两步验证处理器
+func NewTwoFactorHandler(twoFactorService *service.TwoFactorService) *TwoFactorHandler {
+	return &TwoFactorHandler{twoFactorService: twoFactorService}
+}
+
+// TwoFactorCodeRequest 验证码请求
+type TwoFactorCodeRequest struct {
+	Code string `json:"code" binding:"required,max=32"` // 验证器 App 中的 6 位验证码或恢复码
+}
+
+// MFATokenRequest 登录第二步请求
+type MFATokenRequest struct {
+	MFAToken string `json:"mfaToken" binding:"required"`
+	Code     string `json:"code" binding:"max=32"`
+}
+
+// GetStatus 获取两步验证状态
+func (h *TwoFactorHandler) GetStatus(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+
+	status, err := h.twoFactorService.Status(userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "获取两步验证状态失败",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data": status,
+	})
+}
+
+// BeginSetup 开始设置两步验证
+func (h *TwoFactorHandler) BeginSetup(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+
+	setup, err := h.twoFactorService.BeginSetup(userID)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "设置两步验证失败",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data": setup,
+	})
+}
+
+// Enable 启用两步验证
+func (h *TwoFactorHandler) Enable(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+
+	var req TwoFactorCodeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "请求参数错误",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	result, err := h.twoFactorService.Enable(userID, req.Code, c.ClientIP())
+	if err != nil {
+		h.codeError(c, "启用两步验证失败", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data": result,
+	})
+}
+
+// Disable 关闭两步验证
+func (h *TwoFactorHandler) Disable(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+
+	var req TwoFactorCodeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "请求参数错误",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	if err := h.twoFactorService.Disable(userID, req.Code, c.ClientIP()); err != nil {
+		h.codeError(c, "关闭两步验证失败", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "两步验证已关闭",
+	})
+}
+
+// RegenerateRecoveryCodes 重新生成恢复码
+func (h *TwoFactorHandler) RegenerateRecoveryCodes(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+
+	var req TwoFactorCodeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "请求参数错误",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	codes, err := h.twoFactorService.RegenerateRecoveryCodes(userID, req.Code, c.ClientIP())
+	if err != nil {
+		h.codeError(c, "生成恢复码失败", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data": gin.H{
+			"recoveryCodes": codes,
+		},
+	})
+}
+
+// VerifyLogin 登录第二步：校验验证码并签发令牌
+func (h *TwoFactorHandler) VerifyLogin(c *gin.Context) {
+	var req MFATokenRequest
+	if err := c.ShouldBindJSON(&req); err != nil || req.Code == "" {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "请求参数错误",
+			"details": "缺少两步验证令牌或验证码",
+		})
+		return
+	}
+
+	result, err := h.twoFactorService.VerifyLogin(req.MFAToken, req.Code, clientInfo(c))
+	if err != nil {
+		var throttled *service.LoginThrottledError
+		if errors.As(err, &throttled) {
+			c.Header("Retry-After", strconv.Itoa(int(math.Ceil(throttled.RetryAfter.Seconds()))))
+			c.JSON(http.StatusTooManyRequests, gin.H{
+				"error":   "登录失败",
+				"details": throttled.Error(),
+			})
+			return
+		}
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"error":   "登录失败",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	writeLoginResult(c, result)
+}
+
+// BeginLoginSetup 登录时被要求启用两步验证：获取密钥
+func (h *TwoFactorHandler) BeginLoginSetup(c *gin.Context) {
+	var req MFATokenRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "请求参数错误",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	setup, err := h.twoFactorService.BeginSetupForLogin(req.MFAToken)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"error":   "设置两步验证失败",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data": setup,
+	})
+}
+
+// EnableLoginSetup 登录时被要求启用两步验证：启用并完成登录
+func (h *TwoFactorHandler) EnableLoginSetup(c *gin.Context) {
+	var req MFATokenRequest
+	if err := c.ShouldBindJSON(&req); err != nil || req.Code == "" {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "请求参数错误",
+			"details": "缺少两步验证令牌或验证码",
+		})
+		return
+	}
+
+	result, err := h.twoFactorService.EnableForLogin(req.MFAToken, req.Code, clientInfo(c))
+	if err != nil {
+		h.codeError(c, "启用两步验证失败", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data": gin.H{
+			"recoveryCodes": result.RecoveryCodes,
+			"auth":          newAuthResponse(result.Auth),
+		},
+	})
+}
+
+// codeError 统一处理验证码相关错误
+func (h *TwoFactorHandler) codeError(c *gin.Context, msg string, err error) {
+	status := http.StatusBadRequest
+	var throttled *service.LoginThrottledError
+	switch {
+	case errors.As(err, &throttled):
+		c.Header("Retry-After", strconv.Itoa(int(math.Ceil(throttled.RetryAfter.Seconds()))))
+		status = http.StatusTooManyRequests
+	case errors.Is(err, service.ErrInvalidMFACode):
+		status = http.StatusUnauthorized
+	}
+	c.JSON(status, gin.H{
+		"error":   msg,
+		"details": err.Error(),
+	})
+}
@@ -22,6 +22,8 @@ func userToUserResponse(user *repository.User) *dto.UserResponse {
 		ID:        user.ID,
 		Email:     user.Email,
 		Username:  user.Name,
+		Role:      user.Role,
+		IsActive:  user.IsActive,
 		CreatedAt: user.CreatedAt.Format(common.TimeLayout),
 		UpdatedAt: user.UpdatedAt.Format(common.TimeLayout),
 	}
@@ -99,7 +101,7 @@ func (h *UserHandler) UpdatePassword(c *gin.Context) {
 		return
 	}
 
-	err := h.userService.UpdatePassword(userID, &req)
+	err := h.userService.UpdatePassword(userID, middleware.GetSessionID(c), &req)
 	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{
 			"code":  400,
@@ -174,8 +176,6 @@ func (h *UserHandler) GetUserByID(c *gin.Context) {
 		return
 	}
 
-	// TODO: 检查管理员权限
-
 	response, err := h.userService.GetUserByID(uint(id))
 	if err != nil {
 		c.JSON(http.StatusNotFound, gin.H{
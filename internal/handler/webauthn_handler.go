@@ -0,0 +1,188 @@
+package handler
+
+import (
+	"ai-chat/internal/common"
+	"ai-chat/internal/middleware"
+	"ai-chat/internal/repository"
+	"ai-chat/internal/service"
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// WebAuthnHandler 通行密钥处理器
+type WebAuthnHandler struct {
+	webAuthnService *service.WebAuthnService
+}
+
+// NewWebAuthnHandler 创建通行密钥处理器
+func NewWebAuthnHandler(webAuthnService *service.WebAuthnService) *WebAuthnHandler {
+	return &WebAuthnHandler{webAuthnService: webAuthnService}
+}
+
+// WebAuthnFinishRequest 完成注册/登录仪式请求
+type WebAuthnFinishRequest struct {
+	SessionToken string          `json:"sessionToken" binding:"required"`
+	Name         string          `json:"name" binding:"max=100"`        // 注册时可选的通行密钥名称
+	Credential   json.RawMessage `json:"credential" binding:"required"` // 验证器返回的 PublicKeyCredential
+}
+
+// WebAuthnCredentialResponse 通行密钥响应
+type WebAuthnCredentialResponse struct {
+	ID             uint    `json:"id"`
+	Name           string  `json:"name"`
+	BackupEligible bool    `json:"backupEligible"`
+	LastUsedAt     *string `json:"lastUsedAt"`
+	CreatedAt      string  `json:"createdAt"`
+}
+
+// BeginRegistration 发起通行密钥注册
+func (h *WebAuthnHandler) BeginRegistration(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+
+	result, err := h.webAuthnService.BeginRegistration(userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "发起通行密钥注册失败",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data": result,
+	})
+}
+
+// FinishRegistration 完成通行密钥注册
+func (h *WebAuthnHandler) FinishRegistration(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+
+	var req WebAuthnFinishRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "请求参数错误",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	cred, err := h.webAuthnService.FinishRegistration(userID, req.SessionToken, req.Name, bytes.NewReader(req.Credential))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "注册通行密钥失败",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"data": toWebAuthnCredentialResponse(cred),
+	})
+}
+
+// BeginLogin 发起通行密钥登录
+func (h *WebAuthnHandler) BeginLogin(c *gin.Context) {
+	result, err := h.webAuthnService.BeginLogin()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "发起通行密钥登录失败",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data": result,
+	})
+}
+
+// FinishLogin 完成通行密钥登录
+func (h *WebAuthnHandler) FinishLogin(c *gin.Context) {
+	var req WebAuthnFinishRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "请求参数错误",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	result, err := h.webAuthnService.FinishLogin(req.SessionToken, bytes.NewReader(req.Credential), clientInfo(c))
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"error":   "登录失败",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	writeLoginResult(c, result)
+}
+
+// ListCredentials 获取当前用户的通行密钥
+func (h *WebAuthnHandler) ListCredentials(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+
+	creds, err := h.webAuthnService.ListCredentials(userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "获取通行密钥失败",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	responses := make([]WebAuthnCredentialResponse, len(creds))
+	for i, cred := range creds {
+		responses[i] = toWebAuthnCredentialResponse(&cred)
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data": responses,
+	})
+}
+
+// DeleteCredential 删除通行密钥
+func (h *WebAuthnHandler) DeleteCredential(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "无效的通行密钥ID",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	if err := h.webAuthnService.DeleteCredential(userID, uint(id)); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error":   "删除通行密钥失败",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "通行密钥已删除",
+	})
+}
+
+// toWebAuthnCredentialResponse 转换通行密钥响应
+func toWebAuthnCredentialResponse(cred *repository.WebAuthnCredential) WebAuthnCredentialResponse {
+	resp := WebAuthnCredentialResponse{
+		ID:             cred.ID,
+		Name:           cred.Name,
+		BackupEligible: cred.BackupEligible,
+		CreatedAt:      cred.CreatedAt.Format(common.TimeLayout),
+	}
+	if cred.LastUsedAt != nil {
+		lastUsedAt := cred.LastUsedAt.Format(common.TimeLayout)
+		resp.LastUsedAt = &lastUsedAt
+	}
+	return resp
+}
@@ -0,0 +1,256 @@
+package handler
+
+import (
+	"ai-chat/internal/middleware"
+	"ai-chat/internal/service"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// WorkspaceHandler 工作区处理器
+type WorkspaceHandler struct {
+	workspaceService *service.WorkspaceService
+}
+
+// NewWorkspaceHandler 创建工作区处理器
+func NewWorkspaceHandler(workspaceService *service.WorkspaceService) *WorkspaceHandler {
+	return &WorkspaceHandler{workspaceService: workspaceService}
+}
+
+// Create 创建工作区
+func (h *WorkspaceHandler) Create(c *gin.Context) {
+	var req service.CreateWorkspaceRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "请求参数错误",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	workspace, err := h.workspaceService.Create(middleware.GetPrincipal(c), &req)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "创建工作区失败",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"data": workspace,
+	})
+}
+
+// GetList 获取当前用户加入的工作区
+func (h *WorkspaceHandler) GetList(c *gin.Context) {
+	workspaces, err := h.workspaceService.List(middleware.GetPrincipal(c))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "获取工作区列表失败",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data": gin.H{
+			"items": workspaces,
+		},
+	})
+}
+
+// GetByID 获取工作区详情
+func (h *WorkspaceHandler) GetByID(c *gin.Context) {
+	workspaceID, ok := parseWorkspaceIDParam(c)
+	if !ok {
+		return
+	}
+
+	workspace, err := h.workspaceService.Get(middleware.GetPrincipal(c), workspaceID)
+	if err != nil {
+		c.JSON(errorStatus(err, http.StatusInternalServerError), gin.H{
+			"error":   "获取工作区失败",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data": workspace,
+	})
+}
+
+// Update 更新工作区设置
+func (h *WorkspaceHandler) Update(c *gin.Context) {
+	workspaceID, ok := parseWorkspaceIDParam(c)
+	if !ok {
+		return
+	}
+
+	var req service.UpdateWorkspaceRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "请求参数错误",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	workspace, err := h.workspaceService.Update(middleware.GetPrincipal(c), workspaceID, &req)
+	if err != nil {
+		c.JSON(errorStatus(err, http.StatusBadRequest), gin.H{
+			"error":   "更新工作区失败",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data": workspace,
+	})
+}
+
+// Delete 删除工作区
+func (h *WorkspaceHandler) Delete(c *gin.Context) {
+	workspaceID, ok := parseWorkspaceIDParam(c)
+	if !ok {
+		return
+	}
+
+	if err := h.workspaceService.Delete(middleware.GetPrincipal(c), workspaceID); err != nil {
+		c.JSON(errorStatus(err, http.StatusInternalServerError), gin.H{
+			"error":   "删除工作区失败",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "删除成功",
+	})
+}
+
+// GetMembers 获取工作区成员
+func (h *WorkspaceHandler) GetMembers(c *gin.Context) {
+	workspaceID, ok := parseWorkspaceIDParam(c)
+	if !ok {
+		return
+	}
+
+	members, err := h.workspaceService.ListMembers(middleware.GetPrincipal(c), workspaceID)
+	if err != nil {
+		c.JSON(errorStatus(err, http.StatusInternalServerError), gin.H{
+			"error":   "获取工作区成员失败",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data": gin.H{
+			"items": members,
+		},
+	})
+}
+
+// AddMember 添加工作区成员
+func (h *WorkspaceHandler) AddMember(c *gin.Context) {
+	workspaceID, ok := parseWorkspaceIDParam(c)
+	if !ok {
+		return
+	}
+
+	var req service.AddWorkspaceMemberRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "请求参数错误",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	if err := h.workspaceService.AddMember(middleware.GetPrincipal(c), workspaceID, &req); err != nil {
+		c.JSON(errorStatus(err, http.StatusBadRequest), gin.H{
+			"error":   "添加工作区成员失败",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"message": "已添加成员",
+	})
+}
+
+// UpdateMember 修改工作区成员角色
+func (h *WorkspaceHandler) UpdateMember(c *gin.Context) {
+	workspaceID, ok := parseWorkspaceIDParam(c)
+	if !ok {
+		return
+	}
+	userID, ok := parseUserIDParam(c)
+	if !ok {
+		return
+	}
+
+	var req service.UpdateWorkspaceMemberRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "请求参数错误",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	if err := h.workspaceService.UpdateMember(middleware.GetPrincipal(c), workspaceID, userID, req.Role); err != nil {
+		c.JSON(errorStatus(err, http.StatusBadRequest), gin.H{
+			"error":   "修改成员角色失败",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "已修改成员角色",
+	})
+}
+
+// RemoveMember 移除工作区成员或退出工作区
+func (h *WorkspaceHandler) RemoveMember(c *gin.Context) {
+	workspaceID, ok := parseWorkspaceIDParam(c)
+	if !ok {
+		return
+	}
+	userID, ok := parseUserIDParam(c)
+	if !ok {
+		return
+	}
+
+	if err := h.workspaceService.RemoveMember(middleware.GetPrincipal(c), workspaceID, userID); err != nil {
+		c.JSON(errorStatus(err, http.StatusBadRequest), gin.H{
+			"error":   "移除工作区成员失败",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "已移除成员",
+	})
+}
+
+// parseWorkspaceIDParam 解析路径中的工作区ID，失败时直接写入错误响应
+func parseWorkspaceIDParam(c *gin.Context) (uint, bool) {
+	workspaceID, err := strconv.ParseUint(c.Param("workspaceId"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "无效的工作区ID",
+			"details": err.Error(),
+		})
+		return 0, false
+	}
+	return uint(workspaceID), true
+}
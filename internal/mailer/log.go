@@ -0,0 +1,53 @@
+package mailer
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// LogMailer 将邮件输出到日志（以及可选的文件），用于开发和测试环境
+type LogMailer struct {
+	from string
+	file string
+	mu   sync.Mutex
+}
+
+// NewLogMailer 创建日志邮件发送器，file 为空时只写日志
+func NewLogMailer(from, file string) *LogMailer {
+	return &LogMailer{from: from, file: file}
+}
+
+// Send 发送邮件
+func (m *LogMailer) Send(msg *Message) error {
+	var b strings.Builder
+	fmt.Fprintf(&b, "Date: %s\n", time.Now().Format(time.RFC1123Z))
+	fmt.Fprintf(&b, "From: %s\n", m.from)
+	fmt.Fprintf(&b, "To: %s\n", msg.To)
+	fmt.Fprintf(&b, "Subject: %s\n\n", msg.Subject)
+	b.WriteString(msg.Text)
+	b.WriteString("\n")
+
+	log.Printf("[mail] 发送邮件:\n%s", b.String())
+
+	if m.file == "" {
+		return nil
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	f, err := os.OpenFile(m.file, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o600)
+	if err != nil {
+		return fmt.Errorf("打开邮件日志文件失败: %w", err)
+	}
+	defer f.Close()
+
+	if _, err := f.WriteString(b.String() + "\n----\n"); err != nil {
+		return fmt.Errorf("写入邮件日志文件失败: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,35 @@
+package mailer
+
+import (
+	"ai-chat/config"
+	"fmt"
+	"strings"
+)
+
+// Message 邮件内容
+type Message struct {
+	To      string
+	Subject string
+	Text    string // 纯文本正文
+	HTML    string // 可选的 HTML 正文
+}
+
+// Mailer 邮件发送接口
+type Mailer interface {
+	Send(msg *Message) error
+}
+
+// New 根据配置创建邮件发送器
+func New(cfg *config.Config) (Mailer, error) {
+	switch strings.ToLower(cfg.MailDriver) {
+	case "", "log":
+		return NewLogMailer(cfg.MailFrom, cfg.MailLogFile), nil
+	case "smtp":
+		if cfg.SMTPHost == "" {
+			return nil, fmt.Errorf("MAIL_DRIVER=smtp 时必须配置 SMTP_HOST")
+		}
+		return NewSMTPMailer(cfg.SMTPHost, cfg.SMTPPort, cfg.SMTPUsername, cfg.SMTPPassword, cfg.MailFrom), nil
+	default:
+		return nil, fmt.Errorf("不支持的邮件驱动: %s", cfg.MailDriver)
+	}
+}
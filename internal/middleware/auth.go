@@ -1,6 +1,8 @@
 package middleware
 
 import (
+	"ai-chat/internal/common"
+	"ai-chat/internal/policy"
 	"fmt"
 	"net/http"
 	"strings"
@@ -9,12 +11,18 @@ import (
 	"github.com/golang-jwt/jwt/v5"
 )
 
-// Auth JWT认证中间件
-func Auth(jwtSecret string) gin.HandlerFunc {
+// SessionValidator 校验令牌所属的登录会话是否仍然有效
+type SessionValidator interface {
+	ValidateSession(userID, sessionID uint) error
+}
+
+// Auth JWT认证中间件，sessions 不为空时拒绝已注销会话的令牌
+func Auth(jwtSecret string, sessions SessionValidator) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		var tokenString string
 
-		// 1. 尝试从 Header 获取
+		// 只从 Header 获取，不接受查询参数中的令牌，避免令牌泄露到访问日志和浏览器历史
+		// EventSource 等无法设置 Header 的场景请使用 StreamTicket
 		authHeader := c.GetHeader("Authorization")
 		if authHeader != "" {
 			parts := strings.SplitN(authHeader, " ", 2)
@@ -23,11 +31,6 @@ func Auth(jwtSecret string) gin.HandlerFunc {
 			}
 		}
 
-		// 2. 尝试从 Query 参数获取 (用于 EventSource 等不支持自定义 Header 的场景)
-		if tokenString == "" {
-			tokenString = c.Query("token")
-		}
-
 		if tokenString == "" {
 			c.JSON(http.StatusUnauthorized, gin.H{
 				"code":  401,
@@ -87,12 +90,83 @@ func Auth(jwtSecret string) gin.HandlerFunc {
 			return
 		}
 
+		// 校验登录会话，未携带会话ID的令牌无法注销，同样拒绝
+		if sessions != nil {
+			sessionID, ok := claims["sid"].(float64)
+			if !ok || sessions.ValidateSession(GetUserID(c), uint(sessionID)) != nil {
+				c.JSON(http.StatusUnauthorized, gin.H{
+					"code":  401,
+					"error": "会话已失效，请重新登录",
+				})
+				c.Abort()
+				return
+			}
+			c.Set("sessionId", uint(sessionID))
+		}
+
+		// 角色缺失时按普通用户处理（兼容旧令牌）
+		role, _ := claims["role"].(string)
+		if role == "" {
+			role = common.RoleUser
+		}
+		c.Set("role", role)
+
+		// 管理员模拟登录签发的令牌，记录模拟者以便审计
+		if impersonatorID, ok := claims["impersonatorId"].(float64); ok {
+			c.Set("impersonatorId", uint(impersonatorID))
+		}
+
 		c.Next()
 	}
 }
 
+// RequireRole 角色校验中间件，需放在 Auth 之后
+func RequireRole(roles ...string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		role := GetUserRole(c)
+		for _, allowed := range roles {
+			if role == allowed {
+				c.Next()
+				return
+			}
+		}
+
+		c.JSON(http.StatusForbidden, gin.H{
+			"code":  403,
+			"error": "权限不足",
+		})
+		c.Abort()
+	}
+}
+
 // GetUserID 从上下文中获取用户ID
 // 此时中间件已确保用户已认证且ID存在，因此直接返回ID即可
 func GetUserID(c *gin.Context) uint {
 	return c.GetUint("userId")
 }
+
+// GetPrincipal 从上下文中获取访问主体，供服务层做权限判断
+func GetPrincipal(c *gin.Context) policy.Principal {
+	return policy.Principal{
+		UserID:         GetUserID(c),
+		Role:           GetUserRole(c),
+		WorkspaceID:    GetWorkspaceID(c),
+		SessionID:      GetSessionID(c),
+		ImpersonatorID: GetImpersonatorID(c),
+	}
+}
+
+// GetUserRole 从上下文中获取用户角色
+func GetUserRole(c *gin.Context) string {
+	return c.GetString("role")
+}
+
+// GetSessionID 从上下文中获取当前登录会话ID
+func GetSessionID(c *gin.Context) uint {
+	return c.GetUint("sessionId")
+}
+
+// GetImpersonatorID 从上下文中获取模拟登录的管理员ID，非模拟登录时为 0
+func GetImpersonatorID(c *gin.Context) uint {
+	return c.GetUint("impersonatorId")
+}
@@ -11,7 +11,7 @@ func CORS() gin.HandlerFunc {
 		origin := c.Request.Header.Get("Origin")
 
 		c.Header("Access-Control-Allow-Origin", origin)
-		c.Header("Access-Control-Allow-Headers", "Content-Type,AccessToken,X-CSRF-Token,Authorization,Token,x-token,X-User-Id")
+		c.Header("Access-Control-Allow-Headers", "Content-Type,AccessToken,X-CSRF-Token,Authorization,Token,x-token,X-User-Id,X-Workspace-ID,X-Share-Password")
 		c.Header("Access-Control-Allow-Methods", "POST,GET,DELETE,PUT,PATCH,OPTIONS,HEAD")
 		c.Header("Access-Control-Expose-Headers", "Content-Length,Access-Control-Allow-Origin,Access-Control-Allow-Headers,Content-Type")
 		c.Header("Access-Control-Allow-Credentials", "true")
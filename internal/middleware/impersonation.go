@@ -0,0 +1,43 @@
+package middleware
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ImpersonationRecorder 记录模拟登录期间的请求
+type ImpersonationRecorder interface {
+	RecordImpersonatedRequest(impersonatorID, userID uint, method, path string, status int, ip string)
+}
+
+// ImpersonationAudit 模拟登录审计中间件，全局注册
+// 认证中间件在路由组内执行，因此在请求处理完成后再读取模拟者信息
+func ImpersonationAudit(recorder ImpersonationRecorder) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Next()
+
+		impersonatorID := GetImpersonatorID(c)
+		if impersonatorID == 0 {
+			return
+		}
+		recorder.RecordImpersonatedRequest(impersonatorID, GetUserID(c), c.Request.Method,
+			c.Request.URL.Path, c.Writer.Status(), c.ClientIP())
+	}
+}
+
+// DenyImpersonation 禁止模拟登录的令牌访问凭据和账户管理接口，防止管理员在目标账户上留下持久的访问方式
+// 需要在认证中间件之后使用
+func DenyImpersonation() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if GetImpersonatorID(c) != 0 {
+			c.JSON(http.StatusForbidden, gin.H{
+				"code":  403,
+				"error": "模拟登录期间不能修改账户凭据",
+			})
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
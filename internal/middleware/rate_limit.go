@@ -1,14 +1,15 @@
 package middleware
 
 import (
+	"strconv"
 	"sync"
 	"time"
 
 	"github.com/gin-gonic/gin"
 )
 
-// RateLimit 限流中间件
-func RateLimit() gin.HandlerFunc {
+// RateLimit 限流中间件，同一IP在 window 时间窗口内最多允许 limit 次请求
+func RateLimit(limit int, window time.Duration) gin.HandlerFunc {
 	type client struct {
 		count  int
 		expire time.Time
@@ -19,19 +20,21 @@ func RateLimit() gin.HandlerFunc {
 
 	return func(c *gin.Context) {
 		ip := c.ClientIP()
+		now := time.Now()
 
 		mu.Lock()
 		defer mu.Unlock()
 
 		if cl, exists := clients[ip]; exists {
-			if time.Now().After(cl.expire) {
+			if now.After(cl.expire) {
 				// 限流窗口过期，重置计数
 				cl.count = 0
-				cl.expire = time.Now().Add(time.Minute)
+				cl.expire = now.Add(window)
 			}
 
 			cl.count++
-			if cl.count > 100 { // 每分钟最多100次请求
+			if cl.count > limit {
+				c.Header("Retry-After", strconv.Itoa(int(time.Until(cl.expire).Seconds())+1))
 				c.JSON(429, gin.H{
 					"code":  429,
 					"error": "请求过于频繁，请稍后重试",
@@ -40,13 +43,18 @@ func RateLimit() gin.HandlerFunc {
 				return
 			}
 		} else {
-			// 新客户端
+			// 新客户端，顺便清理已过期的记录，避免内存无限增长
+			for key, cl := range clients {
+				if now.After(cl.expire) {
+					delete(clients, key)
+				}
+			}
 			clients[ip] = &client{
 				count:  1,
-				expire: time.Now().Add(time.Minute),
+				expire: now.Add(window),
 			}
 		}
 
 		c.Next()
 	}
-}
\ No newline at end of file
+}
@@ -0,0 +1,59 @@
+package middleware
+
+import (
+	"ai-chat/internal/common"
+	"ai-chat/internal/policy"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// StreamTicketRedeemer 消费流式票据，返回申请票据时的访问主体
+type StreamTicketRedeemer interface {
+	RedeemStreamTicket(ticket string, conversationID uint) (*policy.Principal, error)
+}
+
+// StreamTicket 流式接口认证中间件
+// EventSource 无法设置请求头，使用 ?ticket= 传递一次性票据，票据与路径中的 conversationId 绑定
+// 兑换后恢复申请票据时的角色、工作区、登录会话和模拟登录信息，与 Auth、Workspace 中间件设置的上下文一致
+func StreamTicket(redeemer StreamTicketRedeemer) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		conversationID, err := strconv.ParseUint(c.Param("conversationId"), 10, 32)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": "无效的会话ID",
+			})
+			c.Abort()
+			return
+		}
+
+		p, err := redeemer.RedeemStreamTicket(c.Query("ticket"), uint(conversationID))
+		if err != nil {
+			c.JSON(http.StatusUnauthorized, gin.H{
+				"code":  401,
+				"error": "无效的流式票据",
+			})
+			c.Abort()
+			return
+		}
+
+		c.Set("userId", p.UserID)
+		// 角色缺失时按普通用户处理（兼容未保存访问主体的旧票据）
+		role := p.Role
+		if role == "" {
+			role = common.RoleUser
+		}
+		c.Set("role", role)
+		if p.WorkspaceID != nil {
+			c.Set("workspaceId", *p.WorkspaceID)
+		}
+		if p.SessionID != 0 {
+			c.Set("sessionId", p.SessionID)
+		}
+		if p.ImpersonatorID != 0 {
+			c.Set("impersonatorId", p.ImpersonatorID)
+		}
+		c.Next()
+	}
+}
@@ -0,0 +1,44 @@
+package middleware
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// WorkspaceHeader 选择当前工作区的请求头，不传表示个人空间
+const WorkspaceHeader = "X-Workspace-ID"
+
+// Workspace 工作区切换中间件，解析请求头中的工作区ID，成员资格由服务层校验
+func Workspace() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		value := c.GetHeader(WorkspaceHeader)
+		if value == "" {
+			c.Next()
+			return
+		}
+
+		workspaceID, err := strconv.ParseUint(value, 10, 32)
+		if err != nil || workspaceID == 0 {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"code":  400,
+				"error": "无效的工作区ID",
+			})
+			c.Abort()
+			return
+		}
+
+		c.Set("workspaceId", uint(workspaceID))
+		c.Next()
+	}
+}
+
+// GetWorkspaceID 从上下文中获取当前工作区ID，个人空间返回 nil
+func GetWorkspaceID(c *gin.Context) *uint {
+	workspaceID := c.GetUint("workspaceId")
+	if workspaceID == 0 {
+		return nil
+	}
+	return &workspaceID
+}
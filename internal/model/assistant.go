@@ -0,0 +1,31 @@
+package model
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Assistant 助手模型，组合系统提示词、默认模型和参数、可用工具、知识库和开场问题，工作区中的助手由成员共用
+type Assistant struct {
+	ID           uint           `json:"id" gorm:"primaryKey"`
+	Name         string         `json:"name" gorm:"size:255;not null"`
+	Description  string         `json:"description" gorm:"type:text"`
+	UserID       uint           `json:"userId" gorm:"not null;index"`
+	WorkspaceID  *uint          `json:"workspaceId" gorm:"index"` // 为空表示个人助手
+	SystemPrompt string         `json:"systemPrompt" gorm:"type:text"`
+	Model        *string        `json:"model" gorm:"size:100"` // 为空时使用工作区或系统的默认模型
+	Temperature  *float64       `json:"temperature" gorm:"type:decimal(3,2)"`
+	TopP         *float64       `json:"topP" gorm:"type:decimal(3,2)"`
+	MaxTokens    *int           `json:"maxTokens"`
+	Tools        string         `json:"tools" gorm:"type:jsonb;not null;default:'[]'"`    // 启用的工具名称
+	Starters     string         `json:"starters" gorm:"type:jsonb;not null;default:'[]'"` // 开场问题
+	CreatedAt    time.Time      `json:"createdAt" gorm:"autoCreateTime"`
+	UpdatedAt    time.Time      `json:"updatedAt" gorm:"autoUpdateTime"`
+	DeletedAt    gorm.DeletedAt `json:"-" gorm:"index"`
+
+	// 关联关系
+	User User `json:"user,omitempty" gorm:"foreignKey:UserID"`
+
+	TableName string `json:"-" gorm:"tableName:assistant"`
+}
@@ -0,0 +1,29 @@
+package model
+
+import (
+	"time"
+)
+
+// ConversationShare 会话公开分享模型，保存创建时的消息快照，之后会话的修改不影响分享内容
+type ConversationShare struct {
+	ID             uint       `json:"id" gorm:"primaryKey"`
+	ConversationID uint       `json:"conversationId" gorm:"not null;index"`
+	UserID         uint       `json:"userId" gorm:"not null;index"` // 创建者
+	TokenHash      string     `json:"-" gorm:"size:64;not null;uniqueIndex"`
+	Title          string     `json:"title" gorm:"size:255;not null"`
+	Snapshot       string     `json:"-" gorm:"type:jsonb;not null"` // 消息快照
+	MessageCount   int        `json:"messageCount" gorm:"not null"`
+	HideReasoning  bool       `json:"hideReasoning" gorm:"default:false"`
+	PasswordSalt   string     `json:"-" gorm:"size:64"`
+	PasswordHash   string     `json:"-" gorm:"size:64"` // 为空表示无需密码
+	ExpiresAt      *time.Time `json:"expiresAt"`        // 为空表示永不过期
+	RevokedAt      *time.Time `json:"revokedAt"`
+	ViewCount      int        `json:"viewCount" gorm:"default:0"`
+	CreatedAt      time.Time  `json:"createdAt" gorm:"autoCreateTime"`
+
+	// 关联关系
+	User         User         `json:"user,omitempty" gorm:"foreignKey:UserID"`
+	Conversation Conversation `json:"conversation,omitempty" gorm:"foreignKey:ConversationID"`
+
+	TableName string `json:"-" gorm:"tableName:conversation_share"`
+}
@@ -0,0 +1,44 @@
+package model
+
+import (
+	"time"
+)
+
+// Document 上传解析后的文档模型，原文件不保存，只保存切分后的文本片段
+type Document struct {
+	ID          uint      `json:"id" gorm:"primaryKey"`
+	UserID      uint      `json:"userId" gorm:"not null;index"`
+	WorkspaceID *uint     `json:"workspaceId" gorm:"index"` // 为空表示个人文档
+	FileName    string    `json:"fileName" gorm:"size:255;not null"`
+	Format      string    `json:"format" gorm:"size:20;not null"` // pdf docx html markdown code text
+	Size        int64     `json:"size" gorm:"not null"`
+	Checksum    string    `json:"checksum" gorm:"size:64;not null"`    // sha256
+	PageCount   int       `json:"pageCount" gorm:"not null;default:0"` // 0 表示格式本身不分页
+	ChunkCount  int       `json:"chunkCount" gorm:"not null;default:0"`
+	Tokens      int       `json:"tokens" gorm:"not null;default:0"` // 估算值
+	CreatedAt   time.Time `json:"createdAt" gorm:"autoCreateTime"`
+
+	// 关联关系
+	User   User            `json:"user,omitempty" gorm:"foreignKey:UserID"`
+	Chunks []DocumentChunk `json:"chunks,omitempty" gorm:"foreignKey:DocumentID"`
+
+	TableName string `json:"-" gorm:"tableName:document"`
+}
+
+// DocumentChunk 文档片段模型，记录来源页码和在该页文本中的字符位置
+// 另有全文检索生成列 search_vector（见 repository.migrateDocumentSearch）
+type DocumentChunk struct {
+	ID         uint   `json:"id" gorm:"primaryKey"`
+	DocumentID uint   `json:"documentId" gorm:"not null;uniqueIndex:idx_document_chunks_seq"`
+	Seq        int    `json:"seq" gorm:"not null;uniqueIndex:idx_document_chunks_seq"` // 从 0 开始的顺序
+	Page       int    `json:"page" gorm:"not null;default:0"`                          // 从 1 开始，0 表示不分页
+	Offset     int    `json:"offset" gorm:"not null"`                                  // 在所在页文本中的字符偏移
+	Length     int    `json:"length" gorm:"not null"`
+	Content    string `json:"content" gorm:"type:text;not null"`
+	Tokens     int    `json:"tokens" gorm:"not null"`
+
+	// 关联关系
+	Document Document `json:"document,omitempty" gorm:"foreignKey:DocumentID"`
+
+	TableName string `json:"-" gorm:"tableName:document_chunk"`
+}
@@ -8,18 +8,38 @@ import (
 
 // FixedPrompt 固定提示词模型
 type FixedPrompt struct {
-	ID        uint           `json:"id" gorm:"primaryKey"`
-	Name      string         `json:"name" gorm:"size:255;not null"`
-	Content   string         `json:"content" gorm:"type:text;not null"`
-	UserID    uint           `json:"userId" gorm:"not null;index"` // Added UserID
-	IsActive  bool           `json:"isActive" gorm:"default:true"`
-	CreatedAt time.Time      `json:"createdAt" gorm:"autoCreateTime"`
-	UpdatedAt time.Time      `json:"updatedAt" gorm:"autoUpdateTime"`
-	DeletedAt gorm.DeletedAt `json:"-" gorm:"index"`
+	ID          uint           `json:"id" gorm:"primaryKey"`
+	Name        string         `json:"name" gorm:"size:255;not null"`
+	Content     string         `json:"content" gorm:"type:text;not null"`
+	UserID      uint           `json:"userId" gorm:"not null;index"` // Added UserID
+	WorkspaceID *uint          `json:"workspaceId" gorm:"index"`     // 为空表示个人提示词
+	IsActive    bool           `json:"isActive" gorm:"default:true"`
+	Variables   string         `json:"variables" gorm:"type:jsonb;not null;default:'[]'"` // 模板变量定义
+	Version     int            `json:"version" gorm:"not null;default:1"`                 // 当前版本号
+	CreatedAt   time.Time      `json:"createdAt" gorm:"autoCreateTime"`
+	UpdatedAt   time.Time      `json:"updatedAt" gorm:"autoUpdateTime"`
+	DeletedAt   gorm.DeletedAt `json:"-" gorm:"index"`
 
 	TableName string `json:"-" gorm:"tableName:fixed_prompt"`
 }
 
+// FixedPromptVersion 固定提示词版本，修改内容或变量时追加一条，保存后不再修改
+type FixedPromptVersion struct {
+	ID            uint      `json:"id" gorm:"primaryKey"`
+	FixedPromptID uint      `json:"fixedPromptId" gorm:"not null;uniqueIndex:idx_fixed_prompt_version"`
+	Version       int       `json:"version" gorm:"not null;uniqueIndex:idx_fixed_prompt_version"` // 从 1 开始递增
+	Content       string    `json:"content" gorm:"type:text;not null"`
+	Variables     string    `json:"variables" gorm:"type:jsonb;not null;default:'[]'"`
+	UserID        uint      `json:"userId" gorm:"not null"` // 修改人
+	CreatedAt     time.Time `json:"createdAt" gorm:"autoCreateTime"`
+
+	// 关联关系
+	FixedPrompt FixedPrompt `json:"fixedPrompt,omitempty" gorm:"foreignKey:FixedPromptID"`
+	User        User        `json:"user,omitempty" gorm:"foreignKey:UserID"`
+
+	TableName string `json:"-" gorm:"tableName:fixed_prompt_version"`
+}
+
 // BeforeCreate 创建前钩子
 func (fp *FixedPrompt) BeforeCreate(tx *gorm.DB) error {
 	fp.IsActive = true
@@ -0,0 +1,61 @@
+package model
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// KnowledgeBase 知识库模型，由已解析的文档组成，关联到会话或固定提示词后每轮对话自动检索
+type KnowledgeBase struct {
+	ID          uint           `json:"id" gorm:"primaryKey"`
+	Name        string         `json:"name" gorm:"size:255;not null"`
+	Description string         `json:"description" gorm:"type:text"`
+	UserID      uint           `json:"userId" gorm:"not null;index"`
+	WorkspaceID *uint          `json:"workspaceId" gorm:"index"`       // 为空表示个人知识库
+	TopK        int            `json:"topK" gorm:"not null;default:5"` // 每轮检索的片段数
+	CreatedAt   time.Time      `json:"createdAt" gorm:"autoCreateTime"`
+	UpdatedAt   time.Time      `json:"updatedAt" gorm:"autoUpdateTime"`
+	DeletedAt   gorm.DeletedAt `json:"-" gorm:"index"`
+
+	// 关联关系
+	User User `json:"user,omitempty" gorm:"foreignKey:UserID"`
+
+	TableName string `json:"-" gorm:"tableName:knowledge_base"`
+}
+
+// KnowledgeBaseDocument 知识库包含的文档
+type KnowledgeBaseDocument struct {
+	KnowledgeBaseID uint      `json:"knowledgeBaseId" gorm:"primaryKey"`
+	DocumentID      uint      `json:"documentId" gorm:"primaryKey;index"`
+	CreatedAt       time.Time `json:"createdAt" gorm:"autoCreateTime"`
+
+	TableName string `json:"-" gorm:"tableName:knowledge_base_document"`
+}
+
+// ConversationKnowledgeBase 会话关联的知识库
+type ConversationKnowledgeBase struct {
+	ConversationID  uint      `json:"conversationId" gorm:"primaryKey"`
+	KnowledgeBaseID uint      `json:"knowledgeBaseId" gorm:"primaryKey;index"`
+	CreatedAt       time.Time `json:"createdAt" gorm:"autoCreateTime"`
+
+	TableName string `json:"-" gorm:"tableName:conversation_knowledge_base"`
+}
+
+// FixedPromptKnowledgeBase 固定提示词关联的知识库，使用该提示词的对话都会检索
+type FixedPromptKnowledgeBase struct {
+	FixedPromptID   uint      `json:"fixedPromptId" gorm:"primaryKey"`
+	KnowledgeBaseID uint      `json:"knowledgeBaseId" gorm:"primaryKey;index"`
+	CreatedAt       time.Time `json:"createdAt" gorm:"autoCreateTime"`
+
+	TableName string `json:"-" gorm:"tableName:fixed_prompt_knowledge_base"`
+}
+
+// AssistantKnowledgeBase 助手知识库模型
+type AssistantKnowledgeBase struct {
+	AssistantID     uint      `json:"assistantId" gorm:"primaryKey"`
+	KnowledgeBaseID uint      `json:"knowledgeBaseId" gorm:"primaryKey;index"`
+	CreatedAt       time.Time `json:"createdAt" gorm:"autoCreateTime"`
+
+	TableName string `json:"-" gorm:"tableName:assistant_knowledge_base"`
+}
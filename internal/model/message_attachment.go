@@ -0,0 +1,25 @@
+package model
+
+import (
+	"time"
+)
+
+// MessageAttachment 消息附件模型，文件内容保存在对象存储中，StorageKey 为对象路径
+type MessageAttachment struct {
+	ID             uint      `json:"id" gorm:"primaryKey"`
+	MessageID      uint      `json:"messageId" gorm:"not null;index"`
+	ConversationID uint      `json:"conversationId" gorm:"not null;index"`
+	UserID         uint      `json:"userId" gorm:"not null;index"` // 上传者，占用其存储配额
+	FileName       string    `json:"fileName" gorm:"size:255;not null"`
+	ContentType    string    `json:"contentType" gorm:"size:100;not null"` // 按文件内容识别的类型
+	Size           int64     `json:"size" gorm:"not null"`
+	Checksum       string    `json:"checksum" gorm:"size:64;not null"` // sha256
+	StorageKey     string    `json:"-" gorm:"size:255;not null;uniqueIndex"`
+	CreatedAt      time.Time `json:"createdAt" gorm:"autoCreateTime"`
+
+	// 关联关系
+	Message Message `json:"message,omitempty" gorm:"foreignKey:MessageID"`
+	User    User    `json:"user,omitempty" gorm:"foreignKey:UserID"`
+
+	TableName string `json:"-" gorm:"tableName:message_attachment"`
+}
@@ -0,0 +1,37 @@
+package model
+
+import (
+	"time"
+)
+
+// MessageEmbedding 消息向量模型，供语义检索使用
+// 安装了 pgvector 时另有 embedding 列（见 repository.migrateVectorSearch），由数据库计算相似度
+type MessageEmbedding struct {
+	ID             uint      `json:"id" gorm:"primaryKey"`
+	MessageID      uint      `json:"messageId" gorm:"not null;uniqueIndex"`
+	ConversationID uint      `json:"conversationId" gorm:"not null;index"`
+	Model          string    `json:"model" gorm:"size:100;not null;index"`
+	ContentHash    string    `json:"-" gorm:"size:32;not null"` // 消息内容的 md5，内容修改后重新索引
+	Dimensions     int       `json:"dimensions" gorm:"not null"`
+	Vector         []byte    `json:"-" gorm:"type:bytea;not null"` // float32 小端序
+	CreatedAt      time.Time `json:"createdAt" gorm:"autoCreateTime"`
+	UpdatedAt      time.Time `json:"updatedAt" gorm:"autoUpdateTime"`
+
+	// 关联关系
+	Message Message `json:"message,omitempty" gorm:"foreignKey:MessageID"`
+
+	TableName string `json:"-" gorm:"tableName:message_embedding"`
+}
+
+// MessageEmbeddingFailure 消息向量计算失败记录，到重试时间前索引任务跳过该消息
+type MessageEmbeddingFailure struct {
+	MessageID   uint      `json:"messageId" gorm:"primaryKey"`
+	Model       string    `json:"model" gorm:"size:100;not null"`
+	ContentHash string    `json:"-" gorm:"size:32;not null"` // 失败时的内容 md5，内容修改后立即重试
+	Attempts    int       `json:"attempts" gorm:"not null;default:0"`
+	LastError   string    `json:"lastError" gorm:"size:500"`
+	RetryAt     time.Time `json:"retryAt" gorm:"not null;index"`
+	UpdatedAt   time.Time `json:"updatedAt" gorm:"autoUpdateTime"`
+
+	TableName string `json:"-" gorm:"tableName:message_embedding_failure"`
+}
@@ -0,0 +1,19 @@
+package model
+
+import (
+	"time"
+)
+
+// RecoveryCode 两步验证恢复码模型，只保存哈希
+type RecoveryCode struct {
+	ID        uint       `json:"id" gorm:"primaryKey"`
+	UserID    uint       `json:"userId" gorm:"not null;index"`
+	CodeHash  string     `json:"-" gorm:"size:64;not null"`
+	UsedAt    *time.Time `json:"usedAt"`
+	CreatedAt time.Time  `json:"createdAt" gorm:"autoCreateTime"`
+
+	// 关联关系
+	User User `json:"user,omitempty" gorm:"foreignKey:UserID"`
+
+	TableName string `json:"-" gorm:"tableName:recovery_code"`
+}
@@ -0,0 +1,15 @@
+package model
+
+import (
+	"time"
+)
+
+// SystemSetting 系统设置模型，由管理员在运行时修改
+type SystemSetting struct {
+	Key       string    `json:"key" gorm:"primaryKey;size:100"`
+	Value     string    `json:"value" gorm:"type:text;not null"`
+	UpdatedBy uint      `json:"updatedBy"`
+	UpdatedAt time.Time `json:"updatedAt" gorm:"autoUpdateTime"`
+
+	TableName string `json:"-" gorm:"tableName:system_setting"`
+}
@@ -8,16 +8,22 @@ import (
 
 // User 用户模型
 type User struct {
-	ID        uint           `json:"id" gorm:"primaryKey"`
-	Name      string         `json:"name" gorm:"size:255;not null"`
-	Email     string         `json:"email" gorm:"size:255;uniqueIndex;not null"`
-	Password  string         `json:"-" gorm:"size:255;not null"`
-	Salt      string         `json:"-" gorm:"size:255;not null"`
-	Avatar    *string        `json:"avatar" gorm:"size:255"`
-	IsActive  bool           `json:"isActive" gorm:"default:true"`
-	CreatedAt time.Time      `json:"createdAt" gorm:"autoCreateTime"`
-	UpdatedAt time.Time      `json:"updatedAt" gorm:"autoUpdateTime"`
-	DeletedAt gorm.DeletedAt `json:"-" gorm:"index"`
+	ID              uint           `json:"id" gorm:"primaryKey"`
+	Name            string         `json:"name" gorm:"size:255;not null"`
+	Email           string         `json:"email" gorm:"size:255;uniqueIndex;not null"`
+	Password        string         `json:"-" gorm:"size:255;not null"`
+	Salt            string         `json:"-" gorm:"size:255;not null"`
+	Avatar          *string        `json:"avatar" gorm:"size:255"`
+	IsActive        bool           `json:"isActive" gorm:"default:true"`
+	Role            string         `json:"role" gorm:"size:20;not null;default:user"`
+	EmailVerifiedAt *time.Time     `json:"emailVerifiedAt"`   // 为空表示邮箱未验证
+	TOTPSecret      string         `json:"-" gorm:"size:255"` // 加密存储
+	TOTPEnabled     bool           `json:"totpEnabled" gorm:"default:false"`
+	TOTPLastStep    int64          `json:"-"` // 最近一次使用的验证码时间步，防止重放
+	MemoryEnabled   bool           `json:"memoryEnabled" gorm:"default:false"`
+	CreatedAt       time.Time      `json:"createdAt" gorm:"autoCreateTime"`
+	UpdatedAt       time.Time      `json:"updatedAt" gorm:"autoUpdateTime"`
+	DeletedAt       gorm.DeletedAt `json:"-" gorm:"index"`
 
 	// 关联关系
 	Conversations []Conversation `json:"conversations,omitempty" gorm:"foreignKey:UserID"`
@@ -0,0 +1,23 @@
+package model
+
+import (
+	"time"
+)
+
+// UserSession 登录会话模型，一次登录及其后续刷新视为同一会话
+type UserSession struct {
+	ID         uint       `json:"id" gorm:"primaryKey"`
+	UserID     uint       `json:"userId" gorm:"not null;index"`
+	UserAgent  string     `json:"userAgent" gorm:"size:512"`
+	IP         string     `json:"ip" gorm:"size:64"`
+	LastSeenAt time.Time  `json:"lastSeenAt"`
+	ExpiresAt  time.Time  `json:"expiresAt" gorm:"not null"`
+	RevokedAt  *time.Time `json:"revokedAt"`
+	CreatedAt  time.Time  `json:"createdAt" gorm:"autoCreateTime"`
+	UpdatedAt  time.Time  `json:"updatedAt" gorm:"autoUpdateTime"`
+
+	// 关联关系
+	User User `json:"user,omitempty" gorm:"foreignKey:UserID"`
+
+	TableName string `json:"-" gorm:"tableName:user_session"`
+}
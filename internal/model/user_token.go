@@ -0,0 +1,23 @@
+package model
+
+import (
+	"time"
+)
+
+// UserToken 一次性用户令牌模型（邮箱验证、密码重置、魔法链接登录、流式票据）
+type UserToken struct {
+	ID        uint       `json:"id" gorm:"primaryKey"`
+	UserID    uint       `json:"userId" gorm:"not null;index"`
+	Purpose   string     `json:"purpose" gorm:"size:32;not null;index"`
+	TokenHash string     `json:"-" gorm:"size:64;not null;uniqueIndex"`
+	Scope     string     `json:"scope" gorm:"size:100"` // 令牌的作用范围，如流式票据绑定的会话
+	Claims    *string    `json:"-" gorm:"type:text"`    // 签发时的附加信息（JSON），如流式票据的访问主体
+	ExpiresAt time.Time  `json:"expiresAt" gorm:"not null"`
+	UsedAt    *time.Time `json:"usedAt"`
+	CreatedAt time.Time  `json:"createdAt" gorm:"autoCreateTime"`
+
+	// 关联关系
+	User User `json:"user,omitempty" gorm:"foreignKey:UserID"`
+
+	TableName string `json:"-" gorm:"tableName:user_token"`
+}
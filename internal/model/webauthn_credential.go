@@ -0,0 +1,28 @@
+package model
+
+import (
+	"time"
+)
+
+// WebAuthnCredential 通行密钥模型
+type WebAuthnCredential struct {
+	ID              uint       `json:"id" gorm:"primaryKey"`
+	UserID          uint       `json:"userId" gorm:"not null;index"`
+	Name            string     `json:"name" gorm:"size:100"`
+	CredentialID    []byte     `json:"-" gorm:"not null;uniqueIndex"`
+	PublicKey       []byte     `json:"-" gorm:"not null"`
+	AttestationType string     `json:"-" gorm:"size:50"`
+	Transports      string     `json:"-" gorm:"size:255"` // 逗号分隔
+	AAGUID          []byte     `json:"-"`
+	SignCount       uint32     `json:"-" gorm:"not null;default:0"`
+	BackupEligible  bool       `json:"backupEligible" gorm:"not null;default:false"`
+	BackupState     bool       `json:"backupState" gorm:"not null;default:false"`
+	LastUsedAt      *time.Time `json:"lastUsedAt"`
+	CreatedAt       time.Time  `json:"createdAt" gorm:"autoCreateTime"`
+	UpdatedAt       time.Time  `json:"updatedAt" gorm:"autoUpdateTime"`
+
+	// 关联关系
+	User User `json:"user,omitempty" gorm:"foreignKey:UserID"`
+
+	TableName string `json:"-" gorm:"tableName:webauthn_credential"`
+}
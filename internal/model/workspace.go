@@ -0,0 +1,41 @@
+package model
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Workspace 工作区模型，成员共享其中的会话和固定提示词
+type Workspace struct {
+	ID              uint           `json:"id" gorm:"primaryKey"`
+	Name            string         `json:"name" gorm:"size:100;not null"`
+	OwnerID         uint           `json:"ownerId" gorm:"not null;index"`
+	DefaultModel    *string        `json:"defaultModel" gorm:"size:100"`    // 为空时使用系统默认模型
+	ProviderBaseURL *string        `json:"providerBaseUrl" gorm:"size:255"` // 为空时使用系统配置的接口地址
+	ProviderAPIKey  string         `json:"-" gorm:"size:512"`               // 加密存储，为空时使用系统配置的密钥；自定义接口地址时必须填写
+	CreatedAt       time.Time      `json:"createdAt" gorm:"autoCreateTime"`
+	UpdatedAt       time.Time      `json:"updatedAt" gorm:"autoUpdateTime"`
+	DeletedAt       gorm.DeletedAt `json:"-" gorm:"index"`
+
+	// 关联关系
+	Owner   User              `json:"owner,omitempty" gorm:"foreignKey:OwnerID"`
+	Members []WorkspaceMember `json:"members,omitempty" gorm:"foreignKey:WorkspaceID"`
+
+	TableName string `json:"-" gorm:"tableName:workspace"`
+}
+
+// WorkspaceMember 工作区成员模型
+type WorkspaceMember struct {
+	ID          uint      `json:"id" gorm:"primaryKey"`
+	WorkspaceID uint      `json:"workspaceId" gorm:"not null;uniqueIndex:idx_workspace_member"`
+	UserID      uint      `json:"userId" gorm:"not null;uniqueIndex:idx_workspace_member;index"`
+	Role        string    `json:"role" gorm:"size:20;not null"`
+	CreatedAt   time.Time `json:"createdAt" gorm:"autoCreateTime"`
+	UpdatedAt   time.Time `json:"updatedAt" gorm:"autoUpdateTime"`
+
+	// 关联关系
+	User User `json:"user,omitempty" gorm:"foreignKey:UserID"`
+
+	TableName string `json:"-" gorm:"tableName:workspace_member"`
+}
@@ -0,0 +1,133 @@
+package policy
+
+import (
+	"ai-chat/internal/common"
+	"errors"
+)
+
+// Action 对资源执行的操作，按权限从低到高排列
+type Action string
+
+const (
+	ActionRead   Action = "read"   // 查看
+	ActionWrite  Action = "write"  // 修改、发送消息
+	ActionDelete Action = "delete" // 删除
+)
+
+// 资源类型
+const (
+	KindConversation = "conversation"
+	KindMessage      = "message"
+	KindFixedPrompt  = "fixed_prompt"
+	KindDocument     = "document"
+	KindKnowledge    = "knowledge_base"
+	KindAssistant    = "assistant"
+	KindWorkspace    = "workspace"
+)
+
+var (
+	// ErrNotFound 资源不存在或没有读取权限，两者不做区分，避免泄露资源是否存在
+	ErrNotFound = errors.New("资源不存在或无权访问")
+	// ErrForbidden 可以读取资源但不允许执行该操作
+	ErrForbidden = errors.New("无权执行该操作")
+)
+
+// Principal 访问主体
+type Principal struct {
+	UserID         uint
+	Role           string
+	WorkspaceID    *uint           // 当前选择的工作区，为空表示个人空间
+	Workspaces     map[uint]string // 所属工作区及成员角色，由服务层按需加载
+	SessionID      uint            // 当前登录会话
+	ImpersonatorID uint            // 模拟登录的管理员，非模拟登录时为 0
+}
+
+// Resource 受保护资源的访问属性，由服务层加载后交给策略判断
+type Resource struct {
+	Kind        string
+	ID          uint
+	OwnerID     uint
+	WorkspaceID *uint // 所属工作区，为空表示个人资源；工作区本身也填写自己的ID
+}
+
+// workspaceContentGrants 工作区成员对工作区内会话、提示词等内容的权限
+var workspaceContentGrants = map[string]Action{
+	common.WorkspaceRoleOwner:  ActionDelete,
+	common.WorkspaceRoleAdmin:  ActionDelete,
+	common.WorkspaceRoleMember: ActionWrite,
+	common.WorkspaceRoleViewer: ActionRead,
+}
+
+// workspaceManageGrants 工作区成员对工作区本身的权限：写即管理成员和设置，删除仅限创建者
+var workspaceManageGrants = map[string]Action{
+	common.WorkspaceRoleOwner:  ActionDelete,
+	common.WorkspaceRoleAdmin:  ActionWrite,
+	common.WorkspaceRoleMember: ActionRead,
+	common.WorkspaceRoleViewer: ActionRead,
+}
+
+// Can 判断主体能否对资源执行操作
+// 取所有者和工作区授予权限中的最高者
+// 系统角色不授予内容权限，管理员只能通过留有审计记录的模拟登录访问他人内容
+func Can(p Principal, action Action, r *Resource) bool {
+	if r == nil || p.UserID == 0 {
+		return false
+	}
+	return rank(grant(p, r)) >= rank(action)
+}
+
+// Authorize 与 Can 相同，无权时返回错误
+// 不可读时返回 ErrNotFound，可读但不可执行该操作时返回 ErrForbidden
+func Authorize(p Principal, action Action, r *Resource) error {
+	if Can(p, action, r) {
+		return nil
+	}
+	if Can(p, ActionRead, r) {
+		return ErrForbidden
+	}
+	return ErrNotFound
+}
+
+// grant 计算主体对资源的最高权限
+// 工作区内容的创建者离开工作区后不再保留所有者权限
+func grant(p Principal, r *Resource) Action {
+	if r.OwnerID == p.UserID {
+		if r.WorkspaceID == nil {
+			return ActionDelete
+		}
+		if _, member := p.Workspaces[*r.WorkspaceID]; member {
+			return ActionDelete
+		}
+	}
+
+	best := Action("")
+	raise := func(a Action) {
+		if rank(a) > rank(best) {
+			best = a
+		}
+	}
+
+	if r.WorkspaceID != nil {
+		if role, ok := p.Workspaces[*r.WorkspaceID]; ok {
+			if r.Kind == KindWorkspace {
+				raise(workspaceManageGrants[role])
+			} else {
+				raise(workspaceContentGrants[role])
+			}
+		}
+	}
+	return best
+}
+
+// rank 权限等级，未知操作为 0
+func rank(a Action) int {
+	switch a {
+	case ActionRead:
+		return 1
+	case ActionWrite:
+		return 2
+	case ActionDelete:
+		return 3
+	}
+	return 0
+}
@@ -0,0 +1,104 @@
+package policy
+
+import (
+	"ai-chat/internal/common"
+	"errors"
+	"testing"
+)
+
+func TestCan(t *testing.T) {
+	ws := uint(7)
+	other := uint(8)
+
+	personal := &Resource{Kind: KindConversation, ID: 1, OwnerID: 1}
+	shared := &Resource{Kind: KindConversation, ID: 2, OwnerID: 1, WorkspaceID: &ws}
+	workspace := &Resource{Kind: KindWorkspace, ID: ws, OwnerID: 1, WorkspaceID: &ws}
+
+	user := func(id uint, workspaces map[uint]string) Principal {
+		return Principal{UserID: id, Role: common.RoleUser, Workspaces: workspaces}
+	}
+	member := func(role string) map[uint]string {
+		return map[uint]string{ws: role}
+	}
+
+	tests := []struct {
+		name    string
+		p       Principal
+		r       *Resource
+		allowed Action // 允许的最高操作，空表示不可读
+	}{
+		{"owner of personal resource", user(1, nil), personal, ActionDelete},
+		{"other user on personal resource", user(2, nil), personal, ""},
+		{"other user with unrelated workspace", user(2, map[uint]string{other: common.WorkspaceRoleOwner}), personal, ""},
+		{"anonymous principal", Principal{}, personal, ""},
+
+		{"owner still in workspace", user(1, member(common.WorkspaceRoleViewer)), shared, ActionDelete},
+		{"owner removed from workspace", user(1, nil), shared, ""},
+		{"workspace owner role", user(2, member(common.WorkspaceRoleOwner)), shared, ActionDelete},
+		{"workspace admin role", user(2, member(common.WorkspaceRoleAdmin)), shared, ActionDelete},
+		{"workspace member role", user(2, member(common.WorkspaceRoleMember)), shared, ActionWrite},
+		{"workspace viewer role", user(2, member(common.WorkspaceRoleViewer)), shared, ActionRead},
+		{"removed member", user(2, nil), shared, ""},
+		{"member of another workspace", user(2, map[uint]string{other: common.WorkspaceRoleOwner}), shared, ""},
+		{"unknown workspace role", user(2, member("guest")), shared, ""},
+
+		{"manage workspace as owner", user(2, member(common.WorkspaceRoleOwner)), workspace, ActionDelete},
+		{"manage workspace as admin", user(2, member(common.WorkspaceRoleAdmin)), workspace, ActionWrite},
+		{"manage workspace as member", user(2, member(common.WorkspaceRoleMember)), workspace, ActionRead},
+		{"manage workspace as viewer", user(2, member(common.WorkspaceRoleViewer)), workspace, ActionRead},
+
+		{"admin on personal resource", Principal{UserID: 9, Role: common.RoleAdmin}, personal, ""},
+		{"admin on workspace resource", Principal{UserID: 9, Role: common.RoleAdmin}, shared, ""},
+		{"admin on workspace", Principal{UserID: 9, Role: common.RoleAdmin}, workspace, ""},
+		{"auditor on personal resource", Principal{UserID: 9, Role: common.RoleAuditor}, personal, ""},
+		{"auditor on workspace", Principal{UserID: 9, Role: common.RoleAuditor}, workspace, ""},
+		{"admin who is a workspace viewer", Principal{UserID: 9, Role: common.RoleAdmin, Workspaces: member(common.WorkspaceRoleViewer)}, shared, ActionRead},
+		{"auditor who is a workspace member", Principal{UserID: 9, Role: common.RoleAuditor, Workspaces: member(common.WorkspaceRoleMember)}, shared, ActionWrite},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for _, action := range []Action{ActionRead, ActionWrite, ActionDelete} {
+				want := rank(action) <= rank(tt.allowed)
+				if got := Can(tt.p, action, tt.r); got != want {
+					t.Errorf("Can(%s) = %v, want %v", action, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestCanNilResource(t *testing.T) {
+	if Can(Principal{UserID: 1, Role: common.RoleAdmin}, ActionRead, nil) {
+		t.Fatal("Can should deny a nil resource")
+	}
+}
+
+func TestAuthorize(t *testing.T) {
+	ws := uint(7)
+	shared := &Resource{Kind: KindConversation, ID: 2, OwnerID: 1, WorkspaceID: &ws}
+
+	tests := []struct {
+		name   string
+		p      Principal
+		action Action
+		want   error
+	}{
+		{"owner deletes", Principal{UserID: 1, Workspaces: map[uint]string{ws: common.WorkspaceRoleMember}}, ActionDelete, nil},
+		{"member writes", Principal{UserID: 2, Workspaces: map[uint]string{ws: common.WorkspaceRoleMember}}, ActionWrite, nil},
+		{"member deletes", Principal{UserID: 2, Workspaces: map[uint]string{ws: common.WorkspaceRoleMember}}, ActionDelete, ErrForbidden},
+		{"viewer writes", Principal{UserID: 2, Workspaces: map[uint]string{ws: common.WorkspaceRoleViewer}}, ActionWrite, ErrForbidden},
+		{"removed member reads", Principal{UserID: 2}, ActionRead, ErrNotFound},
+		{"removed owner deletes", Principal{UserID: 1}, ActionDelete, ErrNotFound},
+		{"auditor reads", Principal{UserID: 9, Role: common.RoleAuditor}, ActionRead, ErrNotFound},
+		{"admin deletes", Principal{UserID: 9, Role: common.RoleAdmin}, ActionDelete, ErrNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := Authorize(tt.p, tt.action, shared); !errors.Is(err, tt.want) {
+				t.Fatalf("Authorize = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
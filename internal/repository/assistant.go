@@ -0,0 +1,28 @@
+package repository
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Assistant 助手数据库模型
+type Assistant struct {
+	ID           uint           `json:"id" gorm:"primaryKey"`
+	Name         string         `json:"name" gorm:"size:255;not null"`
+	Description  string         `json:"description" gorm:"type:text"`
+	UserID       uint           `json:"userId" gorm:"not null;index"`
+	WorkspaceID  *uint          `json:"workspaceId" gorm:"index"` // 为空表示个人助手
+	SystemPrompt string         `json:"systemPrompt" gorm:"type:text"`
+	Model        *string        `json:"model" gorm:"size:100"` // 为空时使用工作区或系统的默认模型
+	Temperature  *float64       `json:"temperature" gorm:"type:decimal(3,2)"`
+	TopP         *float64       `json:"topP" gorm:"type:decimal(3,2)"`
+	MaxTokens    *int           `json:"maxTokens"`
+	Tools        string         `json:"tools" gorm:"type:jsonb;not null;default:'[]'"`    // 启用的工具名称
+	Starters     string         `json:"starters" gorm:"type:jsonb;not null;default:'[]'"` // 开场问题
+	CreatedAt    time.Time      `json:"createdAt" gorm:"autoCreateTime"`
+	UpdatedAt    time.Time      `json:"updatedAt" gorm:"autoUpdateTime"`
+	DeletedAt    gorm.DeletedAt `json:"-" gorm:"index"`
+
+	TableName string `json:"-" gorm:"tableName:assistant"`
+}
@@ -0,0 +1,18 @@
+package repository
+
+import (
+	"time"
+)
+
+// AuditLog 审计日志数据库模型
+type AuditLog struct {
+	ID           uint      `json:"id" gorm:"primaryKey"`
+	ActorID      *uint     `json:"actorId" gorm:"index"`      // 操作人，匿名操作（如登录失败）为空
+	TargetUserID *uint     `json:"targetUserId" gorm:"index"` // 被操作的用户
+	Action       string    `json:"action" gorm:"size:64;not null;index"`
+	IP           string    `json:"ip" gorm:"size:64"`
+	Details      string    `json:"details" gorm:"type:text"`
+	CreatedAt    time.Time `json:"createdAt" gorm:"autoCreateTime;index"`
+
+	TableName string `json:"-" gorm:"tableName:audit_log"`
+}
@@ -11,10 +11,13 @@ type Conversation struct {
 	ID           uint           `json:"id" gorm:"primaryKey"`
 	Name         string         `json:"name" gorm:"size:255;not null"`
 	UserID       uint           `json:"userId" gorm:"not null;index"`
+	WorkspaceID  *uint          `json:"workspaceId" gorm:"index"` // 为空表示个人会话
 	IsActive     bool           `json:"isActive" gorm:"default:true"`
 	SystemPrompt *string        `json:"systemPrompt" gorm:"type:text"`
 	Model        *string        `json:"model" gorm:"size:100"`
 	Temperature  *float64       `json:"temperature" gorm:"type:decimal(3,2)"`
+	Metadata     *string        `json:"metadata" gorm:"type:jsonb"` // 导入来源等附加信息
+	AssistantID  *uint          `json:"assistantId" gorm:"index"`   // 创建时选择的助手
 	CreatedAt    time.Time      `json:"createdAt" gorm:"autoCreateTime"`
 	UpdatedAt    time.Time      `json:"updatedAt" gorm:"autoUpdateTime"`
 	DeletedAt    gorm.DeletedAt `json:"-" gorm:"index"`
@@ -0,0 +1,25 @@
+package repository
+
+import (
+	"time"
+)
+
+// ConversationShare 会话公开分享数据库模型
+type ConversationShare struct {
+	ID             uint       `json:"id" gorm:"primaryKey"`
+	ConversationID uint       `json:"conversationId" gorm:"not null;index"`
+	UserID         uint       `json:"userId" gorm:"not null;index"`
+	TokenHash      string     `json:"-" gorm:"size:64;not null;uniqueIndex"`
+	Title          string     `json:"title" gorm:"size:255;not null"`
+	Snapshot       string     `json:"-" gorm:"type:jsonb;not null"`
+	MessageCount   int        `json:"messageCount" gorm:"not null"`
+	HideReasoning  bool       `json:"hideReasoning" gorm:"default:false"`
+	PasswordSalt   string     `json:"-" gorm:"size:64"`
+	PasswordHash   string     `json:"-" gorm:"size:64"`
+	ExpiresAt      *time.Time `json:"expiresAt"`
+	RevokedAt      *time.Time `json:"revokedAt"`
+	ViewCount      int        `json:"viewCount" gorm:"default:0"`
+	CreatedAt      time.Time  `json:"createdAt" gorm:"autoCreateTime"`
+
+	TableName string `json:"-" gorm:"tableName:conversation_share"`
+}
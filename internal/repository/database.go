@@ -76,11 +76,109 @@ func AutoMigrate(db *gorm.DB) error {
 		&model.Conversation{},
 		&model.Message{},
 		&model.FixedPrompt{},
+		&model.FixedPromptVersion{},
+		&model.UserToken{},
+		&model.UserIdentity{},
+		&model.RecoveryCode{},
+		&model.SystemSetting{},
+		&model.WebAuthnCredential{},
+		&model.UserSession{},
+		&model.AuditLog{},
+		&model.LoginThrottle{},
+		&model.Workspace{},
+		&model.WorkspaceMember{},
+		&model.ConversationShare{},
+		&model.MessageEmbedding{},
+		&model.MessageEmbeddingFailure{},
+		&model.MessageAttachment{},
+		&model.Document{},
+		&model.DocumentChunk{},
+		&model.KnowledgeBase{},
+		&model.KnowledgeBaseDocument{},
+		&model.ConversationKnowledgeBase{},
+		&model.FixedPromptKnowledgeBase{},
+		&model.UserMemory{},
+		&model.ConversationMemory{},
+		&model.Assistant{},
+		&model.AssistantKnowledgeBase{},
 	)
 	if err != nil {
 		return fmt.Errorf("failed to auto migrate: %w", err)
 	}
 
+	if err := migrateMessageSearch(db); err != nil {
+		return fmt.Errorf("failed to migrate message search: %w", err)
+	}
+	if err := migrateDocumentSearch(db); err != nil {
+		return fmt.Errorf("failed to migrate document search: %w", err)
+	}
+	if err := migrateMessageContentHash(db); err != nil {
+		return fmt.Errorf("failed to migrate message content hash: %w", err)
+	}
+	migrateVectorSearch(db)
+
 	log.Println("Database migration completed successfully")
 	return nil
 }
+
+// migrateMessageSearch 创建消息全文检索的生成列和索引
+// simple 配置不会切分中文，先把中日韩字符逐字用空格隔开，查询时按短语匹配相邻字符
+func migrateMessageSearch(db *gorm.DB) error {
+	statements := []string{
+		`CREATE OR REPLACE FUNCTION search_split_cjk(text) RETURNS text
+			LANGUAGE sql IMMUTABLE PARALLEL SAFE AS $$
+			SELECT regexp_replace(left(coalesce($1, ''), 100000),
+				'([\u3040-\u30ff\u3400-\u4dbf\u4e00-\u9fff\uac00-\ud7af\uf900-\ufaff])', ' \1 ', 'g')
+		$$`,
+		`ALTER TABLE messages ADD COLUMN IF NOT EXISTS search_vector tsvector
+			GENERATED ALWAYS AS (
+				setweight(to_tsvector('simple', search_split_cjk(content)), 'A') ||
+				setweight(to_tsvector('simple', search_split_cjk(reasoning_content)), 'B')
+			) STORED`,
+		`CREATE INDEX IF NOT EXISTS idx_messages_search_vector ON messages USING GIN (search_vector)`,
+	}
+	for _, statement := range statements {
+		if err := db.Exec(statement).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// migrateDocumentSearch 创建文档片段全文检索的生成列和索引，依赖 migrateMessageSearch 创建的 search_split_cjk
+func migrateDocumentSearch(db *gorm.DB) error {
+	statements := []string{
+		`ALTER TABLE document_chunks ADD COLUMN IF NOT EXISTS search_vector tsvector
+			GENERATED ALWAYS AS (to_tsvector('simple', search_split_cjk(content))) STORED`,
+		`CREATE INDEX IF NOT EXISTS idx_document_chunks_search_vector ON document_chunks USING GIN (search_vector)`,
+	}
+	for _, statement := range statements {
+		if err := db.Exec(statement).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// migrateMessageContentHash 创建消息内容 md5 的生成列，写入时计算一次
+// 语义检索索引任务据此判断内容是否修改，不必每轮对全表重新计算
+func migrateMessageContentHash(db *gorm.DB) error {
+	return db.Exec(`ALTER TABLE messages ADD COLUMN IF NOT EXISTS content_hash char(32)
+		GENERATED ALWAYS AS (md5(content)) STORED`).Error
+}
+
+// migrateVectorSearch 尝试启用 pgvector 并添加向量列，失败时语义检索退回进程内计算
+func migrateVectorSearch(db *gorm.DB) {
+	if err := db.Exec("CREATE EXTENSION IF NOT EXISTS vector").Error; err != nil {
+		log.Printf("pgvector unavailable, semantic search will use in-process scoring: %v", err)
+		return
+	}
+	if err := db.Exec("ALTER TABLE message_embeddings ADD COLUMN IF NOT EXISTS embedding vector").Error; err != nil {
+		log.Printf("Failed to add embedding column, semantic search will use in-process scoring: %v", err)
+	}
+}
+
+// HasVectorColumn 数据库是否已启用 pgvector 向量列
+func HasVectorColumn(db *gorm.DB) bool {
+	return db.Migrator().HasColumn(&MessageEmbedding{}, "embedding")
+}
@@ -0,0 +1,36 @@
+package repository
+
+import (
+	"time"
+)
+
+// Document 文档数据库模型
+type Document struct {
+	ID          uint      `json:"id" gorm:"primaryKey"`
+	UserID      uint      `json:"userId" gorm:"not null;index"`
+	WorkspaceID *uint     `json:"workspaceId" gorm:"index"`
+	FileName    string    `json:"fileName" gorm:"size:255;not null"`
+	Format      string    `json:"format" gorm:"size:20;not null"`
+	Size        int64     `json:"size" gorm:"not null"`
+	Checksum    string    `json:"checksum" gorm:"size:64;not null"`
+	PageCount   int       `json:"pageCount" gorm:"not null;default:0"`
+	ChunkCount  int       `json:"chunkCount" gorm:"not null;default:0"`
+	Tokens      int       `json:"tokens" gorm:"not null;default:0"`
+	CreatedAt   time.Time `json:"createdAt" gorm:"autoCreateTime"`
+
+	TableName string `json:"-" gorm:"tableName:document"`
+}
+
+// DocumentChunk 文档片段数据库模型
+type DocumentChunk struct {
+	ID         uint   `json:"id" gorm:"primaryKey"`
+	DocumentID uint   `json:"documentId" gorm:"not null;uniqueIndex:idx_document_chunks_seq"`
+	Seq        int    `json:"seq" gorm:"not null;uniqueIndex:idx_document_chunks_seq"`
+	Page       int    `json:"page" gorm:"not null;default:0"`
+	Offset     int    `json:"offset" gorm:"not null"`
+	Length     int    `json:"length" gorm:"not null"`
+	Content    string `json:"content" gorm:"type:text;not null"`
+	Tokens     int    `json:"tokens" gorm:"not null"`
+
+	TableName string `json:"-" gorm:"tableName:document_chunk"`
+}
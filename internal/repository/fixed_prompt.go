@@ -8,14 +8,30 @@ import (
 
 // FixedPrompt 固定提示词数据库模型
 type FixedPrompt struct {
-	ID        uint           `json:"id" gorm:"primaryKey"`
-	Name      string         `json:"name" gorm:"size:255;not null"`
-	Content   string         `json:"content" gorm:"type:text;not null"`
-	UserID    uint           `json:"userId" gorm:"not null;index"` // Added UserID
-	IsActive  bool           `json:"isActive" gorm:"default:true"`
-	CreatedAt time.Time      `json:"createdAt" gorm:"autoCreateTime"`
-	UpdatedAt time.Time      `json:"updatedAt" gorm:"autoUpdateTime"`
-	DeletedAt gorm.DeletedAt `json:"-" gorm:"index"`
+	ID          uint           `json:"id" gorm:"primaryKey"`
+	Name        string         `json:"name" gorm:"size:255;not null"`
+	Content     string         `json:"content" gorm:"type:text;not null"`
+	UserID      uint           `json:"userId" gorm:"not null;index"` // Added UserID
+	WorkspaceID *uint          `json:"workspaceId" gorm:"index"`     // 为空表示个人提示词
+	IsActive    bool           `json:"isActive" gorm:"default:true"`
+	Variables   string         `json:"variables" gorm:"type:jsonb;not null;default:'[]'"` // 模板变量定义
+	Version     int            `json:"version" gorm:"not null;default:1"`                 // 当前版本号
+	CreatedAt   time.Time      `json:"createdAt" gorm:"autoCreateTime"`
+	UpdatedAt   time.Time      `json:"updatedAt" gorm:"autoUpdateTime"`
+	DeletedAt   gorm.DeletedAt `json:"-" gorm:"index"`
 
 	TableName string `json:"-" gorm:"tableName:fixed_prompt"`
 }
+
+// FixedPromptVersion 固定提示词版本数据库模型
+type FixedPromptVersion struct {
+	ID            uint      `json:"id" gorm:"primaryKey"`
+	FixedPromptID uint      `json:"fixedPromptId" gorm:"not null;uniqueIndex:idx_fixed_prompt_version"`
+	Version       int       `json:"version" gorm:"not null;uniqueIndex:idx_fixed_prompt_version"`
+	Content       string    `json:"content" gorm:"type:text;not null"`
+	Variables     string    `json:"variables" gorm:"type:jsonb;not null;default:'[]'"`
+	UserID        uint      `json:"userId" gorm:"not null"`
+	CreatedAt     time.Time `json:"createdAt" gorm:"autoCreateTime"`
+
+	TableName string `json:"-" gorm:"tableName:fixed_prompt_version"`
+}
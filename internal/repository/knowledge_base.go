@@ -0,0 +1,58 @@
+package repository
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// KnowledgeBase 知识库数据库模型
+type KnowledgeBase struct {
+	ID          uint           `json:"id" gorm:"primaryKey"`
+	Name        string         `json:"name" gorm:"size:255;not null"`
+	Description string         `json:"description" gorm:"type:text"`
+	UserID      uint           `json:"userId" gorm:"not null;index"`
+	WorkspaceID *uint          `json:"workspaceId" gorm:"index"`
+	TopK        int            `json:"topK" gorm:"not null;default:5"`
+	CreatedAt   time.Time      `json:"createdAt" gorm:"autoCreateTime"`
+	UpdatedAt   time.Time      `json:"updatedAt" gorm:"autoUpdateTime"`
+	DeletedAt   gorm.DeletedAt `json:"-" gorm:"index"`
+
+	TableName string `json:"-" gorm:"tableName:knowledge_base"`
+}
+
+// KnowledgeBaseDocument 知识库文档数据库模型
+type KnowledgeBaseDocument struct {
+	KnowledgeBaseID uint      `json:"knowledgeBaseId" gorm:"primaryKey"`
+	DocumentID      uint      `json:"documentId" gorm:"primaryKey;index"`
+	CreatedAt       time.Time `json:"createdAt" gorm:"autoCreateTime"`
+
+	TableName string `json:"-" gorm:"tableName:knowledge_base_document"`
+}
+
+// ConversationKnowledgeBase 会话知识库数据库模型
+type ConversationKnowledgeBase struct {
+	ConversationID  uint      `json:"conversationId" gorm:"primaryKey"`
+	KnowledgeBaseID uint      `json:"knowledgeBaseId" gorm:"primaryKey;index"`
+	CreatedAt       time.Time `json:"createdAt" gorm:"autoCreateTime"`
+
+	TableName string `json:"-" gorm:"tableName:conversation_knowledge_base"`
+}
+
+// FixedPromptKnowledgeBase 固定提示词知识库数据库模型
+type FixedPromptKnowledgeBase struct {
+	FixedPromptID   uint      `json:"fixedPromptId" gorm:"primaryKey"`
+	KnowledgeBaseID uint      `json:"knowledgeBaseId" gorm:"primaryKey;index"`
+	CreatedAt       time.Time `json:"createdAt" gorm:"autoCreateTime"`
+
+	TableName string `json:"-" gorm:"tableName:fixed_prompt_knowledge_base"`
+}
+
+// AssistantKnowledgeBase 助手知识库数据库模型
+type AssistantKnowledgeBase struct {
+	AssistantID     uint      `json:"assistantId" gorm:"primaryKey"`
+	KnowledgeBaseID uint      `json:"knowledgeBaseId" gorm:"primaryKey;index"`
+	CreatedAt       time.Time `json:"createdAt" gorm:"autoCreateTime"`
+
+	TableName string `json:"-" gorm:"tableName:assistant_knowledge_base"`
+}
@@ -0,0 +1,17 @@
+package repository
+
+import (
+	"time"
+)
+
+// LoginThrottle 登录失败计数数据库模型，Key 为 account:登录名 或 ip:地址
+type LoginThrottle struct {
+	Key           string     `json:"key" gorm:"primaryKey;size:255"`
+	Failures      int        `json:"failures" gorm:"not null;default:0"`
+	LastFailureAt time.Time  `json:"lastFailureAt"`
+	NextAttemptAt time.Time  `json:"nextAttemptAt"` // 渐进延迟，在此之前拒绝登录
+	LockedUntil   *time.Time `json:"lockedUntil"`
+	UpdatedAt     time.Time  `json:"updatedAt" gorm:"autoUpdateTime"`
+
+	TableName string `json:"-" gorm:"tableName:login_throttle"`
+}
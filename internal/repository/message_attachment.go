@@ -0,0 +1,21 @@
+package repository
+
+import (
+	"time"
+)
+
+// MessageAttachment 消息附件数据库模型
+type MessageAttachment struct {
+	ID             uint      `json:"id" gorm:"primaryKey"`
+	MessageID      uint      `json:"messageId" gorm:"not null;index"`
+	ConversationID uint      `json:"conversationId" gorm:"not null;index"`
+	UserID         uint      `json:"userId" gorm:"not null;index"`
+	FileName       string    `json:"fileName" gorm:"size:255;not null"`
+	ContentType    string    `json:"contentType" gorm:"size:100;not null"`
+	Size           int64     `json:"size" gorm:"not null"`
+	Checksum       string    `json:"checksum" gorm:"size:64;not null"`
+	StorageKey     string    `json:"-" gorm:"size:255;not null;uniqueIndex"`
+	CreatedAt      time.Time `json:"createdAt" gorm:"autoCreateTime"`
+
+	TableName string `json:"-" gorm:"tableName:message_attachment"`
+}
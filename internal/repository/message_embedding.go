@@ -0,0 +1,33 @@
+package repository
+
+import (
+	"time"
+)
+
+// MessageEmbedding 消息向量数据库模型
+type MessageEmbedding struct {
+	ID             uint      `json:"id" gorm:"primaryKey"`
+	MessageID      uint      `json:"messageId" gorm:"not null;uniqueIndex"`
+	ConversationID uint      `json:"conversationId" gorm:"not null;index"`
+	Model          string    `json:"model" gorm:"size:100;not null;index"`
+	ContentHash    string    `json:"-" gorm:"size:32;not null"`
+	Dimensions     int       `json:"dimensions" gorm:"not null"`
+	Vector         []byte    `json:"-" gorm:"type:bytea;not null"`
+	CreatedAt      time.Time `json:"createdAt" gorm:"autoCreateTime"`
+	UpdatedAt      time.Time `json:"updatedAt" gorm:"autoUpdateTime"`
+
+	TableName string `json:"-" gorm:"tableName:message_embedding"`
+}
+
+// MessageEmbeddingFailure 消息向量计算失败记录数据库模型
+type MessageEmbeddingFailure struct {
+	MessageID   uint      `json:"messageId" gorm:"primaryKey"`
+	Model       string    `json:"model" gorm:"size:100;not null"`
+	ContentHash string    `json:"-" gorm:"size:32;not null"`
+	Attempts    int       `json:"attempts" gorm:"not null;default:0"`
+	LastError   string    `json:"lastError" gorm:"size:500"`
+	RetryAt     time.Time `json:"retryAt" gorm:"not null;index"`
+	UpdatedAt   time.Time `json:"updatedAt" gorm:"autoUpdateTime"`
+
+	TableName string `json:"-" gorm:"tableName:message_embedding_failure"`
+}
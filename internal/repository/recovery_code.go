@@ -0,0 +1,16 @@
+package repository
+
+import (
+	"time"
+)
+
+// RecoveryCode 两步验证恢复码数据库模型，只保存哈希
+type RecoveryCode struct {
+	ID        uint       `json:"id" gorm:"primaryKey"`
+	UserID    uint       `json:"userId" gorm:"not null;index"`
+	CodeHash  string     `json:"-" gorm:"size:64;not null"`
+	UsedAt    *time.Time `json:"usedAt"`
+	CreatedAt time.Time  `json:"createdAt" gorm:"autoCreateTime"`
+
+	TableName string `json:"-" gorm:"tableName:recovery_code"`
+}
@@ -0,0 +1,19 @@
+package repository
+
+import (
+	"time"
+)
+
+// UserIdentity 外部身份数据库模型，将外部身份源（OIDC 等）的用户标识关联到本地用户
+type UserIdentity struct {
+	ID          uint       `json:"id" gorm:"primaryKey"`
+	UserID      uint       `json:"userId" gorm:"not null;index"`
+	Provider    string     `json:"provider" gorm:"size:255;not null;uniqueIndex:idx_user_identity_provider_subject"`
+	Subject     string     `json:"subject" gorm:"size:255;not null;uniqueIndex:idx_user_identity_provider_subject"`
+	Email       string     `json:"email" gorm:"size:255"`
+	LastLoginAt *time.Time `json:"lastLoginAt"`
+	CreatedAt   time.Time  `json:"createdAt" gorm:"autoCreateTime"`
+	UpdatedAt   time.Time  `json:"updatedAt" gorm:"autoUpdateTime"`
+
+	TableName string `json:"-" gorm:"tableName:user_identity"`
+}
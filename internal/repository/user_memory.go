@@ -0,0 +1,30 @@
+package repository
+
+import (
+	"time"
+)
+
+// UserMemory 用户长期记忆数据库模型
+type UserMemory struct {
+	ID             uint      `json:"id" gorm:"primaryKey"`
+	UserID         uint      `json:"userId" gorm:"not null;index"`
+	Content        string    `json:"content" gorm:"type:text;not null"`
+	Source         string    `json:"source" gorm:"size:20;not null"` // manual 或 extracted
+	ConversationID *uint     `json:"conversationId" gorm:"index"`    // 提取来源会话
+	CreatedAt      time.Time `json:"createdAt" gorm:"autoCreateTime"`
+	UpdatedAt      time.Time `json:"updatedAt" gorm:"autoUpdateTime"`
+
+	TableName string `json:"-" gorm:"tableName:user_memory"`
+}
+
+// ConversationMemory 会话记忆设置数据库模型
+type ConversationMemory struct {
+	ConversationID uint       `json:"conversationId" gorm:"primaryKey"`
+	Disabled       bool       `json:"disabled" gorm:"not null;default:false"`  // 不使用也不提取记忆
+	LastMessageID  uint       `json:"lastMessageId" gorm:"not null;default:0"` // 已提取到的消息
+	Failures       int        `json:"failures" gorm:"not null;default:0"`      // 连续提取失败次数
+	RetryAt        *time.Time `json:"retryAt"`                                 // 提取失败后，到该时间前不再重试
+	UpdatedAt      time.Time  `json:"updatedAt" gorm:"autoUpdateTime"`
+
+	TableName string `json:"-" gorm:"tableName:conversation_memory"`
+}
@@ -0,0 +1,34 @@
+package repository
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Workspace 工作区数据库模型
+type Workspace struct {
+	ID              uint           `json:"id" gorm:"primaryKey"`
+	Name            string         `json:"name" gorm:"size:100;not null"`
+	OwnerID         uint           `json:"ownerId" gorm:"not null;index"`
+	DefaultModel    *string        `json:"defaultModel" gorm:"size:100"`    // 为空时使用系统默认模型
+	ProviderBaseURL *string        `json:"providerBaseUrl" gorm:"size:255"` // 为空时使用系统配置的接口地址
+	ProviderAPIKey  string         `json:"-" gorm:"size:512"`               // 加密存储，为空时使用系统配置的密钥；自定义接口地址时必须填写
+	CreatedAt       time.Time      `json:"createdAt" gorm:"autoCreateTime"`
+	UpdatedAt       time.Time      `json:"updatedAt" gorm:"autoUpdateTime"`
+	DeletedAt       gorm.DeletedAt `json:"-" gorm:"index"`
+
+	TableName string `json:"-" gorm:"tableName:workspace"`
+}
+
+// WorkspaceMember 工作区成员数据库模型
+type WorkspaceMember struct {
+	ID          uint      `json:"id" gorm:"primaryKey"`
+	WorkspaceID uint      `json:"workspaceId" gorm:"not null;uniqueIndex:idx_workspace_member"`
+	UserID      uint      `json:"userId" gorm:"not null;uniqueIndex:idx_workspace_member;index"`
+	Role        string    `json:"role" gorm:"size:20;not null"`
+	CreatedAt   time.Time `json:"createdAt" gorm:"autoCreateTime"`
+	UpdatedAt   time.Time `json:"updatedAt" gorm:"autoUpdateTime"`
+
+	TableName string `json:"-" gorm:"tableName:workspace_member"`
+}
@@ -2,11 +2,13 @@ package router
 
 import (
 	"ai-chat/assets"
+	"ai-chat/internal/common"
 	"ai-chat/internal/handler"
 	"ai-chat/internal/middleware"
 	"io/fs"
 	"net/http"
 	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
 )
@@ -15,25 +17,79 @@ import (
 type Router struct {
 	engine    *gin.Engine
 	jwtSecret string
+	sessions  middleware.SessionValidator
+	tickets   middleware.StreamTicketRedeemer
+	audit     middleware.ImpersonationRecorder
+
+	// 登录、刷新令牌等接口的通用限流
+	rateLimit       int
+	rateLimitWindow time.Duration
+
+	// 邮件相关接口限流
+	mailRateLimit       int
+	mailRateLimitWindow time.Duration
 
 	// 处理器
 	authHandler         *handler.AuthHandler
+	accountHandler      *handler.AccountHandler
+	oidcHandler         *handler.OIDCHandler
+	twoFactorHandler    *handler.TwoFactorHandler
+	settingHandler      *handler.SettingHandler
+	webAuthnHandler     *handler.WebAuthnHandler
+	sessionHandler      *handler.SessionHandler
+	streamTicketHandler *handler.StreamTicketHandler
 	aiHandler           *handler.AIHandler
 	conversationHandler *handler.ConversationHandler
 	messageHandler      *handler.MessageHandler
 	fixedPromptHandler  *handler.FixedPromptHandler
 	userHandler         *handler.UserHandler
+	adminHandler        *handler.AdminHandler
+	workspaceHandler    *handler.WorkspaceHandler
+	shareHandler        *handler.ShareHandler
+	exportHandler       *handler.ExportHandler
+	importHandler       *handler.ImportHandler
+	searchHandler       *handler.SearchHandler
+	attachmentHandler   *handler.AttachmentHandler
+	documentHandler     *handler.DocumentHandler
+	knowledgeHandler    *handler.KnowledgeBaseHandler
+	memoryHandler       *handler.MemoryHandler
+	assistantHandler    *handler.AssistantHandler
 }
 
 // RouterConfig 路由配置
 type RouterConfig struct {
 	JWTSecret           string
+	Sessions            middleware.SessionValidator // 校验令牌所属会话是否已注销
+	StreamTickets       middleware.StreamTicketRedeemer
+	ImpersonationAudit  middleware.ImpersonationRecorder // 记录管理员模拟登录期间的请求
+	RateLimit           int
+	RateLimitWindow     time.Duration
+	MailRateLimit       int
+	MailRateLimitWindow time.Duration
 	AuthHandler         *handler.AuthHandler
+	AccountHandler      *handler.AccountHandler
+	OIDCHandler         *handler.OIDCHandler // 未启用单点登录时为 nil
+	TwoFactorHandler    *handler.TwoFactorHandler
+	SettingHandler      *handler.SettingHandler
+	WebAuthnHandler     *handler.WebAuthnHandler
+	SessionHandler      *handler.SessionHandler
+	StreamTicketHandler *handler.StreamTicketHandler
 	AIHandler           *handler.AIHandler
 	ConversationHandler *handler.ConversationHandler
 	MessageHandler      *handler.MessageHandler
 	FixedPromptHandler  *handler.FixedPromptHandler
 	UserHandler         *handler.UserHandler
+	AdminHandler        *handler.AdminHandler
+	WorkspaceHandler    *handler.WorkspaceHandler
+	ShareHandler        *handler.ShareHandler
+	ExportHandler       *handler.ExportHandler
+	ImportHandler       *handler.ImportHandler
+	SearchHandler       *handler.SearchHandler
+	AttachmentHandler   *handler.AttachmentHandler
+	DocumentHandler     *handler.DocumentHandler
+	KnowledgeHandler    *handler.KnowledgeBaseHandler
+	MemoryHandler       *handler.MemoryHandler
+	AssistantHandler    *handler.AssistantHandler
 }
 
 // NewRouter 创建路由
@@ -41,13 +97,39 @@ func NewRouter(config *RouterConfig) *Router {
 	r := &Router{
 		engine:    gin.Default(),
 		jwtSecret: config.JWTSecret,
+		sessions:  config.Sessions,
+		tickets:   config.StreamTickets,
+		audit:     config.ImpersonationAudit,
+
+		rateLimit:           config.RateLimit,
+		rateLimitWindow:     config.RateLimitWindow,
+		mailRateLimit:       config.MailRateLimit,
+		mailRateLimitWindow: config.MailRateLimitWindow,
 
 		authHandler:         config.AuthHandler,
+		accountHandler:      config.AccountHandler,
+		oidcHandler:         config.OIDCHandler,
+		twoFactorHandler:    config.TwoFactorHandler,
+		settingHandler:      config.SettingHandler,
+		webAuthnHandler:     config.WebAuthnHandler,
+		sessionHandler:      config.SessionHandler,
+		streamTicketHandler: config.StreamTicketHandler,
 		aiHandler:           config.AIHandler,
 		conversationHandler: config.ConversationHandler,
 		messageHandler:      config.MessageHandler,
 		fixedPromptHandler:  config.FixedPromptHandler,
 		userHandler:         config.UserHandler,
+		adminHandler:        config.AdminHandler,
+		workspaceHandler:    config.WorkspaceHandler,
+		shareHandler:        config.ShareHandler,
+		exportHandler:       config.ExportHandler,
+		importHandler:       config.ImportHandler,
+		searchHandler:       config.SearchHandler,
+		attachmentHandler:   config.AttachmentHandler,
+		documentHandler:     config.DocumentHandler,
+		knowledgeHandler:    config.KnowledgeHandler,
+		memoryHandler:       config.MemoryHandler,
+		assistantHandler:    config.AssistantHandler,
 	}
 
 	r.setupRoutes()
@@ -56,6 +138,10 @@ func NewRouter(config *RouterConfig) *Router {
 
 // setupRoutes 设置路由
 func (r *Router) setupRoutes() {
+	// 模拟登录审计需要在认证中间件之后读取上下文，全局注册并在请求结束后记录
+	if r.audit != nil {
+		r.engine.Use(middleware.ImpersonationAudit(r.audit))
+	}
 
 	publicFS, err := fs.Sub(assets.PublicFS, "public")
 	if err != nil {
@@ -77,6 +163,11 @@ func (r *Router) setupRoutes() {
 		c.Data(http.StatusOK, "text/html; charset=utf-8", content)
 	})
 
+	// 公开分享页面，无需登录，限流防止暴力尝试分享密码
+	shareLimit := middleware.RateLimit(r.rateLimit, r.rateLimitWindow)
+	r.engine.GET("/s/:token", shareLimit, r.shareHandler.Page)
+	r.engine.POST("/s/:token", shareLimit, r.shareHandler.Page)
+
 	// API版本组
 	v1 := r.engine.Group("/api/v1")
 	{
@@ -85,72 +176,265 @@ func (r *Router) setupRoutes() {
 		{
 			// 公网环境不注册
 			//auth.POST("/register", r.authHandler.Register)
-			auth.POST("/login", r.authHandler.Login)
-			auth.POST("/refresh", r.authHandler.RefreshToken)
+			// 登录失败次数另由 AuthService 按账户和IP统计
+			authLimit := middleware.RateLimit(r.rateLimit, r.rateLimitWindow)
+			auth.POST("/login", authLimit, r.authHandler.Login)
+			auth.POST("/refresh", authLimit, r.authHandler.RefreshToken)
 			auth.GET("/me",
-				middleware.Auth(r.jwtSecret), r.authHandler.GetProfile)
+				middleware.Auth(r.jwtSecret, r.sessions), r.authHandler.GetProfile)
+			auth.POST("/logout",
+				middleware.Auth(r.jwtSecret, r.sessions), r.authHandler.Logout)
+
+			// 邮箱验证、找回密码、魔法链接登录，均需限流防止滥发邮件和暴力尝试
+			// 每个接口单独计数，避免验证邮箱等正常操作耗尽找回密码的额度
+			mailLimit := func() gin.HandlerFunc {
+				return middleware.RateLimit(r.mailRateLimit, r.mailRateLimitWindow)
+			}
+			auth.POST("/verify-email/send",
+				middleware.Auth(r.jwtSecret, r.sessions), mailLimit(), r.accountHandler.SendVerificationEmail)
+			auth.POST("/verify-email", mailLimit(), r.accountHandler.VerifyEmail)
+			auth.POST("/password/forgot", mailLimit(), r.accountHandler.ForgotPassword)
+			auth.POST("/password/reset", mailLimit(), r.accountHandler.ResetPassword)
+			auth.POST("/magic-link", mailLimit(), r.accountHandler.RequestMagicLink)
+			auth.POST("/magic-link/verify", mailLimit(), r.accountHandler.LoginWithMagicLink)
+
+			// 两步验证登录第二步和通行密钥登录，限流防止暴力尝试
+			mfaLimit := middleware.RateLimit(10, time.Minute)
+			auth.POST("/2fa/verify", mfaLimit, r.twoFactorHandler.VerifyLogin)
+			auth.POST("/2fa/setup", mfaLimit, r.twoFactorHandler.BeginLoginSetup)
+			auth.POST("/2fa/enable", mfaLimit, r.twoFactorHandler.EnableLoginSetup)
+
+			// WebAuthn 通行密钥
+			webAuthn := auth.Group("/webauthn")
+			{
+				webAuthn.POST("/register/begin",
+					middleware.Auth(r.jwtSecret, r.sessions), middleware.DenyImpersonation(), r.webAuthnHandler.BeginRegistration)
+				webAuthn.POST("/register/finish",
+					middleware.Auth(r.jwtSecret, r.sessions), middleware.DenyImpersonation(), r.webAuthnHandler.FinishRegistration)
+				webAuthn.POST("/login/begin", mfaLimit, r.webAuthnHandler.BeginLogin)
+				webAuthn.POST("/login/finish", mfaLimit, r.webAuthnHandler.FinishLogin)
+				webAuthn.GET("/credentials",
+					middleware.Auth(r.jwtSecret, r.sessions), r.webAuthnHandler.ListCredentials)
+				webAuthn.DELETE("/credentials/:id",
+					middleware.Auth(r.jwtSecret, r.sessions), middleware.DenyImpersonation(), r.webAuthnHandler.DeleteCredential)
+			}
+
+			// OpenID Connect 单点登录
+			if r.oidcHandler != nil {
+				oidc := auth.Group("/oidc")
+				oidc.GET("", r.oidcHandler.GetConfig)
+				oidc.GET("/login", r.oidcHandler.Login)
+				oidc.GET("/callback", r.oidcHandler.Callback)
+			}
 		}
 
 		// AI对话路由
+		// EventSource 无法设置请求头，使用一次性票据认证
+		v1.GET("/ai/stream/:conversationId",
+			middleware.StreamTicket(r.tickets), r.aiHandler.StreamChatByConversationID)
+
 		ai := v1.Group("/ai")
-		ai.Use(middleware.Auth(r.jwtSecret))
+		ai.Use(middleware.Auth(r.jwtSecret, r.sessions), middleware.Workspace())
 		{
 			ai.POST("/chat", r.aiHandler.SendMessage)
 			ai.POST("/stream", r.aiHandler.StreamChat)
-			ai.GET("/stream/:conversationId", r.aiHandler.StreamChatByConversationID)
+			ai.POST("/stream-tickets", r.streamTicketHandler.Create)
 			// TODO: 模型可选
 			ai.GET("/models", r.aiHandler.GetModels)
 		}
 
 		// 对话路由
 		conversations := v1.Group("/conversations")
-		conversations.Use(middleware.Auth(r.jwtSecret))
+		conversations.Use(middleware.Auth(r.jwtSecret, r.sessions), middleware.Workspace())
 		{
 			conversations.POST("", r.conversationHandler.Create)
 			conversations.GET("", r.conversationHandler.GetList)
+			conversations.POST("/import", r.conversationHandler.Import)
+			conversations.POST("/import/history", r.importHandler.Import)
 			conversations.GET("/:id", r.conversationHandler.GetByID)
 			conversations.PUT("/:id", r.conversationHandler.Update)
 			conversations.DELETE("/:id", r.conversationHandler.Delete)
+			conversations.GET("/:id/export", r.exportHandler.Export)
+			conversations.GET("/:id/attachments", r.attachmentHandler.GetByConversation)
+			conversations.GET("/:id/knowledge-bases", r.knowledgeHandler.GetConversationKnowledgeBases)
+			conversations.PUT("/:id/knowledge-bases", r.knowledgeHandler.SetConversationKnowledgeBases)
+			conversations.GET("/:id/memory", r.memoryHandler.GetConversationSettings)
+			conversations.PUT("/:id/memory", r.memoryHandler.UpdateConversationSettings)
+
+			// 公开分享链接，分享在模拟登录令牌过期后仍然有效，模拟登录时不允许创建或撤销
+			conversations.POST("/:id/share", middleware.DenyImpersonation(), r.shareHandler.Create)
+			conversations.GET("/:id/shares", r.shareHandler.GetList)
+			conversations.DELETE("/:id/shares/:shareId", middleware.DenyImpersonation(), r.shareHandler.Revoke)
 		}
 
 		// 消息路由
 		messages := v1.Group("/messages")
-		messages.Use(middleware.Auth(r.jwtSecret))
+		messages.Use(middleware.Auth(r.jwtSecret, r.sessions), middleware.Workspace())
 		{
 			messages.POST("", r.messageHandler.Create)
 			messages.GET("", r.messageHandler.GetList)
+			messages.GET("/search", r.searchHandler.SearchMessages)
 			messages.GET("/conversation/:conversation_id", r.messageHandler.GetByConversationID)
 			messages.GET("/:id", r.messageHandler.GetByID)
 			messages.PUT("/:id", r.messageHandler.Update)
 			messages.DELETE("/:id", r.messageHandler.Delete)
+			messages.POST("/:id/attachments", r.attachmentHandler.Upload)
+			messages.GET("/:id/attachments", r.attachmentHandler.GetByMessage)
+		}
+
+		// 消息附件路由
+		attachments := v1.Group("/attachments")
+		attachments.Use(middleware.Auth(r.jwtSecret, r.sessions), middleware.Workspace())
+		{
+			attachments.GET("/usage", r.attachmentHandler.GetUsage)
+			attachments.GET("/:id/url", r.attachmentHandler.GetURL)
+			attachments.DELETE("/:id", r.attachmentHandler.Delete)
+		}
+
+		// 签名下载链接，无需登录
+		v1.GET("/attachments/:id/download", r.attachmentHandler.Download)
+
+		// 文档路由
+		documents := v1.Group("/documents")
+		documents.Use(middleware.Auth(r.jwtSecret, r.sessions), middleware.Workspace())
+		{
+			documents.POST("", r.documentHandler.Upload)
+			documents.GET("", r.documentHandler.GetList)
+			documents.GET("/search", r.documentHandler.Search)
+			documents.GET("/:id", r.documentHandler.GetByID)
+			documents.GET("/:id/chunks", r.documentHandler.GetChunks)
+			documents.DELETE("/:id", r.documentHandler.Delete)
+		}
+
+		// 知识库路由
+		knowledgeBases := v1.Group("/knowledge-bases")
+		knowledgeBases.Use(middleware.Auth(r.jwtSecret, r.sessions), middleware.Workspace())
+		{
+			knowledgeBases.POST("", r.knowledgeHandler.Create)
+			knowledgeBases.GET("", r.knowledgeHandler.GetList)
+			knowledgeBases.GET("/:id", r.knowledgeHandler.GetByID)
+			knowledgeBases.PUT("/:id", r.knowledgeHandler.Update)
+			knowledgeBases.DELETE("/:id", r.knowledgeHandler.Delete)
+			knowledgeBases.GET("/:id/documents", r.knowledgeHandler.GetDocuments)
+			knowledgeBases.POST("/:id/documents", r.knowledgeHandler.AddDocuments)
+			knowledgeBases.DELETE("/:id/documents/:documentId", r.knowledgeHandler.RemoveDocument)
+			knowledgeBases.GET("/:id/retrieve", r.knowledgeHandler.Retrieve)
+		}
+
+		// 助手路由
+		assistants := v1.Group("/assistants")
+		assistants.Use(middleware.Auth(r.jwtSecret, r.sessions), middleware.Workspace())
+		{
+			assistants.POST("", r.assistantHandler.Create)
+			assistants.GET("", r.assistantHandler.GetList)
+			assistants.GET("/:id", r.assistantHandler.GetByID)
+			assistants.PUT("/:id", r.assistantHandler.Update)
+			assistants.DELETE("/:id", r.assistantHandler.Delete)
+			assistants.POST("/:id/copy", r.assistantHandler.Copy)
+			assistants.GET("/:id/knowledge-bases", r.assistantHandler.GetKnowledgeBases)
+			assistants.PUT("/:id/knowledge-bases", r.assistantHandler.SetKnowledgeBases)
 		}
 
 		// 固定提示词路由
 		fixedPrompts := v1.Group("/fixed-prompts")
-		fixedPrompts.Use(middleware.Auth(r.jwtSecret))
+		fixedPrompts.Use(middleware.Auth(r.jwtSecret, r.sessions), middleware.Workspace())
 		{
 			fixedPrompts.POST("", r.fixedPromptHandler.Create)
 			fixedPrompts.GET("", r.fixedPromptHandler.GetList)
 			fixedPrompts.GET("/:id", r.fixedPromptHandler.GetByID)
 			fixedPrompts.PUT("/:id", r.fixedPromptHandler.Update)
 			fixedPrompts.DELETE("/:id", r.fixedPromptHandler.Delete)
+			fixedPrompts.GET("/:id/versions", r.fixedPromptHandler.GetVersions)
+			fixedPrompts.GET("/:id/versions/:version", r.fixedPromptHandler.GetVersion)
+			fixedPrompts.POST("/:id/versions/:version/rollback", r.fixedPromptHandler.Rollback)
+			fixedPrompts.GET("/:id/diff", r.fixedPromptHandler.Diff)
+			fixedPrompts.GET("/:id/knowledge-bases", r.knowledgeHandler.GetFixedPromptKnowledgeBases)
+			fixedPrompts.PUT("/:id/knowledge-bases", r.knowledgeHandler.SetFixedPromptKnowledgeBases)
+		}
+
+		// 跨会话检索
+		search := v1.Group("/search")
+		search.Use(middleware.Auth(r.jwtSecret, r.sessions), middleware.Workspace())
+		{
+			search.GET("/semantic", r.searchHandler.SemanticSearch)
+		}
+
+		// 公开分享内容，无需登录
+		v1.GET("/shares/:token", shareLimit, r.shareHandler.GetShared)
+
+		// 工作区路由，其他接口通过 X-Workspace-ID 请求头切换工作区
+		workspaces := v1.Group("/workspaces")
+		workspaces.Use(middleware.Auth(r.jwtSecret, r.sessions))
+		{
+			workspaces.POST("", r.workspaceHandler.Create)
+			workspaces.GET("", r.workspaceHandler.GetList)
+			workspaces.GET("/:workspaceId", r.workspaceHandler.GetByID)
+			workspaces.PUT("/:workspaceId", r.workspaceHandler.Update)
+			workspaces.DELETE("/:workspaceId", r.workspaceHandler.Delete)
+			workspaces.GET("/:workspaceId/members", r.workspaceHandler.GetMembers)
+			// 成员权限在模拟登录结束后仍然保留，模拟登录时不允许授予
+			workspaces.POST("/:workspaceId/members", middleware.DenyImpersonation(), r.workspaceHandler.AddMember)
+			workspaces.PUT("/:workspaceId/members/:id", middleware.DenyImpersonation(), r.workspaceHandler.UpdateMember)
+			workspaces.DELETE("/:workspaceId/members/:id", r.workspaceHandler.RemoveMember)
 		}
 
 		// 用户路由
 		users := v1.Group("/users")
-		users.Use(middleware.Auth(r.jwtSecret))
+		users.Use(middleware.Auth(r.jwtSecret, r.sessions))
 		{
 			users.GET("/profile", r.userHandler.GetProfile)
 			users.PUT("/profile", r.userHandler.UpdateProfile)
-			// users.PUT("/password", r.userHandler.UpdatePassword)
-			users.DELETE("/account", r.userHandler.DeleteAccount)
+			users.PUT("/password", middleware.DenyImpersonation(), r.userHandler.UpdatePassword)
+			users.DELETE("/account", middleware.DenyImpersonation(), r.userHandler.DeleteAccount)
+			users.GET("/export", r.exportHandler.ExportAccount)
+
+			// 登录会话管理
+			sessions := users.Group("/sessions", middleware.DenyImpersonation())
+			sessions.GET("", r.sessionHandler.GetList)
+			sessions.DELETE("", r.sessionHandler.RevokeOthers)
+			sessions.DELETE("/:id", r.sessionHandler.Revoke)
+
+			// 长期记忆
+			users.GET("/memories/settings", r.memoryHandler.GetSettings)
+			users.PUT("/memories/settings", r.memoryHandler.UpdateSettings)
+			users.GET("/memories", r.memoryHandler.GetList)
+			users.POST("/memories", r.memoryHandler.Create)
+			users.DELETE("/memories", r.memoryHandler.Clear)
+			users.PUT("/memories/:id", r.memoryHandler.Update)
+			users.DELETE("/memories/:id", r.memoryHandler.Delete)
 
-			// 管理员路由，暂时不提供
-			// admin := users.Group("/admin")
-			// {
-			// admin.GET("", r.userHandler.GetUserList)
-			// admin.GET("/:id", r.userHandler.GetUserByID)
-			// }
+			// 两步验证
+			users.GET("/2fa", r.twoFactorHandler.GetStatus)
+			// 需要校验验证码的操作与登录第二步一样限流
+			twoFactor := users.Group("/2fa", middleware.DenyImpersonation(), middleware.RateLimit(10, time.Minute))
+			twoFactor.POST("/setup", r.twoFactorHandler.BeginSetup)
+			twoFactor.POST("/enable", r.twoFactorHandler.Enable)
+			twoFactor.POST("/disable", r.twoFactorHandler.Disable)
+			twoFactor.POST("/recovery-codes", r.twoFactorHandler.RegenerateRecoveryCodes)
+		}
+
+		// 系统管理路由，审计员只能访问只读接口
+		admin := v1.Group("/admin")
+		admin.Use(middleware.Auth(r.jwtSecret, r.sessions), middleware.RequireRole(common.RoleAdmin, common.RoleAuditor))
+		{
+			admin.GET("/users", r.userHandler.GetUserList)
+			admin.GET("/users/:id", r.userHandler.GetUserByID)
+			admin.GET("/users/:id/usage", r.adminHandler.GetUsage)
+			admin.GET("/audit-logs", r.adminHandler.GetAuditLogs)
+
+			manage := admin.Group("", middleware.RequireRole(common.RoleAdmin))
+			{
+				manage.GET("/settings", r.settingHandler.Get)
+				manage.PUT("/settings", r.settingHandler.Update)
+
+				manage.POST("/users", r.adminHandler.CreateUser)
+				manage.POST("/users/:id/disable", r.adminHandler.DisableUser)
+				manage.POST("/users/:id/enable", r.adminHandler.EnableUser)
+				manage.PUT("/users/:id/role", r.adminHandler.UpdateRole)
+				manage.POST("/users/:id/password", r.adminHandler.ResetPassword)
+				manage.POST("/users/:id/unlock", r.authHandler.UnlockAccount)
+				manage.POST("/users/:id/impersonate", r.adminHandler.Impersonate)
+			}
 		}
 	}
 
@@ -160,7 +444,7 @@ func (r *Router) setupRoutes() {
 			"status": "ok",
 		})
 	})
-	
+
 	// 统一 404 处理
 	r.engine.NoRoute(func(c *gin.Context) {
 		// API 路径返回 JSON 格式错误
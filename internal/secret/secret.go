@@ -0,0 +1,58 @@
+package secret
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+)
+
+// Box 使用 AES-256-GCM 加密需要落库的敏感数据（TOTP 密钥等）
+type Box struct {
+	aead cipher.AEAD
+}
+
+// New 由主密钥和用途派生独立的加密密钥，不同用途的数据互不可解
+func New(masterKey, purpose string) (*Box, error) {
+	key := sha256.Sum256([]byte(purpose + ":" + masterKey))
+
+	block, err := aes.NewCipher(key[:])
+	if err != nil {
+		return nil, fmt.Errorf("初始化加密失败: %w", err)
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, fmt.Errorf("初始化加密失败: %w", err)
+	}
+	return &Box{aead: aead}, nil
+}
+
+// Encrypt 加密并返回 Base64 编码的 nonce+密文
+func (b *Box) Encrypt(plaintext string) (string, error) {
+	nonce := make([]byte, b.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", fmt.Errorf("生成随机数失败: %w", err)
+	}
+	sealed := b.aead.Seal(nonce, nonce, []byte(plaintext), nil)
+	return base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+// Decrypt 解密 Encrypt 的结果
+func (b *Box) Decrypt(ciphertext string) (string, error) {
+	data, err := base64.StdEncoding.DecodeString(ciphertext)
+	if err != nil {
+		return "", fmt.Errorf("解码密文失败: %w", err)
+	}
+	if len(data) < b.aead.NonceSize() {
+		return "", errors.New("密文长度无效")
+	}
+	nonce, sealed := data[:b.aead.NonceSize()], data[b.aead.NonceSize():]
+	plaintext, err := b.aead.Open(nil, nonce, sealed, nil)
+	if err != nil {
+		return "", fmt.Errorf("解密失败: %w", err)
+	}
+	return string(plaintext), nil
+}
@@ -0,0 +1,345 @@
+package service
+
+import (
+	"ai-chat/config"
+	"ai-chat/internal/mailer"
+	"ai-chat/internal/repository"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"net/url"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// 一次性令牌用途
+const (
+	TokenPurposeVerifyEmail   = "verify_email"
+	TokenPurposePasswordReset = "password_reset"
+	TokenPurposeMagicLink     = "magic_link"
+)
+
+// ErrInvalidToken 令牌无效、已使用或已过期
+var ErrInvalidToken = errors.New("链接无效或已过期")
+
+// ErrMailCooldown 距离上一封同类邮件发送时间过短
+var ErrMailCooldown = errors.New("邮件发送过于频繁，请稍后再试")
+
+// AccountService 账户服务：邮箱验证、找回密码、魔法链接登录
+type AccountService struct {
+	db          *gorm.DB
+	cfg         *config.Config
+	mailer      mailer.Mailer
+	authService *AuthService
+}
+
+// NewAccountService 创建账户服务
+func NewAccountService(db *gorm.DB, cfg *config.Config, m mailer.Mailer, authService *AuthService) *AccountService {
+	return &AccountService{
+		db:          db,
+		cfg:         cfg,
+		mailer:      m,
+		authService: authService,
+	}
+}
+
+// ResetPasswordRequest 重置密码请求
+type ResetPasswordRequest struct {
+	Token       string `json:"token" binding:"required"`
+	NewPassword string `json:"newPassword" binding:"required,min=6"`
+}
+
+// SendVerificationEmail 向用户发送邮箱验证邮件
+func (s *AccountService) SendVerificationEmail(userID uint) error {
+	var user repository.User
+	if err := s.db.First(&user, userID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return errors.New("用户不存在")
+		}
+		return fmt.Errorf("查找用户失败: %w", err)
+	}
+
+	if user.EmailVerifiedAt != nil {
+		return errors.New("邮箱已验证")
+	}
+
+	token, err := s.issueToken(user.ID, TokenPurposeVerifyEmail, time.Duration(s.cfg.EmailVerifyTokenTTL)*time.Second)
+	if err != nil {
+		return err
+	}
+
+	link := s.link("/verify-email", token)
+	return s.mailer.Send(&mailer.Message{
+		To:      user.Email,
+		Subject: "验证你的邮箱",
+		Text: fmt.Sprintf("你好 %s，\n\n请点击以下链接验证你的邮箱地址（%d 小时内有效）：\n\n%s\n\n如果这不是你本人的操作，请忽略此邮件。",
+			user.Name, s.cfg.EmailVerifyTokenTTL/3600, link),
+	})
+}
+
+// VerifyEmail 使用令牌完成邮箱验证
+func (s *AccountService) VerifyEmail(token string) error {
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		userToken, err := s.consumeToken(tx, token, TokenPurposeVerifyEmail)
+		if err != nil {
+			return err
+		}
+
+		now := time.Now()
+		if err := tx.Model(&repository.User{}).
+			Where("id = ? AND email_verified_at IS NULL", userToken.UserID).
+			Update("email_verified_at", now).Error; err != nil {
+			return fmt.Errorf("更新邮箱验证状态失败: %w", err)
+		}
+		return nil
+	})
+}
+
+// RequestPasswordReset 发送密码重置邮件
+// 为防止枚举邮箱，邮箱不存在时同样返回成功
+func (s *AccountService) RequestPasswordReset(email string) error {
+	user, err := s.findActiveUserByEmail(email)
+	if err != nil || user == nil {
+		return err
+	}
+
+	token, err := s.issueToken(user.ID, TokenPurposePasswordReset, time.Duration(s.cfg.PasswordResetTokenTTL)*time.Second)
+	if errors.Is(err, ErrMailCooldown) {
+		// 与邮箱不存在时的响应一致，不提示冷却
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	link := s.link("/reset-password", token)
+	return s.mailer.Send(&mailer.Message{
+		To:      user.Email,
+		Subject: "重置你的密码",
+		Text: fmt.Sprintf("你好 %s，\n\n我们收到了重置你账户密码的请求，请点击以下链接设置新密码（%d 分钟内有效）：\n\n%s\n\n如果这不是你本人的操作，请忽略此邮件，你的密码不会被修改。",
+			user.Name, s.cfg.PasswordResetTokenTTL/60, link),
+	})
+}
+
+// ResetPassword 使用令牌重置密码
+func (s *AccountService) ResetPassword(req *ResetPasswordRequest) error {
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		userToken, err := s.consumeToken(tx, req.Token, TokenPurposePasswordReset)
+		if err != nil {
+			return err
+		}
+
+		salt := GenerateSalt()
+		updates := map[string]interface{}{
+			"password": HashPassword(req.NewPassword, salt),
+			"salt":     salt,
+		}
+		if err := tx.Model(&repository.User{}).Where("id = ?", userToken.UserID).Updates(updates).Error; err != nil {
+			return fmt.Errorf("更新密码失败: %w", err)
+		}
+
+		// 能收到重置邮件说明邮箱有效，顺便标记为已验证
+		if err := tx.Model(&repository.User{}).
+			Where("id = ? AND email_verified_at IS NULL", userToken.UserID).
+			Update("email_verified_at", time.Now()).Error; err != nil {
+			return fmt.Errorf("更新邮箱验证状态失败: %w", err)
+		}
+
+		// 密码已重置，注销所有已登录的会话
+		if err := s.authService.sessions.RevokeAll(tx, userToken.UserID); err != nil {
+			return err
+		}
+
+		// 作废该用户其余未使用的重置令牌
+		return s.revokeTokens(tx, userToken.UserID, TokenPurposePasswordReset)
+	})
+}
+
+// RequestMagicLink 发送魔法链接登录邮件
+// 为防止枚举邮箱，邮箱不存在时同样返回成功
+func (s *AccountService) RequestMagicLink(email string) error {
+	user, err := s.findActiveUserByEmail(email)
+	if err != nil || user == nil {
+		return err
+	}
+
+	token, err := s.issueToken(user.ID, TokenPurposeMagicLink, time.Duration(s.cfg.MagicLinkTokenTTL)*time.Second)
+	if errors.Is(err, ErrMailCooldown) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	link := s.link("/magic-link", token)
+	return s.mailer.Send(&mailer.Message{
+		To:      user.Email,
+		Subject: "登录 AI Chat",
+		Text: fmt.Sprintf("你好 %s，\n\n点击以下链接即可直接登录（%d 分钟内有效，仅可使用一次）：\n\n%s\n\n如果这不是你本人的操作，请忽略此邮件。",
+			user.Name, s.cfg.MagicLinkTokenTTL/60, link),
+	})
+}
+
+// LoginWithMagicLink 使用魔法链接令牌登录
+func (s *AccountService) LoginWithMagicLink(token string, client ClientInfo) (*AuthResponse, error) {
+	var user repository.User
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		userToken, err := s.consumeToken(tx, token, TokenPurposeMagicLink)
+		if err != nil {
+			return err
+		}
+
+		if err := tx.First(&user, userToken.UserID).Error; err != nil {
+			return fmt.Errorf("查找用户失败: %w", err)
+		}
+		if !user.IsActive {
+			return ErrInvalidToken
+		}
+
+		if user.EmailVerifiedAt == nil {
+			now := time.Now()
+			user.EmailVerifiedAt = &now
+			if err := tx.Model(&user).Update("email_verified_at", now).Error; err != nil {
+				return fmt.Errorf("更新邮箱验证状态失败: %w", err)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// 邮箱只能证明第一因素，启用了两步验证的用户仍需完成第二步
+	return s.authService.LoginUser(&user, client)
+}
+
+// findActiveUserByEmail 根据邮箱查找启用中的用户，不存在时返回 nil, nil
+func (s *AccountService) findActiveUserByEmail(email string) (*repository.User, error) {
+	var user repository.User
+	if err := s.db.Where("email = ?", strings.TrimSpace(email)).First(&user).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			log.Printf("[account] 邮箱不存在，忽略请求")
+			return nil, nil
+		}
+		return nil, fmt.Errorf("查找用户失败: %w", err)
+	}
+	if !user.IsActive {
+		return nil, nil
+	}
+	return &user, nil
+}
+
+// issueToken 签发一次性令牌，数据库中只保存令牌哈希
+// 令牌格式为 随机串.签名，签名绑定用途，篡改或跨用途使用会在查库前被拒绝
+// 每个令牌对应一封邮件，冷却时间内已签发过同类令牌时返回 ErrMailCooldown
+func (s *AccountService) issueToken(userID uint, purpose string, ttl time.Duration) (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("生成令牌失败: %w", err)
+	}
+	nonce := base64.RawURLEncoding.EncodeToString(b)
+	token := nonce + "." + s.sign(purpose, nonce)
+
+	userToken := &repository.UserToken{
+		UserID:    userID,
+		Purpose:   purpose,
+		TokenHash: hashToken(token),
+		ExpiresAt: time.Now().Add(ttl),
+	}
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		// 锁定用户行，并发请求依次检查冷却时间
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Select("id").First(&repository.User{}, userID).Error; err != nil {
+			return fmt.Errorf("保存令牌失败: %w", err)
+		}
+		if s.cfg.MailCooldown > 0 {
+			var count int64
+			if err := tx.Model(&repository.UserToken{}).
+				Where("user_id = ? AND purpose = ? AND created_at > ?", userID, purpose,
+					time.Now().Add(-time.Duration(s.cfg.MailCooldown)*time.Second)).
+				Count(&count).Error; err != nil {
+				return fmt.Errorf("查询令牌失败: %w", err)
+			}
+			if count > 0 {
+				return ErrMailCooldown
+			}
+		}
+		if err := tx.Create(userToken).Error; err != nil {
+			return fmt.Errorf("保存令牌失败: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return "", err
+	}
+
+	return token, nil
+}
+
+// consumeToken 校验并消费一次性令牌
+func (s *AccountService) consumeToken(tx *gorm.DB, token, purpose string) (*repository.UserToken, error) {
+	nonce, sig, ok := strings.Cut(token, ".")
+	if !ok || !hmac.Equal([]byte(sig), []byte(s.sign(purpose, nonce))) {
+		return nil, ErrInvalidToken
+	}
+
+	var userToken repository.UserToken
+	if err := tx.Where("token_hash = ? AND purpose = ?", hashToken(token), purpose).First(&userToken).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrInvalidToken
+		}
+		return nil, fmt.Errorf("查找令牌失败: %w", err)
+	}
+
+	if userToken.UsedAt != nil || time.Now().After(userToken.ExpiresAt) {
+		return nil, ErrInvalidToken
+	}
+
+	// 条件更新保证并发请求下只有一个能成功消费
+	result := tx.Model(&repository.UserToken{}).
+		Where("id = ? AND used_at IS NULL", userToken.ID).
+		Update("used_at", time.Now())
+	if result.Error != nil {
+		return nil, fmt.Errorf("更新令牌状态失败: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return nil, ErrInvalidToken
+	}
+
+	return &userToken, nil
+}
+
+// revokeTokens 作废用户指定用途的所有未使用令牌
+func (s *AccountService) revokeTokens(tx *gorm.DB, userID uint, purpose string) error {
+	if err := tx.Model(&repository.UserToken{}).
+		Where("user_id = ? AND purpose = ? AND used_at IS NULL", userID, purpose).
+		Update("used_at", time.Now()).Error; err != nil {
+		return fmt.Errorf("作废令牌失败: %w", err)
+	}
+	return nil
+}
+
+// sign 计算令牌签名
+func (s *AccountService) sign(purpose, nonce string) string {
+	mac := hmac.New(sha256.New, []byte(s.cfg.JWTSecret))
+	mac.Write([]byte(purpose + "." + nonce))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// link 生成邮件中的前端链接
+func (s *AccountService) link(path, token string) string {
+	return s.cfg.AppBaseURL + path + "?token=" + url.QueryEscape(token)
+}
+
+// hashToken 计算令牌哈希用于存储和查找
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,280 @@
+package service
+
+import (
+	"ai-chat/internal/common"
+	"ai-chat/internal/repository"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// AdminService 管理后台用户管理服务，所有写操作都记入审计日志
+type AdminService struct {
+	db          *gorm.DB
+	authService *AuthService
+	audit       *AuditService
+}
+
+// NewAdminService 创建管理后台服务
+func NewAdminService(db *gorm.DB, authService *AuthService) *AdminService {
+	return &AdminService{
+		db:          db,
+		authService: authService,
+		audit:       authService.audit,
+	}
+}
+
+// ListAuditLogs 分页查询审计日志
+func (s *AdminService) ListAuditLogs(query *AuditLogQuery) ([]repository.AuditLog, int64, error) {
+	return s.audit.List(query)
+}
+
+// RecordImpersonatedRequest 记录模拟登录期间的请求
+func (s *AdminService) RecordImpersonatedRequest(impersonatorID, userID uint, method, path string, status int, ip string) {
+	s.audit.RecordImpersonatedRequest(impersonatorID, userID, method, path, status, ip)
+}
+
+// CreateUserRequest 管理员创建用户请求
+type CreateUserRequest struct {
+	Name     string `json:"name" binding:"required,min=2,max=100"`
+	Email    string `json:"email" binding:"required,email"`
+	Password string `json:"password" binding:"required,min=6"`
+	Role     string `json:"role" binding:"omitempty,oneof=user admin auditor"`
+}
+
+// UpdateRoleRequest 修改角色请求
+type UpdateRoleRequest struct {
+	Role string `json:"role" binding:"required,oneof=user admin auditor"`
+}
+
+// ResetPasswordByAdminRequest 管理员重置密码请求
+type ResetPasswordByAdminRequest struct {
+	Password string `json:"password" binding:"required,min=6"`
+}
+
+// UserUsage 用户使用量统计
+type UserUsage struct {
+	UserID         uint       `json:"userId"`
+	Conversations  int64      `json:"conversations"`
+	Messages       int64      `json:"messages"`
+	Tokens         int64      `json:"tokens"`
+	LastMessageAt  *time.Time `json:"lastMessageAt"`
+	ActiveSessions int64      `json:"activeSessions"`
+}
+
+// CreateUser 创建用户，管理员创建的账户视为邮箱已验证
+func (s *AdminService) CreateUser(adminID uint, req *CreateUserRequest, ip string) (*repository.User, error) {
+	email := strings.TrimSpace(req.Email)
+
+	var count int64
+	if err := s.db.Model(&repository.User{}).Where("email = ?", email).Count(&count).Error; err != nil {
+		return nil, fmt.Errorf("查找用户失败: %w", err)
+	}
+	if count > 0 {
+		return nil, errors.New("邮箱已被注册")
+	}
+
+	role := req.Role
+	if role == "" {
+		role = common.RoleUser
+	}
+
+	salt := GenerateSalt()
+	now := time.Now()
+	user := &repository.User{
+		Name:            req.Name,
+		Email:           email,
+		Password:        HashPassword(req.Password, salt),
+		Salt:            salt,
+		Role:            role,
+		EmailVerifiedAt: &now,
+	}
+	if err := s.db.Create(user).Error; err != nil {
+		return nil, fmt.Errorf("创建用户失败: %w", err)
+	}
+
+	s.audit.Record(&AuditEntry{
+		ActorID:      &adminID,
+		TargetUserID: &user.ID,
+		Action:       AuditUserCreated,
+		IP:           ip,
+		Details:      "role=" + role,
+	})
+	return user, nil
+}
+
+// SetActive 启用或禁用用户，禁用时注销其全部登录会话
+func (s *AdminService) SetActive(adminID, userID uint, active bool, ip string) (*repository.User, error) {
+	if adminID == userID && !active {
+		return nil, errors.New("不能禁用自己的账户")
+	}
+
+	user, err := s.authService.GetUserByID(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(user).Update("is_active", active).Error; err != nil {
+			return fmt.Errorf("更新用户状态失败: %w", err)
+		}
+		if !active {
+			return s.authService.sessions.RevokeAll(tx, user.ID)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	action := AuditUserEnabled
+	if !active {
+		action = AuditUserDisabled
+	}
+	s.audit.Record(&AuditEntry{
+		ActorID:      &adminID,
+		TargetUserID: &user.ID,
+		Action:       action,
+		IP:           ip,
+	})
+	return user, nil
+}
+
+// UpdateRole 修改用户角色，已签发令牌中的角色在刷新前保持不变，因此同时注销其会话
+func (s *AdminService) UpdateRole(adminID, userID uint, role, ip string) (*repository.User, error) {
+	if adminID == userID {
+		return nil, errors.New("不能修改自己的角色")
+	}
+
+	user, err := s.authService.GetUserByID(userID)
+	if err != nil {
+		return nil, err
+	}
+	previous := user.Role
+	if previous == role {
+		return user, nil
+	}
+
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(user).Update("role", role).Error; err != nil {
+			return fmt.Errorf("更新用户角色失败: %w", err)
+		}
+		return s.authService.sessions.RevokeAll(tx, user.ID)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	s.audit.Record(&AuditEntry{
+		ActorID:      &adminID,
+		TargetUserID: &user.ID,
+		Action:       AuditUserRoleChanged,
+		IP:           ip,
+		Details:      fmt.Sprintf("role=%s->%s", previous, role),
+	})
+	return user, nil
+}
+
+// ResetPassword 管理员重置用户密码，并注销其全部登录会话
+func (s *AdminService) ResetPassword(adminID, userID uint, password, ip string) error {
+	user, err := s.authService.GetUserByID(userID)
+	if err != nil {
+		return err
+	}
+
+	salt := GenerateSalt()
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(user).Updates(map[string]interface{}{
+			"password": HashPassword(password, salt),
+			"salt":     salt,
+		}).Error; err != nil {
+			return fmt.Errorf("重置密码失败: %w", err)
+		}
+		return s.authService.sessions.RevokeAll(tx, user.ID)
+	})
+	if err != nil {
+		return err
+	}
+
+	s.audit.Record(&AuditEntry{
+		ActorID:      &adminID,
+		TargetUserID: &user.ID,
+		Action:       AuditPasswordReset,
+		IP:           ip,
+	})
+	return nil
+}
+
+// Usage 统计用户的会话、消息和 token 使用量
+func (s *AdminService) Usage(userID uint) (*UserUsage, error) {
+	if _, err := s.authService.GetUserByID(userID); err != nil {
+		return nil, err
+	}
+
+	usage := &UserUsage{UserID: userID}
+	if err := s.db.Model(&repository.Conversation{}).
+		Where("user_id = ?", userID).
+		Count(&usage.Conversations).Error; err != nil {
+		return nil, fmt.Errorf("统计会话失败: %w", err)
+	}
+
+	var stats struct {
+		Messages      int64
+		Tokens        int64
+		LastMessageAt *time.Time
+	}
+	if err := s.db.Model(&repository.Message{}).
+		Select("COUNT(messages.id) AS messages, COALESCE(SUM(messages.tokens), 0) AS tokens, MAX(messages.created_at) AS last_message_at").
+		Joins("JOIN conversations ON conversations.id = messages.conversation_id").
+		Where("conversations.user_id = ?", userID).
+		Scan(&stats).Error; err != nil {
+		return nil, fmt.Errorf("统计消息失败: %w", err)
+	}
+	usage.Messages = stats.Messages
+	usage.Tokens = stats.Tokens
+	usage.LastMessageAt = stats.LastMessageAt
+
+	if err := s.db.Model(&repository.UserSession{}).
+		Where("user_id = ? AND revoked_at IS NULL AND expires_at > ?", userID, time.Now()).
+		Count(&usage.ActiveSessions).Error; err != nil {
+		return nil, fmt.Errorf("统计登录会话失败: %w", err)
+	}
+
+	return usage, nil
+}
+
+// Impersonate 管理员以目标用户身份登录，用于排查问题
+// 不能模拟管理员、审计员或已禁用的账户，令牌有效期一小时且不可刷新
+func (s *AdminService) Impersonate(adminID, userID uint, reason string, client ClientInfo) (*AuthResponse, error) {
+	if adminID == userID {
+		return nil, errors.New("不能模拟自己")
+	}
+
+	user, err := s.authService.GetUserByID(userID)
+	if err != nil {
+		return nil, err
+	}
+	if user.Role == common.RoleAdmin || user.Role == common.RoleAuditor {
+		return nil, errors.New("不能模拟管理员或审计员账户")
+	}
+	if !user.IsActive {
+		return nil, errors.New("账户已被禁用")
+	}
+
+	token, err := s.authService.issueImpersonationToken(adminID, user, client)
+	if err != nil {
+		return nil, err
+	}
+
+	s.audit.Record(&AuditEntry{
+		ActorID:      &adminID,
+		TargetUserID: &user.ID,
+		Action:       AuditImpersonateStart,
+		IP:           client.IP,
+		Details:      "reason=" + reason,
+	})
+	return &AuthResponse{User: user, Token: token}, nil
+}
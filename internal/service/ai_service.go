@@ -16,10 +16,11 @@ import (
 	"gorm.io/gorm"
 )
 
-// Message 消息结构体
+// Message 消息结构体，Parts 为文本之外的内容（如图片），序列化方式见 MarshalJSON
 type Message struct {
-	Role    string `json:"role"`
-	Content string `json:"content"`
+	Role    string        `json:"role"`
+	Content string        `json:"content"`
+	Parts   []ContentPart `json:"-"`
 }
 
 // ChatRequest 聊天请求
@@ -27,10 +28,21 @@ type ChatRequest struct {
 	Messages    []Message `json:"messages"`
 	Model       *string   `json:"model,omitempty"`
 	Temperature *float64  `json:"temperature,omitempty"`
+	TopP        *float64  `json:"top_p,omitempty"`
+	MaxTokens   *int      `json:"max_tokens,omitempty"`
 	Stream      bool      `json:"stream,omitempty"`
 	Thinking    *struct {
 		Type string `json:"type"`
 	} `json:"thinking,omitempty"`
+
+	Endpoint *AIEndpoint `json:"-"` // 为空时使用系统配置的接口
+}
+
+// AIEndpoint 模型接口配置（如工作区自定义的接口和密钥），为空的字段使用系统配置
+type AIEndpoint struct {
+	BaseURL string
+	APIKey  string
+	Model   string
 }
 
 // StreamResponse 流式响应
@@ -76,16 +88,38 @@ func NewAIService(db *gorm.DB, cfg *config.Config) *AIService {
 	}
 }
 
-// ChatCompletion 单次聊天完成
-func (s *AIService) ChatCompletion(req *ChatRequest) (*ChatResponse, error) {
+// resolveEndpoint 合并请求的接口配置和系统配置，返回接口地址和密钥，并填充默认模型
+func (s *AIService) resolveEndpoint(req *ChatRequest) (string, string) {
+	baseURL, apiKey, model := s.cfg.BaseURL, s.cfg.OpenAIKey, s.cfg.Model
+	if e := req.Endpoint; e != nil {
+		// 自定义接口地址只使用对应的密钥，不把系统密钥发给其他接口
+		if e.BaseURL != "" {
+			baseURL, apiKey = e.BaseURL, e.APIKey
+		}
+		if e.APIKey != "" {
+			apiKey = e.APIKey
+		}
+		if e.Model != "" {
+			model = e.Model
+		}
+	}
 	if req.Model == nil {
-		model := s.cfg.Model
 		req.Model = &model
 	}
+	return baseURL, apiKey
+}
+
+// ChatCompletion 单次聊天完成
+func (s *AIService) ChatCompletion(req *ChatRequest) (*ChatResponse, error) {
+	if HasImages(req.Messages) && !s.SupportsVision(req) {
+		return nil, ErrVisionNotSupported
+	}
+	baseURL, apiKey := s.resolveEndpoint(req)
 	if req.Temperature == nil {
 		temperature := 0.7
 		req.Temperature = &temperature
 	}
+	req.Messages = toProviderFormat(req.Messages, baseURL)
 
 	// 构建请求体
 	requestBody, err := json.Marshal(req)
@@ -94,18 +128,18 @@ func (s *AIService) ChatCompletion(req *ChatRequest) (*ChatResponse, error) {
 	}
 
 	// 构建请求
-	url := s.cfg.BaseURL + "/chat/completions"
+	url := baseURL + "/chat/completions"
 	httpReq, err := http.NewRequest("POST", url, bytes.NewBuffer(requestBody))
 	if err != nil {
 		return nil, fmt.Errorf("创建请求失败: %w", err)
 	}
 
 	httpReq.Header.Set("Content-Type", "application/json")
-	httpReq.Header.Set("Authorization", "Bearer "+s.cfg.OpenAIKey)
+	httpReq.Header.Set("Authorization", "Bearer "+apiKey)
 
 	// 发送请求
 	log.Printf("发送AI请求: URL=%s", url)
-	log.Printf("发送AI请求: Body=%s", string(requestBody))
+	log.Printf("发送AI请求: Body=%s", logBody(requestBody))
 
 	resp, err := s.client.Do(httpReq)
 	if err != nil {
@@ -138,14 +172,20 @@ func (s *AIService) ChatCompletion(req *ChatRequest) (*ChatResponse, error) {
 
 // StreamChat 流式聊天
 func (s *AIService) StreamChat(req *ChatRequest) (<-chan StreamResponse, <-chan error) {
-	if req.Model == nil {
-		model := s.cfg.Model
-		req.Model = &model
+	if HasImages(req.Messages) && !s.SupportsVision(req) {
+		responses := make(chan StreamResponse)
+		errors := make(chan error, 1)
+		errors <- ErrVisionNotSupported
+		close(responses)
+		close(errors)
+		return responses, errors
 	}
+	baseURL, apiKey := s.resolveEndpoint(req)
 	if req.Temperature == nil {
 		temperature := 0.7
 		req.Temperature = &temperature
 	}
+	req.Messages = toProviderFormat(req.Messages, baseURL)
 
 	// 默认开启思考模式，如果模型支持
 	if req.Thinking == nil {
@@ -174,7 +214,7 @@ func (s *AIService) StreamChat(req *ChatRequest) (<-chan StreamResponse, <-chan
 		}
 
 		// 构建请求
-		url := s.cfg.BaseURL + "/chat/completions"
+		url := baseURL + "/chat/completions"
 		httpReq, err := http.NewRequest("POST", url, bytes.NewBuffer(requestBody))
 		if err != nil {
 			errors <- fmt.Errorf("创建流式请求失败: %w", err)
@@ -182,11 +222,11 @@ func (s *AIService) StreamChat(req *ChatRequest) (<-chan StreamResponse, <-chan
 		}
 
 		httpReq.Header.Set("Content-Type", "application/json")
-		httpReq.Header.Set("Authorization", "Bearer "+s.cfg.OpenAIKey)
+		httpReq.Header.Set("Authorization", "Bearer "+apiKey)
 
 		// 发送请求
 		log.Printf("发送流式AI请求: model=%s, messages=%d", *streamReq.Model, len(streamReq.Messages))
-		log.Printf("发送流式AI请求: 请求体=%s", string(requestBody))
+		log.Printf("发送流式AI请求: 请求体=%s", logBody(requestBody))
 
 		startTime := time.Now()
 		resp, err := s.client.Do(httpReq)
@@ -306,3 +346,12 @@ func (s *AIService) GetConversationHistory(conversationID uint) ([]Message, erro
 
 	return chatMessages, nil
 }
+
+// logBody 日志中的请求体，内联图片可能很大，超过 4KB 时截断
+func logBody(body []byte) string {
+	const limit = 4096
+	if len(body) <= limit {
+		return string(body)
+	}
+	return fmt.Sprintf("%s...(共 %d 字节)", body[:limit], len(body))
+}
@@ -0,0 +1,424 @@
+package service
+
+import (
+	"ai-chat/internal/policy"
+	"ai-chat/internal/repository"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// 助手可启用的工具，即每轮对话中可以使用的能力
+const (
+	ToolMemory    = "memory"    // 加入用户的长期记忆
+	ToolKnowledge = "knowledge" // 检索关联的知识库并附带引用来源
+)
+
+// defaultAssistantTools 未指定工具时默认启用的工具
+var defaultAssistantTools = []string{ToolMemory, ToolKnowledge}
+
+// ErrAssistantSpace 助手与会话不在同一空间
+var ErrAssistantSpace = errors.New("助手只能在所属空间（个人或同一工作区）中使用")
+
+// AssistantService 助手服务
+type AssistantService struct {
+	db    *gorm.DB
+	authz *Authorizer
+}
+
+// NewAssistantService 创建助手服务
+func NewAssistantService(db *gorm.DB) *AssistantService {
+	return &AssistantService{
+		db:    db,
+		authz: NewAuthorizer(db),
+	}
+}
+
+// CreateAssistantRequest 创建助手请求，助手创建在主体当前选择的空间中，工具为空时启用全部工具
+type CreateAssistantRequest struct {
+	Name         string   `json:"name" binding:"required,min=1,max=255"`
+	Description  string   `json:"description"`
+	SystemPrompt string   `json:"systemPrompt"`
+	Model        *string  `json:"model,omitempty" binding:"omitempty,max=100"`
+	Temperature  *float64 `json:"temperature,omitempty" binding:"omitempty,min=0,max=2"`
+	TopP         *float64 `json:"topP,omitempty" binding:"omitempty,min=0,max=1"`
+	MaxTokens    *int     `json:"maxTokens,omitempty" binding:"omitempty,min=1"`
+	Tools        []string `json:"tools,omitempty" binding:"omitempty,dive,oneof=memory knowledge"`
+	Starters     []string `json:"starters,omitempty" binding:"max=6,dive,min=1,max=200"`
+}
+
+// UpdateAssistantRequest 更新助手请求，model 为空字符串表示使用默认模型，tools 为空列表表示不启用工具
+type UpdateAssistantRequest struct {
+	Name         *string  `json:"name,omitempty" binding:"omitempty,min=1,max=255"`
+	Description  *string  `json:"description,omitempty"`
+	SystemPrompt *string  `json:"systemPrompt,omitempty"`
+	Model        *string  `json:"model,omitempty" binding:"omitempty,max=100"`
+	Temperature  *float64 `json:"temperature,omitempty" binding:"omitempty,min=0,max=2"`
+	TopP         *float64 `json:"topP,omitempty" binding:"omitempty,min=0,max=1"`
+	MaxTokens    *int     `json:"maxTokens,omitempty" binding:"omitempty,min=1"`
+	Tools        []string `json:"tools,omitempty" binding:"omitempty,dive,oneof=memory knowledge"`
+	Starters     []string `json:"starters,omitempty" binding:"max=6,dive,min=1,max=200"`
+}
+
+// AssistantResponse 助手响应
+type AssistantResponse struct {
+	ID               uint      `json:"id"`
+	Name             string    `json:"name"`
+	Description      string    `json:"description"`
+	UserID           uint      `json:"userId"`
+	WorkspaceID      *uint     `json:"workspaceId"`
+	SystemPrompt     string    `json:"systemPrompt"`
+	Model            *string   `json:"model"`
+	Temperature      *float64  `json:"temperature"`
+	TopP             *float64  `json:"topP"`
+	MaxTokens        *int      `json:"maxTokens"`
+	Tools            []string  `json:"tools"`
+	Starters         []string  `json:"starters"`
+	KnowledgeBaseIDs []uint    `json:"knowledgeBaseIds"`
+	CreatedAt        time.Time `json:"createdAt"`
+	UpdatedAt        time.Time `json:"updatedAt"`
+}
+
+// AssistantSettings 对话时使用的助手配置，为 nil 表示会话没有选择助手
+type AssistantSettings struct {
+	ID           uint
+	SystemPrompt string
+	Model        *string
+	Temperature  *float64
+	TopP         *float64
+	MaxTokens    *int
+	Tools        []string
+}
+
+// Allows 是否启用了工具，没有选择助手时全部启用
+func (a *AssistantSettings) Allows(tool string) bool {
+	return a == nil || slices.Contains(a.Tools, tool)
+}
+
+// Apply 将助手的默认模型和参数填入请求中未指定的字段
+func (a *AssistantSettings) Apply(req *ChatRequest) {
+	if a == nil {
+		return
+	}
+	if req.Model == nil {
+		req.Model = a.Model
+	}
+	if req.Temperature == nil {
+		req.Temperature = a.Temperature
+	}
+	if req.TopP == nil {
+		req.TopP = a.TopP
+	}
+	if req.MaxTokens == nil {
+		req.MaxTokens = a.MaxTokens
+	}
+}
+
+// Create 创建助手
+func (s *AssistantService) Create(p policy.Principal, req *CreateAssistantRequest) (*AssistantResponse, error) {
+	if err := s.authz.WorkspaceContent(p, policy.ActionWrite); err != nil {
+		return nil, err
+	}
+
+	tools := req.Tools
+	if tools == nil {
+		tools = defaultAssistantTools
+	}
+	assistant := &repository.Assistant{
+		Name:         req.Name,
+		Description:  req.Description,
+		UserID:       p.UserID,
+		WorkspaceID:  p.WorkspaceID,
+		SystemPrompt: req.SystemPrompt,
+		Model:        nonEmpty(req.Model),
+		Temperature:  req.Temperature,
+		TopP:         req.TopP,
+		MaxTokens:    req.MaxTokens,
+		Tools:        encodeStrings(tools),
+		Starters:     encodeStrings(req.Starters),
+	}
+
+	if err := s.db.Create(assistant).Error; err != nil {
+		return nil, fmt.Errorf("创建助手失败: %w", err)
+	}
+	return toAssistantResponse(assistant, nil), nil
+}
+
+// FindAll 获取当前空间的助手，q 按名称过滤
+func (s *AssistantService) FindAll(p policy.Principal, page, pageSize int, q string) ([]*AssistantResponse, int64, error) {
+	if err := s.authz.WorkspaceContent(p, policy.ActionRead); err != nil {
+		return nil, 0, err
+	}
+
+	query := s.db.Model(&repository.Assistant{}).Scopes(s.authz.AssistantScope(p))
+	if q != "" {
+		query = query.Where("name ILIKE ?", "%"+q+"%")
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, fmt.Errorf("查询助手总数失败: %w", err)
+	}
+
+	var assistants []*repository.Assistant
+	if err := query.Offset((page - 1) * pageSize).Limit(pageSize).Order("created_at DESC").Find(&assistants).Error; err != nil {
+		return nil, 0, fmt.Errorf("查询助手列表失败: %w", err)
+	}
+
+	items, err := s.toResponses(assistants)
+	if err != nil {
+		return nil, 0, err
+	}
+	return items, total, nil
+}
+
+// FindByID 根据ID获取助手
+func (s *AssistantService) FindByID(p policy.Principal, id uint) (*AssistantResponse, error) {
+	assistant, err := s.authz.Assistant(p, policy.ActionRead, id)
+	if err != nil {
+		return nil, err
+	}
+
+	items, err := s.toResponses([]*repository.Assistant{assistant})
+	if err != nil {
+		return nil, err
+	}
+	return items[0], nil
+}
+
+// Update 更新助手，使用该助手的会话从下一轮对话开始生效
+func (s *AssistantService) Update(p policy.Principal, id uint, req *UpdateAssistantRequest) (*AssistantResponse, error) {
+	assistant, err := s.authz.Assistant(p, policy.ActionWrite, id)
+	if err != nil {
+		return nil, err
+	}
+
+	updates := make(map[string]interface{})
+	if req.Name != nil {
+		updates["name"] = *req.Name
+	}
+	if req.Description != nil {
+		updates["description"] = *req.Description
+	}
+	if req.SystemPrompt != nil {
+		updates["system_prompt"] = *req.SystemPrompt
+	}
+	if req.Model != nil {
+		updates["model"] = nonEmpty(req.Model)
+	}
+	if req.Temperature != nil {
+		updates["temperature"] = *req.Temperature
+	}
+	if req.TopP != nil {
+		updates["top_p"] = *req.TopP
+	}
+	if req.MaxTokens != nil {
+		updates["max_tokens"] = *req.MaxTokens
+	}
+	if req.Tools != nil {
+		updates["tools"] = encodeStrings(req.Tools)
+	}
+	if req.Starters != nil {
+		updates["starters"] = encodeStrings(req.Starters)
+	}
+	if len(updates) > 0 {
+		if err := s.db.Model(assistant).Updates(updates).Error; err != nil {
+			return nil, fmt.Errorf("更新助手失败: %w", err)
+		}
+	}
+
+	return s.FindByID(p, id)
+}
+
+// Delete 删除助手，已使用该助手的会话保留，之后按没有选择助手的会话处理
+func (s *AssistantService) Delete(p policy.Principal, id uint) error {
+	if _, err := s.authz.Assistant(p, policy.ActionDelete, id); err != nil {
+		return err
+	}
+
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("assistant_id = ?", id).Delete(&repository.AssistantKnowledgeBase{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&repository.Assistant{}, id).Error
+	})
+	if err != nil {
+		return fmt.Errorf("删除助手失败: %w", err)
+	}
+	return nil
+}
+
+// Copy 将可读取的助手复制到主体当前选择的空间，用于把个人助手分享到工作区或在工作区之间复用
+// 知识库只在与新助手同一空间时保留关联
+func (s *AssistantService) Copy(p policy.Principal, id uint) (*AssistantResponse, error) {
+	source, err := s.authz.Assistant(p, policy.ActionRead, id)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.authz.WorkspaceContent(p, policy.ActionWrite); err != nil {
+		return nil, err
+	}
+
+	var knowledgeBases []*repository.KnowledgeBase
+	if err := s.db.Where("id IN (?)", s.db.Model(&repository.AssistantKnowledgeBase{}).
+		Select("knowledge_base_id").Where("assistant_id = ?", id)).
+		Find(&knowledgeBases).Error; err != nil {
+		return nil, fmt.Errorf("查询助手知识库失败: %w", err)
+	}
+
+	assistant := &repository.Assistant{
+		Name:         source.Name,
+		Description:  source.Description,
+		UserID:       p.UserID,
+		WorkspaceID:  p.WorkspaceID,
+		SystemPrompt: source.SystemPrompt,
+		Model:        source.Model,
+		Temperature:  source.Temperature,
+		TopP:         source.TopP,
+		MaxTokens:    source.MaxTokens,
+		Tools:        source.Tools,
+		Starters:     source.Starters,
+	}
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(assistant).Error; err != nil {
+			return err
+		}
+		var links []*repository.AssistantKnowledgeBase
+		for _, knowledgeBase := range knowledgeBases {
+			if sameSpace(knowledgeBase, p.UserID, p.WorkspaceID) {
+				links = append(links, &repository.AssistantKnowledgeBase{AssistantID: assistant.ID, KnowledgeBaseID: knowledgeBase.ID})
+			}
+		}
+		if len(links) == 0 {
+			return nil
+		}
+		return tx.Create(&links).Error
+	})
+	if err != nil {
+		return nil, fmt.Errorf("复制助手失败: %w", err)
+	}
+
+	return s.FindByID(p, assistant.ID)
+}
+
+// Select 校验主体可以在当前空间的新会话中使用助手，返回对话时使用的配置
+func (s *AssistantService) Select(p policy.Principal, id uint) (*AssistantSettings, error) {
+	assistant, err := s.authz.Assistant(p, policy.ActionRead, id)
+	if err != nil {
+		return nil, err
+	}
+	if !spaceMatches(assistant.UserID, assistant.WorkspaceID, p.UserID, p.WorkspaceID) {
+		return nil, ErrAssistantSpace
+	}
+	return toAssistantSettings(assistant), nil
+}
+
+// ForConversation 返回会话选择的助手配置，没有选择助手或助手已删除时返回 nil
+// 调用方需已校验会话读取权限
+func (s *AssistantService) ForConversation(conversationID uint) (*AssistantSettings, error) {
+	var assistant repository.Assistant
+	err := s.db.Joins("JOIN conversations ON conversations.assistant_id = assistants.id").
+		Where("conversations.id = ?", conversationID).
+		Take(&assistant).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("查询会话助手失败: %w", err)
+	}
+	return toAssistantSettings(&assistant), nil
+}
+
+// toResponses 转换为响应结构并查询关联的知识库
+func (s *AssistantService) toResponses(assistants []*repository.Assistant) ([]*AssistantResponse, error) {
+	knowledgeBases := make(map[uint][]uint, len(assistants))
+	if len(assistants) > 0 {
+		ids := make([]uint, len(assistants))
+		for i, assistant := range assistants {
+			ids[i] = assistant.ID
+		}
+
+		var links []*repository.AssistantKnowledgeBase
+		if err := s.db.Where("assistant_id IN ?", ids).
+			Order("assistant_id, knowledge_base_id").
+			Find(&links).Error; err != nil {
+			return nil, fmt.Errorf("查询助手知识库失败: %w", err)
+		}
+		for _, link := range links {
+			knowledgeBases[link.AssistantID] = append(knowledgeBases[link.AssistantID], link.KnowledgeBaseID)
+		}
+	}
+
+	items := make([]*AssistantResponse, len(assistants))
+	for i, assistant := range assistants {
+		items[i] = toAssistantResponse(assistant, knowledgeBases[assistant.ID])
+	}
+	return items, nil
+}
+
+// toAssistantSettings 转换为对话时使用的助手配置
+func toAssistantSettings(assistant *repository.Assistant) *AssistantSettings {
+	return &AssistantSettings{
+		ID:           assistant.ID,
+		SystemPrompt: assistant.SystemPrompt,
+		Model:        assistant.Model,
+		Temperature:  assistant.Temperature,
+		TopP:         assistant.TopP,
+		MaxTokens:    assistant.MaxTokens,
+		Tools:        decodeStrings(assistant.Tools),
+	}
+}
+
+// toAssistantResponse 转换为助手响应
+func toAssistantResponse(assistant *repository.Assistant, knowledgeBaseIDs []uint) *AssistantResponse {
+	if knowledgeBaseIDs == nil {
+		knowledgeBaseIDs = []uint{}
+	}
+	return &AssistantResponse{
+		ID:               assistant.ID,
+		Name:             assistant.Name,
+		Description:      assistant.Description,
+		UserID:           assistant.UserID,
+		WorkspaceID:      assistant.WorkspaceID,
+		SystemPrompt:     assistant.SystemPrompt,
+		Model:            assistant.Model,
+		Temperature:      assistant.Temperature,
+		TopP:             assistant.TopP,
+		MaxTokens:        assistant.MaxTokens,
+		Tools:            decodeStrings(assistant.Tools),
+		Starters:         decodeStrings(assistant.Starters),
+		KnowledgeBaseIDs: knowledgeBaseIDs,
+		CreatedAt:        assistant.CreatedAt,
+		UpdatedAt:        assistant.UpdatedAt,
+	}
+}
+
+// nonEmpty 空字符串转换为 nil
+func nonEmpty(s *string) *string {
+	if s == nil || *s == "" {
+		return nil
+	}
+	return s
+}
+
+// encodeStrings 将字符串列表编码为 JSON 数组
+func encodeStrings(values []string) string {
+	if values == nil {
+		values = []string{}
+	}
+	data, _ := json.Marshal(values)
+	return string(data)
+}
+
+// decodeStrings 解析 JSON 数组，格式错误时返回空列表
+func decodeStrings(data string) []string {
+	values := []string{}
+	if data != "" {
+		_ = json.Unmarshal([]byte(data), &values)
+	}
+	return values
+}
@@ -0,0 +1,105 @@
+package service
+
+import (
+	"ai-chat/internal/repository"
+	"fmt"
+	"log"
+
+	"gorm.io/gorm"
+)
+
+// 审计事件
+const (
+	AuditLoginFailed   = "login.failed"
+	AuditLoginLocked   = "login.locked"
+	AuditAccountUnlock = "account.unlocked"
+
+	AuditUserCreated       = "user.created"
+	AuditUserDisabled      = "user.disabled"
+	AuditUserEnabled       = "user.enabled"
+	AuditUserRoleChanged   = "user.role_changed"
+	AuditPasswordReset     = "user.password_reset"
+	AuditImpersonateStart  = "impersonation.started"
+	AuditImpersonateAction = "impersonation.request"
+)
+
+// AuditEntry 审计记录
+type AuditEntry struct {
+	ActorID      *uint
+	TargetUserID *uint
+	Action       string
+	IP           string
+	Details      string
+}
+
+// AuditService 审计日志服务
+type AuditService struct {
+	db *gorm.DB
+}
+
+// NewAuditService 创建审计日志服务
+func NewAuditService(db *gorm.DB) *AuditService {
+	return &AuditService{db: db}
+}
+
+// Record 写入审计日志，写入失败只记录日志，不影响业务流程
+func (s *AuditService) Record(entry *AuditEntry) {
+	record := &repository.AuditLog{
+		ActorID:      entry.ActorID,
+		TargetUserID: entry.TargetUserID,
+		Action:       entry.Action,
+		IP:           truncateRunes(entry.IP, 64),
+		Details:      entry.Details,
+	}
+	if err := s.db.Create(record).Error; err != nil {
+		log.Printf("[audit] 写入审计日志失败: %v, action=%s", err, entry.Action)
+	}
+}
+
+// RecordImpersonatedRequest 记录模拟登录期间的请求，供中间件调用
+func (s *AuditService) RecordImpersonatedRequest(impersonatorID, userID uint, method, path string, status int, ip string) {
+	s.Record(&AuditEntry{
+		ActorID:      &impersonatorID,
+		TargetUserID: &userID,
+		Action:       AuditImpersonateAction,
+		IP:           ip,
+		Details:      fmt.Sprintf("%s %s -> %d", method, path, status),
+	})
+}
+
+// AuditLogQuery 审计日志查询条件
+type AuditLogQuery struct {
+	Page         int    `form:"page,default=1" binding:"min=1"`
+	PageSize     int    `form:"page_size,default=20" binding:"min=1,max=100"`
+	Action       string `form:"action"`
+	ActorID      uint   `form:"actor_id"`
+	TargetUserID uint   `form:"target_user_id"`
+}
+
+// List 分页查询审计日志，按时间倒序
+func (s *AuditService) List(query *AuditLogQuery) ([]repository.AuditLog, int64, error) {
+	db := s.db.Model(&repository.AuditLog{})
+	if query.Action != "" {
+		db = db.Where("action = ?", query.Action)
+	}
+	if query.ActorID != 0 {
+		db = db.Where("actor_id = ?", query.ActorID)
+	}
+	if query.TargetUserID != 0 {
+		db = db.Where("target_user_id = ?", query.TargetUserID)
+	}
+
+	var total int64
+	if err := db.Count(&total).Error; err != nil {
+		return nil, 0, fmt.Errorf("查询审计日志失败: %w", err)
+	}
+
+	var logs []repository.AuditLog
+	if err := db.Order("created_at DESC, id DESC").
+		Offset((query.Page - 1) * query.PageSize).
+		Limit(query.PageSize).
+		Find(&logs).Error; err != nil {
+		return nil, 0, fmt.Errorf("查询审计日志失败: %w", err)
+	}
+	return logs, total, nil
+}
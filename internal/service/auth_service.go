@@ -8,6 +8,7 @@ import (
 	"encoding/hex"
 	"errors"
 	"fmt"
+	"log"
 	"time"
 
 	"github.com/golang-jwt/jwt/v5"
@@ -16,32 +17,61 @@ import (
 
 // AuthService 认证服务
 type AuthService struct {
-	db  *gorm.DB
-	cfg *config.Config
+	db             *gorm.DB
+	cfg            *config.Config
+	settings       *SettingService
+	sessions       *SessionService
+	guard          *LoginGuard
+	audit          *AuditService
+	authenticators []Authenticator
 }
 
-// NewAuthService 创建认证服务
-func NewAuthService(db *gorm.DB, cfg *config.Config) *AuthService {
+// NewAuthService 创建认证服务，authenticators 为空时只使用本地密码认证
+func NewAuthService(db *gorm.DB, cfg *config.Config, settings *SettingService, sessions *SessionService, authenticators ...Authenticator) *AuthService {
 	if cfg == nil {
 		panic("初始化 AuthService 失败: config 不能为 nil")
 	}
+	if sessions == nil {
+		sessions = NewSessionService(db)
+	}
+	if len(authenticators) == 0 {
+		authenticators = []Authenticator{NewLocalAuthenticator(db)}
+	}
+	audit := NewAuditService(db)
 	return &AuthService{
-		db:  db,
-		cfg: cfg,
+		db:             db,
+		cfg:            cfg,
+		settings:       settings,
+		sessions:       sessions,
+		guard:          NewLoginGuard(db, cfg, audit),
+		audit:          audit,
+		authenticators: authenticators,
 	}
 }
 
+const (
+	// mfaTokenTTL 两步验证第二步令牌的有效期
+	mfaTokenTTL = 5 * time.Minute
+	// impersonationTTL 模拟登录令牌的有效期，不签发刷新令牌
+	impersonationTTL = time.Hour
+)
+
 // RegisterRequest 注册请求
 type RegisterRequest struct {
 	Name     string `json:"name" binding:"required,min=2,max=100"`
 	Email    string `json:"email" binding:"required,email"`
 	Password string `json:"password" binding:"required,min=6"`
+
+	Client ClientInfo `json:"-"`
 }
 
 // LoginRequest 登录请求
+// Email 为登录名，本地账户使用邮箱，LDAP 账户也可以使用用户名
 type LoginRequest struct {
-	Email    string `json:"email" binding:"required,email"`
+	Email    string `json:"email" binding:"required,max=255"`
 	Password string `json:"password" binding:"required,min=6"`
+
+	Client ClientInfo `json:"-"`
 }
 
 // TokenResponse 令牌响应
@@ -53,9 +83,18 @@ type TokenResponse struct {
 }
 
 // AuthResponse 认证响应
+// 需要两步验证时 Token 为空，客户端需携带 MFA.Token 完成第二步
 type AuthResponse struct {
 	User  *repository.User `json:"user"`
 	Token *TokenResponse   `json:"token"`
+	MFA   *MFAChallenge    `json:"mfa,omitempty"`
+}
+
+// MFAChallenge 两步验证挑战
+type MFAChallenge struct {
+	Token         string    `json:"mfaToken"`
+	SetupRequired bool      `json:"mfaSetupRequired"` // 管理员要求两步验证但用户尚未启用
+	ExpiresAt     time.Time `json:"expiresAt"`
 }
 
 // HashPassword 密码哈希
@@ -76,13 +115,24 @@ func VerifyPassword(password, salt, hashedPassword string) bool {
 	return HashPassword(password, salt) == hashedPassword
 }
 
-// GenerateJWT 生成JWT令牌
-func (s *AuthService) GenerateJWT(user *repository.User) (*TokenResponse, error) {
+// GenerateJWT 为新登录创建会话并生成JWT令牌
+func (s *AuthService) GenerateJWT(user *repository.User, client ClientInfo) (*TokenResponse, error) {
+	session, err := s.sessions.Create(user.ID, client)
+	if err != nil {
+		return nil, err
+	}
+	return s.signTokens(user, session.ID)
+}
+
+// signTokens 生成访问令牌和刷新令牌，sid 声明关联登录会话
+func (s *AuthService) signTokens(user *repository.User, sessionID uint) (*TokenResponse, error) {
 	expireTime := time.Now().Add(24 * time.Hour) // 24小时过期
 
 	claims := jwt.MapClaims{
 		"userId": user.ID,
 		"email":  user.Email,
+		"role":   user.Role,
+		"sid":    sessionID,
 		"exp":    expireTime.Unix(),
 		"iat":    time.Now().Unix(),
 	}
@@ -95,7 +145,7 @@ func (s *AuthService) GenerateJWT(user *repository.User) (*TokenResponse, error)
 	}
 
 	// 刷新令牌（更长的过期时间）
-	expireTime = time.Now().Add(7 * 24 * time.Hour) // 7天过期
+	expireTime = time.Now().Add(sessionTTL) // 7天过期
 	claims["exp"] = expireTime.Unix()
 	refreshToken := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
 	refreshTokenString, err := refreshToken.SignedString([]byte(s.cfg.JWTSecret))
@@ -135,7 +185,7 @@ func (s *AuthService) Register(req *RegisterRequest) (*AuthResponse, error) {
 	}
 
 	// 生成令牌
-	token, err := s.GenerateJWT(user)
+	token, err := s.GenerateJWT(user, req.Client)
 	if err != nil {
 		return nil, fmt.Errorf("生成令牌失败: %w", err)
 	}
@@ -147,33 +197,106 @@ func (s *AuthService) Register(req *RegisterRequest) (*AuthResponse, error) {
 }
 
 // Login 用户登录
+// 连续失败会触发渐进延迟和临时锁定，此时返回 *LoginThrottledError
 func (s *AuthService) Login(req *LoginRequest) (*AuthResponse, error) {
-	// 查找用户
-	var user repository.User
-	if err := s.db.Where("email = ?", req.Email).First(&user).Error; err != nil {
-		if errors.Is(err, gorm.ErrRecordNotFound) {
-			return nil, errors.New("邮箱或密码错误")
+	if err := s.guard.Check(req.Email, req.Client.IP); err != nil {
+		return nil, err
+	}
+
+	user, err := s.authenticate(req.Email, req.Password)
+	if err != nil {
+		if errors.Is(err, ErrInvalidCredentials) {
+			s.guard.Fail(req.Email, req.Client.IP)
 		}
-		return nil, fmt.Errorf("查找用户失败: %w", err)
+		return nil, err
 	}
+	s.guard.Succeed(req.Email)
+
+	return s.LoginUser(user, req.Client)
+}
 
-	// 验证密码
-	if !VerifyPassword(req.Password, user.Salt, user.Password) {
-		return nil, errors.New("邮箱或密码错误")
+// LoginUser 为已通过第一步认证的用户签发令牌
+// 用户启用了两步验证（或管理员要求两步验证）时只返回两步验证挑战
+func (s *AuthService) LoginUser(user *repository.User, client ClientInfo) (*AuthResponse, error) {
+	if !user.IsActive {
+		return nil, errors.New("账户已被禁用")
+	}
+
+	require2FA := false
+	if s.settings != nil {
+		var err error
+		if require2FA, err = s.settings.Bool(SettingRequire2FA, false); err != nil {
+			return nil, err
+		}
+	}
+
+	if user.TOTPEnabled || require2FA {
+		challenge, err := s.issueMFAToken(user)
+		if err != nil {
+			return nil, err
+		}
+		challenge.SetupRequired = !user.TOTPEnabled
+		return &AuthResponse{User: user, MFA: challenge}, nil
 	}
 
 	// 生成令牌
-	token, err := s.GenerateJWT(&user)
+	token, err := s.GenerateJWT(user, client)
 	if err != nil {
 		return nil, fmt.Errorf("生成令牌失败: %w", err)
 	}
 
 	return &AuthResponse{
-		User:  &user,
+		User:  user,
 		Token: token,
 	}, nil
 }
 
+// issueMFAToken 签发两步验证第二步使用的短期令牌
+// 使用 mfaUserId 而不是 userId 声明，保证它不能被当作访问令牌使用
+func (s *AuthService) issueMFAToken(user *repository.User) (*MFAChallenge, error) {
+	expiresAt := time.Now().Add(mfaTokenTTL)
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+		"mfaUserId": user.ID,
+		"exp":       expiresAt.Unix(),
+		"iat":       time.Now().Unix(),
+	})
+
+	signed, err := token.SignedString([]byte(s.cfg.JWTSecret))
+	if err != nil {
+		return nil, fmt.Errorf("生成两步验证令牌失败: %w", err)
+	}
+	return &MFAChallenge{Token: signed, ExpiresAt: expiresAt}, nil
+}
+
+// ParseMFAToken 解析两步验证令牌，返回对应用户ID
+func (s *AuthService) ParseMFAToken(tokenString string) (uint, error) {
+	claims, err := s.ParseJWT(tokenString)
+	if err != nil {
+		return 0, errors.New("两步验证已过期，请重新登录")
+	}
+
+	userID, ok := (*claims)["mfaUserId"].(float64)
+	if !ok {
+		return 0, errors.New("无效的两步验证令牌")
+	}
+	return uint(userID), nil
+}
+
+// authenticate 按认证链依次尝试，任一认证器成功即返回
+// 某个认证器出错（如 LDAP 不可用）时记录日志并继续尝试下一个
+func (s *AuthService) authenticate(identifier, password string) (*repository.User, error) {
+	for _, authenticator := range s.authenticators {
+		user, err := authenticator.Authenticate(identifier, password)
+		if err == nil {
+			return user, nil
+		}
+		if !errors.Is(err, ErrUnknownUser) && !errors.Is(err, ErrInvalidCredentials) {
+			log.Printf("认证器 %s 认证失败: %v", authenticator.Name(), err)
+		}
+	}
+	return nil, ErrInvalidCredentials
+}
+
 // ParseJWT 解析JWT令牌
 func (s *AuthService) ParseJWT(tokenString string) (*jwt.MapClaims, error) {
 	token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
@@ -215,8 +338,8 @@ func (s *AuthService) GetUserFromToken(tokenString string) (*repository.User, er
 	return &user, nil
 }
 
-// RefreshToken 刷新令牌
-func (s *AuthService) RefreshToken(refreshToken string) (*TokenResponse, error) {
+// RefreshToken 刷新令牌，沿用原会话，会话已注销时拒绝刷新
+func (s *AuthService) RefreshToken(refreshToken string, client ClientInfo) (*TokenResponse, error) {
 	// 解析刷新令牌
 	claims, err := s.ParseJWT(refreshToken)
 	if err != nil {
@@ -227,15 +350,86 @@ func (s *AuthService) RefreshToken(refreshToken string) (*TokenResponse, error)
 	if !ok {
 		return nil, errors.New("令牌中缺少用户ID")
 	}
+	// 模拟登录令牌不能刷新，否则会换到不带模拟标记的正常令牌
+	if _, impersonated := (*claims)["impersonatorId"]; impersonated {
+		return nil, errors.New("模拟登录令牌不能刷新")
+	}
+	sessionID, ok := (*claims)["sid"].(float64)
+	if !ok {
+		return nil, ErrSessionRevoked
+	}
 
 	// 查找用户
 	var user repository.User
 	if err := s.db.First(&user, uint(userID)).Error; err != nil {
 		return nil, fmt.Errorf("查找用户失败: %w", err)
 	}
+	if !user.IsActive {
+		return nil, errors.New("账户已被禁用")
+	}
+
+	if err := s.sessions.Refresh(user.ID, uint(sessionID), client); err != nil {
+		return nil, err
+	}
 
 	// 生成新的访问令牌
-	return s.GenerateJWT(&user)
+	return s.signTokens(&user, uint(sessionID))
+}
+
+// issueImpersonationToken 为管理员签发以目标用户身份访问的短期令牌
+// 令牌携带 impersonatorId 声明，认证中间件据此把请求记入审计日志
+func (s *AuthService) issueImpersonationToken(adminID uint, user *repository.User, client ClientInfo) (*TokenResponse, error) {
+	session, err := s.sessions.create(user.ID, client, impersonationTTL)
+	if err != nil {
+		return nil, err
+	}
+
+	expireTime := time.Now().Add(impersonationTTL)
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+		"userId":         user.ID,
+		"email":          user.Email,
+		"role":           user.Role,
+		"sid":            session.ID,
+		"impersonatorId": adminID,
+		"exp":            expireTime.Unix(),
+		"iat":            time.Now().Unix(),
+	})
+
+	accessToken, err := token.SignedString([]byte(s.cfg.JWTSecret))
+	if err != nil {
+		return nil, fmt.Errorf("生成令牌失败: %w", err)
+	}
+
+	return &TokenResponse{
+		AccessToken: accessToken,
+		ExpiresAt:   expireTime,
+		TokenType:   "Bearer",
+	}, nil
+}
+
+// UnlockAccount 管理员解除用户因登录失败导致的锁定
+func (s *AuthService) UnlockAccount(adminID, userID uint, ip string) error {
+	user, err := s.GetUserByID(userID)
+	if err != nil {
+		return err
+	}
+
+	if err := s.guard.Unlock(user.Email, user.ID); err != nil {
+		return err
+	}
+
+	s.audit.Record(&AuditEntry{
+		ActorID:      &adminID,
+		TargetUserID: &user.ID,
+		Action:       AuditAccountUnlock,
+		IP:           ip,
+	})
+	return nil
+}
+
+// Logout 注销当前会话
+func (s *AuthService) Logout(userID, sessionID uint) error {
+	return s.sessions.Revoke(userID, sessionID)
 }
 
 // GetUserByID 根据ID获取用户信息
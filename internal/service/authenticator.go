@@ -0,0 +1,88 @@
+package service
+
+import (
+	"ai-chat/config"
+	"ai-chat/internal/repository"
+	"errors"
+	"fmt"
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+var (
+	// ErrInvalidCredentials 登录名或密码错误
+	ErrInvalidCredentials = errors.New("邮箱或密码错误")
+	// ErrUnknownUser 认证器中不存在该用户，交由下一个认证器处理
+	ErrUnknownUser = errors.New("用户不存在")
+)
+
+// Authenticator 登录认证器，AuthService.Login 按配置顺序依次尝试
+type Authenticator interface {
+	// Name 认证器名称，用于日志
+	Name() string
+	// Authenticate 校验登录名和密码，成功时返回本地用户
+	// 用户不属于该认证器时返回 ErrUnknownUser，密码错误时返回 ErrInvalidCredentials
+	Authenticate(identifier, password string) (*repository.User, error)
+}
+
+// NewAuthenticators 根据 AUTH_PROVIDERS 配置创建认证链
+func NewAuthenticators(db *gorm.DB, cfg *config.Config) ([]Authenticator, error) {
+	var authenticators []Authenticator
+	for _, name := range cfg.AuthProviders {
+		switch strings.ToLower(name) {
+		case "local":
+			authenticators = append(authenticators, NewLocalAuthenticator(db))
+		case "ldap":
+			ldapAuth, err := NewLDAPAuthenticator(db, cfg)
+			if err != nil {
+				return nil, err
+			}
+			authenticators = append(authenticators, ldapAuth)
+		default:
+			return nil, fmt.Errorf("未知的认证方式: %s", name)
+		}
+	}
+
+	if len(authenticators) == 0 {
+		return nil, errors.New("至少需要配置一种认证方式")
+	}
+	return authenticators, nil
+}
+
+// LocalAuthenticator 本地密码认证
+type LocalAuthenticator struct {
+	db *gorm.DB
+}
+
+// NewLocalAuthenticator 创建本地密码认证器
+func NewLocalAuthenticator(db *gorm.DB) *LocalAuthenticator {
+	return &LocalAuthenticator{db: db}
+}
+
+// Name 认证器名称
+func (a *LocalAuthenticator) Name() string {
+	return "local"
+}
+
+// Authenticate 使用邮箱和本地密码认证
+func (a *LocalAuthenticator) Authenticate(identifier, password string) (*repository.User, error) {
+	var user repository.User
+	if err := a.db.Where("email = ?", identifier).First(&user).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrUnknownUser
+		}
+		return nil, fmt.Errorf("查找用户失败: %w", err)
+	}
+
+	// 外部身份源创建的用户没有本地密码
+	if user.Password == "" {
+		return nil, ErrUnknownUser
+	}
+
+	if !VerifyPassword(password, user.Salt, user.Password) {
+		return nil, ErrInvalidCredentials
+	}
+
+	return &user, nil
+}
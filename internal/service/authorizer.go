@@ -0,0 +1,298 @@
+package service
+
+import (
+	"ai-chat/internal/policy"
+	"ai-chat/internal/repository"
+	"errors"
+	"fmt"
+
+	"gorm.io/gorm"
+)
+
+// Authorizer 加载资源的访问属性并交给 policy 判断
+// 各服务通过它做归属和权限校验，新增分享、团队等访问方式时只需修改这里
+type Authorizer struct {
+	db *gorm.DB
+}
+
+// NewAuthorizer 创建授权器
+func NewAuthorizer(db *gorm.DB) *Authorizer {
+	return &Authorizer{db: db}
+}
+
+// Conversation 校验会话权限并返回会话
+func (a *Authorizer) Conversation(p policy.Principal, action policy.Action, id uint) (*repository.Conversation, error) {
+	var conversation repository.Conversation
+	if err := a.db.First(&conversation, id).Error; err != nil {
+		return nil, notFoundOr(err, "查找会话失败")
+	}
+
+	if err := a.authorize(p, action, conversationResource(&conversation)); err != nil {
+		return nil, err
+	}
+	return &conversation, nil
+}
+
+// Message 校验消息权限并返回消息，消息的权限继承所属会话
+func (a *Authorizer) Message(p policy.Principal, action policy.Action, id uint) (*repository.Message, error) {
+	var message repository.Message
+	if err := a.db.First(&message, id).Error; err != nil {
+		return nil, notFoundOr(err, "查找消息失败")
+	}
+
+	var conversation repository.Conversation
+	if err := a.db.First(&conversation, message.ConversationID).Error; err != nil {
+		return nil, notFoundOr(err, "查找会话失败")
+	}
+
+	resource := conversationResource(&conversation)
+	resource.Kind = policy.KindMessage
+	resource.ID = message.ID
+	if err := a.authorize(p, action, resource); err != nil {
+		return nil, err
+	}
+	return &message, nil
+}
+
+// FixedPrompt 校验固定提示词权限并返回固定提示词
+func (a *Authorizer) FixedPrompt(p policy.Principal, action policy.Action, id uint) (*repository.FixedPrompt, error) {
+	var fixedPrompt repository.FixedPrompt
+	if err := a.db.First(&fixedPrompt, id).Error; err != nil {
+		return nil, notFoundOr(err, "查找固定提示词失败")
+	}
+
+	resource := &policy.Resource{
+		Kind:        policy.KindFixedPrompt,
+		ID:          fixedPrompt.ID,
+		OwnerID:     fixedPrompt.UserID,
+		WorkspaceID: fixedPrompt.WorkspaceID,
+	}
+	if err := a.authorize(p, action, resource); err != nil {
+		return nil, err
+	}
+	return &fixedPrompt, nil
+}
+
+// Document 校验文档权限并返回文档
+func (a *Authorizer) Document(p policy.Principal, action policy.Action, id uint) (*repository.Document, error) {
+	var document repository.Document
+	if err := a.db.First(&document, id).Error; err != nil {
+		return nil, notFoundOr(err, "查找文档失败")
+	}
+
+	resource := &policy.Resource{
+		Kind:        policy.KindDocument,
+		ID:          document.ID,
+		OwnerID:     document.UserID,
+		WorkspaceID: document.WorkspaceID,
+	}
+	if err := a.authorize(p, action, resource); err != nil {
+		return nil, err
+	}
+	return &document, nil
+}
+
+// KnowledgeBase 校验知识库权限并返回知识库
+func (a *Authorizer) KnowledgeBase(p policy.Principal, action policy.Action, id uint) (*repository.KnowledgeBase, error) {
+	var knowledgeBase repository.KnowledgeBase
+	if err := a.db.First(&knowledgeBase, id).Error; err != nil {
+		return nil, notFoundOr(err, "查找知识库失败")
+	}
+
+	resource := &policy.Resource{
+		Kind:        policy.KindKnowledge,
+		ID:          knowledgeBase.ID,
+		OwnerID:     knowledgeBase.UserID,
+		WorkspaceID: knowledgeBase.WorkspaceID,
+	}
+	if err := a.authorize(p, action, resource); err != nil {
+		return nil, err
+	}
+	return &knowledgeBase, nil
+}
+
+// Assistant 校验助手权限并返回助手
+func (a *Authorizer) Assistant(p policy.Principal, action policy.Action, id uint) (*repository.Assistant, error) {
+	var assistant repository.Assistant
+	if err := a.db.First(&assistant, id).Error; err != nil {
+		return nil, notFoundOr(err, "查找助手失败")
+	}
+
+	resource := &policy.Resource{
+		Kind:        policy.KindAssistant,
+		ID:          assistant.ID,
+		OwnerID:     assistant.UserID,
+		WorkspaceID: assistant.WorkspaceID,
+	}
+	if err := a.authorize(p, action, resource); err != nil {
+		return nil, err
+	}
+	return &assistant, nil
+}
+
+// Workspace 校验工作区本身的权限（读取、管理、删除）并返回工作区
+func (a *Authorizer) Workspace(p policy.Principal, action policy.Action, id uint) (*repository.Workspace, error) {
+	var workspace repository.Workspace
+	if err := a.db.First(&workspace, id).Error; err != nil {
+		return nil, notFoundOr(err, "查找工作区失败")
+	}
+
+	if err := a.authorize(p, action, workspaceResource(workspace.ID)); err != nil {
+		return nil, err
+	}
+	return &workspace, nil
+}
+
+// WorkspaceContent 校验主体能否对当前工作区中的内容执行操作，用于创建和列表
+// 个人空间总是允许
+func (a *Authorizer) WorkspaceContent(p policy.Principal, action policy.Action) error {
+	if p.WorkspaceID == nil {
+		return nil
+	}
+
+	var count int64
+	if err := a.db.Model(&repository.Workspace{}).Where("id = ?", *p.WorkspaceID).Count(&count).Error; err != nil {
+		return fmt.Errorf("查找工作区失败: %w", err)
+	}
+	if count == 0 {
+		return policy.ErrNotFound
+	}
+
+	// 内容资源没有具体所有者，只按工作区成员角色判断
+	resource := &policy.Resource{Kind: policy.KindConversation, WorkspaceID: p.WorkspaceID}
+	return a.authorize(p, action, resource)
+}
+
+// ConversationScope 列表查询条件：当前空间（个人或工作区）的会话，需先通过 WorkspaceContent 校验
+func (a *Authorizer) ConversationScope(p policy.Principal) func(*gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		if p.WorkspaceID != nil {
+			return db.Where("conversations.workspace_id = ?", *p.WorkspaceID)
+		}
+		return db.Where("conversations.user_id = ? AND conversations.workspace_id IS NULL", p.UserID)
+	}
+}
+
+// FixedPromptScope 列表查询条件：当前空间（个人或工作区）的固定提示词，需先通过 WorkspaceContent 校验
+func (a *Authorizer) FixedPromptScope(p policy.Principal) func(*gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		if p.WorkspaceID != nil {
+			return db.Where("fixed_prompts.workspace_id = ?", *p.WorkspaceID)
+		}
+		return db.Where("fixed_prompts.user_id = ? AND fixed_prompts.workspace_id IS NULL", p.UserID)
+	}
+}
+
+// DocumentScope 列表查询条件：当前空间（个人或工作区）的文档，需先通过 WorkspaceContent 校验
+func (a *Authorizer) DocumentScope(p policy.Principal) func(*gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		if p.WorkspaceID != nil {
+			return db.Where("documents.workspace_id = ?", *p.WorkspaceID)
+		}
+		return db.Where("documents.user_id = ? AND documents.workspace_id IS NULL", p.UserID)
+	}
+}
+
+// KnowledgeBaseScope 列表查询条件：当前空间（个人或工作区）的知识库，需先通过 WorkspaceContent 校验
+func (a *Authorizer) KnowledgeBaseScope(p policy.Principal) func(*gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		if p.WorkspaceID != nil {
+			return db.Where("knowledge_bases.workspace_id = ?", *p.WorkspaceID)
+		}
+		return db.Where("knowledge_bases.user_id = ? AND knowledge_bases.workspace_id IS NULL", p.UserID)
+	}
+}
+
+// AssistantScope 列表查询条件：当前空间（个人或工作区）的助手，需先通过 WorkspaceContent 校验
+func (a *Authorizer) AssistantScope(p policy.Principal) func(*gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		if p.WorkspaceID != nil {
+			return db.Where("assistants.workspace_id = ?", *p.WorkspaceID)
+		}
+		return db.Where("assistants.user_id = ? AND assistants.workspace_id IS NULL", p.UserID)
+	}
+}
+
+// FilterConversations 过滤出主体可以执行该操作的会话，用于批量处理
+// 一次加载主体的全部工作区成员角色，已离开的工作区中的会话会被排除
+func (a *Authorizer) FilterConversations(p policy.Principal, action policy.Action, conversations []*repository.Conversation) ([]*repository.Conversation, error) {
+	var members []repository.WorkspaceMember
+	if err := a.db.Where("user_id = ?", p.UserID).Find(&members).Error; err != nil {
+		return nil, fmt.Errorf("查找工作区成员失败: %w", err)
+	}
+	workspaces := make(map[uint]string, len(members))
+	for _, member := range members {
+		workspaces[member.WorkspaceID] = member.Role
+	}
+	p.Workspaces = workspaces
+
+	allowed := make([]*repository.Conversation, 0, len(conversations))
+	for _, conversation := range conversations {
+		if policy.Can(p, action, conversationResource(conversation)) {
+			allowed = append(allowed, conversation)
+		}
+	}
+	return allowed, nil
+}
+
+// authorize 加载主体在资源所属工作区的成员角色后交给 policy 判断
+func (a *Authorizer) authorize(p policy.Principal, action policy.Action, r *policy.Resource) error {
+	if r.WorkspaceID != nil {
+		var err error
+		if p, err = a.withWorkspace(p, *r.WorkspaceID); err != nil {
+			return err
+		}
+	}
+	return policy.Authorize(p, action, r)
+}
+
+// withWorkspace 返回补充了指定工作区成员角色的主体，不修改调用方的 map
+func (a *Authorizer) withWorkspace(p policy.Principal, workspaceID uint) (policy.Principal, error) {
+	if _, loaded := p.Workspaces[workspaceID]; loaded {
+		return p, nil
+	}
+
+	var member repository.WorkspaceMember
+	err := a.db.Where("workspace_id = ? AND user_id = ?", workspaceID, p.UserID).First(&member).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return p, nil
+	}
+	if err != nil {
+		return p, fmt.Errorf("查找工作区成员失败: %w", err)
+	}
+
+	workspaces := make(map[uint]string, len(p.Workspaces)+1)
+	for id, role := range p.Workspaces {
+		workspaces[id] = role
+	}
+	workspaces[workspaceID] = member.Role
+	p.Workspaces = workspaces
+	return p, nil
+}
+
+// conversationResource 会话的访问属性
+func conversationResource(conversation *repository.Conversation) *policy.Resource {
+	return &policy.Resource{
+		Kind:        policy.KindConversation,
+		ID:          conversation.ID,
+		OwnerID:     conversation.UserID,
+		WorkspaceID: conversation.WorkspaceID,
+	}
+}
+
+// workspaceResource 工作区本身的访问属性，工作区的权限完全来自成员角色
+func workspaceResource(id uint) *policy.Resource {
+	return &policy.Resource{
+		Kind:        policy.KindWorkspace,
+		ID:          id,
+		WorkspaceID: &id,
+	}
+}
+
+// notFoundOr 记录不存在时返回 policy.ErrNotFound，其他错误加上说明
+func notFoundOr(err error, msg string) error {
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return policy.ErrNotFound
+	}
+	return fmt.Errorf("%s: %w", msg, err)
+}
@@ -1,7 +1,9 @@
 package service
 
 import (
+	"ai-chat/internal/policy"
 	"ai-chat/internal/repository"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"time"
@@ -11,21 +13,25 @@ import (
 
 // ConversationService 会话服务
 type ConversationService struct {
-	db *gorm.DB
+	db    *gorm.DB
+	authz *Authorizer
 }
 
 // NewConversationService 创建会话服务
 func NewConversationService(db *gorm.DB) *ConversationService {
-	return &ConversationService{db: db}
+	return &ConversationService{
+		db:    db,
+		authz: NewAuthorizer(db),
+	}
 }
 
-// CreateConversationRequest 创建会话请求
+// CreateConversationRequest 创建会话请求，会话创建在主体当前选择的空间中
 type CreateConversationRequest struct {
 	Name         string   `json:"name" binding:"required,min=1,max=255"`
-	UserID       uint     `json:"userId" binding:"required"`
 	SystemPrompt *string  `json:"systemPrompt,omitempty"`
 	Model        *string  `json:"model,omitempty"`
 	Temperature  *float64 `json:"temperature,omitempty"`
+	AssistantID  *uint    `json:"assistantId,omitempty"` // 须为同一空间中的助手
 }
 
 // UpdateConversationRequest 更新会话请求
@@ -37,11 +43,51 @@ type UpdateConversationRequest struct {
 	IsActive     *bool    `json:"isActive,omitempty"`
 }
 
+// ImportConversationRequest 导入会话请求，来源会话ID和导入文件二选一
+type ImportConversationRequest struct {
+	ConversationID *uint                 `json:"conversationId,omitempty"` // 有读取权限的会话，如工作区共享会话
+	Conversation   *ImportedConversation `json:"conversation,omitempty"`
+	Name           *string               `json:"name,omitempty" binding:"omitempty,min=1,max=255"` // 为空时沿用来源名称
+}
+
+// ImportedConversation 导入文件中的会话，同时兼容公开分享接口返回的 JSON
+type ImportedConversation struct {
+	Name         string            `json:"name"`
+	Title        string            `json:"title"` // 公开分享的 JSON 使用 title 作为名称
+	SystemPrompt *string           `json:"systemPrompt,omitempty"`
+	Model        *string           `json:"model,omitempty"`
+	Temperature  *float64          `json:"temperature,omitempty"`
+	Messages     []ImportedMessage `json:"messages" binding:"required,min=1,max=5000,dive"`
+}
+
+// ImportedMessage 导入文件中的消息
+type ImportedMessage struct {
+	Type             string  `json:"type" binding:"required,oneof=system user assistant"`
+	Content          string  `json:"content"`
+	ReasoningContent string  `json:"reasoningContent,omitempty"`
+	Model            *string `json:"model,omitempty"`
+	Tokens           *int    `json:"tokens,omitempty"`
+}
+
+// importProvenance 导入会话的来源，保存在会话 metadata 中
+type importProvenance struct {
+	ImportedFrom struct {
+		Source             string    `json:"source"` // conversation file chatgpt claude native
+		ConversationID     *uint     `json:"conversationId,omitempty"`
+		ExternalID         string    `json:"externalId,omitempty"` // 外部工具中的会话ID，用于跳过重复导入
+		OwnerID            *uint     `json:"ownerId,omitempty"`
+		OmittedAttachments int64     `json:"omittedAttachments,omitempty"` // 来源消息的附件数量，附件不随导入复制
+		ImportedBy         uint      `json:"importedBy"`
+		ImportedAt         time.Time `json:"importedAt"`
+	} `json:"importedFrom"`
+}
+
 // ConversationResponse 会话响应
 type ConversationResponse struct {
 	ID           uint      `json:"id"`
 	Name         string    `json:"name"`
 	UserID       uint      `json:"userId"`
+	WorkspaceID  *uint     `json:"workspaceId"`
 	IsActive     bool      `json:"isActive"`
 	SystemPrompt *string   `json:"systemPrompt"`
 	Model        *string   `json:"model"`
@@ -49,16 +95,33 @@ type ConversationResponse struct {
 	CreatedAt    time.Time `json:"createdAt"`
 	UpdatedAt    time.Time `json:"updatedAt"`
 	Messages     int64     `json:"messageCount"`
+	Metadata     *string   `json:"metadata"`
+	AssistantID  *uint     `json:"assistantId"`
 }
 
 // Create 创建会话
-func (s *ConversationService) Create(req *CreateConversationRequest) (*ConversationResponse, error) {
+func (s *ConversationService) Create(p policy.Principal, req *CreateConversationRequest) (*ConversationResponse, error) {
+	if err := s.authz.WorkspaceContent(p, policy.ActionWrite); err != nil {
+		return nil, err
+	}
+	if req.AssistantID != nil {
+		assistant, err := s.authz.Assistant(p, policy.ActionRead, *req.AssistantID)
+		if err != nil {
+			return nil, err
+		}
+		if !spaceMatches(assistant.UserID, assistant.WorkspaceID, p.UserID, p.WorkspaceID) {
+			return nil, ErrAssistantSpace
+		}
+	}
+
 	conversation := &repository.Conversation{
 		Name:         req.Name,
-		UserID:       req.UserID,
+		UserID:       p.UserID,
+		WorkspaceID:  p.WorkspaceID,
 		SystemPrompt: req.SystemPrompt,
 		Model:        req.Model,
 		Temperature:  req.Temperature,
+		AssistantID:  req.AssistantID,
 	}
 
 	if err := s.db.Create(conversation).Error; err != nil {
@@ -69,8 +132,8 @@ func (s *ConversationService) Create(req *CreateConversationRequest) (*Conversat
 }
 
 // FindByID 根据ID查找会话
-func (s *ConversationService) FindByID(userID, id uint) (*ConversationResponse, error) {
-	conversation, err := s.findByID(userID, id)
+func (s *ConversationService) FindByID(p policy.Principal, id uint) (*ConversationResponse, error) {
+	conversation, err := s.authz.Conversation(p, policy.ActionRead, id)
 	if err != nil {
 		return nil, err
 	}
@@ -82,23 +145,21 @@ func (s *ConversationService) FindByID(userID, id uint) (*ConversationResponse,
 	return s.toResponse(conversation, messageCount), nil
 }
 
-// findByID 内部方法：根据ID查找会话
-func (s *ConversationService) findByID(userID, id uint) (*repository.Conversation, error) {
-	var conversation repository.Conversation
-	if err := s.db.Where("id = ? AND user_id = ?", id, userID).First(&conversation).Error; err != nil {
-		if errors.Is(err, gorm.ErrRecordNotFound) {
-			return nil, fmt.Errorf("会话不存在")
-		}
-		return nil, fmt.Errorf("查找会话失败: %w", err)
-	}
-	return &conversation, nil
+// Authorize 校验主体对会话的权限，读取会话的助手、模型配置等之前调用
+func (s *ConversationService) Authorize(p policy.Principal, action policy.Action, id uint) error {
+	_, err := s.authz.Conversation(p, action, id)
+	return err
 }
 
-// FindByUserID 根据用户ID查找会话
-func (s *ConversationService) FindByUserID(userID uint, q string) ([]*ConversationResponse, error) {
+// List 查找当前空间的会话，个人空间只包含自己的会话，工作区包含全部共享会话
+func (s *ConversationService) List(p policy.Principal, q string) ([]*ConversationResponse, error) {
+	if err := s.authz.WorkspaceContent(p, policy.ActionRead); err != nil {
+		return nil, err
+	}
+
 	var conversations []*repository.Conversation
 
-	query := s.db.Model(&repository.Conversation{}).Where("user_id = ?", userID)
+	query := s.db.Model(&repository.Conversation{}).Scopes(s.authz.ConversationScope(p))
 
 	if q != "" {
 		query = query.Where("name ILIKE ?", "%"+q+"%")
@@ -147,8 +208,8 @@ func (s *ConversationService) FindAll(q string) ([]*ConversationResponse, error)
 }
 
 // Update 更新会话
-func (s *ConversationService) Update(userID, id uint, req *UpdateConversationRequest) (*ConversationResponse, error) {
-	conversation, err := s.findByID(userID, id)
+func (s *ConversationService) Update(p policy.Principal, id uint, req *UpdateConversationRequest) (*ConversationResponse, error) {
+	conversation, err := s.authz.Conversation(p, policy.ActionWrite, id)
 	if err != nil {
 		return nil, err
 	}
@@ -189,14 +250,9 @@ func (s *ConversationService) Update(userID, id uint, req *UpdateConversationReq
 }
 
 // Delete 删除会话
-func (s *ConversationService) Delete(userID, id uint) error {
-	// 验证归属权
-	var count int64
-	if err := s.db.Model(&repository.Conversation{}).Where("id = ? AND user_id = ?", id, userID).Count(&count).Error; err != nil {
-		return fmt.Errorf("验证会话归属权失败: %w", err)
-	}
-	if count == 0 {
-		return fmt.Errorf("会话不存在或无权删除")
+func (s *ConversationService) Delete(p policy.Principal, id uint) error {
+	if _, err := s.authz.Conversation(p, policy.ActionDelete, id); err != nil {
+		return err
 	}
 
 	// 先删除相关的消息
@@ -212,6 +268,133 @@ func (s *ConversationService) Delete(userID, id uint) error {
 	return nil
 }
 
+// Import 将有权读取的会话或导入文件复制为主体当前空间中的新会话
+// 保留消息内容、思考内容和模型信息，来源记录在会话 metadata 中
+// 附件、知识库引用和固定提示词版本依赖来源会话的权限，不随导入复制
+func (s *ConversationService) Import(p policy.Principal, req *ImportConversationRequest) (*ConversationResponse, error) {
+	if (req.ConversationID == nil) == (req.Conversation == nil) {
+		return nil, errors.New("必须且只能指定来源会话或导入文件之一")
+	}
+	if err := s.authz.WorkspaceContent(p, policy.ActionWrite); err != nil {
+		return nil, err
+	}
+
+	var provenance importProvenance
+	provenance.ImportedFrom.ImportedBy = p.UserID
+	provenance.ImportedFrom.ImportedAt = time.Now()
+
+	conversation := &repository.Conversation{
+		UserID:      p.UserID,
+		WorkspaceID: p.WorkspaceID,
+	}
+	var messages []*repository.Message
+
+	if req.ConversationID != nil {
+		source, err := s.authz.Conversation(p, policy.ActionRead, *req.ConversationID)
+		if err != nil {
+			return nil, err
+		}
+		if err := s.db.Where("conversation_id = ?", source.ID).
+			Order("sort ASC, id ASC").Find(&messages).Error; err != nil {
+			return nil, fmt.Errorf("查询消息失败: %w", err)
+		}
+		if err := s.db.Model(&repository.MessageAttachment{}).
+			Where("message_id IN (?)", s.db.Model(&repository.Message{}).Select("id").Where("conversation_id = ?", source.ID)).
+			Count(&provenance.ImportedFrom.OmittedAttachments).Error; err != nil {
+			return nil, fmt.Errorf("查询附件失败: %w", err)
+		}
+
+		conversation.Name = source.Name
+		conversation.SystemPrompt = source.SystemPrompt
+		conversation.Model = source.Model
+		conversation.Temperature = source.Temperature
+		provenance.ImportedFrom.Source = "conversation"
+		provenance.ImportedFrom.ConversationID = &source.ID
+		provenance.ImportedFrom.OwnerID = &source.UserID
+	} else {
+		imported := req.Conversation
+		conversation.Name = imported.Name
+		if conversation.Name == "" {
+			conversation.Name = imported.Title
+		}
+		if conversation.Name == "" {
+			conversation.Name = "导入的会话"
+		}
+		conversation.SystemPrompt = imported.SystemPrompt
+		conversation.Model = imported.Model
+		conversation.Temperature = imported.Temperature
+		provenance.ImportedFrom.Source = "file"
+
+		messages = make([]*repository.Message, len(imported.Messages))
+		for i, m := range imported.Messages {
+			messages[i] = &repository.Message{
+				Content:          m.Content,
+				ReasoningContent: m.ReasoningContent,
+				Sort:             i + 1,
+				Type:             m.Type,
+				Tokens:           m.Tokens,
+				Model:            m.Model,
+			}
+		}
+	}
+
+	if req.Name != nil {
+		conversation.Name = *req.Name
+	}
+	conversation.Name = truncateRunes(conversation.Name, 255)
+
+	metadata, err := json.Marshal(provenance)
+	if err != nil {
+		return nil, fmt.Errorf("生成会话来源信息失败: %w", err)
+	}
+	metadataStr := string(metadata)
+	conversation.Metadata = &metadataStr
+
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(conversation).Error; err != nil {
+			return fmt.Errorf("创建会话失败: %w", err)
+		}
+
+		// 按原顺序复制消息，并把回复关系映射到新消息ID
+		ids := make(map[uint]uint, len(messages))
+		for _, m := range messages {
+			copied := &repository.Message{
+				ConversationID:   conversation.ID,
+				Content:          m.Content,
+				ReasoningContent: m.ReasoningContent,
+				Sort:             m.Sort,
+				Type:             m.Type,
+				Tokens:           m.Tokens,
+				Model:            m.Model,
+				Metadata:         portableMessageMetadata(m.Metadata),
+			}
+			if m.ParentID != nil {
+				if parentID, ok := ids[*m.ParentID]; ok {
+					copied.ParentID = &parentID
+				}
+			}
+			if err := tx.Create(copied).Error; err != nil {
+				return fmt.Errorf("复制消息失败: %w", err)
+			}
+			if m.ID != 0 {
+				ids[m.ID] = copied.ID
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return s.toResponse(conversation, int64(len(messages))), nil
+}
+
+// portableMessageMetadata 导入时只保留远程图片地址
+// 引用来源指向导入者可能无权读取的文档分块，固定提示词版本同理，均不复制
+func portableMessageMetadata(metadata *string) *string {
+	return ImageMetadata(messageImageURLs(metadata))
+}
+
 // NextSort 获取下一个消息排序值
 func (s *ConversationService) NextSort(conversationID uint) (int, error) {
 	var maxSort int
@@ -230,6 +413,7 @@ func (s *ConversationService) toResponse(conv *repository.Conversation, messageC
 		ID:           conv.ID,
 		Name:         conv.Name,
 		UserID:       conv.UserID,
+		WorkspaceID:  conv.WorkspaceID,
 		IsActive:     conv.IsActive,
 		SystemPrompt: conv.SystemPrompt,
 		Model:        conv.Model,
@@ -237,5 +421,7 @@ func (s *ConversationService) toResponse(conv *repository.Conversation, messageC
 		CreatedAt:    conv.CreatedAt,
 		UpdatedAt:    conv.UpdatedAt,
 		Messages:     messageCount,
+		Metadata:     conv.Metadata,
+		AssistantID:  conv.AssistantID,
 	}
 }
@@ -0,0 +1,563 @@
+package service
+
+import (
+	"ai-chat/config"
+	"ai-chat/internal/docparse"
+	"ai-chat/internal/policy"
+	"ai-chat/internal/repository"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+	"unicode"
+
+	"gorm.io/gorm"
+)
+
+const (
+	// MaxDocumentsPerChat 每次对话最多引用的文档数
+	MaxDocumentsPerChat = 10
+	// maxContextTerms 按相关度挑选片段时使用的查询词数量上限
+	maxContextTerms = 32
+	// maxRankedChunks 按相关度挑选片段时最多考虑的片段数
+	maxRankedChunks = 200
+)
+
+var (
+	// ErrDocumentTooLarge 文档超过大小限制
+	ErrDocumentTooLarge = errors.New("文档超过大小限制")
+	// ErrDocumentParse 文档无法解析
+	ErrDocumentParse = errors.New("文档解析失败")
+)
+
+// DocumentService 文档解析服务
+// 上传的文档解析为纯文本后切分为带重叠的片段保存，供检索或在对话中按 token 预算引用
+type DocumentService struct {
+	db    *gorm.DB
+	cfg   *config.Config
+	authz *Authorizer
+}
+
+// NewDocumentService 创建文档解析服务
+func NewDocumentService(db *gorm.DB, cfg *config.Config) *DocumentService {
+	return &DocumentService{
+		db:    db,
+		cfg:   cfg,
+		authz: NewAuthorizer(db),
+	}
+}
+
+// DocumentResponse 文档响应
+type DocumentResponse struct {
+	ID          uint      `json:"id"`
+	WorkspaceID *uint     `json:"workspaceId"`
+	FileName    string    `json:"fileName"`
+	Format      string    `json:"format"`
+	Size        int64     `json:"size"`
+	Checksum    string    `json:"checksum"`
+	PageCount   int       `json:"pageCount"`
+	ChunkCount  int       `json:"chunkCount"`
+	Tokens      int       `json:"tokens"`
+	CreatedAt   time.Time `json:"createdAt"`
+}
+
+// DocumentChunkResponse 文档片段响应
+type DocumentChunkResponse struct {
+	ID      uint   `json:"id"`
+	Seq     int    `json:"seq"`
+	Page    int    `json:"page"`   // 0 表示不分页
+	Offset  int    `json:"offset"` // 在所在页文本中的字符偏移
+	Length  int    `json:"length"`
+	Content string `json:"content"`
+	Tokens  int    `json:"tokens"`
+}
+
+// DocumentSearchQuery 文档片段检索条件，查询语法与消息检索相同
+type DocumentSearchQuery struct {
+	Q          string `form:"q" binding:"required,max=200"`
+	DocumentID uint   `form:"document_id"`
+	Page       int    `form:"page,default=1" binding:"min=1"`
+	PageSize   int    `form:"page_size,default=20" binding:"min=1,max=100"`
+}
+
+// DocumentSearchResult 文档片段检索结果
+type DocumentSearchResult struct {
+	DocumentID uint    `json:"documentId"`
+	FileName   string  `json:"fileName"`
+	ChunkID    uint    `json:"chunkId"`
+	Seq        int     `json:"seq"`
+	Page       int     `json:"page"`
+	Offset     int     `json:"offset"`
+	Rank       float64 `json:"rank"`
+	Snippet    string  `json:"snippet"` // 已做 HTML 转义，匹配处用 <mark> 标记
+	Source     string  `json:"source"`  // 来源标注，如 "报告.pdf 第 3 页"
+}
+
+// DocumentContext 对话中引用的文档内容
+type DocumentContext struct {
+	Content   string // 带来源标注的文本，作为系统消息发送
+	Tokens    int
+	Truncated bool // 超出预算，只包含部分片段
+}
+
+// documentSearchRow 检索查询的结果行
+type documentSearchRow struct {
+	ID         uint
+	DocumentID uint
+	FileName   string
+	Seq        int
+	Page       int
+	Offset     int
+	Content    string
+	Rank       float64
+}
+
+// Upload 解析并保存文档，文档属于当前空间（个人或工作区）
+func (s *DocumentService) Upload(p policy.Principal, fileName string, data []byte) (*DocumentResponse, error) {
+	if err := s.authz.WorkspaceContent(p, policy.ActionWrite); err != nil {
+		return nil, err
+	}
+	if int64(len(data)) > s.cfg.DocumentMaxSize {
+		return nil, ErrDocumentTooLarge
+	}
+
+	parsed, err := docparse.Parse(fileName, data)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrDocumentParse, err)
+	}
+	chunks := docparse.Split(parsed, s.cfg.DocumentChunkTokens, s.cfg.DocumentChunkOverlap)
+
+	checksum := sha256.Sum256(data)
+	document := &repository.Document{
+		UserID:      p.UserID,
+		WorkspaceID: p.WorkspaceID,
+		FileName:    fileName,
+		Format:      parsed.Format,
+		Size:        int64(len(data)),
+		Checksum:    hex.EncodeToString(checksum[:]),
+		ChunkCount:  len(chunks),
+	}
+	if parsed.Paginated() {
+		document.PageCount = len(parsed.Pages)
+	}
+
+	rows := make([]*repository.DocumentChunk, len(chunks))
+	for i, chunk := range chunks {
+		rows[i] = &repository.DocumentChunk{
+			Seq:     i,
+			Page:    chunk.Page,
+			Offset:  chunk.Offset,
+			Length:  chunk.Length,
+			Content: chunk.Content,
+			Tokens:  chunk.Tokens,
+		}
+		document.Tokens += chunk.Tokens
+	}
+
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(document).Error; err != nil {
+			return err
+		}
+		for _, row := range rows {
+			row.DocumentID = document.ID
+		}
+		return tx.CreateInBatches(rows, 200).Error
+	})
+	if err != nil {
+		return nil, fmt.Errorf("保存文档失败: %w", err)
+	}
+	return toDocumentResponse(document), nil
+}
+
+// FindAll 获取当前空间的文档，q 按文件名过滤
+func (s *DocumentService) FindAll(p policy.Principal, page, pageSize int, q string) ([]*DocumentResponse, int64, error) {
+	if err := s.authz.WorkspaceContent(p, policy.ActionRead); err != nil {
+		return nil, 0, err
+	}
+
+	query := s.db.Model(&repository.Document{}).Scopes(s.authz.DocumentScope(p))
+	if q != "" {
+		query = query.Where("file_name ILIKE ?", "%"+q+"%")
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, fmt.Errorf("查询文档总数失败: %w", err)
+	}
+
+	var documents []*repository.Document
+	if err := query.Offset((page - 1) * pageSize).Limit(pageSize).Order("created_at DESC").Find(&documents).Error; err != nil {
+		return nil, 0, fmt.Errorf("查询文档列表失败: %w", err)
+	}
+
+	items := make([]*DocumentResponse, len(documents))
+	for i, document := range documents {
+		items[i] = toDocumentResponse(document)
+	}
+	return items, total, nil
+}
+
+// FindByID 根据ID获取文档
+func (s *DocumentService) FindByID(p policy.Principal, id uint) (*DocumentResponse, error) {
+	document, err := s.authz.Document(p, policy.ActionRead, id)
+	if err != nil {
+		return nil, err
+	}
+	return toDocumentResponse(document), nil
+}
+
+// FindChunks 按顺序分页获取文档片段，page 不为 0 时只返回该页的片段
+func (s *DocumentService) FindChunks(p policy.Principal, id uint, pageNumber, page, pageSize int) ([]*DocumentChunkResponse, int64, error) {
+	if _, err := s.authz.Document(p, policy.ActionRead, id); err != nil {
+		return nil, 0, err
+	}
+
+	query := s.db.Model(&repository.DocumentChunk{}).Where("document_id = ?", id)
+	if pageNumber > 0 {
+		query = query.Where("page = ?", pageNumber)
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, fmt.Errorf("查询文档片段失败: %w", err)
+	}
+
+	var chunks []*repository.DocumentChunk
+	if err := query.Order("seq ASC").Offset((page - 1) * pageSize).Limit(pageSize).Find(&chunks).Error; err != nil {
+		return nil, 0, fmt.Errorf("查询文档片段失败: %w", err)
+	}
+
+	items := make([]*DocumentChunkResponse, len(chunks))
+	for i, chunk := range chunks {
+		items[i] = &DocumentChunkResponse{
+			ID:      chunk.ID,
+			Seq:     chunk.Seq,
+			Page:    chunk.Page,
+			Offset:  chunk.Offset,
+			Length:  chunk.Length,
+			Content: chunk.Content,
+			Tokens:  chunk.Tokens,
+		}
+	}
+	return items, total, nil
+}
+
+// Delete 删除文档及其片段，并从所在的知识库中移除
+func (s *DocumentService) Delete(p policy.Principal, id uint) error {
+	if _, err := s.authz.Document(p, policy.ActionDelete, id); err != nil {
+		return err
+	}
+
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("document_id = ?", id).Delete(&repository.DocumentChunk{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("document_id = ?", id).Delete(&repository.KnowledgeBaseDocument{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&repository.Document{}, id).Error
+	})
+	if err != nil {
+		return fmt.Errorf("删除文档失败: %w", err)
+	}
+	return nil
+}
+
+// Search 在当前空间的文档中检索片段，按相关度排序
+func (s *DocumentService) Search(p policy.Principal, q *DocumentSearchQuery) ([]*DocumentSearchResult, int64, error) {
+	if err := s.authz.WorkspaceContent(p, policy.ActionRead); err != nil {
+		return nil, 0, err
+	}
+
+	terms := strings.Fields(q.Q)
+	if len(terms) > maxSearchTerms {
+		terms = terms[:maxSearchTerms]
+	}
+	tsquery, args := searchTSQuery(terms)
+
+	query := s.db.Model(&repository.DocumentChunk{}).
+		Joins("JOIN documents ON documents.id = document_chunks.document_id").
+		Scopes(s.authz.DocumentScope(p)).
+		Where("document_chunks.search_vector @@ ("+tsquery+")", args...)
+	if q.DocumentID != 0 {
+		query = query.Where("document_chunks.document_id = ?", q.DocumentID)
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, fmt.Errorf("检索文档失败: %w", err)
+	}
+
+	var rows []*documentSearchRow
+	if err := query.
+		Select("document_chunks.id, document_chunks.document_id, documents.file_name, document_chunks.seq, "+
+			"document_chunks.page, document_chunks.offset, document_chunks.content, "+
+			"ts_rank_cd(document_chunks.search_vector, ("+tsquery+")) AS rank", args...).
+		Order("rank DESC, document_chunks.document_id DESC, document_chunks.seq ASC").
+		Offset((q.Page - 1) * q.PageSize).Limit(q.PageSize).
+		Scan(&rows).Error; err != nil {
+		return nil, 0, fmt.Errorf("检索文档失败: %w", err)
+	}
+
+	results := make([]*DocumentSearchResult, len(rows))
+	for i, row := range rows {
+		results[i] = &DocumentSearchResult{
+			DocumentID: row.DocumentID,
+			FileName:   row.FileName,
+			ChunkID:    row.ID,
+			Seq:        row.Seq,
+			Page:       row.Page,
+			Offset:     row.Offset,
+			Rank:       row.Rank,
+			Snippet:    highlightSnippet(row.Content, terms),
+			Source:     documentSource(row.FileName, row.Page),
+		}
+	}
+	return results, total, nil
+}
+
+// Context 生成对话中引用文档的内容
+// 全部片段不超过 token 预算时按原文顺序全部引用；否则优先选择与 question 最相关的片段，剩余预算按原文顺序补充
+// 选中的片段按文档和原文顺序排列，相邻片段去掉重叠部分后合并，并标注文件名和页码
+func (s *DocumentService) Context(p policy.Principal, ids []uint, question string) (*DocumentContext, error) {
+	if len(ids) == 0 {
+		return nil, nil
+	}
+	if len(ids) > MaxDocumentsPerChat {
+		return nil, fmt.Errorf("每次对话最多引用 %d 个文档", MaxDocumentsPerChat)
+	}
+
+	documents := make(map[uint]*repository.Document, len(ids))
+	order := make(map[uint]int, len(ids))
+	for i, id := range ids {
+		document, err := s.authz.Document(p, policy.ActionRead, id)
+		if err != nil {
+			return nil, err
+		}
+		documents[id] = document
+		order[id] = i
+	}
+
+	var chunks []*repository.DocumentChunk
+	if err := s.db.Select("id", "document_id", "seq", "tokens").
+		Where("document_id IN ?", ids).Find(&chunks).Error; err != nil {
+		return nil, fmt.Errorf("查询文档片段失败: %w", err)
+	}
+	sort.Slice(chunks, func(i, j int) bool {
+		if chunks[i].DocumentID != chunks[j].DocumentID {
+			return order[chunks[i].DocumentID] < order[chunks[j].DocumentID]
+		}
+		return chunks[i].Seq < chunks[j].Seq
+	})
+
+	budget := s.cfg.DocumentContextTokens
+	total := 0
+	for _, chunk := range chunks {
+		total += chunk.Tokens
+	}
+
+	selected := make(map[uint]bool)
+	truncated := total > budget
+	if !truncated {
+		for _, chunk := range chunks {
+			selected[chunk.ID] = true
+		}
+	} else {
+		byID := make(map[uint]*repository.DocumentChunk, len(chunks))
+		for _, chunk := range chunks {
+			byID[chunk.ID] = chunk
+		}
+		ranked, err := s.rankChunks(ids, question)
+		if err != nil {
+			return nil, err
+		}
+
+		used := 0
+		take := func(chunk *repository.DocumentChunk) {
+			if chunk != nil && !selected[chunk.ID] && used+chunk.Tokens <= budget {
+				selected[chunk.ID] = true
+				used += chunk.Tokens
+			}
+		}
+		for _, id := range ranked {
+			take(byID[id])
+		}
+		for _, chunk := range chunks {
+			take(chunk)
+		}
+	}
+
+	var picked []*repository.DocumentChunk
+	var selectedIDs []uint
+	for _, chunk := range chunks {
+		if selected[chunk.ID] {
+			selectedIDs = append(selectedIDs, chunk.ID)
+		}
+	}
+	if len(selectedIDs) > 0 {
+		if err := s.db.Where("id IN ?", selectedIDs).Find(&picked).Error; err != nil {
+			return nil, fmt.Errorf("查询文档片段失败: %w", err)
+		}
+	}
+	sort.Slice(picked, func(i, j int) bool {
+		if picked[i].DocumentID != picked[j].DocumentID {
+			return order[picked[i].DocumentID] < order[picked[j].DocumentID]
+		}
+		return picked[i].Seq < picked[j].Seq
+	})
+
+	content := renderDocumentContext(picked, documents, truncated)
+	return &DocumentContext{
+		Content:   content,
+		Tokens:    docparse.EstimateTokens(content),
+		Truncated: truncated,
+	}, nil
+}
+
+// rankChunks 按与问题的相关度返回片段ID
+func (s *DocumentService) rankChunks(ids []uint, question string) ([]uint, error) {
+	tsquery, args := relevanceTSQuery(question)
+	if tsquery == "" {
+		return nil, nil
+	}
+
+	var rows []struct {
+		ID   uint
+		Rank float64
+	}
+	err := s.db.Model(&repository.DocumentChunk{}).
+		Select("id, ts_rank_cd(search_vector, ("+tsquery+")) AS rank", args...).
+		Where("document_id IN ?", ids).
+		Where("search_vector @@ ("+tsquery+")", args...).
+		Order("rank DESC").
+		Limit(maxRankedChunks).
+		Scan(&rows).Error
+	if err != nil {
+		return nil, fmt.Errorf("检索文档片段失败: %w", err)
+	}
+
+	ranked := make([]uint, len(rows))
+	for i, row := range rows {
+		ranked[i] = row.ID
+	}
+	return ranked, nil
+}
+
+// relevanceTSQuery 按问题生成相关度检索的 tsquery 表达式，任一查询词匹配即可，问题中没有查询词时返回空字符串
+func relevanceTSQuery(question string) (string, []interface{}) {
+	terms := contextTerms(question)
+	if len(terms) == 0 {
+		return "", nil
+	}
+
+	parts := make([]string, len(terms))
+	args := make([]interface{}, len(terms))
+	for i, term := range terms {
+		parts[i] = "phraseto_tsquery('simple', search_split_cjk(?))"
+		args[i] = term
+	}
+	return strings.Join(parts, " || "), args
+}
+
+// contextTerms 从问题中提取查询词：非中日韩文本按单词，中日韩文本按相邻两字
+func contextTerms(question string) []string {
+	seen := make(map[string]bool)
+	var terms []string
+	add := func(term string) {
+		if !seen[term] && len(terms) < maxContextTerms {
+			seen[term] = true
+			terms = append(terms, term)
+		}
+	}
+
+	var word []rune
+	var cjk []rune
+	flush := func() {
+		if len(word) >= 2 {
+			add(strings.ToLower(string(word)))
+		}
+		if len(cjk) == 1 {
+			add(string(cjk))
+		}
+		for i := 0; i+1 < len(cjk); i++ {
+			add(string(cjk[i : i+2]))
+		}
+		word, cjk = word[:0], cjk[:0]
+	}
+
+	for _, r := range question {
+		switch {
+		case unicode.In(r, unicode.Han, unicode.Hiragana, unicode.Katakana, unicode.Hangul):
+			if len(word) > 0 {
+				flush()
+			}
+			cjk = append(cjk, r)
+		case unicode.IsLetter(r) || unicode.IsDigit(r):
+			if len(cjk) > 0 {
+				flush()
+			}
+			word = append(word, r)
+		default:
+			flush()
+		}
+	}
+	flush()
+	return terms
+}
+
+// renderDocumentContext 拼接选中的片段，连续片段合并并去掉重叠部分，不连续处重新标注来源
+func renderDocumentContext(chunks []*repository.DocumentChunk, documents map[uint]*repository.Document, truncated bool) string {
+	var b strings.Builder
+	b.WriteString("以下是用户提供的文档内容，回答时请优先依据这些内容，并用方括号中的来源标注引用出处。")
+	if truncated {
+		b.WriteString("文档较长，这里只包含与问题最相关的部分。")
+	}
+
+	var prev *repository.DocumentChunk
+	for _, chunk := range chunks {
+		content := chunk.Content
+		continued := prev != nil && prev.DocumentID == chunk.DocumentID && prev.Seq+1 == chunk.Seq && prev.Page == chunk.Page
+		if continued {
+			overlap := prev.Offset + prev.Length - chunk.Offset
+			if overlap > 0 {
+				runes := []rune(content)
+				content = string(runes[min(overlap, len(runes)):])
+			} else {
+				b.WriteString("\n")
+			}
+			b.WriteString(content)
+		} else {
+			b.WriteString("\n\n[" + documentSource(documents[chunk.DocumentID].FileName, chunk.Page) + "]\n")
+			b.WriteString(content)
+		}
+		prev = chunk
+	}
+	return b.String()
+}
+
+// documentSource 片段的来源标注
+func documentSource(fileName string, page int) string {
+	if page > 0 {
+		return fmt.Sprintf("%s 第 %d 页", fileName, page)
+	}
+	return fileName
+}
+
+// toDocumentResponse 转换为文档响应
+func toDocumentResponse(document *repository.Document) *DocumentResponse {
+	return &DocumentResponse{
+		ID:          document.ID,
+		WorkspaceID: document.WorkspaceID,
+		FileName:    document.FileName,
+		Format:      document.Format,
+		Size:        document.Size,
+		Checksum:    document.Checksum,
+		PageCount:   document.PageCount,
+		ChunkCount:  document.ChunkCount,
+		Tokens:      document.Tokens,
+		CreatedAt:   document.CreatedAt,
+	}
+}
@@ -0,0 +1,340 @@
+package service
+
+import (
+	"ai-chat/assets"
+	"ai-chat/internal/policy"
+	"ai-chat/internal/repository"
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html/template"
+	"strings"
+	"time"
+	"unicode"
+
+	"gorm.io/gorm"
+)
+
+// ExportSchemaVersion JSON 导出格式版本，字段只增不改，不兼容的修改需要提升版本
+const ExportSchemaVersion = 1
+
+// 导出格式
+const (
+	ExportFormatMarkdown = "md"
+	ExportFormatJSON     = "json"
+	ExportFormatHTML     = "html"
+	ExportFormatJSONL    = "jsonl" // OpenAI 微调数据格式，不包含思考内容
+)
+
+// ErrNoFineTuningData 会话中没有用户和助手的对话，无法生成微调数据
+var ErrNoFineTuningData = errors.New("会话中没有可用于微调的对话")
+
+// exportTimeLayout 导出文件中展示的时间格式
+const exportTimeLayout = "2006-01-02 15:04:05"
+
+// ExportService 会话导出服务
+type ExportService struct {
+	db    *gorm.DB
+	authz *Authorizer
+	page  *template.Template
+}
+
+// NewExportService 创建会话导出服务
+func NewExportService(db *gorm.DB) *ExportService {
+	return &ExportService{
+		db:    db,
+		authz: NewAuthorizer(db),
+		page:  template.Must(template.ParseFS(assets.TemplatesFS, "templates/export.html")),
+	}
+}
+
+// ExportOptions 导出选项
+type ExportOptions struct {
+	Format           string `form:"format,default=json" binding:"oneof=md json html jsonl"`
+	IncludeReasoning bool   `form:"include_reasoning"`
+}
+
+// ConversationExport 会话的 JSON 导出格式，可以直接通过会话导入接口导入
+type ConversationExport struct {
+	SchemaVersion int               `json:"schemaVersion"`
+	ID            uint              `json:"id"`
+	Name          string            `json:"name"`
+	SystemPrompt  *string           `json:"systemPrompt"`
+	Model         *string           `json:"model"`
+	Temperature   *float64          `json:"temperature"`
+	CreatedAt     time.Time         `json:"createdAt"`
+	UpdatedAt     time.Time         `json:"updatedAt"`
+	ExportedAt    time.Time         `json:"exportedAt"`
+	Messages      []ExportedMessage `json:"messages"`
+}
+
+// ExportedMessage 导出的消息，未选择导出思考内容时省略 reasoningContent
+type ExportedMessage struct {
+	ID               uint      `json:"id"`
+	ParentID         *uint     `json:"parentId"`
+	Type             string    `json:"type"` // system user assistant
+	Content          string    `json:"content"`
+	ReasoningContent string    `json:"reasoningContent,omitempty"`
+	Model            *string   `json:"model"`
+	Tokens           *int      `json:"tokens"`
+	CreatedAt        time.Time `json:"createdAt"`
+}
+
+// ExportFile 导出结果
+type ExportFile struct {
+	Name        string
+	ContentType string
+	Data        []byte
+}
+
+// fineTuningLine OpenAI 微调数据的一行
+type fineTuningLine struct {
+	Messages []fineTuningMessage `json:"messages"`
+}
+
+// fineTuningMessage OpenAI 微调数据中的消息
+type fineTuningMessage struct {
+	Role    string `json:"role"`
+	Content string `json:"content"`
+}
+
+// Export 导出单个会话，需要会话读取权限
+func (s *ExportService) Export(p policy.Principal, id uint, opts *ExportOptions) (*ExportFile, error) {
+	conversation, err := s.authz.Conversation(p, policy.ActionRead, id)
+	if err != nil {
+		return nil, err
+	}
+
+	export, err := s.load(conversation, opts.IncludeReasoning)
+	if err != nil {
+		return nil, err
+	}
+
+	data, contentType, err := s.render(export, opts.Format)
+	if err != nil {
+		return nil, err
+	}
+
+	return &ExportFile{
+		Name:        exportFileName(export) + "." + opts.Format,
+		ContentType: contentType,
+		Data:        data,
+	}, nil
+}
+
+// ExportAccount 将用户创建且仍有权查看的全部会话打包为 zip，已离开的工作区中的会话不导出
+// jsonl 格式合并为一个 conversations.jsonl，便于直接用于微调，没有对话的会话不写入
+func (s *ExportService) ExportAccount(p policy.Principal, opts *ExportOptions) (*ExportFile, error) {
+	var conversations []*repository.Conversation
+	if err := s.db.Where("user_id = ?", p.UserID).Order("id").Find(&conversations).Error; err != nil {
+		return nil, fmt.Errorf("查询会话列表失败: %w", err)
+	}
+	conversations, err := s.authz.FilterConversations(p, policy.ActionRead, conversations)
+	if err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	var lines bytes.Buffer
+
+	for _, conversation := range conversations {
+		export, err := s.load(conversation, opts.IncludeReasoning)
+		if err != nil {
+			return nil, err
+		}
+
+		data, _, err := s.render(export, opts.Format)
+		if errors.Is(err, ErrNoFineTuningData) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		if opts.Format == ExportFormatJSONL {
+			lines.Write(data)
+			continue
+		}
+
+		w, err := zw.CreateHeader(&zip.FileHeader{
+			Name:     exportFileName(export) + "." + opts.Format,
+			Method:   zip.Deflate,
+			Modified: export.UpdatedAt,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("生成压缩包失败: %w", err)
+		}
+		if _, err := w.Write(data); err != nil {
+			return nil, fmt.Errorf("生成压缩包失败: %w", err)
+		}
+	}
+
+	if opts.Format == ExportFormatJSONL {
+		w, err := zw.Create("conversations.jsonl")
+		if err != nil {
+			return nil, fmt.Errorf("生成压缩包失败: %w", err)
+		}
+		if _, err := w.Write(lines.Bytes()); err != nil {
+			return nil, fmt.Errorf("生成压缩包失败: %w", err)
+		}
+	}
+
+	if err := zw.Close(); err != nil {
+		return nil, fmt.Errorf("生成压缩包失败: %w", err)
+	}
+
+	return &ExportFile{
+		Name:        "conversations-" + time.Now().Format("20060102") + ".zip",
+		ContentType: "application/zip",
+		Data:        buf.Bytes(),
+	}, nil
+}
+
+// load 读取会话消息并转换为导出格式
+func (s *ExportService) load(conversation *repository.Conversation, includeReasoning bool) (*ConversationExport, error) {
+	var messages []*repository.Message
+	if err := s.db.Where("conversation_id = ?", conversation.ID).
+		Order("sort ASC, id ASC").Find(&messages).Error; err != nil {
+		return nil, fmt.Errorf("查询消息失败: %w", err)
+	}
+
+	export := &ConversationExport{
+		SchemaVersion: ExportSchemaVersion,
+		ID:            conversation.ID,
+		Name:          conversation.Name,
+		SystemPrompt:  conversation.SystemPrompt,
+		Model:         conversation.Model,
+		Temperature:   conversation.Temperature,
+		CreatedAt:     conversation.CreatedAt,
+		UpdatedAt:     conversation.UpdatedAt,
+		ExportedAt:    time.Now(),
+		Messages:      make([]ExportedMessage, len(messages)),
+	}
+	for i, message := range messages {
+		export.Messages[i] = ExportedMessage{
+			ID:        message.ID,
+			ParentID:  message.ParentID,
+			Type:      message.Type,
+			Content:   message.Content,
+			Model:     message.Model,
+			Tokens:    message.Tokens,
+			CreatedAt: message.CreatedAt,
+		}
+		if includeReasoning {
+			export.Messages[i].ReasoningContent = message.ReasoningContent
+		}
+	}
+	return export, nil
+}
+
+// render 按格式生成导出内容，返回内容和 Content-Type
+func (s *ExportService) render(export *ConversationExport, format string) ([]byte, string, error) {
+	switch format {
+	case ExportFormatJSON:
+		data, err := json.MarshalIndent(export, "", "  ")
+		if err != nil {
+			return nil, "", fmt.Errorf("生成 JSON 失败: %w", err)
+		}
+		return data, "application/json; charset=utf-8", nil
+	case ExportFormatJSONL:
+		line, ok := toFineTuningLine(export)
+		if !ok {
+			return nil, "", ErrNoFineTuningData
+		}
+		data, err := json.Marshal(line)
+		if err != nil {
+			return nil, "", fmt.Errorf("生成 JSONL 失败: %w", err)
+		}
+		return append(data, '\n'), "application/jsonl; charset=utf-8", nil
+	case ExportFormatMarkdown:
+		return []byte(toMarkdown(export)), "text/markdown; charset=utf-8", nil
+	case ExportFormatHTML:
+		var buf bytes.Buffer
+		if err := s.page.Execute(&buf, export); err != nil {
+			return nil, "", fmt.Errorf("生成 HTML 失败: %w", err)
+		}
+		return buf.Bytes(), "text/html; charset=utf-8", nil
+	}
+	return nil, "", fmt.Errorf("不支持的导出格式: %s", format)
+}
+
+// toMarkdown 生成 Markdown 格式，思考内容以引用块展示
+func toMarkdown(export *ConversationExport) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "# %s\n\n", export.Name)
+	if export.Model != nil {
+		fmt.Fprintf(&b, "- 模型: %s\n", *export.Model)
+	}
+	fmt.Fprintf(&b, "- 创建时间: %s\n", export.CreatedAt.Format(exportTimeLayout))
+	fmt.Fprintf(&b, "- 导出时间: %s\n\n", export.ExportedAt.Format(exportTimeLayout))
+	if export.SystemPrompt != nil && *export.SystemPrompt != "" {
+		fmt.Fprintf(&b, "## 系统提示词\n\n%s\n\n", *export.SystemPrompt)
+	}
+
+	for _, message := range export.Messages {
+		b.WriteString("---\n\n")
+		fmt.Fprintf(&b, "### %s", messageRoleLabel(message.Type))
+		if message.Model != nil {
+			fmt.Fprintf(&b, " (%s)", *message.Model)
+		}
+		fmt.Fprintf(&b, " · %s\n\n", message.CreatedAt.Format(exportTimeLayout))
+		if message.ReasoningContent != "" {
+			b.WriteString("> **思考过程**\n>\n")
+			for _, line := range strings.Split(message.ReasoningContent, "\n") {
+				b.WriteString(strings.TrimRight("> "+line, " ") + "\n")
+			}
+			b.WriteString("\n")
+		}
+		b.WriteString(message.Content + "\n\n")
+	}
+	return b.String()
+}
+
+// toFineTuningLine 转换为 OpenAI 微调数据，会话提示词作为第一条 system 消息，跳过空消息
+// 没有用户和助手的对话时返回 false，OpenAI 不接受这样的数据
+func toFineTuningLine(export *ConversationExport) (fineTuningLine, bool) {
+	var line fineTuningLine
+	if export.SystemPrompt != nil && *export.SystemPrompt != "" {
+		line.Messages = append(line.Messages, fineTuningMessage{Role: "system", Content: *export.SystemPrompt})
+	}
+	hasUser, hasAssistant := false, false
+	for _, message := range export.Messages {
+		if message.Content == "" {
+			continue
+		}
+		hasUser = hasUser || message.Type == "user"
+		hasAssistant = hasAssistant || message.Type == "assistant"
+		line.Messages = append(line.Messages, fineTuningMessage{Role: message.Type, Content: message.Content})
+	}
+	return line, hasUser && hasAssistant
+}
+
+// messageRoleLabel 消息类型的展示名称
+func messageRoleLabel(messageType string) string {
+	switch messageType {
+	case "user":
+		return "用户"
+	case "assistant":
+		return "助手"
+	case "system":
+		return "系统"
+	}
+	return messageType
+}
+
+// exportFileName 导出文件名（不含扩展名），由会话ID和名称组成，去掉文件名中不安全的字符
+func exportFileName(export *ConversationExport) string {
+	name := []rune(strings.Map(func(r rune) rune {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) || r == '-' || r == '_' {
+			return r
+		}
+		return '_'
+	}, export.Name))
+	if len(name) > 50 {
+		name = name[:50]
+	}
+	return fmt.Sprintf("%d-%s", export.ID, string(name))
+}
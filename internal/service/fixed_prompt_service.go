@@ -3,43 +3,89 @@ package service
 import (
 	"ai-chat/internal/common"
 	"ai-chat/internal/dto"
+	"ai-chat/internal/policy"
 	"ai-chat/internal/repository"
+	"errors"
 	"fmt"
+	"strings"
+	"time"
 
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
+// maxDiffCells 逐行对比的最大计算量（两个版本行数的乘积），超过时按整体替换展示
+const maxDiffCells = 4_000_000
+
+// PromptVersionRef 生成回答时使用的固定提示词版本，记录在助手消息的元数据中
+type PromptVersionRef struct {
+	ID      uint `json:"id"`
+	Version int  `json:"version"`
+}
+
+// PromptID 返回提示词ID，未使用固定提示词时返回 nil
+func (r *PromptVersionRef) PromptID() *uint {
+	if r == nil {
+		return nil
+	}
+	return &r.ID
+}
+
 // FixedPromptService 固定提示词服务
 type FixedPromptService struct {
-	db *gorm.DB
+	db    *gorm.DB
+	authz *Authorizer
 }
 
 // NewFixedPromptService 创建固定提示词服务
 func NewFixedPromptService(db *gorm.DB) *FixedPromptService {
-	return &FixedPromptService{db: db}
+	return &FixedPromptService{
+		db:    db,
+		authz: NewAuthorizer(db),
+	}
 }
 
 // Create 创建固定提示词
-func (s *FixedPromptService) Create(userID uint, req *dto.CreateFixedPromptRequest) (*dto.FixedPromptResponse, error) {
+func (s *FixedPromptService) Create(p policy.Principal, req *dto.CreateFixedPromptRequest) (*dto.FixedPromptResponse, error) {
+	if err := s.authz.WorkspaceContent(p, policy.ActionWrite); err != nil {
+		return nil, err
+	}
+	if err := validatePromptTemplate(req.Content, req.Variables); err != nil {
+		return nil, err
+	}
+
 	fixedPrompt := &repository.FixedPrompt{
-		Name:    req.Name,
-		Content: req.Content,
-		UserID:  userID,
+		Name:        req.Name,
+		Content:     req.Content,
+		Variables:   encodePromptVariables(req.Variables),
+		Version:     1,
+		UserID:      p.UserID,
+		WorkspaceID: p.WorkspaceID,
 	}
 
-	if err := s.db.Create(fixedPrompt).Error; err != nil {
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(fixedPrompt).Error; err != nil {
+			return err
+		}
+		return tx.Create(initialVersion(fixedPrompt, p.UserID)).Error
+	})
+	if err != nil {
 		return nil, fmt.Errorf("创建固定提示词失败: %w", err)
 	}
 
 	return s.toResponse(fixedPrompt), nil
 }
 
-// FindAll 获取用户的固定提示词
-func (s *FixedPromptService) FindAll(userID uint, page, pageSize int, q string) (*dto.PaginatedFixedPrompts, error) {
+// FindAll 获取当前空间的固定提示词，工作区中即为共享提示词库
+func (s *FixedPromptService) FindAll(p policy.Principal, page, pageSize int, q string) (*dto.PaginatedFixedPrompts, error) {
+	if err := s.authz.WorkspaceContent(p, policy.ActionRead); err != nil {
+		return nil, err
+	}
+
 	var fixedPrompts []*repository.FixedPrompt
 	var total int64
 
-	query := s.db.Model(&repository.FixedPrompt{}).Where("user_id = ?", userID)
+	query := s.db.Model(&repository.FixedPrompt{}).Scopes(s.authz.FixedPromptScope(p))
 
 	if q != "" {
 		query = query.Where("name ILIKE ? OR content ILIKE ?", "%"+q+"%", "%"+q+"%")
@@ -73,20 +119,20 @@ func (s *FixedPromptService) FindAll(userID uint, page, pageSize int, q string)
 }
 
 // FindByID 根据ID查找固定提示词
-func (s *FixedPromptService) FindByID(userID, id uint) (*dto.FixedPromptResponse, error) {
-	var fixedPrompt repository.FixedPrompt
-	if err := s.db.Where("id = ? AND user_id = ?", id, userID).First(&fixedPrompt).Error; err != nil {
-		return nil, fmt.Errorf("查找固定提示词失败或无权访问: %w", err)
+func (s *FixedPromptService) FindByID(p policy.Principal, id uint) (*dto.FixedPromptResponse, error) {
+	fixedPrompt, err := s.authz.FixedPrompt(p, policy.ActionRead, id)
+	if err != nil {
+		return nil, err
 	}
 
-	return s.toResponse(&fixedPrompt), nil
+	return s.toResponse(fixedPrompt), nil
 }
 
 // Update 更新固定提示词
-func (s *FixedPromptService) Update(userID, id uint, req *dto.UpdateFixedPromptRequest) (*dto.FixedPromptResponse, error) {
-	var fixedPrompt repository.FixedPrompt
-	if err := s.db.Where("id = ? AND user_id = ?", id, userID).First(&fixedPrompt).Error; err != nil {
-		return nil, fmt.Errorf("查找固定提示词失败或无权访问: %w", err)
+func (s *FixedPromptService) Update(p policy.Principal, id uint, req *dto.UpdateFixedPromptRequest) (*dto.FixedPromptResponse, error) {
+	fixedPrompt, err := s.authz.FixedPrompt(p, policy.ActionWrite, id)
+	if err != nil {
+		return nil, err
 	}
 
 	// 更新字段
@@ -94,44 +140,338 @@ func (s *FixedPromptService) Update(userID, id uint, req *dto.UpdateFixedPromptR
 	if req.Name != nil {
 		updates["name"] = *req.Name
 	}
-	if req.Content != nil {
-		updates["content"] = *req.Content
-	}
 	if req.IsActive != nil {
 		updates["is_active"] = *req.IsActive
 	}
 
-	if len(updates) == 0 {
-		return s.toResponse(&fixedPrompt), nil
+	content, variables := fixedPrompt.Content, decodePromptVariables(fixedPrompt.Variables)
+	if req.Content != nil {
+		content = *req.Content
+	}
+	if req.Variables != nil {
+		variables = *req.Variables
 	}
 
-	if err := s.db.Model(&fixedPrompt).Updates(updates).Error; err != nil {
+	// 内容或变量变化时校验模板并追加新版本，只修改名称或状态时不产生版本
+	if versionChanged(fixedPrompt, content, variables) {
+		if err := validatePromptTemplate(content, variables); err != nil {
+			return nil, err
+		}
+		if err := s.commitVersion(p, fixedPrompt.ID, content, variables, updates); err != nil {
+			return nil, fmt.Errorf("更新固定提示词失败: %w", err)
+		}
+	} else if len(updates) == 0 {
+		return s.toResponse(fixedPrompt), nil
+	} else if err := s.db.Model(fixedPrompt).Updates(updates).Error; err != nil {
 		return nil, fmt.Errorf("更新固定提示词失败: %w", err)
 	}
 
 	// 重新获取更新后的数据
-	s.db.First(&fixedPrompt, id)
+	s.db.First(fixedPrompt, id)
 
-	return s.toResponse(&fixedPrompt), nil
+	return s.toResponse(fixedPrompt), nil
 }
 
 // Delete 删除固定提示词
-func (s *FixedPromptService) Delete(userID, id uint) error {
-	if err := s.db.Where("id = ? AND user_id = ?", id, userID).Delete(&repository.FixedPrompt{}).Error; err != nil {
-		return fmt.Errorf("删除固定提示词失败或无权删除: %w", err)
+func (s *FixedPromptService) Delete(p policy.Principal, id uint) error {
+	if _, err := s.authz.FixedPrompt(p, policy.ActionDelete, id); err != nil {
+		return err
+	}
+
+	if err := s.db.Delete(&repository.FixedPrompt{}, id).Error; err != nil {
+		return fmt.Errorf("删除固定提示词失败: %w", err)
 	}
 
 	return nil
 }
 
+// Versions 获取固定提示词的全部版本，按版本号倒序
+func (s *FixedPromptService) Versions(p policy.Principal, id uint) ([]*dto.FixedPromptVersionResponse, error) {
+	fixedPrompt, err := s.authz.FixedPrompt(p, policy.ActionRead, id)
+	if err != nil {
+		return nil, err
+	}
+
+	var versions []*repository.FixedPromptVersion
+	if err := s.db.Where("fixed_prompt_id = ?", id).Order("version DESC").Find(&versions).Error; err != nil {
+		return nil, fmt.Errorf("查询提示词版本失败: %w", err)
+	}
+	// 版本功能上线前创建且未修改过的提示词没有版本记录，以当前内容作为第一版
+	if len(versions) == 0 {
+		versions = append(versions, initialVersion(fixedPrompt, fixedPrompt.UserID))
+	}
+
+	items := make([]*dto.FixedPromptVersionResponse, len(versions))
+	for i, version := range versions {
+		items[i] = toVersionResponse(version, fixedPrompt.Version)
+	}
+	return items, nil
+}
+
+// Version 获取固定提示词的指定版本
+func (s *FixedPromptService) Version(p policy.Principal, id uint, version int) (*dto.FixedPromptVersionResponse, error) {
+	fixedPrompt, err := s.authz.FixedPrompt(p, policy.ActionRead, id)
+	if err != nil {
+		return nil, err
+	}
+
+	v, err := s.findVersion(fixedPrompt, version)
+	if err != nil {
+		return nil, err
+	}
+	return toVersionResponse(v, fixedPrompt.Version), nil
+}
+
+// Diff 逐行对比两个版本的内容，并标记变量定义是否变化
+func (s *FixedPromptService) Diff(p policy.Principal, id uint, from, to int) (*dto.FixedPromptDiffResponse, error) {
+	fixedPrompt, err := s.authz.FixedPrompt(p, policy.ActionRead, id)
+	if err != nil {
+		return nil, err
+	}
+
+	if to == 0 {
+		to = fixedPrompt.Version
+	}
+	if from == 0 {
+		from = max(to-1, 1)
+	}
+	fromVersion, err := s.findVersion(fixedPrompt, from)
+	if err != nil {
+		return nil, err
+	}
+	toVersion, err := s.findVersion(fixedPrompt, to)
+	if err != nil {
+		return nil, err
+	}
+
+	fromVariables := decodePromptVariables(fromVersion.Variables)
+	toVariables := decodePromptVariables(toVersion.Variables)
+	diff := &dto.FixedPromptDiffResponse{
+		From:             from,
+		To:               to,
+		Lines:            diffLines(fromVersion.Content, toVersion.Content),
+		VariablesChanged: encodePromptVariables(fromVariables) != encodePromptVariables(toVariables),
+		FromVariables:    fromVariables,
+		ToVariables:      toVariables,
+	}
+	for _, line := range diff.Lines {
+		switch line.Op {
+		case diffInsert:
+			diff.Added++
+		case diffDelete:
+			diff.Removed++
+		}
+	}
+	return diff, nil
+}
+
+// Rollback 回滚到指定版本，以该版本的内容追加一个新版本，历史版本保持不变
+func (s *FixedPromptService) Rollback(p policy.Principal, id uint, version int) (*dto.FixedPromptResponse, error) {
+	fixedPrompt, err := s.authz.FixedPrompt(p, policy.ActionWrite, id)
+	if err != nil {
+		return nil, err
+	}
+
+	target, err := s.findVersion(fixedPrompt, version)
+	if err != nil {
+		return nil, err
+	}
+	variables := decodePromptVariables(target.Variables)
+	if !versionChanged(fixedPrompt, target.Content, variables) {
+		return s.toResponse(fixedPrompt), nil
+	}
+
+	if err := s.commitVersion(p, id, target.Content, variables, map[string]interface{}{}); err != nil {
+		return nil, fmt.Errorf("回滚固定提示词失败: %w", err)
+	}
+	if err := s.db.First(fixedPrompt, id).Error; err != nil {
+		return nil, fmt.Errorf("查询固定提示词失败: %w", err)
+	}
+	return s.toResponse(fixedPrompt), nil
+}
+
+// commitVersion 追加新版本并更新提示词的当前内容，updates 为同时更新的其他字段
+func (s *FixedPromptService) commitVersion(p policy.Principal, id uint, content string, variables []dto.PromptVariable, updates map[string]interface{}) error {
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		// 锁定提示词，避免并发修改产生相同的版本号
+		var current repository.FixedPrompt
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&current, id).Error; err != nil {
+			return err
+		}
+		// 版本功能上线前创建的提示词没有版本记录，先保存修改前的内容
+		if err := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(initialVersion(&current, current.UserID)).Error; err != nil {
+			return err
+		}
+
+		version := &repository.FixedPromptVersion{
+			FixedPromptID: id,
+			Version:       current.Version + 1,
+			Content:       content,
+			Variables:     encodePromptVariables(variables),
+			UserID:        p.UserID,
+		}
+		if err := tx.Create(version).Error; err != nil {
+			return err
+		}
+
+		updates["content"] = content
+		updates["variables"] = version.Variables
+		updates["version"] = version.Version
+		return tx.Model(&current).Updates(updates).Error
+	})
+}
+
+// findVersion 查询指定版本，没有版本记录的当前版本以提示词的当前内容代替
+func (s *FixedPromptService) findVersion(fp *repository.FixedPrompt, version int) (*repository.FixedPromptVersion, error) {
+	var v repository.FixedPromptVersion
+	err := s.db.Where("fixed_prompt_id = ? AND version = ?", fp.ID, version).First(&v).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) && version == fp.Version {
+		return initialVersion(fp, fp.UserID), nil
+	}
+	if err != nil {
+		return nil, notFoundOr(err, "查询提示词版本失败")
+	}
+	return &v, nil
+}
+
+// Render 渲染提示词模板，values 为调用方填写的变量取值，内置变量由服务端填充
+func (s *FixedPromptService) Render(p policy.Principal, fp *dto.FixedPromptResponse, values map[string]string) (string, error) {
+	if len(fp.Variables) == 0 && len(values) == 0 && len(promptTemplateVariables(fp.Content)) == 0 {
+		return fp.Content, nil
+	}
+
+	builtins, err := s.builtinVariables(p)
+	if err != nil {
+		return "", err
+	}
+	return renderPromptTemplate(fp.Content, fp.Variables, values, builtins)
+}
+
+// builtinVariables 当前时间、用户资料和工作区名称等内置变量
+func (s *FixedPromptService) builtinVariables(p policy.Principal) (map[string]string, error) {
+	builtins := timePromptVariables(time.Now())
+
+	var user repository.User
+	if err := s.db.Select("name", "email").First(&user, p.UserID).Error; err != nil {
+		return nil, fmt.Errorf("查询用户资料失败: %w", err)
+	}
+	builtins["user.name"] = user.Name
+	builtins["user.email"] = user.Email
+
+	builtins["workspace.name"] = ""
+	if p.WorkspaceID != nil {
+		var workspace repository.Workspace
+		if err := s.db.Select("name").First(&workspace, *p.WorkspaceID).Error; err != nil {
+			return nil, fmt.Errorf("查询工作区失败: %w", err)
+		}
+		builtins["workspace.name"] = workspace.Name
+	}
+	return builtins, nil
+}
+
 // toResponse 转换为响应结构
 func (s *FixedPromptService) toResponse(fp *repository.FixedPrompt) *dto.FixedPromptResponse {
 	return &dto.FixedPromptResponse{
-		ID:        fp.ID,
-		Name:      fp.Name,
-		Content:   fp.Content,
-		IsActive:  fp.IsActive,
-		CreatedAt: fp.CreatedAt.Format(common.TimeLayout),
-		UpdatedAt: fp.UpdatedAt.Format(common.TimeLayout),
+		ID:          fp.ID,
+		Name:        fp.Name,
+		Content:     fp.Content,
+		Variables:   decodePromptVariables(fp.Variables),
+		Version:     fp.Version,
+		WorkspaceID: fp.WorkspaceID,
+		IsActive:    fp.IsActive,
+		CreatedAt:   fp.CreatedAt.Format(common.TimeLayout),
+		UpdatedAt:   fp.UpdatedAt.Format(common.TimeLayout),
+	}
+}
+
+// initialVersion 以提示词的当前内容生成版本记录
+func initialVersion(fp *repository.FixedPrompt, userID uint) *repository.FixedPromptVersion {
+	return &repository.FixedPromptVersion{
+		FixedPromptID: fp.ID,
+		Version:       fp.Version,
+		Content:       fp.Content,
+		Variables:     encodePromptVariables(decodePromptVariables(fp.Variables)),
+		UserID:        userID,
+		CreatedAt:     fp.UpdatedAt,
+	}
+}
+
+// versionChanged 内容或变量定义与当前版本不同时返回 true
+func versionChanged(fp *repository.FixedPrompt, content string, variables []dto.PromptVariable) bool {
+	return content != fp.Content || encodePromptVariables(variables) != encodePromptVariables(decodePromptVariables(fp.Variables))
+}
+
+// toVersionResponse 转换为版本响应结构
+func toVersionResponse(v *repository.FixedPromptVersion, current int) *dto.FixedPromptVersionResponse {
+	return &dto.FixedPromptVersionResponse{
+		Version:   v.Version,
+		Content:   v.Content,
+		Variables: decodePromptVariables(v.Variables),
+		UserID:    v.UserID,
+		Current:   v.Version == current,
+		CreatedAt: v.CreatedAt.Format(common.TimeLayout),
+	}
+}
+
+// 逐行对比的操作类型
+const (
+	diffEqual  = "equal"
+	diffInsert = "insert"
+	diffDelete = "delete"
+)
+
+// diffLines 基于最长公共子序列逐行对比两段文本
+func diffLines(from, to string) []dto.FixedPromptDiffLine {
+	a := strings.Split(from, "\n")
+	b := strings.Split(to, "\n")
+	lines := make([]dto.FixedPromptDiffLine, 0, len(a)+len(b))
+
+	// 行数过多时不计算公共子序列，整体替换
+	if len(a)*len(b) > maxDiffCells {
+		for _, line := range a {
+			lines = append(lines, dto.FixedPromptDiffLine{Op: diffDelete, Text: line})
+		}
+		for _, line := range b {
+			lines = append(lines, dto.FixedPromptDiffLine{Op: diffInsert, Text: line})
+		}
+		return lines
+	}
+
+	// lcs[i][j] 为 a[i:] 与 b[j:] 的最长公共子序列长度
+	lcs := make([][]int, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+
+	i, j := 0, 0
+	for i < len(a) && j < len(b) {
+		switch {
+		case a[i] == b[j]:
+			lines = append(lines, dto.FixedPromptDiffLine{Op: diffEqual, Text: a[i]})
+			i++
+			j++
+		case lcs[i+1][j] >= lcs[i][j+1]:
+			lines = append(lines, dto.FixedPromptDiffLine{Op: diffDelete, Text: a[i]})
+			i++
+		default:
+			lines = append(lines, dto.FixedPromptDiffLine{Op: diffInsert, Text: b[j]})
+			j++
+		}
+	}
+	for ; i < len(a); i++ {
+		lines = append(lines, dto.FixedPromptDiffLine{Op: diffDelete, Text: a[i]})
+	}
+	for ; j < len(b); j++ {
+		lines = append(lines, dto.FixedPromptDiffLine{Op: diffInsert, Text: b[j]})
 	}
+	return lines
 }
@@ -0,0 +1,724 @@
+package service
+
+import (
+	"ai-chat/internal/policy"
+	"ai-chat/internal/repository"
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"path"
+	"sort"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// 历史记录导入来源
+const (
+	HistorySourceAuto    = "auto"
+	HistorySourceChatGPT = "chatgpt" // ChatGPT 导出的 conversations.json
+	HistorySourceClaude  = "claude"  // Claude 导出的 conversations.json
+	HistorySourceNative  = "native"  // 本系统的 JSON 导出
+)
+
+// 单个会话的导入结果
+const (
+	HistoryImportImported = "imported"
+	HistoryImportSkipped  = "skipped" // 已导入过或没有可导入的消息
+	HistoryImportFailed   = "failed"
+)
+
+// MaxHistoryUnzippedSize 命令行导入时压缩包解压后的总大小上限，接口导入由调用方传入更小的上限
+const MaxHistoryUnzippedSize = 1 << 30
+
+// HistoryImportService 从其他聊天工具导入历史会话
+// 每个会话单独一个事务，个别会话失败不影响其他会话
+type HistoryImportService struct {
+	db    *gorm.DB
+	authz *Authorizer
+}
+
+// NewHistoryImportService 创建历史记录导入服务
+func NewHistoryImportService(db *gorm.DB) *HistoryImportService {
+	return &HistoryImportService{
+		db:    db,
+		authz: NewAuthorizer(db),
+	}
+}
+
+// HistoryImportReport 导入结果报告
+type HistoryImportReport struct {
+	Source   string              `json:"source"`
+	Total    int                 `json:"total"`
+	Imported int                 `json:"imported"`
+	Skipped  int                 `json:"skipped"`
+	Failed   int                 `json:"failed"`
+	Items    []HistoryImportItem `json:"items"`
+}
+
+// HistoryImportItem 单个会话的导入结果
+type HistoryImportItem struct {
+	Title          string `json:"title"`
+	ExternalID     string `json:"externalId,omitempty"`
+	ConversationID *uint  `json:"conversationId,omitempty"`
+	Messages       int    `json:"messages"`
+	Status         string `json:"status"`
+	Error          string `json:"error,omitempty"`
+}
+
+// HistoryImportProgress 导入进度回调，每处理完一个会话调用一次
+type HistoryImportProgress func(done, total int, item HistoryImportItem)
+
+// historyConversation 各来源解析后的统一会话结构
+type historyConversation struct {
+	ExternalID   string
+	Title        string
+	SystemPrompt *string
+	Model        *string
+	Temperature  *float64
+	CreatedAt    time.Time
+	UpdatedAt    time.Time
+	Messages     []historyMessage
+}
+
+// historyMessage 各来源解析后的统一消息结构
+type historyMessage struct {
+	Type      string
+	Content   string
+	Reasoning string
+	Model     *string
+	Tokens    *int
+	CreatedAt time.Time
+	Parent    int // 父消息在 Messages 中的下标，-1 表示没有
+}
+
+// Import 解析导出文件并导入到主体当前空间，data 可以是 JSON 或包含 conversations.json 的 zip
+// maxUnzipped 为压缩包中读取的文件解压后的总大小上限，防止压缩炸弹
+func (s *HistoryImportService) Import(p policy.Principal, data []byte, source string, maxUnzipped int64, progress HistoryImportProgress) (*HistoryImportReport, error) {
+	if err := s.authz.WorkspaceContent(p, policy.ActionWrite); err != nil {
+		return nil, err
+	}
+
+	source, conversations, err := parseHistory(data, source, maxUnzipped)
+	if err != nil {
+		return nil, err
+	}
+
+	report := &HistoryImportReport{
+		Source: source,
+		Total:  len(conversations),
+		Items:  make([]HistoryImportItem, 0, len(conversations)),
+	}
+	for i, conversation := range conversations {
+		item := s.importOne(p, source, conversation)
+		switch item.Status {
+		case HistoryImportImported:
+			report.Imported++
+		case HistoryImportSkipped:
+			report.Skipped++
+		case HistoryImportFailed:
+			report.Failed++
+		}
+		report.Items = append(report.Items, item)
+		if progress != nil {
+			progress(i+1, len(conversations), item)
+		}
+	}
+	return report, nil
+}
+
+// importOne 导入单个会话
+func (s *HistoryImportService) importOne(p policy.Principal, source string, h *historyConversation) HistoryImportItem {
+	item := HistoryImportItem{
+		Title:      h.Title,
+		ExternalID: h.ExternalID,
+		Messages:   len(h.Messages),
+	}
+	if len(h.Messages) == 0 {
+		item.Status = HistoryImportSkipped
+		item.Error = "没有可导入的消息"
+		return item
+	}
+
+	if h.ExternalID != "" {
+		var existing repository.Conversation
+		err := s.db.Scopes(s.authz.ConversationScope(p)).
+			Where("user_id = ? AND metadata->'importedFrom'->>'source' = ? AND metadata->'importedFrom'->>'externalId' = ?",
+				p.UserID, source, h.ExternalID).
+			First(&existing).Error
+		if err == nil {
+			item.Status = HistoryImportSkipped
+			item.ConversationID = &existing.ID
+			item.Error = "已导入过"
+			return item
+		}
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			item.Status = HistoryImportFailed
+			item.Error = fmt.Sprintf("查找已导入会话失败: %v", err)
+			return item
+		}
+	}
+
+	var provenance importProvenance
+	provenance.ImportedFrom.Source = source
+	provenance.ImportedFrom.ExternalID = h.ExternalID
+	provenance.ImportedFrom.ImportedBy = p.UserID
+	provenance.ImportedFrom.ImportedAt = time.Now()
+	metadata, err := json.Marshal(provenance)
+	if err != nil {
+		item.Status = HistoryImportFailed
+		item.Error = err.Error()
+		return item
+	}
+	metadataStr := string(metadata)
+
+	title := h.Title
+	if title == "" {
+		title = "导入的会话"
+	}
+	conversation := &repository.Conversation{
+		Name:         truncateRunes(title, 255),
+		UserID:       p.UserID,
+		WorkspaceID:  p.WorkspaceID,
+		SystemPrompt: h.SystemPrompt,
+		Model:        truncateModel(h.Model),
+		Temperature:  h.Temperature,
+		Metadata:     &metadataStr,
+		CreatedAt:    h.CreatedAt,
+		UpdatedAt:    h.UpdatedAt,
+	}
+
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(conversation).Error; err != nil {
+			return fmt.Errorf("创建会话失败: %w", err)
+		}
+
+		ids := make([]uint, len(h.Messages))
+		for i, m := range h.Messages {
+			message := &repository.Message{
+				ConversationID:   conversation.ID,
+				Content:          m.Content,
+				ReasoningContent: m.Reasoning,
+				Sort:             i + 1,
+				Type:             m.Type,
+				Tokens:           m.Tokens,
+				Model:            truncateModel(m.Model),
+				CreatedAt:        m.CreatedAt,
+			}
+			if m.Parent >= 0 && m.Parent < i {
+				message.ParentID = &ids[m.Parent]
+			}
+			if err := tx.Create(message).Error; err != nil {
+				return fmt.Errorf("创建消息失败: %w", err)
+			}
+			ids[i] = message.ID
+		}
+		return nil
+	})
+	if err != nil {
+		item.Status = HistoryImportFailed
+		item.Error = err.Error()
+		return item
+	}
+
+	item.Status = HistoryImportImported
+	item.ConversationID = &conversation.ID
+	return item
+}
+
+// parseHistory 识别来源并解析为统一结构，返回实际识别出的来源
+func parseHistory(data []byte, source string, maxUnzipped int64) (string, []*historyConversation, error) {
+	if source == "" {
+		source = HistorySourceAuto
+	}
+
+	if bytes.HasPrefix(data, []byte("PK\x03\x04")) {
+		return parseHistoryZip(data, source, maxUnzipped)
+	}
+
+	if source == HistorySourceAuto {
+		source = detectHistorySource(data)
+		if source == "" {
+			return "", nil, errors.New("无法识别导出文件格式")
+		}
+	}
+
+	var conversations []*historyConversation
+	var err error
+	switch source {
+	case HistorySourceChatGPT:
+		conversations, err = parseChatGPT(data)
+	case HistorySourceClaude:
+		conversations, err = parseClaude(data)
+	case HistorySourceNative:
+		conversations, err = parseNative(data)
+	default:
+		return "", nil, fmt.Errorf("不支持的导入来源: %s", source)
+	}
+	if err != nil {
+		return "", nil, err
+	}
+	return source, conversations, nil
+}
+
+// parseHistoryZip 解析 zip 压缩包
+// ChatGPT 和 Claude 的导出包中读取 conversations.json，本系统的导出包中读取每个 .json 文件
+func parseHistoryZip(data []byte, source string, maxUnzipped int64) (string, []*historyConversation, error) {
+	zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return "", nil, fmt.Errorf("读取压缩包失败: %w", err)
+	}
+
+	remaining := maxUnzipped
+	for _, f := range zr.File {
+		if path.Base(f.Name) == "conversations.json" {
+			content, err := readZipFile(f, &remaining, maxUnzipped)
+			if err != nil {
+				return "", nil, err
+			}
+			return parseHistory(content, source, remaining)
+		}
+	}
+
+	if source != HistorySourceAuto && source != HistorySourceNative {
+		return "", nil, errors.New("压缩包中没有 conversations.json")
+	}
+
+	var conversations []*historyConversation
+	for _, f := range zr.File {
+		if f.FileInfo().IsDir() || path.Ext(f.Name) != ".json" {
+			continue
+		}
+		content, err := readZipFile(f, &remaining, maxUnzipped)
+		if err != nil {
+			return "", nil, err
+		}
+		parsed, err := parseNative(content)
+		if err != nil {
+			return "", nil, fmt.Errorf("%s: %w", f.Name, err)
+		}
+		conversations = append(conversations, parsed...)
+	}
+	if len(conversations) == 0 {
+		return "", nil, errors.New("压缩包中没有可导入的会话")
+	}
+	return HistorySourceNative, conversations, nil
+}
+
+// readZipFile 读取压缩包中的文件，remaining 为剩余可解压的字节数，读取后扣减，limit 为总上限
+// 文件头中的大小可以伪造，因此除检查声明的大小外，实际读取时同样限制字节数
+func readZipFile(f *zip.File, remaining *int64, limit int64) ([]byte, error) {
+	if f.UncompressedSize64 > uint64(*remaining) {
+		return nil, fmt.Errorf("%s 解压后超过 %d MB", f.Name, limit>>20)
+	}
+
+	rc, err := f.Open()
+	if err != nil {
+		return nil, fmt.Errorf("读取 %s 失败: %w", f.Name, err)
+	}
+	defer rc.Close()
+
+	content, err := io.ReadAll(io.LimitReader(rc, *remaining+1))
+	if err != nil {
+		return nil, fmt.Errorf("读取 %s 失败: %w", f.Name, err)
+	}
+	if int64(len(content)) > *remaining {
+		return nil, fmt.Errorf("%s 解压后超过 %d MB", f.Name, limit>>20)
+	}
+	*remaining -= int64(len(content))
+	return content, nil
+}
+
+// detectHistorySource 根据特征字段识别来源，无法识别时返回空字符串
+func detectHistorySource(data []byte) string {
+	var probe struct {
+		SchemaVersion *int            `json:"schemaVersion"`
+		Mapping       json.RawMessage `json:"mapping"`
+		ChatMessages  json.RawMessage `json:"chat_messages"`
+	}
+
+	trimmed := bytes.TrimSpace(data)
+	if bytes.HasPrefix(trimmed, []byte("[")) {
+		var items []json.RawMessage
+		if err := json.Unmarshal(trimmed, &items); err != nil || len(items) == 0 {
+			return ""
+		}
+		trimmed = items[0]
+	}
+	if err := json.Unmarshal(trimmed, &probe); err != nil {
+		return ""
+	}
+
+	switch {
+	case probe.Mapping != nil:
+		return HistorySourceChatGPT
+	case probe.ChatMessages != nil:
+		return HistorySourceClaude
+	case probe.SchemaVersion != nil:
+		return HistorySourceNative
+	}
+	return ""
+}
+
+// chatGPTConversation ChatGPT 导出的会话，消息以树的形式保存在 mapping 中
+type chatGPTConversation struct {
+	ID               string                 `json:"id"`
+	ConversationID   string                 `json:"conversation_id"`
+	Title            string                 `json:"title"`
+	CreateTime       float64                `json:"create_time"`
+	UpdateTime       float64                `json:"update_time"`
+	CurrentNode      string                 `json:"current_node"`
+	DefaultModelSlug *string                `json:"default_model_slug"`
+	Mapping          map[string]chatGPTNode `json:"mapping"`
+}
+
+// chatGPTNode ChatGPT 消息树的节点
+type chatGPTNode struct {
+	ID       string          `json:"id"`
+	Message  *chatGPTMessage `json:"message"`
+	Parent   *string         `json:"parent"`
+	Children []string        `json:"children"`
+}
+
+// chatGPTMessage ChatGPT 消息
+type chatGPTMessage struct {
+	Author struct {
+		Role string `json:"role"`
+	} `json:"author"`
+	CreateTime *float64 `json:"create_time"`
+	Content    struct {
+		ContentType string            `json:"content_type"`
+		Parts       []json.RawMessage `json:"parts"`
+		Text        string            `json:"text"`
+		Thoughts    []struct {
+			Summary string `json:"summary"`
+			Content string `json:"content"`
+		} `json:"thoughts"`
+	} `json:"content"`
+	Metadata struct {
+		ModelSlug *string `json:"model_slug"`
+		Hidden    bool    `json:"is_visually_hidden_from_conversation"`
+	} `json:"metadata"`
+}
+
+// parseChatGPT 解析 ChatGPT 导出
+// 只导入当前分支（从根节点到 current_node），工具调用和隐藏的系统消息会被跳过，思考内容合并到随后的回复中
+func parseChatGPT(data []byte) ([]*historyConversation, error) {
+	var items []chatGPTConversation
+	if err := json.Unmarshal(data, &items); err != nil {
+		return nil, fmt.Errorf("解析 ChatGPT 导出失败: %w", err)
+	}
+
+	conversations := make([]*historyConversation, 0, len(items))
+	for _, item := range items {
+		h := &historyConversation{
+			ExternalID: item.ConversationID,
+			Title:      item.Title,
+			Model:      item.DefaultModelSlug,
+			CreatedAt:  unixSeconds(item.CreateTime),
+			UpdatedAt:  unixSeconds(item.UpdateTime),
+		}
+		if h.ExternalID == "" {
+			h.ExternalID = item.ID
+		}
+
+		reasoning := ""
+		for _, node := range chatGPTBranch(&item) {
+			m := node.Message
+			if m == nil || m.Metadata.Hidden {
+				continue
+			}
+			role := m.Author.Role
+			if role != "user" && role != "assistant" && role != "system" {
+				continue
+			}
+
+			if m.Content.ContentType == "thoughts" {
+				for _, thought := range m.Content.Thoughts {
+					reasoning = joinNonEmpty(reasoning, joinNonEmpty(thought.Summary, thought.Content))
+				}
+				continue
+			}
+
+			content := chatGPTText(m)
+			if content == "" {
+				continue
+			}
+
+			message := historyMessage{
+				Type:      role,
+				Content:   content,
+				Model:     m.Metadata.ModelSlug,
+				CreatedAt: h.CreatedAt,
+				Parent:    len(h.Messages) - 1,
+			}
+			if m.CreateTime != nil {
+				message.CreatedAt = unixSeconds(*m.CreateTime)
+			}
+			if role == "assistant" {
+				message.Reasoning = reasoning
+				reasoning = ""
+			}
+			h.Messages = append(h.Messages, message)
+		}
+		conversations = append(conversations, h)
+	}
+	return conversations, nil
+}
+
+// chatGPTBranch 返回从根节点到当前节点的路径，没有 current_node 时沿每层最后一个子节点向下
+func chatGPTBranch(c *chatGPTConversation) []chatGPTNode {
+	var branch []chatGPTNode
+	visited := make(map[string]bool)
+
+	if node, ok := c.Mapping[c.CurrentNode]; ok {
+		for !visited[node.ID] {
+			visited[node.ID] = true
+			branch = append(branch, node)
+			if node.Parent == nil {
+				break
+			}
+			parent, ok := c.Mapping[*node.Parent]
+			if !ok {
+				break
+			}
+			node = parent
+		}
+		for i, j := 0, len(branch)-1; i < j; i, j = i+1, j-1 {
+			branch[i], branch[j] = branch[j], branch[i]
+		}
+		return branch
+	}
+
+	// 按ID排序保证结果稳定
+	ids := make([]string, 0, len(c.Mapping))
+	for id := range c.Mapping {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	for _, id := range ids {
+		node := c.Mapping[id]
+		if node.Parent != nil {
+			continue
+		}
+		for !visited[node.ID] {
+			visited[node.ID] = true
+			branch = append(branch, node)
+			if len(node.Children) == 0 {
+				break
+			}
+			child, ok := c.Mapping[node.Children[len(node.Children)-1]]
+			if !ok {
+				break
+			}
+			node = child
+		}
+		break
+	}
+	return branch
+}
+
+// chatGPTText 提取 ChatGPT 消息的文本内容，图片等非文本部分会被忽略
+func chatGPTText(m *chatGPTMessage) string {
+	switch m.Content.ContentType {
+	case "text", "multimodal_text":
+		var parts []string
+		for _, raw := range m.Content.Parts {
+			var text string
+			if err := json.Unmarshal(raw, &text); err == nil && text != "" {
+				parts = append(parts, text)
+			}
+		}
+		return strings.Join(parts, "\n")
+	case "code":
+		return m.Content.Text
+	}
+	return ""
+}
+
+// claudeConversation Claude 导出的会话
+type claudeConversation struct {
+	UUID         string          `json:"uuid"`
+	Name         string          `json:"name"`
+	CreatedAt    time.Time       `json:"created_at"`
+	UpdatedAt    time.Time       `json:"updated_at"`
+	ChatMessages []claudeMessage `json:"chat_messages"`
+}
+
+// claudeMessage Claude 导出的消息，新版导出在 content 中区分正文和思考内容
+type claudeMessage struct {
+	Text      string    `json:"text"`
+	Sender    string    `json:"sender"` // human assistant
+	CreatedAt time.Time `json:"created_at"`
+	Content   []struct {
+		Type     string `json:"type"`
+		Text     string `json:"text"`
+		Thinking string `json:"thinking"`
+	} `json:"content"`
+}
+
+// parseClaude 解析 Claude 导出
+func parseClaude(data []byte) ([]*historyConversation, error) {
+	var items []claudeConversation
+	if err := json.Unmarshal(data, &items); err != nil {
+		return nil, fmt.Errorf("解析 Claude 导出失败: %w", err)
+	}
+
+	conversations := make([]*historyConversation, 0, len(items))
+	for _, item := range items {
+		h := &historyConversation{
+			ExternalID: item.UUID,
+			Title:      item.Name,
+			CreatedAt:  item.CreatedAt,
+			UpdatedAt:  item.UpdatedAt,
+		}
+
+		for _, m := range item.ChatMessages {
+			var messageType string
+			switch m.Sender {
+			case "human":
+				messageType = "user"
+			case "assistant":
+				messageType = "assistant"
+			default:
+				continue
+			}
+
+			content, reasoning := m.Text, ""
+			if len(m.Content) > 0 {
+				content = ""
+				for _, block := range m.Content {
+					switch block.Type {
+					case "text":
+						content = joinNonEmpty(content, block.Text)
+					case "thinking":
+						reasoning = joinNonEmpty(reasoning, block.Thinking)
+					}
+				}
+			}
+			if content == "" {
+				continue
+			}
+
+			h.Messages = append(h.Messages, historyMessage{
+				Type:      messageType,
+				Content:   content,
+				Reasoning: reasoning,
+				CreatedAt: m.CreatedAt,
+				Parent:    len(h.Messages) - 1,
+			})
+		}
+		conversations = append(conversations, h)
+	}
+	return conversations, nil
+}
+
+// parseNative 解析本系统的 JSON 导出，支持单个会话或会话数组
+func parseNative(data []byte) ([]*historyConversation, error) {
+	var items []ConversationExport
+	trimmed := bytes.TrimSpace(data)
+	if bytes.HasPrefix(trimmed, []byte("[")) {
+		if err := json.Unmarshal(trimmed, &items); err != nil {
+			return nil, fmt.Errorf("解析导出文件失败: %w", err)
+		}
+	} else {
+		var item ConversationExport
+		if err := json.Unmarshal(trimmed, &item); err != nil {
+			return nil, fmt.Errorf("解析导出文件失败: %w", err)
+		}
+		items = append(items, item)
+	}
+
+	conversations := make([]*historyConversation, 0, len(items))
+	for _, item := range items {
+		if item.SchemaVersion > ExportSchemaVersion {
+			return nil, fmt.Errorf("不支持的导出格式版本: %d", item.SchemaVersion)
+		}
+
+		h := &historyConversation{
+			ExternalID:   nativeExternalID(&item),
+			Title:        item.Name,
+			SystemPrompt: item.SystemPrompt,
+			Model:        item.Model,
+			Temperature:  item.Temperature,
+			CreatedAt:    item.CreatedAt,
+			UpdatedAt:    item.UpdatedAt,
+		}
+
+		indexes := make(map[uint]int, len(item.Messages))
+		for _, m := range item.Messages {
+			if m.Type != "user" && m.Type != "assistant" && m.Type != "system" {
+				continue
+			}
+			message := historyMessage{
+				Type:      m.Type,
+				Content:   m.Content,
+				Reasoning: m.ReasoningContent,
+				Model:     m.Model,
+				Tokens:    m.Tokens,
+				CreatedAt: m.CreatedAt,
+				Parent:    -1,
+			}
+			if m.ParentID != nil {
+				if parent, ok := indexes[*m.ParentID]; ok {
+					message.Parent = parent
+				}
+			}
+			indexes[m.ID] = len(h.Messages)
+			h.Messages = append(h.Messages, message)
+		}
+		conversations = append(conversations, h)
+	}
+	return conversations, nil
+}
+
+// nativeExternalID 本系统导出会话的外部ID，重复导入同一导出时据此跳过
+// 不同实例的会话ID可能相同，因此同时使用导出的ID和创建时间；没有ID的旧导出返回空字符串，不做去重
+func nativeExternalID(item *ConversationExport) string {
+	if item.ID == 0 {
+		return ""
+	}
+	return fmt.Sprintf("%d@%d", item.ID, item.CreatedAt.Unix())
+}
+
+// unixSeconds 将带小数的 Unix 秒转换为时间，0 表示未知
+func unixSeconds(seconds float64) time.Time {
+	if seconds <= 0 {
+		return time.Time{}
+	}
+	sec, frac := math.Modf(seconds)
+	return time.Unix(int64(sec), int64(frac*1e9))
+}
+
+// joinNonEmpty 用空行连接两段非空文本
+func joinNonEmpty(a, b string) string {
+	switch {
+	case a == "":
+		return b
+	case b == "":
+		return a
+	}
+	return a + "\n\n" + b
+}
+
+// truncateModel 截断过长的模型名称以适应字段长度
+func truncateModel(model *string) *string {
+	if model == nil {
+		return nil
+	}
+	truncated := truncateRunes(*model, 100)
+	return &truncated
+}
+
+// truncateRunes 按字符截断字符串
+func truncateRunes(s string, n int) string {
+	if r := []rune(s); len(r) > n {
+		return string(r[:n])
+	}
+	return s
+}
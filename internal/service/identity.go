@@ -0,0 +1,196 @@
+package service
+
+import (
+	"ai-chat/internal/common"
+	"ai-chat/internal/repository"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// ExternalIdentity 外部身份源认证通过后得到的用户信息
+type ExternalIdentity struct {
+	Provider      string // 身份源标识，如 OIDC issuer
+	Subject       string // 身份源内的唯一用户标识
+	Email         string
+	EmailVerified bool
+	Name          string
+	Groups        []string // 为 nil 表示身份源未下发组信息，空切片表示不属于任何组
+}
+
+// ExternalUserOptions 外部用户关联选项
+type ExternalUserOptions struct {
+	AutoProvision bool              // 本地不存在对应用户时是否自动创建
+	RoleMapping   map[string]string // 组到角色的映射，为空或身份源未下发组信息时保留原角色
+}
+
+// rolePriority 角色优先级，多个组映射到不同角色时取最高者
+var rolePriority = map[string]int{
+	common.RoleUser:    0,
+	common.RoleAuditor: 5,
+	common.RoleAdmin:   10,
+}
+
+// ParseRoleMapping 解析 group1=admin,group2=user 格式的组角色映射
+// 组名本身含逗号（如 LDAP DN）时改用分号分隔，组名与角色以最后一个等号分隔
+func ParseRoleMapping(s string) (map[string]string, error) {
+	sep := ","
+	if strings.Contains(s, ";") {
+		sep = ";"
+	}
+
+	mapping := make(map[string]string)
+	for _, pair := range strings.Split(s, sep) {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+		i := strings.LastIndex(pair, "=")
+		if i <= 0 {
+			return nil, fmt.Errorf("无效的角色映射: %s", pair)
+		}
+		group, role := strings.TrimSpace(pair[:i]), strings.TrimSpace(pair[i+1:])
+		if group == "" {
+			return nil, fmt.Errorf("无效的角色映射: %s", pair)
+		}
+		if _, valid := rolePriority[role]; !valid {
+			return nil, fmt.Errorf("未知角色: %s", role)
+		}
+		mapping[group] = role
+	}
+	return mapping, nil
+}
+
+// MapGroupsToRole 根据映射计算用户角色，没有匹配的组时为普通用户
+// 未配置映射或身份源未下发组信息（groups 为 nil）时返回 false，表示不同步角色
+func MapGroupsToRole(groups []string, mapping map[string]string) (string, bool) {
+	if len(mapping) == 0 || groups == nil {
+		return "", false
+	}
+	role, matched := common.RoleUser, false
+	for _, group := range groups {
+		if mapped, ok := mapping[group]; ok && (!matched || rolePriority[mapped] > rolePriority[role]) {
+			role, matched = mapped, true
+		}
+	}
+	return role, true
+}
+
+// ResolveExternalUser 将外部身份关联到本地用户
+// 依次按 身份标识 -> 已验证邮箱 查找，找不到时按选项自动创建
+func ResolveExternalUser(db *gorm.DB, ext *ExternalIdentity, opts ExternalUserOptions) (*repository.User, error) {
+	if ext.Subject == "" {
+		return nil, errors.New("外部身份缺少用户标识")
+	}
+
+	var user repository.User
+	err := db.Transaction(func(tx *gorm.DB) error {
+		now := time.Now()
+
+		var identity repository.UserIdentity
+		err := tx.Where("provider = ? AND subject = ?", ext.Provider, ext.Subject).First(&identity).Error
+		switch {
+		case err == nil:
+			if err := tx.First(&user, identity.UserID).Error; err != nil {
+				return fmt.Errorf("查找关联用户失败: %w", err)
+			}
+			if err := tx.Model(&identity).Updates(map[string]interface{}{
+				"email":         ext.Email,
+				"last_login_at": now,
+			}).Error; err != nil {
+				return fmt.Errorf("更新外部身份失败: %w", err)
+			}
+
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			if err := findOrProvisionUser(tx, ext, opts, &user); err != nil {
+				return err
+			}
+			identity = repository.UserIdentity{
+				UserID:      user.ID,
+				Provider:    ext.Provider,
+				Subject:     ext.Subject,
+				Email:       ext.Email,
+				LastLoginAt: &now,
+			}
+			if err := tx.Create(&identity).Error; err != nil {
+				return fmt.Errorf("保存外部身份失败: %w", err)
+			}
+
+		default:
+			return fmt.Errorf("查找外部身份失败: %w", err)
+		}
+
+		if !user.IsActive {
+			return errors.New("账户已被禁用")
+		}
+
+		// 身份源漏发组声明时不降级；下发了组但没有匹配时降为普通用户，避免移出管理员组后仍保留权限
+		if role, ok := MapGroupsToRole(ext.Groups, opts.RoleMapping); ok {
+			if role != user.Role {
+				if err := tx.Model(&user).Update("role", role).Error; err != nil {
+					return fmt.Errorf("同步用户角色失败: %w", err)
+				}
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &user, nil
+}
+
+// findOrProvisionUser 按已验证邮箱查找本地用户，找不到时按选项自动创建
+func findOrProvisionUser(tx *gorm.DB, ext *ExternalIdentity, opts ExternalUserOptions, user *repository.User) error {
+	if ext.Email != "" && ext.EmailVerified {
+		err := tx.Where("email = ?", ext.Email).First(user).Error
+		if err == nil {
+			return nil
+		}
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			return fmt.Errorf("查找用户失败: %w", err)
+		}
+	}
+
+	if !opts.AutoProvision {
+		return errors.New("该账户未在系统中开通")
+	}
+	if ext.Email == "" {
+		return errors.New("外部身份缺少邮箱，无法自动创建用户")
+	}
+
+	// 邮箱已被本地账户占用但未经身份源验证时，不能自动关联也不能重复创建
+	var count int64
+	if err := tx.Model(&repository.User{}).Where("email = ?", ext.Email).Count(&count).Error; err != nil {
+		return fmt.Errorf("查找用户失败: %w", err)
+	}
+	if count > 0 {
+		return errors.New("邮箱已被其他账户使用")
+	}
+
+	name := ext.Name
+	if name == "" {
+		name, _, _ = strings.Cut(ext.Email, "@")
+	}
+
+	// 外部用户没有本地密码，空哈希保证无法通过密码登录
+	*user = repository.User{
+		Name:     name,
+		Email:    ext.Email,
+		Password: "",
+		Salt:     GenerateSalt(),
+	}
+	if ext.EmailVerified {
+		now := time.Now()
+		user.EmailVerifiedAt = &now
+	}
+	if err := tx.Create(user).Error; err != nil {
+		return fmt.Errorf("创建用户失败: %w", err)
+	}
+	// 重新读取以获得数据库默认值（角色、启用状态）
+	return tx.First(user, user.ID).Error
+}
@@ -0,0 +1,632 @@
+package service
+
+import (
+	"ai-chat/config"
+	"ai-chat/internal/policy"
+	"ai-chat/internal/repository"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	// maxKnowledgeBasesPerTarget 每个会话或固定提示词最多关联的知识库数
+	maxKnowledgeBasesPerTarget = 10
+	// maxRetrievalTopK 每轮检索的片段数上限
+	maxRetrievalTopK = 20
+	// citationSnippetWidth 引用中保存的原文片段字符数
+	citationSnippetWidth = 200
+)
+
+// ErrKnowledgeBaseSpace 知识库与会话、提示词或文档不在同一空间
+var ErrKnowledgeBaseSpace = errors.New("知识库只能关联同一空间（个人或同一工作区）中的内容")
+
+// KnowledgeBaseService 知识库服务
+// 知识库由已解析的文档组成，关联到会话或固定提示词后，每轮对话按用户消息检索最相关的片段并附带引用来源
+type KnowledgeBaseService struct {
+	db    *gorm.DB
+	cfg   *config.Config
+	authz *Authorizer
+}
+
+// NewKnowledgeBaseService 创建知识库服务
+func NewKnowledgeBaseService(db *gorm.DB, cfg *config.Config) *KnowledgeBaseService {
+	return &KnowledgeBaseService{
+		db:    db,
+		cfg:   cfg,
+		authz: NewAuthorizer(db),
+	}
+}
+
+// CreateKnowledgeBaseRequest 创建知识库请求，知识库创建在主体当前选择的空间中
+type CreateKnowledgeBaseRequest struct {
+	Name        string `json:"name" binding:"required,min=1,max=255"`
+	Description string `json:"description"`
+	TopK        *int   `json:"topK,omitempty" binding:"omitempty,min=1,max=20"`
+}
+
+// UpdateKnowledgeBaseRequest 更新知识库请求
+type UpdateKnowledgeBaseRequest struct {
+	Name        *string `json:"name,omitempty" binding:"omitempty,min=1,max=255"`
+	Description *string `json:"description,omitempty"`
+	TopK        *int    `json:"topK,omitempty" binding:"omitempty,min=1,max=20"`
+}
+
+// KnowledgeBaseDocumentsRequest 向知识库添加文档的请求
+type KnowledgeBaseDocumentsRequest struct {
+	DocumentIDs []uint `json:"documentIds" binding:"required,min=1,max=100"`
+}
+
+// LinkKnowledgeBasesRequest 设置会话或固定提示词关联的知识库，空列表表示取消关联
+type LinkKnowledgeBasesRequest struct {
+	KnowledgeBaseIDs []uint `json:"knowledgeBaseIds" binding:"max=10"`
+}
+
+// KnowledgeBaseResponse 知识库响应
+type KnowledgeBaseResponse struct {
+	ID          uint      `json:"id"`
+	Name        string    `json:"name"`
+	Description string    `json:"description"`
+	WorkspaceID *uint     `json:"workspaceId"`
+	TopK        int       `json:"topK"`
+	Documents   int64     `json:"documentCount"`
+	CreatedAt   time.Time `json:"createdAt"`
+	UpdatedAt   time.Time `json:"updatedAt"`
+}
+
+// Citation 检索到的片段及来源，Index 对应回答中的 [编号] 标记
+type Citation struct {
+	Index      int    `json:"index"`
+	DocumentID uint   `json:"documentId"`
+	ChunkID    uint   `json:"chunkId"`
+	FileName   string `json:"fileName"`
+	Page       int    `json:"page"`   // 0 表示不分页
+	Offset     int    `json:"offset"` // 在所在页文本中的字符偏移
+	Length     int    `json:"length"`
+	Snippet    string `json:"snippet"` // 片段开头的原文，供界面预览
+}
+
+// Retrieval 一轮对话的检索结果
+type Retrieval struct {
+	Content   string // 带编号来源标记的资料，作为系统消息发送
+	Citations []Citation
+}
+
+// assistantMetadata 助手消息元数据，记录引用来源和使用的固定提示词版本
+type assistantMetadata struct {
+	Citations   []Citation        `json:"citations,omitempty"`
+	FixedPrompt *PromptVersionRef `json:"fixedPrompt,omitempty"`
+}
+
+// retrievalRow 检索查询的结果行
+type retrievalRow struct {
+	ID         uint
+	DocumentID uint
+	FileName   string
+	Page       int
+	Offset     int
+	Length     int
+	Content    string
+	Tokens     int
+	Rank       float64
+}
+
+// Create 创建知识库
+func (s *KnowledgeBaseService) Create(p policy.Principal, req *CreateKnowledgeBaseRequest) (*KnowledgeBaseResponse, error) {
+	if err := s.authz.WorkspaceContent(p, policy.ActionWrite); err != nil {
+		return nil, err
+	}
+
+	knowledgeBase := &repository.KnowledgeBase{
+		Name:        req.Name,
+		Description: req.Description,
+		UserID:      p.UserID,
+		WorkspaceID: p.WorkspaceID,
+		TopK:        5,
+	}
+	if req.TopK != nil {
+		knowledgeBase.TopK = *req.TopK
+	}
+
+	if err := s.db.Create(knowledgeBase).Error; err != nil {
+		return nil, fmt.Errorf("创建知识库失败: %w", err)
+	}
+	return toKnowledgeBaseResponse(knowledgeBase, 0), nil
+}
+
+// FindAll 获取当前空间的知识库，q 按名称过滤
+func (s *KnowledgeBaseService) FindAll(p policy.Principal, page, pageSize int, q string) ([]*KnowledgeBaseResponse, int64, error) {
+	if err := s.authz.WorkspaceContent(p, policy.ActionRead); err != nil {
+		return nil, 0, err
+	}
+
+	query := s.db.Model(&repository.KnowledgeBase{}).Scopes(s.authz.KnowledgeBaseScope(p))
+	if q != "" {
+		query = query.Where("name ILIKE ?", "%"+q+"%")
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, fmt.Errorf("查询知识库总数失败: %w", err)
+	}
+
+	var knowledgeBases []*repository.KnowledgeBase
+	if err := query.Offset((page - 1) * pageSize).Limit(pageSize).Order("created_at DESC").Find(&knowledgeBases).Error; err != nil {
+		return nil, 0, fmt.Errorf("查询知识库列表失败: %w", err)
+	}
+
+	items, err := s.toResponses(knowledgeBases)
+	if err != nil {
+		return nil, 0, err
+	}
+	return items, total, nil
+}
+
+// FindByID 根据ID获取知识库
+func (s *KnowledgeBaseService) FindByID(p policy.Principal, id uint) (*KnowledgeBaseResponse, error) {
+	knowledgeBase, err := s.authz.KnowledgeBase(p, policy.ActionRead, id)
+	if err != nil {
+		return nil, err
+	}
+
+	items, err := s.toResponses([]*repository.KnowledgeBase{knowledgeBase})
+	if err != nil {
+		return nil, err
+	}
+	return items[0], nil
+}
+
+// Update 更新知识库
+func (s *KnowledgeBaseService) Update(p policy.Principal, id uint, req *UpdateKnowledgeBaseRequest) (*KnowledgeBaseResponse, error) {
+	knowledgeBase, err := s.authz.KnowledgeBase(p, policy.ActionWrite, id)
+	if err != nil {
+		return nil, err
+	}
+
+	updates := make(map[string]interface{})
+	if req.Name != nil {
+		updates["name"] = *req.Name
+	}
+	if req.Description != nil {
+		updates["description"] = *req.Description
+	}
+	if req.TopK != nil {
+		updates["top_k"] = *req.TopK
+	}
+	if len(updates) > 0 {
+		if err := s.db.Model(knowledgeBase).Updates(updates).Error; err != nil {
+			return nil, fmt.Errorf("更新知识库失败: %w", err)
+		}
+	}
+
+	return s.FindByID(p, id)
+}
+
+// Delete 删除知识库，同时取消与文档、会话、固定提示词和助手的关联（文档本身保留）
+func (s *KnowledgeBaseService) Delete(p policy.Principal, id uint) error {
+	if _, err := s.authz.KnowledgeBase(p, policy.ActionDelete, id); err != nil {
+		return err
+	}
+
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		links := []interface{}{
+			&repository.KnowledgeBaseDocument{},
+			&repository.ConversationKnowledgeBase{},
+			&repository.FixedPromptKnowledgeBase{},
+			&repository.AssistantKnowledgeBase{},
+		}
+		for _, link := range links {
+			if err := tx.Where("knowledge_base_id = ?", id).Delete(link).Error; err != nil {
+				return err
+			}
+		}
+		return tx.Delete(&repository.KnowledgeBase{}, id).Error
+	})
+	if err != nil {
+		return fmt.Errorf("删除知识库失败: %w", err)
+	}
+	return nil
+}
+
+// Documents 获取知识库中的文档
+func (s *KnowledgeBaseService) Documents(p policy.Principal, id uint) ([]*DocumentResponse, error) {
+	if _, err := s.authz.KnowledgeBase(p, policy.ActionRead, id); err != nil {
+		return nil, err
+	}
+
+	var documents []*repository.Document
+	err := s.db.Joins("JOIN knowledge_base_documents ON knowledge_base_documents.document_id = documents.id").
+		Where("knowledge_base_documents.knowledge_base_id = ?", id).
+		Order("knowledge_base_documents.created_at ASC").
+		Find(&documents).Error
+	if err != nil {
+		return nil, fmt.Errorf("查询知识库文档失败: %w", err)
+	}
+
+	items := make([]*DocumentResponse, len(documents))
+	for i, document := range documents {
+		items[i] = toDocumentResponse(document)
+	}
+	return items, nil
+}
+
+// AddDocuments 向知识库添加文档，文档须与知识库在同一空间，已添加的文档会被跳过
+func (s *KnowledgeBaseService) AddDocuments(p policy.Principal, id uint, documentIDs []uint) ([]*DocumentResponse, error) {
+	knowledgeBase, err := s.authz.KnowledgeBase(p, policy.ActionWrite, id)
+	if err != nil {
+		return nil, err
+	}
+
+	documentIDs = uniqueIDs(documentIDs)
+	links := make([]*repository.KnowledgeBaseDocument, 0, len(documentIDs))
+	for _, documentID := range documentIDs {
+		document, err := s.authz.Document(p, policy.ActionRead, documentID)
+		if err != nil {
+			return nil, fmt.Errorf("文档 %d: %w", documentID, err)
+		}
+		if !sameSpace(knowledgeBase, document.UserID, document.WorkspaceID) {
+			return nil, ErrKnowledgeBaseSpace
+		}
+		links = append(links, &repository.KnowledgeBaseDocument{KnowledgeBaseID: id, DocumentID: documentID})
+	}
+
+	if err := s.db.Clauses(clause.OnConflict{DoNothing: true}).Create(&links).Error; err != nil {
+		return nil, fmt.Errorf("添加知识库文档失败: %w", err)
+	}
+	return s.Documents(p, id)
+}
+
+// RemoveDocument 从知识库中移除文档，文档本身保留
+func (s *KnowledgeBaseService) RemoveDocument(p policy.Principal, id, documentID uint) error {
+	if _, err := s.authz.KnowledgeBase(p, policy.ActionWrite, id); err != nil {
+		return err
+	}
+
+	if err := s.db.Where("knowledge_base_id = ? AND document_id = ?", id, documentID).
+		Delete(&repository.KnowledgeBaseDocument{}).Error; err != nil {
+		return fmt.Errorf("移除知识库文档失败: %w", err)
+	}
+	return nil
+}
+
+// ConversationKnowledgeBases 获取会话关联的知识库
+func (s *KnowledgeBaseService) ConversationKnowledgeBases(p policy.Principal, conversationID uint) ([]*KnowledgeBaseResponse, error) {
+	if _, err := s.authz.Conversation(p, policy.ActionRead, conversationID); err != nil {
+		return nil, err
+	}
+	return s.linked(&repository.ConversationKnowledgeBase{}, "conversation_id = ?", conversationID)
+}
+
+// SetConversationKnowledgeBases 设置会话关联的知识库，需要会话写入权限和知识库读取权限
+func (s *KnowledgeBaseService) SetConversationKnowledgeBases(p policy.Principal, conversationID uint, ids []uint) ([]*KnowledgeBaseResponse, error) {
+	conversation, err := s.authz.Conversation(p, policy.ActionWrite, conversationID)
+	if err != nil {
+		return nil, err
+	}
+	ids = uniqueIDs(ids)
+	if err := s.checkLinks(p, ids, conversation.UserID, conversation.WorkspaceID); err != nil {
+		return nil, err
+	}
+
+	links := make([]*repository.ConversationKnowledgeBase, len(ids))
+	for i, id := range ids {
+		links[i] = &repository.ConversationKnowledgeBase{ConversationID: conversationID, KnowledgeBaseID: id}
+	}
+	if err := s.replaceLinks(&repository.ConversationKnowledgeBase{}, "conversation_id = ?", conversationID, links, len(links)); err != nil {
+		return nil, err
+	}
+	return s.linked(&repository.ConversationKnowledgeBase{}, "conversation_id = ?", conversationID)
+}
+
+// FixedPromptKnowledgeBases 获取固定提示词关联的知识库
+func (s *KnowledgeBaseService) FixedPromptKnowledgeBases(p policy.Principal, fixedPromptID uint) ([]*KnowledgeBaseResponse, error) {
+	if _, err := s.authz.FixedPrompt(p, policy.ActionRead, fixedPromptID); err != nil {
+		return nil, err
+	}
+	return s.linked(&repository.FixedPromptKnowledgeBase{}, "fixed_prompt_id = ?", fixedPromptID)
+}
+
+// SetFixedPromptKnowledgeBases 设置固定提示词关联的知识库，需要提示词写入权限和知识库读取权限
+func (s *KnowledgeBaseService) SetFixedPromptKnowledgeBases(p policy.Principal, fixedPromptID uint, ids []uint) ([]*KnowledgeBaseResponse, error) {
+	fixedPrompt, err := s.authz.FixedPrompt(p, policy.ActionWrite, fixedPromptID)
+	if err != nil {
+		return nil, err
+	}
+	ids = uniqueIDs(ids)
+	if err := s.checkLinks(p, ids, fixedPrompt.UserID, fixedPrompt.WorkspaceID); err != nil {
+		return nil, err
+	}
+
+	links := make([]*repository.FixedPromptKnowledgeBase, len(ids))
+	for i, id := range ids {
+		links[i] = &repository.FixedPromptKnowledgeBase{FixedPromptID: fixedPromptID, KnowledgeBaseID: id}
+	}
+	if err := s.replaceLinks(&repository.FixedPromptKnowledgeBase{}, "fixed_prompt_id = ?", fixedPromptID, links, len(links)); err != nil {
+		return nil, err
+	}
+	return s.linked(&repository.FixedPromptKnowledgeBase{}, "fixed_prompt_id = ?", fixedPromptID)
+}
+
+// AssistantKnowledgeBases 获取助手关联的知识库
+func (s *KnowledgeBaseService) AssistantKnowledgeBases(p policy.Principal, assistantID uint) ([]*KnowledgeBaseResponse, error) {
+	if _, err := s.authz.Assistant(p, policy.ActionRead, assistantID); err != nil {
+		return nil, err
+	}
+	return s.linked(&repository.AssistantKnowledgeBase{}, "assistant_id = ?", assistantID)
+}
+
+// SetAssistantKnowledgeBases 设置助手关联的知识库，需要助手写入权限和知识库读取权限
+func (s *KnowledgeBaseService) SetAssistantKnowledgeBases(p policy.Principal, assistantID uint, ids []uint) ([]*KnowledgeBaseResponse, error) {
+	assistant, err := s.authz.Assistant(p, policy.ActionWrite, assistantID)
+	if err != nil {
+		return nil, err
+	}
+	ids = uniqueIDs(ids)
+	if err := s.checkLinks(p, ids, assistant.UserID, assistant.WorkspaceID); err != nil {
+		return nil, err
+	}
+
+	links := make([]*repository.AssistantKnowledgeBase, len(ids))
+	for i, id := range ids {
+		links[i] = &repository.AssistantKnowledgeBase{AssistantID: assistantID, KnowledgeBaseID: id}
+	}
+	if err := s.replaceLinks(&repository.AssistantKnowledgeBase{}, "assistant_id = ?", assistantID, links, len(links)); err != nil {
+		return nil, err
+	}
+	return s.linked(&repository.AssistantKnowledgeBase{}, "assistant_id = ?", assistantID)
+}
+
+// checkLinks 校验知识库可读且与关联目标在同一空间
+// 工作区会话只能关联该工作区的知识库，避免其他成员通过检索读到无权访问的个人文档
+func (s *KnowledgeBaseService) checkLinks(p policy.Principal, ids []uint, ownerID uint, workspaceID *uint) error {
+	if len(ids) > maxKnowledgeBasesPerTarget {
+		return fmt.Errorf("最多关联 %d 个知识库", maxKnowledgeBasesPerTarget)
+	}
+	for _, id := range ids {
+		knowledgeBase, err := s.authz.KnowledgeBase(p, policy.ActionRead, id)
+		if err != nil {
+			return fmt.Errorf("知识库 %d: %w", id, err)
+		}
+		if !sameSpace(knowledgeBase, ownerID, workspaceID) {
+			return ErrKnowledgeBaseSpace
+		}
+	}
+	return nil
+}
+
+// replaceLinks 替换关联记录，count 为新关联的数量
+func (s *KnowledgeBaseService) replaceLinks(model interface{}, query string, targetID uint, links interface{}, count int) error {
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where(query, targetID).Delete(model).Error; err != nil {
+			return err
+		}
+		if count == 0 {
+			return nil
+		}
+		return tx.Create(links).Error
+	})
+	if err != nil {
+		return fmt.Errorf("关联知识库失败: %w", err)
+	}
+	return nil
+}
+
+// linked 查询关联的知识库
+func (s *KnowledgeBaseService) linked(model interface{}, query string, targetID uint) ([]*KnowledgeBaseResponse, error) {
+	var ids []uint
+	if err := s.db.Model(model).Where(query, targetID).Pluck("knowledge_base_id", &ids).Error; err != nil {
+		return nil, fmt.Errorf("查询关联知识库失败: %w", err)
+	}
+	if len(ids) == 0 {
+		return []*KnowledgeBaseResponse{}, nil
+	}
+
+	var knowledgeBases []*repository.KnowledgeBase
+	if err := s.db.Where("id IN ?", ids).Order("id ASC").Find(&knowledgeBases).Error; err != nil {
+		return nil, fmt.Errorf("查询关联知识库失败: %w", err)
+	}
+	return s.toResponses(knowledgeBases)
+}
+
+// Preview 按问题检索知识库，用于调试检索效果，不经过对话
+func (s *KnowledgeBaseService) Preview(p policy.Principal, id uint, question string) ([]Citation, error) {
+	knowledgeBase, err := s.authz.KnowledgeBase(p, policy.ActionRead, id)
+	if err != nil {
+		return nil, err
+	}
+
+	retrieval, err := s.retrieve([]uint{id}, knowledgeBase.TopK, question)
+	if err != nil || retrieval == nil {
+		return []Citation{}, err
+	}
+	return retrieval.Citations, nil
+}
+
+// Retrieve 按用户消息检索会话、会话所选助手和固定提示词关联的知识库，没有关联知识库或没有命中时返回 nil
+// 调用方需已校验会话读取权限；关联时已校验知识库与会话、助手在同一空间
+func (s *KnowledgeBaseService) Retrieve(conversationID uint, fixedPromptID *uint, question string) (*Retrieval, error) {
+	var ids []uint
+	if err := s.db.Model(&repository.ConversationKnowledgeBase{}).
+		Where("conversation_id = ?", conversationID).
+		Pluck("knowledge_base_id", &ids).Error; err != nil {
+		return nil, fmt.Errorf("查询会话知识库失败: %w", err)
+	}
+	var assistantIDs []uint
+	if err := s.db.Model(&repository.AssistantKnowledgeBase{}).
+		Where("assistant_id IN (?)", s.db.Model(&repository.Conversation{}).Select("assistant_id").Where("id = ?", conversationID)).
+		Pluck("knowledge_base_id", &assistantIDs).Error; err != nil {
+		return nil, fmt.Errorf("查询助手知识库失败: %w", err)
+	}
+	ids = append(ids, assistantIDs...)
+	if fixedPromptID != nil {
+		var promptIDs []uint
+		if err := s.db.Model(&repository.FixedPromptKnowledgeBase{}).
+			Where("fixed_prompt_id = ?", *fixedPromptID).
+			Pluck("knowledge_base_id", &promptIDs).Error; err != nil {
+			return nil, fmt.Errorf("查询提示词知识库失败: %w", err)
+		}
+		ids = append(ids, promptIDs...)
+	}
+	if len(ids) == 0 {
+		return nil, nil
+	}
+
+	// 已删除的知识库不参与检索，多个知识库取最大的 TopK
+	var knowledgeBases []*repository.KnowledgeBase
+	if err := s.db.Where("id IN ?", ids).Find(&knowledgeBases).Error; err != nil {
+		return nil, fmt.Errorf("查询知识库失败: %w", err)
+	}
+	if len(knowledgeBases) == 0 {
+		return nil, nil
+	}
+	topK := 0
+	ids = ids[:0]
+	for _, knowledgeBase := range knowledgeBases {
+		ids = append(ids, knowledgeBase.ID)
+		topK = max(topK, knowledgeBase.TopK)
+	}
+
+	return s.retrieve(ids, topK, question)
+}
+
+// retrieve 检索知识库中与问题最相关的 topK 个片段，总量不超过文档上下文的 token 预算
+func (s *KnowledgeBaseService) retrieve(ids []uint, topK int, question string) (*Retrieval, error) {
+	tsquery, args := relevanceTSQuery(question)
+	if tsquery == "" {
+		return nil, nil
+	}
+	topK = min(max(topK, 1), maxRetrievalTopK)
+
+	var rows []*retrievalRow
+	err := s.db.Model(&repository.DocumentChunk{}).
+		Joins("JOIN documents ON documents.id = document_chunks.document_id").
+		Select("document_chunks.id, document_chunks.document_id, documents.file_name, document_chunks.page, "+
+			"document_chunks.offset, document_chunks.length, document_chunks.content, document_chunks.tokens, "+
+			"ts_rank_cd(document_chunks.search_vector, ("+tsquery+")) AS rank", args...).
+		Where("document_chunks.document_id IN (?)",
+			s.db.Model(&repository.KnowledgeBaseDocument{}).Select("document_id").Where("knowledge_base_id IN ?", ids)).
+		Where("document_chunks.search_vector @@ ("+tsquery+")", args...).
+		Order("rank DESC, document_chunks.id ASC").
+		Limit(topK).
+		Scan(&rows).Error
+	if err != nil {
+		return nil, fmt.Errorf("检索知识库失败: %w", err)
+	}
+	if len(rows) == 0 {
+		return nil, nil
+	}
+
+	var b strings.Builder
+	b.WriteString("以下是从知识库中检索到的资料，每段以 [编号] 开头。回答时请依据这些资料，并在引用处用对应的 [编号] 标注来源；资料与问题无关时请忽略，不要编造来源。")
+
+	retrieval := &Retrieval{}
+	used := 0
+	for _, row := range rows {
+		if used+row.Tokens > s.cfg.DocumentContextTokens && len(retrieval.Citations) > 0 {
+			break
+		}
+		used += row.Tokens
+
+		citation := Citation{
+			Index:      len(retrieval.Citations) + 1,
+			DocumentID: row.DocumentID,
+			ChunkID:    row.ID,
+			FileName:   row.FileName,
+			Page:       row.Page,
+			Offset:     row.Offset,
+			Length:     row.Length,
+			Snippet:    truncateRunes(row.Content, citationSnippetWidth),
+		}
+		retrieval.Citations = append(retrieval.Citations, citation)
+		fmt.Fprintf(&b, "\n\n[%d] %s\n%s", citation.Index, documentSource(row.FileName, row.Page), row.Content)
+	}
+	retrieval.Content = b.String()
+	return retrieval, nil
+}
+
+// AssistantMetadata 生成记录引用来源和固定提示词版本的助手消息元数据，都没有时返回 nil
+func AssistantMetadata(citations []Citation, fixedPrompt *PromptVersionRef) *string {
+	if len(citations) == 0 && fixedPrompt == nil {
+		return nil
+	}
+	data, _ := json.Marshal(assistantMetadata{Citations: citations, FixedPrompt: fixedPrompt})
+	metadata := string(data)
+	return &metadata
+}
+
+// toResponses 转换为响应结构并统计文档数
+func (s *KnowledgeBaseService) toResponses(knowledgeBases []*repository.KnowledgeBase) ([]*KnowledgeBaseResponse, error) {
+	counts := make(map[uint]int64, len(knowledgeBases))
+	if len(knowledgeBases) > 0 {
+		ids := make([]uint, len(knowledgeBases))
+		for i, knowledgeBase := range knowledgeBases {
+			ids[i] = knowledgeBase.ID
+		}
+
+		var rows []struct {
+			KnowledgeBaseID uint
+			Count           int64
+		}
+		if err := s.db.Model(&repository.KnowledgeBaseDocument{}).
+			Select("knowledge_base_id, COUNT(*) AS count").
+			Where("knowledge_base_id IN ?", ids).
+			Group("knowledge_base_id").
+			Scan(&rows).Error; err != nil {
+			return nil, fmt.Errorf("统计知识库文档失败: %w", err)
+		}
+		for _, row := range rows {
+			counts[row.KnowledgeBaseID] = row.Count
+		}
+	}
+
+	items := make([]*KnowledgeBaseResponse, len(knowledgeBases))
+	for i, knowledgeBase := range knowledgeBases {
+		items[i] = toKnowledgeBaseResponse(knowledgeBase, counts[knowledgeBase.ID])
+	}
+	return items, nil
+}
+
+// uniqueIDs 去掉重复的ID，保持原有顺序
+func uniqueIDs(ids []uint) []uint {
+	seen := make(map[uint]bool, len(ids))
+	unique := make([]uint, 0, len(ids))
+	for _, id := range ids {
+		if !seen[id] {
+			seen[id] = true
+			unique = append(unique, id)
+		}
+	}
+	return unique
+}
+
+// sameSpace 知识库与内容是否在同一空间
+func sameSpace(knowledgeBase *repository.KnowledgeBase, ownerID uint, workspaceID *uint) bool {
+	return spaceMatches(knowledgeBase.UserID, knowledgeBase.WorkspaceID, ownerID, workspaceID)
+}
+
+// spaceMatches 两项内容是否在同一空间：同一工作区，或同一用户的个人空间
+func spaceMatches(userID uint, workspaceID *uint, otherUserID uint, otherWorkspaceID *uint) bool {
+	if workspaceID == nil || otherWorkspaceID == nil {
+		return workspaceID == nil && otherWorkspaceID == nil && userID == otherUserID
+	}
+	return *workspaceID == *otherWorkspaceID
+}
+
+// toKnowledgeBaseResponse 转换为知识库响应
+func toKnowledgeBaseResponse(knowledgeBase *repository.KnowledgeBase, documents int64) *KnowledgeBaseResponse {
+	return &KnowledgeBaseResponse{
+		ID:          knowledgeBase.ID,
+		Name:        knowledgeBase.Name,
+		Description: knowledgeBase.Description,
+		WorkspaceID: knowledgeBase.WorkspaceID,
+		TopK:        knowledgeBase.TopK,
+		Documents:   documents,
+		CreatedAt:   knowledgeBase.CreatedAt,
+		UpdatedAt:   knowledgeBase.UpdatedAt,
+	}
+}
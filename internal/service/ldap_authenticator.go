@@ -0,0 +1,201 @@
+package service
+
+import (
+	"ai-chat/config"
+	"ai-chat/internal/repository"
+	"crypto/tls"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/go-ldap/ldap/v3"
+	"gorm.io/gorm"
+)
+
+// LDAPAuthenticator LDAP / Active Directory 认证
+// 先用服务账号按过滤条件查找用户，再以用户 DN 和密码绑定校验
+type LDAPAuthenticator struct {
+	db          *gorm.DB
+	cfg         *config.Config
+	roleMapping map[string]string
+	tlsConfig   *tls.Config
+}
+
+// NewLDAPAuthenticator 创建 LDAP 认证器
+func NewLDAPAuthenticator(db *gorm.DB, cfg *config.Config) (*LDAPAuthenticator, error) {
+	if cfg.LDAPURL == "" || cfg.LDAPBaseDN == "" {
+		return nil, errors.New("启用 LDAP 认证时必须配置 LDAP_URL 和 LDAP_BASE_DN")
+	}
+	if !strings.Contains(cfg.LDAPUserFilter, "{username}") {
+		return nil, errors.New("LDAP_USER_FILTER 必须包含 {username} 占位符")
+	}
+
+	u, err := url.Parse(cfg.LDAPURL)
+	if err != nil {
+		return nil, fmt.Errorf("无效的 LDAP_URL: %w", err)
+	}
+
+	mapping, err := ParseRoleMapping(cfg.LDAPRoleMapping)
+	if err != nil {
+		return nil, fmt.Errorf("解析 LDAP_ROLE_MAPPING 失败: %w", err)
+	}
+
+	return &LDAPAuthenticator{
+		db:          db,
+		cfg:         cfg,
+		roleMapping: mapping,
+		tlsConfig: &tls.Config{
+			ServerName:         u.Hostname(),
+			InsecureSkipVerify: cfg.LDAPInsecureSkipVerify,
+		},
+	}, nil
+}
+
+// Name 认证器名称
+func (a *LDAPAuthenticator) Name() string {
+	return "ldap"
+}
+
+// Authenticate 使用 LDAP 绑定认证，首次成功时自动创建本地用户
+func (a *LDAPAuthenticator) Authenticate(identifier, password string) (*repository.User, error) {
+	dn, ext, err := a.lookup(identifier, password)
+	if err != nil {
+		return nil, err
+	}
+
+	// 早期版本以 DN 作为身份标识，登录时迁移为不随改名变化的唯一标识
+	if err := a.db.Model(&repository.UserIdentity{}).
+		Where("provider = ? AND subject = ?", ext.Provider, dn).
+		Update("subject", ext.Subject).Error; err != nil {
+		return nil, fmt.Errorf("迁移 LDAP 身份标识失败: %w", err)
+	}
+
+	return ResolveExternalUser(a.db, ext, ExternalUserOptions{
+		AutoProvision: a.cfg.LDAPAutoProvision,
+		RoleMapping:   a.roleMapping,
+	})
+}
+
+// lookup 查找用户并以其 DN 和密码绑定校验，返回 DN 和目录中的用户信息
+func (a *LDAPAuthenticator) lookup(identifier, password string) (string, *ExternalIdentity, error) {
+	// 空密码在很多目录服务上会被当作匿名绑定而“成功”，必须提前拒绝
+	if password == "" {
+		return "", nil, ErrInvalidCredentials
+	}
+
+	conn, err := a.dial()
+	if err != nil {
+		return "", nil, err
+	}
+	defer conn.Close()
+
+	if a.cfg.LDAPBindDN != "" {
+		if err := conn.Bind(a.cfg.LDAPBindDN, a.cfg.LDAPBindPassword); err != nil {
+			return "", nil, fmt.Errorf("LDAP 服务账号绑定失败: %w", err)
+		}
+	}
+
+	entry, err := a.findUser(conn, identifier)
+	if err != nil {
+		return "", nil, err
+	}
+
+	if err := conn.Bind(entry.DN, password); err != nil {
+		if ldap.IsErrorWithCode(err, ldap.LDAPResultInvalidCredentials) {
+			return "", nil, ErrInvalidCredentials
+		}
+		return "", nil, fmt.Errorf("LDAP 用户绑定失败: %w", err)
+	}
+
+	subject := a.entryID(entry)
+	if subject == "" {
+		return "", nil, fmt.Errorf("LDAP 用户缺少 %s 属性: %s", a.cfg.LDAPIDAttribute, entry.DN)
+	}
+
+	return entry.DN, &ExternalIdentity{
+		Provider: "ldap",
+		Subject:  subject,
+		Email:    entry.GetAttributeValue(a.cfg.LDAPEmailAttribute),
+		// 目录中的邮箱可能由用户自行修改，只有运维确认后才用于关联已有账户
+		EmailVerified: a.cfg.LDAPTrustEmail,
+		Name:          entry.GetAttributeValue(a.cfg.LDAPNameAttribute),
+		Groups:        groupNames(entry.GetAttributeValues(a.cfg.LDAPGroupAttribute)),
+	}, nil
+}
+
+// entryID 读取用户的唯一标识，objectGUID 等二进制属性转换为十六进制
+func (a *LDAPAuthenticator) entryID(entry *ldap.Entry) string {
+	raw := entry.GetRawAttributeValue(a.cfg.LDAPIDAttribute)
+	if strings.EqualFold(a.cfg.LDAPIDAttribute, "objectGUID") {
+		return hex.EncodeToString(raw)
+	}
+	return string(raw)
+}
+
+// dial 连接 LDAP 服务器，按配置启用 StartTLS
+func (a *LDAPAuthenticator) dial() (*ldap.Conn, error) {
+	conn, err := ldap.DialURL(a.cfg.LDAPURL, ldap.DialWithTLSConfig(a.tlsConfig))
+	if err != nil {
+		return nil, fmt.Errorf("连接 LDAP 服务器失败: %w", err)
+	}
+	conn.SetTimeout(10 * time.Second)
+
+	if a.cfg.LDAPStartTLS {
+		if err := conn.StartTLS(a.tlsConfig); err != nil {
+			conn.Close()
+			return nil, fmt.Errorf("LDAP StartTLS 失败: %w", err)
+		}
+	}
+	return conn, nil
+}
+
+// findUser 按过滤条件查找唯一用户
+func (a *LDAPAuthenticator) findUser(conn *ldap.Conn, identifier string) (*ldap.Entry, error) {
+	filter := strings.ReplaceAll(a.cfg.LDAPUserFilter, "{username}", ldap.EscapeFilter(identifier))
+
+	req := ldap.NewSearchRequest(
+		a.cfg.LDAPBaseDN,
+		ldap.ScopeWholeSubtree,
+		ldap.NeverDerefAliases,
+		2,  // 只需判断是否唯一
+		10, // 秒
+		false,
+		filter,
+		[]string{"dn", a.cfg.LDAPIDAttribute, a.cfg.LDAPEmailAttribute, a.cfg.LDAPNameAttribute, a.cfg.LDAPGroupAttribute},
+		nil,
+	)
+
+	result, err := conn.Search(req)
+	if err != nil && !ldap.IsErrorWithCode(err, ldap.LDAPResultSizeLimitExceeded) {
+		return nil, fmt.Errorf("LDAP 查找用户失败: %w", err)
+	}
+	if result == nil || len(result.Entries) == 0 {
+		return nil, ErrUnknownUser
+	}
+	if len(result.Entries) > 1 {
+		return nil, fmt.Errorf("LDAP 中匹配到多个用户: %s", identifier)
+	}
+	return result.Entries[0], nil
+}
+
+// groupNames 将组 DN 展开为 完整DN 和 CN 两种形式，便于角色映射按任一形式配置
+func groupNames(values []string) []string {
+	// 目录中没有组属性即不属于任何组，返回空切片而不是 nil，使角色映射降级
+	names := []string{}
+	for _, value := range values {
+		names = append(names, value)
+		dn, err := ldap.ParseDN(value)
+		if err != nil || len(dn.RDNs) == 0 {
+			continue
+		}
+		for _, attr := range dn.RDNs[0].Attributes {
+			if strings.EqualFold(attr.Type, "cn") {
+				names = append(names, attr.Value)
+			}
+		}
+	}
+	return names
+}
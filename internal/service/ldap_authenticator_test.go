@@ -0,0 +1,296 @@
+package service
+
+import (
+	"ai-chat/config"
+	"errors"
+	"net"
+	"slices"
+	"strings"
+	"testing"
+
+	ber "github.com/go-asn1-ber/asn1-ber"
+	"github.com/go-ldap/ldap/v3"
+)
+
+// LDAP 协议操作标签
+const (
+	ldapBindRequest    = 0
+	ldapBindResponse   = 1
+	ldapSearchRequest  = 3
+	ldapSearchEntry    = 4
+	ldapSearchDone     = 5
+	ldapResultSuccess  = 0
+	ldapResultBadCreds = 49
+)
+
+// testDirectoryEntry 测试目录中的用户
+type testDirectoryEntry struct {
+	dn         string
+	password   string
+	attributes map[string][]string
+}
+
+// testDirectory 只支持简单绑定和搜索的 LDAP 服务器，按 uid 或 mail 的等值条件匹配用户
+type testDirectory struct {
+	listener net.Listener
+	bindDN   string
+	bindPass string
+	entries  []testDirectoryEntry
+}
+
+// startTestDirectory 在本地随机端口启动测试目录
+func startTestDirectory(t *testing.T, entries ...testDirectoryEntry) *testDirectory {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	d := &testDirectory{
+		listener: listener,
+		bindDN:   "cn=svc,dc=example,dc=com",
+		bindPass: "svc-secret",
+		entries:  entries,
+	}
+	t.Cleanup(func() { listener.Close() })
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go d.serve(conn)
+		}
+	}()
+	return d
+}
+
+// url 测试目录的连接地址
+func (d *testDirectory) url() string {
+	return "ldap://" + d.listener.Addr().String()
+}
+
+// serve 处理一个连接上的请求，连接关闭或收到未知请求时结束
+func (d *testDirectory) serve(conn net.Conn) {
+	defer conn.Close()
+	for {
+		packet, err := ber.ReadPacket(conn)
+		if err != nil || len(packet.Children) < 2 {
+			return
+		}
+		id := packet.Children[0].Value.(int64)
+		op := packet.Children[1]
+
+		switch op.Tag {
+		case ldapBindRequest:
+			dn := op.Children[1].Value.(string)
+			password := op.Children[2].Data.String()
+			code := ldapResultBadCreds
+			if d.checkPassword(dn, password) {
+				code = ldapResultSuccess
+			}
+			conn.Write(ldapResult(id, ldapBindResponse, code).Bytes())
+
+		case ldapSearchRequest:
+			filter, err := ldap.DecompileFilter(op.Children[6])
+			if err != nil {
+				return
+			}
+			var attributes []string
+			for _, attr := range op.Children[7].Children {
+				attributes = append(attributes, strings.ToLower(attr.Value.(string)))
+			}
+			for _, entry := range d.entries {
+				if entry.matches(filter) {
+					conn.Write(entry.packet(id, attributes).Bytes())
+				}
+			}
+			conn.Write(ldapResult(id, ldapSearchDone, ldapResultSuccess).Bytes())
+
+		default:
+			return
+		}
+	}
+}
+
+// checkPassword 校验服务账号或用户的密码，空密码视为匿名绑定
+func (d *testDirectory) checkPassword(dn, password string) bool {
+	if dn == "" && password == "" {
+		return true
+	}
+	if dn == d.bindDN {
+		return password == d.bindPass
+	}
+	for _, entry := range d.entries {
+		if entry.dn == dn {
+			return password == entry.password
+		}
+	}
+	return false
+}
+
+// matches 过滤条件中包含用户 uid 或 mail 的等值或存在性匹配
+func (e testDirectoryEntry) matches(filter string) bool {
+	for _, attr := range []string{"uid", "mail"} {
+		if strings.Contains(filter, "("+attr+"=*)") {
+			return true
+		}
+		for _, value := range e.attributes[attr] {
+			if strings.Contains(filter, "("+attr+"="+value+")") {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// packet 编码搜索结果条目，只返回请求的属性（与 entryUUID 等操作属性的行为一致）
+func (e testDirectoryEntry) packet(id int64, requested []string) *ber.Packet {
+	entry := ber.Encode(ber.ClassApplication, ber.TypeConstructed, ldapSearchEntry, nil, "SearchResultEntry")
+	entry.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, e.dn, "objectName"))
+	attributes := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "attributes")
+	for name, values := range e.attributes {
+		if !slices.Contains(requested, strings.ToLower(name)) {
+			continue
+		}
+		attr := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "attribute")
+		attr.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, name, "type"))
+		set := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSet, nil, "vals")
+		for _, value := range values {
+			set.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, value, "value"))
+		}
+		attr.AppendChild(set)
+		attributes.AppendChild(attr)
+	}
+	entry.AppendChild(attributes)
+	return ldapMessage(id, entry)
+}
+
+// ldapResult 编码 BindResponse、SearchResultDone 等只有结果码的响应
+func ldapResult(id int64, tag ber.Tag, code int) *ber.Packet {
+	result := ber.Encode(ber.ClassApplication, ber.TypeConstructed, tag, nil, "LDAPResult")
+	result.AppendChild(ber.NewInteger(ber.ClassUniversal, ber.TypePrimitive, ber.TagEnumerated, code, "resultCode"))
+	result.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, "", "matchedDN"))
+	result.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, "", "diagnosticMessage"))
+	return ldapMessage(id, result)
+}
+
+// ldapMessage 包装为带消息ID的 LDAPMessage
+func ldapMessage(id int64, op *ber.Packet) *ber.Packet {
+	message := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "LDAPMessage")
+	message.AppendChild(ber.NewInteger(ber.ClassUniversal, ber.TypePrimitive, ber.TagInteger, id, "messageID"))
+	message.AppendChild(op)
+	return message
+}
+
+// newTestLDAPAuthenticator 创建连接测试目录的认证器
+func newTestLDAPAuthenticator(t *testing.T, d *testDirectory, configure func(cfg *config.Config)) *LDAPAuthenticator {
+	t.Helper()
+	cfg := &config.Config{
+		LDAPURL:            d.url(),
+		LDAPBindDN:         d.bindDN,
+		LDAPBindPassword:   d.bindPass,
+		LDAPBaseDN:         "ou=people,dc=example,dc=com",
+		LDAPUserFilter:     "(&(objectClass=person)(|(uid={username})(mail={username})))",
+		LDAPIDAttribute:    "entryUUID",
+		LDAPEmailAttribute: "mail",
+		LDAPNameAttribute:  "cn",
+		LDAPGroupAttribute: "memberOf",
+	}
+	if configure != nil {
+		configure(cfg)
+	}
+	a, err := NewLDAPAuthenticator(nil, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return a
+}
+
+var aliceEntry = testDirectoryEntry{
+	dn:       "uid=alice,ou=people,dc=example,dc=com",
+	password: "alice-secret",
+	attributes: map[string][]string{
+		"uid":        {"alice"},
+		"mail":       {"alice@example.com"},
+		"cn":         {"Alice"},
+		"entryUUID":  {"6f1c1a4e-2f0b-4b8e-9a57-0d2c4f1e9b11"},
+		"memberOf":   {"cn=aichat-admins,ou=groups,dc=example,dc=com"},
+		"objectGUID": {"\x01\x02\xff\x00"},
+	},
+}
+
+func TestLDAPLookup(t *testing.T) {
+	d := startTestDirectory(t, aliceEntry)
+	a := newTestLDAPAuthenticator(t, d, nil)
+
+	for _, identifier := range []string{"alice", "alice@example.com"} {
+		dn, ext, err := a.lookup(identifier, "alice-secret")
+		if err != nil {
+			t.Fatalf("lookup(%s): %v", identifier, err)
+		}
+		if dn != aliceEntry.dn {
+			t.Errorf("dn = %q", dn)
+		}
+		if ext.Subject != "6f1c1a4e-2f0b-4b8e-9a57-0d2c4f1e9b11" {
+			t.Errorf("subject = %q, want entryUUID", ext.Subject)
+		}
+		if ext.Email != "alice@example.com" || ext.Name != "Alice" {
+			t.Errorf("identity = %+v", ext)
+		}
+		if ext.EmailVerified {
+			t.Error("directory email should not be trusted by default")
+		}
+		if !slices.Contains(ext.Groups, "aichat-admins") {
+			t.Errorf("groups = %v, want CN of memberOf", ext.Groups)
+		}
+	}
+}
+
+func TestLDAPLookupRejectsBadCredentials(t *testing.T) {
+	d := startTestDirectory(t, aliceEntry)
+	a := newTestLDAPAuthenticator(t, d, nil)
+
+	tests := []struct {
+		name       string
+		identifier string
+		password   string
+		want       error
+	}{
+		{"wrong password", "alice", "wrong", ErrInvalidCredentials},
+		{"empty password", "alice", "", ErrInvalidCredentials},
+		{"unknown user", "bob", "alice-secret", ErrUnknownUser},
+		{"filter injection", "*", "alice-secret", ErrUnknownUser},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, _, err := a.lookup(tt.identifier, tt.password); !errors.Is(err, tt.want) {
+				t.Fatalf("lookup = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestLDAPLookupOptions(t *testing.T) {
+	d := startTestDirectory(t, aliceEntry)
+
+	trusted := newTestLDAPAuthenticator(t, d, func(cfg *config.Config) { cfg.LDAPTrustEmail = true })
+	if _, ext, err := trusted.lookup("alice", "alice-secret"); err != nil || !ext.EmailVerified {
+		t.Fatalf("LDAP_TRUST_EMAIL: ext = %+v, err = %v", ext, err)
+	}
+
+	guid := newTestLDAPAuthenticator(t, d, func(cfg *config.Config) { cfg.LDAPIDAttribute = "objectGUID" })
+	if _, ext, err := guid.lookup("alice", "alice-secret"); err != nil || ext.Subject != "0102ff00" {
+		t.Fatalf("objectGUID: ext = %+v, err = %v", ext, err)
+	}
+
+	missing := newTestLDAPAuthenticator(t, d, func(cfg *config.Config) { cfg.LDAPIDAttribute = "nsUniqueId" })
+	if _, _, err := missing.lookup("alice", "alice-secret"); err == nil {
+		t.Fatal("missing ID attribute: expected error")
+	}
+
+	badService := newTestLDAPAuthenticator(t, d, func(cfg *config.Config) { cfg.LDAPBindPassword = "wrong" })
+	if _, _, err := badService.lookup("alice", "alice-secret"); err == nil || errors.Is(err, ErrInvalidCredentials) {
+		t.Fatalf("service account bind failure: err = %v", err)
+	}
+}
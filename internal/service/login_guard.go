@@ -0,0 +1,245 @@
+package service
+
+import (
+	"ai-chat/config"
+	"ai-chat/internal/repository"
+	"fmt"
+	"log"
+	"math"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	// loginDelayAfter 连续失败超过该次数后开始渐进延迟
+	loginDelayAfter = 3
+	// loginMaxDelay 渐进延迟上限
+	loginMaxDelay = time.Minute
+)
+
+// LoginThrottledError 登录尝试过于频繁或账户被临时锁定
+type LoginThrottledError struct {
+	RetryAfter time.Duration
+	Locked     bool
+}
+
+// Error 实现 error 接口
+func (e *LoginThrottledError) Error() string {
+	seconds := int(math.Ceil(e.RetryAfter.Seconds()))
+	if e.Locked {
+		return fmt.Sprintf("登录失败次数过多，账户已临时锁定，请 %d 秒后重试", seconds)
+	}
+	return fmt.Sprintf("尝试过于频繁，请 %d 秒后重试", seconds)
+}
+
+// LoginGuard 登录防暴力破解：按账户和IP统计连续失败次数，渐进延迟并临时锁定
+type LoginGuard struct {
+	db    *gorm.DB
+	cfg   *config.Config
+	audit *AuditService
+}
+
+// NewLoginGuard 创建登录防护
+func NewLoginGuard(db *gorm.DB, cfg *config.Config, audit *AuditService) *LoginGuard {
+	return &LoginGuard{
+		db:    db,
+		cfg:   cfg,
+		audit: audit,
+	}
+}
+
+// Check 登录前检查账户和IP是否处于延迟或锁定状态
+func (g *LoginGuard) Check(identifier, ip string) error {
+	return g.check(accountKey(identifier), ip)
+}
+
+// CheckMFA 校验两步验证码前检查用户和IP是否处于延迟或锁定状态
+func (g *LoginGuard) CheckMFA(userID uint, ip string) error {
+	return g.check(mfaKey(userID), ip)
+}
+
+// check 检查计数键和IP中等待时间最长的限制
+func (g *LoginGuard) check(key, ip string) error {
+	keys := []string{key}
+	if ip != "" {
+		keys = append(keys, ipKey(ip))
+	}
+
+	var rows []repository.LoginThrottle
+	if err := g.db.Where("key IN ?", keys).Find(&rows).Error; err != nil {
+		return fmt.Errorf("检查登录状态失败: %w", err)
+	}
+
+	now := time.Now()
+	var throttled *LoginThrottledError
+	for _, row := range rows {
+		var e *LoginThrottledError
+		if row.LockedUntil != nil && now.Before(*row.LockedUntil) {
+			e = &LoginThrottledError{RetryAfter: row.LockedUntil.Sub(now), Locked: true}
+		} else if now.Before(row.NextAttemptAt) {
+			e = &LoginThrottledError{RetryAfter: row.NextAttemptAt.Sub(now)}
+		}
+		if e != nil && (throttled == nil || e.RetryAfter > throttled.RetryAfter) {
+			throttled = e
+		}
+	}
+	if throttled != nil {
+		return throttled
+	}
+	return nil
+}
+
+// Fail 记录一次登录失败
+func (g *LoginGuard) Fail(identifier, ip string) {
+	g.audit.Record(&AuditEntry{
+		Action:  AuditLoginFailed,
+		IP:      ip,
+		Details: "identifier=" + normalizeIdentifier(identifier),
+	})
+
+	if g.fail(accountKey(identifier), g.cfg.LoginLockoutThreshold) {
+		g.audit.Record(&AuditEntry{
+			Action:  AuditLoginLocked,
+			IP:      ip,
+			Details: "identifier=" + normalizeIdentifier(identifier),
+		})
+	}
+	if ip != "" && g.fail(ipKey(ip), g.cfg.LoginIPLockoutThreshold) {
+		g.audit.Record(&AuditEntry{
+			Action:  AuditLoginLocked,
+			IP:      ip,
+			Details: "ip=" + ip,
+		})
+	}
+}
+
+// FailMFA 记录一次两步验证失败
+// 与密码失败分开计数，否则持有密码的攻击者可以反复登录成功来清零计数
+func (g *LoginGuard) FailMFA(userID uint, ip string) {
+	g.audit.Record(&AuditEntry{
+		TargetUserID: &userID,
+		Action:       AuditLoginFailed,
+		IP:           ip,
+		Details:      "mfa",
+	})
+
+	if g.fail(mfaKey(userID), g.cfg.LoginLockoutThreshold) {
+		g.audit.Record(&AuditEntry{
+			TargetUserID: &userID,
+			Action:       AuditLoginLocked,
+			IP:           ip,
+			Details:      "mfa",
+		})
+	}
+	if ip != "" && g.fail(ipKey(ip), g.cfg.LoginIPLockoutThreshold) {
+		g.audit.Record(&AuditEntry{
+			Action:  AuditLoginLocked,
+			IP:      ip,
+			Details: "ip=" + ip,
+		})
+	}
+}
+
+// Succeed 登录成功后清除账户的失败计数
+// IP 计数不清除，避免攻击者用自己的账户穿插登录来重置计数
+func (g *LoginGuard) Succeed(identifier string) {
+	if err := g.db.Where("key = ?", accountKey(identifier)).Delete(&repository.LoginThrottle{}).Error; err != nil {
+		log.Printf("[login] 清除登录失败计数失败: %v", err)
+	}
+}
+
+// SucceedMFA 两步验证成功后清除用户的失败计数
+func (g *LoginGuard) SucceedMFA(userID uint) {
+	if err := g.db.Where("key = ?", mfaKey(userID)).Delete(&repository.LoginThrottle{}).Error; err != nil {
+		log.Printf("[login] 清除两步验证失败计数失败: %v", err)
+	}
+}
+
+// Unlock 解除账户锁定，包括密码和两步验证的失败计数
+func (g *LoginGuard) Unlock(identifier string, userID uint) error {
+	if err := g.db.Where("key IN ?", []string{accountKey(identifier), mfaKey(userID)}).Delete(&repository.LoginThrottle{}).Error; err != nil {
+		return fmt.Errorf("解除锁定失败: %w", err)
+	}
+	return nil
+}
+
+// fail 累加失败次数，返回本次是否触发锁定
+func (g *LoginGuard) fail(key string, threshold int) bool {
+	locked := false
+	err := g.db.Transaction(func(tx *gorm.DB) error {
+		// 并发的首次失败可能同时插入，冲突时忽略后再加锁读取
+		if err := tx.Clauses(clause.OnConflict{DoNothing: true}).
+			Create(&repository.LoginThrottle{Key: key}).Error; err != nil {
+			return err
+		}
+
+		var row repository.LoginThrottle
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("key = ?", key).First(&row).Error; err != nil {
+			return err
+		}
+
+		now := time.Now()
+		window := time.Duration(g.cfg.LoginLockoutTTL) * time.Second
+		// 距上次失败超过锁定时长，重新计数
+		if now.Sub(row.LastFailureAt) > window && (row.LockedUntil == nil || now.After(*row.LockedUntil)) {
+			row.Failures = 0
+			row.LockedUntil = nil
+		}
+
+		row.Failures++
+		row.LastFailureAt = now
+		row.NextAttemptAt = now.Add(loginDelay(row.Failures))
+		if threshold > 0 && row.Failures >= threshold && row.LockedUntil == nil {
+			lockedUntil := now.Add(window)
+			row.LockedUntil = &lockedUntil
+			locked = true
+		}
+
+		return tx.Save(&row).Error
+	})
+	if err != nil {
+		log.Printf("[login] 记录登录失败次数失败: %v", err)
+		return false
+	}
+	return locked
+}
+
+// loginDelay 第 n 次连续失败后需要等待的时间：前几次不延迟，之后每次翻倍
+func loginDelay(failures int) time.Duration {
+	if failures <= loginDelayAfter {
+		return 0
+	}
+	exp := failures - loginDelayAfter - 1
+	if exp > 6 {
+		return loginMaxDelay
+	}
+	delay := time.Duration(1<<exp) * time.Second
+	if delay > loginMaxDelay {
+		return loginMaxDelay
+	}
+	return delay
+}
+
+// normalizeIdentifier 登录名不区分大小写
+func normalizeIdentifier(identifier string) string {
+	return strings.ToLower(strings.TrimSpace(identifier))
+}
+
+// accountKey 账户维度的计数键
+func accountKey(identifier string) string {
+	return "account:" + truncateRunes(normalizeIdentifier(identifier), 240)
+}
+
+// mfaKey 两步验证的计数键
+func mfaKey(userID uint) string {
+	return fmt.Sprintf("mfa:%d", userID)
+}
+
+// ipKey IP维度的计数键
+func ipKey(ip string) string {
+	return "ip:" + ip
+}
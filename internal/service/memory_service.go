@@ -0,0 +1,542 @@
+package service
+
+import (
+	"ai-chat/config"
+	"ai-chat/internal/policy"
+	"ai-chat/internal/repository"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	// memoryExtractBatchSize 每次提取记忆的会话数量
+	memoryExtractBatchSize = 10
+	// maxMemoryExtractMessages 每次提取时读取的最近消息数量
+	maxMemoryExtractMessages = 40
+	// maxMemoryMessageRunes 提取时每条消息的最大字符数，超出部分截断
+	maxMemoryMessageRunes = 2000
+	// maxMemoryRunes 单条记忆的最大字符数
+	maxMemoryRunes = 500
+	// memoryRetryBase 会话提取失败后首次重试的等待时间，之后每次翻倍
+	memoryRetryBase = 5 * time.Minute
+	// memoryRetryMax 提取失败重试的最长等待时间
+	memoryRetryMax = 24 * time.Hour
+)
+
+// 记忆来源
+const (
+	MemorySourceManual    = "manual"
+	MemorySourceExtracted = "extracted"
+)
+
+// ErrMemoryLimit 记忆条数已达上限
+var ErrMemoryLimit = errors.New("记忆条数已达上限，请先删除不需要的记忆")
+
+// memoryExtractPrompt 提取记忆的系统提示词
+const memoryExtractPrompt = `你负责维护用户的长期记忆。阅读下面的对话，提取关于用户本人、在以后的对话中仍然有用的事实和偏好，例如职业、常用的技术栈、所在地、希望的回答语言和风格。
+不要提取一次性的问题、助手回答的内容，也不要提取密码、密钥、证件号等敏感信息；已有记忆中包含的信息不要重复。
+只输出 JSON 字符串数组，每条记忆是一句独立完整的陈述，不超过 100 字，使用用户使用的语言；没有可提取的内容时输出 []。`
+
+// MemoryService 用户长期记忆服务
+// 用户在设置中开启后，后台任务从空闲的个人会话中提取记忆，每轮对话加入与问题相关的记忆；会话可以单独关闭记忆
+type MemoryService struct {
+	db    *gorm.DB
+	cfg   *config.Config
+	ai    *AIService
+	authz *Authorizer
+}
+
+// NewMemoryService 创建记忆服务
+func NewMemoryService(db *gorm.DB, cfg *config.Config, ai *AIService) *MemoryService {
+	return &MemoryService{
+		db:    db,
+		cfg:   cfg,
+		ai:    ai,
+		authz: NewAuthorizer(db),
+	}
+}
+
+// MemoryRequest 保存或修改记忆的请求
+type MemoryRequest struct {
+	Content string `json:"content" binding:"required,max=500"`
+}
+
+// MemorySettingsRequest 开启或关闭记忆的请求
+type MemorySettingsRequest struct {
+	Enabled *bool `json:"enabled" binding:"required"`
+}
+
+// MemorySettings 记忆设置
+type MemorySettings struct {
+	Enabled bool `json:"enabled"`
+}
+
+// MemoryResponse 记忆响应
+type MemoryResponse struct {
+	ID             uint      `json:"id"`
+	Content        string    `json:"content"`
+	Source         string    `json:"source"`
+	ConversationID *uint     `json:"conversationId"`
+	CreatedAt      time.Time `json:"createdAt"`
+	UpdatedAt      time.Time `json:"updatedAt"`
+}
+
+// memoryCandidate 待提取记忆的会话
+type memoryCandidate struct {
+	ConversationID uint
+	UserID         uint
+	LastMessageID  uint
+}
+
+// Settings 获取用户的记忆设置
+func (s *MemoryService) Settings(userID uint) (*MemorySettings, error) {
+	var user repository.User
+	if err := s.db.Select("id", "memory_enabled").First(&user, userID).Error; err != nil {
+		return nil, notFoundOr(err, "查找用户失败")
+	}
+	return &MemorySettings{Enabled: user.MemoryEnabled}, nil
+}
+
+// UpdateSettings 开启或关闭记忆，关闭后不再提取和使用记忆，已保存的记忆保留
+func (s *MemoryService) UpdateSettings(userID uint, req *MemorySettingsRequest) (*MemorySettings, error) {
+	if err := s.db.Model(&repository.User{}).Where("id = ?", userID).
+		Update("memory_enabled", *req.Enabled).Error; err != nil {
+		return nil, fmt.Errorf("更新记忆设置失败: %w", err)
+	}
+	return &MemorySettings{Enabled: *req.Enabled}, nil
+}
+
+// FindAll 获取用户的记忆，q 按内容过滤
+func (s *MemoryService) FindAll(userID uint, page, pageSize int, q string) ([]*MemoryResponse, int64, error) {
+	query := s.db.Model(&repository.UserMemory{}).Where("user_id = ?", userID)
+	if q != "" {
+		query = query.Where("content ILIKE ?", "%"+q+"%")
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, fmt.Errorf("统计记忆失败: %w", err)
+	}
+
+	var memories []*repository.UserMemory
+	if err := query.Order("updated_at DESC, id DESC").
+		Offset((page - 1) * pageSize).Limit(pageSize).
+		Find(&memories).Error; err != nil {
+		return nil, 0, fmt.Errorf("查询记忆失败: %w", err)
+	}
+
+	items := make([]*MemoryResponse, len(memories))
+	for i, memory := range memories {
+		items[i] = toMemoryResponse(memory)
+	}
+	return items, total, nil
+}
+
+// Create 手动保存一条记忆
+func (s *MemoryService) Create(userID uint, req *MemoryRequest) (*MemoryResponse, error) {
+	var count int64
+	if err := s.db.Model(&repository.UserMemory{}).Where("user_id = ?", userID).Count(&count).Error; err != nil {
+		return nil, fmt.Errorf("统计记忆失败: %w", err)
+	}
+	if int(count) >= s.cfg.MemoryMaxItems {
+		return nil, ErrMemoryLimit
+	}
+
+	memory := &repository.UserMemory{
+		UserID:  userID,
+		Content: strings.TrimSpace(req.Content),
+		Source:  MemorySourceManual,
+	}
+	if err := s.db.Create(memory).Error; err != nil {
+		return nil, fmt.Errorf("保存记忆失败: %w", err)
+	}
+	return toMemoryResponse(memory), nil
+}
+
+// Update 修改记忆内容
+func (s *MemoryService) Update(userID, id uint, req *MemoryRequest) (*MemoryResponse, error) {
+	memory, err := s.find(userID, id)
+	if err != nil {
+		return nil, err
+	}
+
+	memory.Content = strings.TrimSpace(req.Content)
+	if err := s.db.Save(memory).Error; err != nil {
+		return nil, fmt.Errorf("修改记忆失败: %w", err)
+	}
+	return toMemoryResponse(memory), nil
+}
+
+// Delete 删除一条记忆
+func (s *MemoryService) Delete(userID, id uint) error {
+	memory, err := s.find(userID, id)
+	if err != nil {
+		return err
+	}
+	if err := s.db.Delete(memory).Error; err != nil {
+		return fmt.Errorf("删除记忆失败: %w", err)
+	}
+	return nil
+}
+
+// Clear 删除用户的全部记忆，返回删除的条数
+func (s *MemoryService) Clear(userID uint) (int64, error) {
+	result := s.db.Where("user_id = ?", userID).Delete(&repository.UserMemory{})
+	if result.Error != nil {
+		return 0, fmt.Errorf("清空记忆失败: %w", result.Error)
+	}
+	return result.RowsAffected, nil
+}
+
+// ConversationSettings 获取会话是否使用记忆
+func (s *MemoryService) ConversationSettings(p policy.Principal, conversationID uint) (*MemorySettings, error) {
+	if _, err := s.authz.Conversation(p, policy.ActionRead, conversationID); err != nil {
+		return nil, err
+	}
+
+	disabled, err := s.conversationDisabled(conversationID)
+	if err != nil {
+		return nil, err
+	}
+	return &MemorySettings{Enabled: !disabled}, nil
+}
+
+// UpdateConversationSettings 设置会话是否使用记忆，关闭后该会话既不加入记忆也不从中提取
+func (s *MemoryService) UpdateConversationSettings(p policy.Principal, conversationID uint, req *MemorySettingsRequest) (*MemorySettings, error) {
+	if _, err := s.authz.Conversation(p, policy.ActionWrite, conversationID); err != nil {
+		return nil, err
+	}
+
+	setting := &repository.ConversationMemory{
+		ConversationID: conversationID,
+		Disabled:       !*req.Enabled,
+	}
+	if err := s.db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "conversation_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"disabled", "updated_at"}),
+	}).Create(setting).Error; err != nil {
+		return nil, fmt.Errorf("更新会话记忆设置失败: %w", err)
+	}
+	return &MemorySettings{Enabled: *req.Enabled}, nil
+}
+
+// Context 生成加入对话的记忆，用户未开启记忆、会话关闭了记忆或没有记忆时返回空字符串
+// 工作区会话的历史对其他成员可见，与提取范围一致，不加入个人记忆
+// 记忆较多时按与问题的相关度选取，相关度相同时优先最近更新的
+func (s *MemoryService) Context(userID, conversationID uint, question string) (string, error) {
+	settings, err := s.Settings(userID)
+	if err != nil || !settings.Enabled {
+		return "", err
+	}
+	var conversation repository.Conversation
+	if err := s.db.Select("id", "workspace_id").Take(&conversation, conversationID).Error; err != nil {
+		return "", fmt.Errorf("查询会话失败: %w", err)
+	}
+	if conversation.WorkspaceID != nil {
+		return "", nil
+	}
+	disabled, err := s.conversationDisabled(conversationID)
+	if err != nil || disabled {
+		return "", err
+	}
+
+	var memories []*repository.UserMemory
+	if err := s.db.Where("user_id = ?", userID).
+		Order("updated_at DESC, id DESC").
+		Find(&memories).Error; err != nil {
+		return "", fmt.Errorf("查询记忆失败: %w", err)
+	}
+	if len(memories) == 0 {
+		return "", nil
+	}
+
+	limit := max(s.cfg.MemoryContextItems, 1)
+	if len(memories) > limit {
+		terms := contextTerms(question)
+		scores := make(map[uint]int, len(memories))
+		for _, memory := range memories {
+			content := strings.ToLower(memory.Content)
+			for _, term := range terms {
+				if strings.Contains(content, term) {
+					scores[memory.ID]++
+				}
+			}
+		}
+		sort.SliceStable(memories, func(i, j int) bool {
+			return scores[memories[i].ID] > scores[memories[j].ID]
+		})
+		memories = memories[:limit]
+	}
+
+	var b strings.Builder
+	b.WriteString("以下是用户希望你记住的背景信息和偏好，回答时在相关的地方参考，不必复述：")
+	for _, memory := range memories {
+		b.WriteString("\n- ")
+		b.WriteString(memory.Content)
+	}
+	return b.String(), nil
+}
+
+// RunExtractor 后台提取任务，按间隔从空闲的会话中提取记忆，直到 ctx 结束
+func (s *MemoryService) RunExtractor(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		for {
+			processed, err := s.extractBatch(ctx)
+			if err != nil {
+				log.Printf("Memory extraction failed: %v", err)
+				break
+			}
+			if processed < memoryExtractBatchSize {
+				break
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// extractBatch 为一批会话提取记忆，返回处理的会话数
+// 只处理开启了记忆的用户的个人会话，工作区会话中有其他成员的消息，不从中提取
+func (s *MemoryService) extractBatch(ctx context.Context) (int, error) {
+	now := time.Now()
+	idleSince := now.Add(-time.Duration(s.cfg.MemoryIdleMinutes) * time.Minute)
+
+	var candidates []*memoryCandidate
+	if err := s.db.Table("conversations").
+		Select("conversations.id AS conversation_id, conversations.user_id, "+
+			"COALESCE(conversation_memories.last_message_id, 0) AS last_message_id").
+		Joins("JOIN users ON users.id = conversations.user_id AND users.memory_enabled AND users.deleted_at IS NULL").
+		Joins("LEFT JOIN conversation_memories ON conversation_memories.conversation_id = conversations.id").
+		Where("conversations.deleted_at IS NULL AND conversations.workspace_id IS NULL").
+		Where("COALESCE(conversation_memories.disabled, false) = false").
+		Where("conversation_memories.retry_at IS NULL OR conversation_memories.retry_at <= ?", now).
+		Where("EXISTS (SELECT 1 FROM messages WHERE messages.conversation_id = conversations.id "+
+			"AND messages.deleted_at IS NULL AND messages.type = 'user' "+
+			"AND messages.id > COALESCE(conversation_memories.last_message_id, 0))").
+		Where("NOT EXISTS (SELECT 1 FROM messages WHERE messages.conversation_id = conversations.id "+
+			"AND messages.deleted_at IS NULL AND messages.created_at > ?)", idleSince).
+		Order("conversations.id").
+		Limit(memoryExtractBatchSize).
+		Scan(&candidates).Error; err != nil {
+		return 0, fmt.Errorf("查询待提取的会话失败: %w", err)
+	}
+
+	// 失败的会话不记录进度，按连续失败次数推迟重试，避免每轮都先处理同一批失败的会话
+	var errs []error
+	for _, candidate := range candidates {
+		if err := s.extract(ctx, candidate); err != nil {
+			s.markFailed(candidate.ConversationID, now)
+			errs = append(errs, fmt.Errorf("会话 %d: %w", candidate.ConversationID, err))
+		}
+	}
+	return len(candidates), errors.Join(errs...)
+}
+
+// markFailed 记录会话提取失败并计算下次重试时间
+func (s *MemoryService) markFailed(conversationID uint, now time.Time) {
+	setting := repository.ConversationMemory{ConversationID: conversationID}
+	if err := s.db.Where("conversation_id = ?", conversationID).Limit(1).Find(&setting).Error; err != nil {
+		log.Printf("Failed to load memory progress of conversation %d: %v", conversationID, err)
+		return
+	}
+
+	setting.Failures++
+	retryAt := now.Add(retryDelay(setting.Failures, memoryRetryBase, memoryRetryMax))
+	setting.RetryAt = &retryAt
+	if err := s.db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "conversation_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"failures", "retry_at", "updated_at"}),
+	}).Create(&setting).Error; err != nil {
+		log.Printf("Failed to record memory extraction failure of conversation %d: %v", conversationID, err)
+	}
+}
+
+// extract 从会话的新消息中提取记忆并记录提取进度
+func (s *MemoryService) extract(ctx context.Context, candidate *memoryCandidate) error {
+	var messages []*repository.Message
+	if err := s.db.Where("conversation_id = ? AND id > ? AND type IN ?",
+		candidate.ConversationID, candidate.LastMessageID, []string{"user", "assistant"}).
+		Order("id DESC").
+		Limit(maxMemoryExtractMessages).
+		Find(&messages).Error; err != nil {
+		return fmt.Errorf("查询消息失败: %w", err)
+	}
+	if len(messages) == 0 {
+		return nil
+	}
+	lastMessageID := messages[0].ID
+
+	var existing []*repository.UserMemory
+	if err := s.db.Where("user_id = ?", candidate.UserID).Order("id").Find(&existing).Error; err != nil {
+		return fmt.Errorf("查询记忆失败: %w", err)
+	}
+
+	var facts []string
+	if len(existing) < s.cfg.MemoryMaxItems {
+		var err error
+		facts, err = s.extractFacts(ctx, existing, messages)
+		if err != nil {
+			return err
+		}
+	}
+
+	seen := make(map[string]bool, len(existing))
+	for _, memory := range existing {
+		seen[normalizeMemory(memory.Content)] = true
+	}
+	var memories []*repository.UserMemory
+	for _, fact := range facts {
+		fact = truncateRunes(strings.TrimSpace(fact), maxMemoryRunes)
+		key := normalizeMemory(fact)
+		if key == "" || seen[key] || len(existing)+len(memories) >= s.cfg.MemoryMaxItems {
+			continue
+		}
+		seen[key] = true
+		memories = append(memories, &repository.UserMemory{
+			UserID:         candidate.UserID,
+			Content:        fact,
+			Source:         MemorySourceExtracted,
+			ConversationID: &candidate.ConversationID,
+		})
+	}
+
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		if len(memories) > 0 {
+			if err := tx.Create(&memories).Error; err != nil {
+				return fmt.Errorf("保存记忆失败: %w", err)
+			}
+		}
+		progress := &repository.ConversationMemory{
+			ConversationID: candidate.ConversationID,
+			LastMessageID:  lastMessageID,
+		}
+		if err := tx.Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "conversation_id"}},
+			DoUpdates: clause.AssignmentColumns([]string{"last_message_id", "failures", "retry_at", "updated_at"}),
+		}).Create(progress).Error; err != nil {
+			return fmt.Errorf("记录提取进度失败: %w", err)
+		}
+		return nil
+	})
+}
+
+// extractFacts 调用模型从消息中提取新的记忆，messages 按时间倒序
+func (s *MemoryService) extractFacts(ctx context.Context, existing []*repository.UserMemory, messages []*repository.Message) ([]string, error) {
+	var b strings.Builder
+	b.WriteString("已有记忆：")
+	if len(existing) == 0 {
+		b.WriteString("无")
+	}
+	for _, memory := range existing {
+		b.WriteString("\n- ")
+		b.WriteString(memory.Content)
+	}
+	b.WriteString("\n\n对话：")
+	for i := len(messages) - 1; i >= 0; i-- {
+		role := "用户"
+		if messages[i].Type == "assistant" {
+			role = "助手"
+		}
+		fmt.Fprintf(&b, "\n%s: %s", role, truncateRunes(messages[i].Content, maxMemoryMessageRunes))
+	}
+
+	temperature := 0.0
+	req := &ChatRequest{
+		Messages: []Message{
+			{Role: "system", Content: memoryExtractPrompt},
+			{Role: "user", Content: b.String()},
+		},
+		Temperature: &temperature,
+	}
+	if s.cfg.MemoryModel != "" {
+		req.Model = &s.cfg.MemoryModel
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	result, err := s.ai.ChatCompletion(req)
+	if err != nil {
+		return nil, fmt.Errorf("调用模型失败: %w", err)
+	}
+	if len(result.Choices) == 0 {
+		return nil, nil
+	}
+
+	facts, err := parseMemoryFacts(result.Choices[0].Message.Content)
+	if err != nil {
+		// 模型输出格式不对时跳过这些消息，避免反复重试
+		log.Printf("Memory extraction returned invalid output: %v", err)
+		return nil, nil
+	}
+	return facts, nil
+}
+
+// conversationDisabled 会话是否关闭了记忆
+func (s *MemoryService) conversationDisabled(conversationID uint) (bool, error) {
+	var setting repository.ConversationMemory
+	err := s.db.Where("conversation_id = ?", conversationID).Take(&setting).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("查询会话记忆设置失败: %w", err)
+	}
+	return setting.Disabled, nil
+}
+
+// find 查找用户的记忆
+func (s *MemoryService) find(userID, id uint) (*repository.UserMemory, error) {
+	var memory repository.UserMemory
+	if err := s.db.Where("id = ? AND user_id = ?", id, userID).Take(&memory).Error; err != nil {
+		return nil, notFoundOr(err, "查找记忆失败")
+	}
+	return &memory, nil
+}
+
+// parseMemoryFacts 解析模型输出的 JSON 字符串数组，允许外层包含代码块标记或说明文字
+func parseMemoryFacts(output string) ([]string, error) {
+	start := strings.Index(output, "[")
+	end := strings.LastIndex(output, "]")
+	if start < 0 || end < start {
+		return nil, fmt.Errorf("未找到 JSON 数组: %q", truncateRunes(output, 200))
+	}
+
+	var facts []string
+	if err := json.Unmarshal([]byte(output[start:end+1]), &facts); err != nil {
+		return nil, err
+	}
+	return facts, nil
+}
+
+// normalizeMemory 用于判断记忆是否重复的规范化内容
+func normalizeMemory(content string) string {
+	return strings.ToLower(strings.Join(strings.Fields(content), " "))
+}
+
+// toMemoryResponse 转换为记忆响应
+func toMemoryResponse(memory *repository.UserMemory) *MemoryResponse {
+	return &MemoryResponse{
+		ID:             memory.ID,
+		Content:        memory.Content,
+		Source:         memory.Source,
+		ConversationID: memory.ConversationID,
+		CreatedAt:      memory.CreatedAt,
+		UpdatedAt:      memory.UpdatedAt,
+	}
+}
@@ -1,6 +1,7 @@
 package service
 
 import (
+	"ai-chat/internal/policy"
 	"ai-chat/internal/repository"
 	"fmt"
 	"time"
@@ -10,12 +11,16 @@ import (
 
 // MessageService 消息服务
 type MessageService struct {
-	db *gorm.DB
+	db    *gorm.DB
+	authz *Authorizer
 }
 
 // NewMessageService 创建消息服务
 func NewMessageService(db *gorm.DB) *MessageService {
-	return &MessageService{db: db}
+	return &MessageService{
+		db:    db,
+		authz: NewAuthorizer(db),
+	}
 }
 
 // CreateMessageRequest 创建消息请求
@@ -26,6 +31,7 @@ type CreateMessageRequest struct {
 	Type             string  `json:"type" binding:"required,oneof=system user assistant"`
 	Model            *string `json:"model,omitempty"`
 	ParentID         *uint   `json:"parentId,omitempty"`
+	Metadata         *string `json:"-"` // 由服务端生成，如聊天中附带的远程图片地址
 }
 
 // UpdateMessageRequest 更新消息请求
@@ -50,16 +56,9 @@ type MessageResponse struct {
 }
 
 // Create 创建消息
-func (s *MessageService) Create(userID uint, req *CreateMessageRequest) (*MessageResponse, error) {
-	// 验证会话归属权
-	var count int64
-	if err := s.db.Model(&repository.Conversation{}).
-		Where(&repository.Conversation{ID: req.ConversationID, UserID: userID}).
-		Count(&count).Error; err != nil {
-		return nil, fmt.Errorf("验证会话归属权失败: %w", err)
-	}
-	if count == 0 {
-		return nil, fmt.Errorf("无权向该会话发送消息")
+func (s *MessageService) Create(p policy.Principal, req *CreateMessageRequest) (*MessageResponse, error) {
+	if _, err := s.authz.Conversation(p, policy.ActionWrite, req.ConversationID); err != nil {
+		return nil, err
 	}
 
 	// 获取下一个排序值
@@ -76,6 +75,7 @@ func (s *MessageService) Create(userID uint, req *CreateMessageRequest) (*Messag
 		Type:             req.Type,
 		Model:            req.Model,
 		ParentID:         req.ParentID,
+		Metadata:         req.Metadata,
 	}
 
 	if err := s.db.Create(message).Error; err != nil {
@@ -98,14 +98,9 @@ func (s *MessageService) NextSort(conversationID uint) (int, error) {
 }
 
 // FindByConversationID 根据会话ID查找消息
-func (s *MessageService) FindByConversationID(userID, conversationID uint) ([]*MessageResponse, error) {
-	// 验证会话归属权
-	var count int64
-	if err := s.db.Model(&repository.Conversation{}).Where(&repository.Conversation{ID: conversationID, UserID: userID}).Count(&count).Error; err != nil {
-		return nil, fmt.Errorf("验证会话归属权失败: %w", err)
-	}
-	if count == 0 {
-		return nil, fmt.Errorf("无权查看该会话消息")
+func (s *MessageService) FindByConversationID(p policy.Principal, conversationID uint) ([]*MessageResponse, error) {
+	if _, err := s.authz.Conversation(p, policy.ActionRead, conversationID); err != nil {
+		return nil, err
 	}
 
 	var messages []*repository.Message
@@ -125,17 +120,21 @@ func (s *MessageService) FindByConversationID(userID, conversationID uint) ([]*M
 	return items, nil
 }
 
-// FindAll 获取用户的消息列表
-func (s *MessageService) FindAll(userID uint) ([]*MessageResponse, error) {
+// FindAll 获取当前空间全部会话的消息列表
+func (s *MessageService) FindAll(p policy.Principal) ([]*MessageResponse, error) {
+	if err := s.authz.WorkspaceContent(p, policy.ActionRead); err != nil {
+		return nil, err
+	}
+
 	var messages []*repository.Message
 
-	// 使用 Join 查询属于该用户的消息
+	// 使用 Join 查询当前空间会话的消息
 	query := s.db.Model(&repository.Message{}).
-		Joins("JOIN conversation ON conversation.id = message.conversation_id").
-		Where("conversation.user_id = ?", userID)
+		Joins("JOIN conversations ON conversations.id = messages.conversation_id").
+		Scopes(s.authz.ConversationScope(p))
 
 	// 查询列表
-	if err := query.Order("message.sort asc").Find(&messages).Error; err != nil {
+	if err := query.Order("messages.sort asc").Find(&messages).Error; err != nil {
 		return nil, fmt.Errorf("查询消息列表失败: %w", err)
 	}
 
@@ -148,34 +147,20 @@ func (s *MessageService) FindAll(userID uint) ([]*MessageResponse, error) {
 }
 
 // FindByID 根据ID查找消息
-func (s *MessageService) FindByID(userID, id uint) (*MessageResponse, error) {
-	var message repository.Message
-
-	// 使用 Join 验证归属权
-	err := s.db.Model(&repository.Message{}).
-		Joins("JOIN conversation ON conversation.id = message.conversation_id").
-		Where("message.id = ? AND conversation.user_id = ?", id, userID).
-		First(&message).Error
-
+func (s *MessageService) FindByID(p policy.Principal, id uint) (*MessageResponse, error) {
+	message, err := s.authz.Message(p, policy.ActionRead, id)
 	if err != nil {
-		return nil, fmt.Errorf("查找消息失败: %w", err)
+		return nil, err
 	}
 
-	return s.toResponse(&message), nil
+	return s.toResponse(message), nil
 }
 
 // Update 更新消息
-func (s *MessageService) Update(userID, id uint, req *UpdateMessageRequest) (*MessageResponse, error) {
-	var message repository.Message
-
-	// 验证归属权并获取消息
-	err := s.db.Model(&repository.Message{}).
-		Joins("JOIN conversation ON conversation.id = message.conversation_id").
-		Where("message.id = ? AND conversation.user_id = ?", id, userID).
-		First(&message).Error
-
+func (s *MessageService) Update(p policy.Principal, id uint, req *UpdateMessageRequest) (*MessageResponse, error) {
+	message, err := s.authz.Message(p, policy.ActionWrite, id)
 	if err != nil {
-		return nil, fmt.Errorf("查找消息失败: %w", err)
+		return nil, err
 	}
 
 	// 更新字段
@@ -188,33 +173,23 @@ func (s *MessageService) Update(userID, id uint, req *UpdateMessageRequest) (*Me
 	}
 
 	if len(updates) == 0 {
-		return s.toResponse(&message), nil
+		return s.toResponse(message), nil
 	}
 
-	if err := s.db.Model(&message).Updates(updates).Error; err != nil {
+	if err := s.db.Model(message).Updates(updates).Error; err != nil {
 		return nil, fmt.Errorf("更新消息失败: %w", err)
 	}
 
 	// 重新获取更新后的数据
-	s.db.First(&message, id)
+	s.db.First(message, id)
 
-	return s.toResponse(&message), nil
+	return s.toResponse(message), nil
 }
 
 // Delete 删除消息
-func (s *MessageService) Delete(userID, id uint) error {
-	// 验证归属权
-	var count int64
-	err := s.db.Model(&repository.Message{}).
-		Joins("JOIN conversation ON conversation.id = message.conversation_id").
-		Where("message.id = ? AND conversation.user_id = ?", id, userID).
-		Count(&count).Error
-
-	if err != nil {
-		return fmt.Errorf("验证消息归属权失败: %w", err)
-	}
-	if count == 0 {
-		return fmt.Errorf("消息不存在或无权删除")
+func (s *MessageService) Delete(p policy.Principal, id uint) error {
+	if _, err := s.authz.Message(p, policy.ActionDelete, id); err != nil {
+		return err
 	}
 
 	if err := s.db.Delete(&repository.Message{}, id).Error; err != nil {
@@ -0,0 +1,508 @@
+package service
+
+import (
+	"ai-chat/config"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"gorm.io/gorm"
+)
+
+// oidcFlowTTL 授权流程（state/nonce/PKCE）的有效期
+const oidcFlowTTL = 10 * time.Minute
+
+// OIDCService OpenID Connect 单点登录服务（授权码 + PKCE）
+type OIDCService struct {
+	db          *gorm.DB
+	cfg         *config.Config
+	authService *AuthService
+	roleMapping map[string]string
+	client      *http.Client
+
+	mu        sync.Mutex
+	discovery *oidcDiscovery
+	keys      map[string]interface{} // kid -> 公钥
+	keysAt    time.Time
+}
+
+// oidcDiscovery 身份源的 .well-known/openid-configuration
+type oidcDiscovery struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	UserinfoEndpoint      string `json:"userinfo_endpoint"`
+	JWKSURI               string `json:"jwks_uri"`
+}
+
+// oidcFlowClaims 授权流程状态，签名后保存在浏览器 Cookie 中
+type oidcFlowClaims struct {
+	State    string `json:"state"`
+	Nonce    string `json:"nonce"`
+	Verifier string `json:"verifier"`
+	jwt.RegisteredClaims
+}
+
+// OIDCBeginResult 发起登录的结果
+type OIDCBeginResult struct {
+	AuthURL   string
+	FlowToken string // 需要原样在回调时带回，用于校验 state 并取回 PKCE verifier
+}
+
+// NewOIDCService 创建 OIDC 服务
+func NewOIDCService(db *gorm.DB, cfg *config.Config, authService *AuthService) (*OIDCService, error) {
+	mapping, err := ParseRoleMapping(cfg.OIDCRoleMapping)
+	if err != nil {
+		return nil, fmt.Errorf("解析 OIDC_ROLE_MAPPING 失败: %w", err)
+	}
+
+	return &OIDCService{
+		db:          db,
+		cfg:         cfg,
+		authService: authService,
+		roleMapping: mapping,
+		client: &http.Client{
+			Timeout:   15 * time.Second,
+			Transport: &http.Transport{Proxy: http.ProxyFromEnvironment},
+		},
+	}, nil
+}
+
+// ProviderName 登录按钮显示名称
+func (s *OIDCService) ProviderName() string {
+	return s.cfg.OIDCProviderName
+}
+
+// BeginLogin 生成授权地址以及保存在 Cookie 中的流程令牌
+func (s *OIDCService) BeginLogin() (*OIDCBeginResult, error) {
+	disc, err := s.getDiscovery()
+	if err != nil {
+		return nil, err
+	}
+
+	state, err := randomURLString(24)
+	if err != nil {
+		return nil, err
+	}
+	nonce, err := randomURLString(24)
+	if err != nil {
+		return nil, err
+	}
+	verifier, err := randomURLString(48)
+	if err != nil {
+		return nil, err
+	}
+
+	challenge := sha256.Sum256([]byte(verifier))
+
+	query := url.Values{}
+	query.Set("response_type", "code")
+	query.Set("client_id", s.cfg.OIDCClientID)
+	query.Set("redirect_uri", s.cfg.OIDCRedirectURL)
+	query.Set("scope", s.cfg.OIDCScopes)
+	query.Set("state", state)
+	query.Set("nonce", nonce)
+	query.Set("code_challenge", base64.RawURLEncoding.EncodeToString(challenge[:]))
+	query.Set("code_challenge_method", "S256")
+
+	authURL := disc.AuthorizationEndpoint
+	if strings.Contains(authURL, "?") {
+		authURL += "&" + query.Encode()
+	} else {
+		authURL += "?" + query.Encode()
+	}
+
+	now := time.Now()
+	flow := jwt.NewWithClaims(jwt.SigningMethodHS256, &oidcFlowClaims{
+		State:    state,
+		Nonce:    nonce,
+		Verifier: verifier,
+		RegisteredClaims: jwt.RegisteredClaims{
+			Subject:   "oidc_flow",
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(oidcFlowTTL)),
+		},
+	})
+	flowToken, err := flow.SignedString([]byte(s.cfg.JWTSecret))
+	if err != nil {
+		return nil, fmt.Errorf("签名授权流程失败: %w", err)
+	}
+
+	return &OIDCBeginResult{AuthURL: authURL, FlowToken: flowToken}, nil
+}
+
+// CompleteLogin 处理回调：校验 state，用授权码换取令牌，校验 ID Token 并签发本系统令牌
+func (s *OIDCService) CompleteLogin(flowToken, state, code string, client ClientInfo) (*AuthResponse, error) {
+	ext, err := s.authenticate(flowToken, state, code)
+	if err != nil {
+		return nil, err
+	}
+
+	user, err := ResolveExternalUser(s.db, ext, ExternalUserOptions{
+		AutoProvision: s.cfg.OIDCAutoProvision,
+		RoleMapping:   s.roleMapping,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if !s.cfg.OIDCSkip2FA {
+		return s.authService.LoginUser(user, client)
+	}
+
+	// 运维确认多因素认证由身份提供方负责，不再要求本系统的两步验证
+	if !user.IsActive {
+		return nil, errors.New("账户已被禁用")
+	}
+	token, err := s.authService.GenerateJWT(user, client)
+	if err != nil {
+		return nil, fmt.Errorf("生成令牌失败: %w", err)
+	}
+
+	return &AuthResponse{User: user, Token: token}, nil
+}
+
+// authenticate 校验 state，用授权码换取令牌并校验 ID Token，返回外部身份
+func (s *OIDCService) authenticate(flowToken, state, code string) (*ExternalIdentity, error) {
+	var flow oidcFlowClaims
+	_, err := jwt.ParseWithClaims(flowToken, &flow, func(token *jwt.Token) (interface{}, error) {
+		return []byte(s.cfg.JWTSecret), nil
+	}, jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}), jwt.WithSubject("oidc_flow"))
+	if err != nil {
+		return nil, errors.New("登录流程已过期，请重新登录")
+	}
+	if state == "" || state != flow.State {
+		return nil, errors.New("state 校验失败")
+	}
+	if code == "" {
+		return nil, errors.New("缺少授权码")
+	}
+
+	disc, err := s.getDiscovery()
+	if err != nil {
+		return nil, err
+	}
+
+	tokens, err := s.exchangeCode(disc, code, flow.Verifier)
+	if err != nil {
+		return nil, err
+	}
+
+	claims, err := s.verifyIDToken(disc, tokens.IDToken, flow.Nonce)
+	if err != nil {
+		return nil, err
+	}
+
+	ext := s.identityFromClaims(disc, claims)
+	if ext.Email == "" && disc.UserinfoEndpoint != "" && tokens.AccessToken != "" {
+		if info, err := s.fetchUserinfo(disc, tokens.AccessToken); err == nil && info["sub"] == claims["sub"] {
+			for k, v := range info {
+				if _, exists := claims[k]; !exists {
+					claims[k] = v
+				}
+			}
+			ext = s.identityFromClaims(disc, claims)
+		}
+	}
+	return ext, nil
+}
+
+// oidcTokenResponse 令牌端点响应
+type oidcTokenResponse struct {
+	AccessToken string `json:"access_token"`
+	IDToken     string `json:"id_token"`
+	TokenType   string `json:"token_type"`
+}
+
+// exchangeCode 用授权码换取令牌
+func (s *OIDCService) exchangeCode(disc *oidcDiscovery, code, verifier string) (*oidcTokenResponse, error) {
+	form := url.Values{}
+	form.Set("grant_type", "authorization_code")
+	form.Set("code", code)
+	form.Set("redirect_uri", s.cfg.OIDCRedirectURL)
+	form.Set("code_verifier", verifier)
+	form.Set("client_id", s.cfg.OIDCClientID)
+
+	req, err := http.NewRequest("POST", disc.TokenEndpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, fmt.Errorf("创建令牌请求失败: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	if s.cfg.OIDCClientSecret != "" {
+		req.SetBasicAuth(url.QueryEscape(s.cfg.OIDCClientID), url.QueryEscape(s.cfg.OIDCClientSecret))
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("请求令牌端点失败: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return nil, fmt.Errorf("读取令牌响应失败: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("令牌端点错误: %s", string(body))
+	}
+
+	var tokens oidcTokenResponse
+	if err := json.Unmarshal(body, &tokens); err != nil {
+		return nil, fmt.Errorf("解析令牌响应失败: %w", err)
+	}
+	if tokens.IDToken == "" {
+		return nil, errors.New("令牌响应中缺少 id_token")
+	}
+	return &tokens, nil
+}
+
+// verifyIDToken 校验 ID Token 的签名、issuer、audience、有效期和 nonce
+func (s *OIDCService) verifyIDToken(disc *oidcDiscovery, idToken, nonce string) (jwt.MapClaims, error) {
+	claims := jwt.MapClaims{}
+	_, err := jwt.ParseWithClaims(idToken, claims, func(token *jwt.Token) (interface{}, error) {
+		kid, _ := token.Header["kid"].(string)
+		return s.getKey(disc, kid)
+	},
+		jwt.WithValidMethods([]string{"RS256", "RS384", "RS512", "ES256", "ES384"}),
+		jwt.WithIssuer(disc.Issuer),
+		jwt.WithAudience(s.cfg.OIDCClientID),
+		jwt.WithExpirationRequired(),
+		jwt.WithLeeway(time.Minute),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("ID Token 校验失败: %w", err)
+	}
+
+	if got, _ := claims["nonce"].(string); got != nonce {
+		return nil, errors.New("ID Token nonce 校验失败")
+	}
+	return claims, nil
+}
+
+// identityFromClaims 从声明中提取外部身份信息
+func (s *OIDCService) identityFromClaims(disc *oidcDiscovery, claims jwt.MapClaims) *ExternalIdentity {
+	ext := &ExternalIdentity{Provider: disc.Issuer}
+	ext.Subject, _ = claims["sub"].(string)
+	ext.Email, _ = claims["email"].(string)
+	ext.Name, _ = claims["name"].(string)
+	if ext.Name == "" {
+		ext.Name, _ = claims["preferred_username"].(string)
+	}
+
+	switch v := claims["email_verified"].(type) {
+	case bool:
+		ext.EmailVerified = v
+	case string:
+		ext.EmailVerified = v == "true"
+	}
+
+	switch groups := claims[s.cfg.OIDCGroupsClaim].(type) {
+	case []interface{}:
+		ext.Groups = []string{}
+		for _, g := range groups {
+			if name, ok := g.(string); ok {
+				ext.Groups = append(ext.Groups, name)
+			}
+		}
+	case string:
+		ext.Groups = strings.Fields(strings.ReplaceAll(groups, ",", " "))
+	}
+	return ext
+}
+
+// fetchUserinfo 请求 userinfo 端点补全用户信息
+func (s *OIDCService) fetchUserinfo(disc *oidcDiscovery, accessToken string) (map[string]interface{}, error) {
+	req, err := http.NewRequest("GET", disc.UserinfoEndpoint, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Authorization", "Bearer "+accessToken)
+
+	var info map[string]interface{}
+	if err := s.getJSON(req, &info); err != nil {
+		return nil, err
+	}
+	return info, nil
+}
+
+// getDiscovery 获取（并缓存）身份源配置
+func (s *OIDCService) getDiscovery() (*oidcDiscovery, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.discovery != nil {
+		return s.discovery, nil
+	}
+
+	req, err := http.NewRequest("GET", s.cfg.OIDCIssuer+"/.well-known/openid-configuration", nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var disc oidcDiscovery
+	if err := s.getJSON(req, &disc); err != nil {
+		return nil, fmt.Errorf("获取 OIDC 配置失败: %w", err)
+	}
+	if strings.TrimRight(disc.Issuer, "/") != s.cfg.OIDCIssuer {
+		return nil, fmt.Errorf("OIDC issuer 不匹配: %s", disc.Issuer)
+	}
+	if disc.AuthorizationEndpoint == "" || disc.TokenEndpoint == "" || disc.JWKSURI == "" {
+		return nil, errors.New("OIDC 配置缺少必要的端点")
+	}
+
+	s.discovery = &disc
+	return s.discovery, nil
+}
+
+// getKey 根据 kid 获取签名公钥，未知 kid 时刷新 JWKS（限制刷新频率）
+func (s *OIDCService) getKey(disc *oidcDiscovery, kid string) (interface{}, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if key, ok := s.lookupKey(kid); ok {
+		return key, nil
+	}
+	if time.Since(s.keysAt) < 30*time.Second {
+		return nil, fmt.Errorf("未知的签名密钥: %s", kid)
+	}
+
+	req, err := http.NewRequest("GET", disc.JWKSURI, nil)
+	if err != nil {
+		return nil, err
+	}
+	var jwks struct {
+		Keys []jsonWebKey `json:"keys"`
+	}
+	if err := s.getJSON(req, &jwks); err != nil {
+		return nil, fmt.Errorf("获取 JWKS 失败: %w", err)
+	}
+
+	keys := make(map[string]interface{})
+	for _, k := range jwks.Keys {
+		if k.Use != "" && k.Use != "sig" {
+			continue
+		}
+		if key, err := k.publicKey(); err == nil {
+			keys[k.Kid] = key
+		}
+	}
+	s.keys = keys
+	s.keysAt = time.Now()
+
+	if key, ok := s.lookupKey(kid); ok {
+		return key, nil
+	}
+	return nil, fmt.Errorf("未知的签名密钥: %s", kid)
+}
+
+// lookupKey 查找缓存的公钥，ID Token 未指定 kid 且只有一个密钥时直接使用
+func (s *OIDCService) lookupKey(kid string) (interface{}, bool) {
+	if kid == "" && len(s.keys) == 1 {
+		for _, key := range s.keys {
+			return key, true
+		}
+	}
+	key, ok := s.keys[kid]
+	return key, ok
+}
+
+// getJSON 发送请求并解析 JSON 响应
+func (s *OIDCService) getJSON(req *http.Request, v interface{}) error {
+	req.Header.Set("Accept", "application/json")
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("HTTP %d: %s", resp.StatusCode, string(body))
+	}
+	return json.Unmarshal(body, v)
+}
+
+// jsonWebKey JWKS 中的单个公钥
+type jsonWebKey struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+// publicKey 将 JWK 转换为公钥
+func (k *jsonWebKey) publicKey() (interface{}, error) {
+	switch k.Kty {
+	case "RSA":
+		n, err := base64.RawURLEncoding.DecodeString(k.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := base64.RawURLEncoding.DecodeString(k.E)
+		if err != nil {
+			return nil, err
+		}
+		return &rsa.PublicKey{
+			N: new(big.Int).SetBytes(n),
+			E: int(new(big.Int).SetBytes(e).Int64()),
+		}, nil
+
+	case "EC":
+		var curve elliptic.Curve
+		switch k.Crv {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		default:
+			return nil, fmt.Errorf("不支持的曲线: %s", k.Crv)
+		}
+		x, err := base64.RawURLEncoding.DecodeString(k.X)
+		if err != nil {
+			return nil, err
+		}
+		y, err := base64.RawURLEncoding.DecodeString(k.Y)
+		if err != nil {
+			return nil, err
+		}
+		return &ecdsa.PublicKey{
+			Curve: curve,
+			X:     new(big.Int).SetBytes(x),
+			Y:     new(big.Int).SetBytes(y),
+		}, nil
+
+	default:
+		return nil, fmt.Errorf("不支持的密钥类型: %s", k.Kty)
+	}
+}
+
+// randomURLString 生成 URL 安全的随机字符串
+func randomURLString(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("生成随机数失败: %w", err)
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
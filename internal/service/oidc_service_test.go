@@ -0,0 +1,351 @@
+package service
+
+import (
+	"ai-chat/config"
+	"ai-chat/internal/common"
+	"ai-chat/internal/repository"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// testIdP 模拟的 OpenID Connect 身份提供方，支持授权码 + PKCE
+type testIdP struct {
+	server   *httptest.Server
+	key      *rsa.PrivateKey
+	signer   *rsa.PrivateKey // 签发 ID Token 使用的密钥，默认为 key
+	claims   func(claims jwt.MapClaims)
+	userinfo map[string]interface{}
+
+	challenge string // 授权请求中的 code_challenge
+	nonce     string
+}
+
+// startTestIdP 启动模拟身份提供方
+func startTestIdP(t *testing.T) *testIdP {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	idp := &testIdP{key: key, signer: key}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		writeTestJSON(w, oidcDiscovery{
+			Issuer:                idp.server.URL,
+			AuthorizationEndpoint: idp.server.URL + "/authorize",
+			TokenEndpoint:         idp.server.URL + "/token",
+			UserinfoEndpoint:      idp.server.URL + "/userinfo",
+			JWKSURI:               idp.server.URL + "/jwks",
+		})
+	})
+	mux.HandleFunc("/jwks", func(w http.ResponseWriter, r *http.Request) {
+		writeTestJSON(w, map[string]interface{}{"keys": []jsonWebKey{{
+			Kty: "RSA",
+			Kid: "k1",
+			Use: "sig",
+			N:   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+			E:   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+		}}})
+	})
+	mux.HandleFunc("/token", idp.token)
+	mux.HandleFunc("/userinfo", func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer access-token" || idp.userinfo == nil {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		writeTestJSON(w, idp.userinfo)
+	})
+
+	idp.server = httptest.NewServer(mux)
+	t.Cleanup(idp.server.Close)
+	return idp
+}
+
+// token 令牌端点：校验客户端凭据、授权码和 PKCE verifier 后签发 ID Token
+func (idp *testIdP) token(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if id, secret, _ := r.BasicAuth(); id != "aichat" || secret != "client-secret" {
+		http.Error(w, `{"error":"invalid_client"}`, http.StatusUnauthorized)
+		return
+	}
+	verifier := sha256.Sum256([]byte(r.PostForm.Get("code_verifier")))
+	if r.PostForm.Get("grant_type") != "authorization_code" || r.PostForm.Get("code") != "auth-code" ||
+		r.PostForm.Get("redirect_uri") != "https://chat.example.com/api/v1/auth/oidc/callback" ||
+		base64.RawURLEncoding.EncodeToString(verifier[:]) != idp.challenge {
+		http.Error(w, `{"error":"invalid_grant"}`, http.StatusBadRequest)
+		return
+	}
+
+	now := time.Now()
+	claims := jwt.MapClaims{
+		"iss":            idp.server.URL,
+		"aud":            "aichat",
+		"sub":            "user-1",
+		"iat":            now.Unix(),
+		"exp":            now.Add(time.Hour).Unix(),
+		"nonce":          idp.nonce,
+		"email":          "alice@example.com",
+		"email_verified": true,
+		"name":           "Alice",
+		"groups":         []string{"staff", "aichat-admins"},
+	}
+	if idp.claims != nil {
+		idp.claims(claims)
+	}
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+	token.Header["kid"] = "k1"
+	idToken, err := token.SignedString(idp.signer)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	writeTestJSON(w, oidcTokenResponse{AccessToken: "access-token", IDToken: idToken, TokenType: "Bearer"})
+}
+
+func writeTestJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(v)
+}
+
+// newTestOIDCService 创建连接模拟身份提供方的 OIDC 服务
+func newTestOIDCService(t *testing.T, idp *testIdP) *OIDCService {
+	t.Helper()
+	s, err := NewOIDCService(nil, &config.Config{
+		JWTSecret:        "test-secret",
+		OIDCIssuer:       idp.server.URL,
+		OIDCClientID:     "aichat",
+		OIDCClientSecret: "client-secret",
+		OIDCRedirectURL:  "https://chat.example.com/api/v1/auth/oidc/callback",
+		OIDCScopes:       "openid email profile",
+		OIDCGroupsClaim:  "groups",
+	}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+// authorize 发起登录并模拟浏览器在身份提供方完成授权，返回流程令牌和 state
+func (idp *testIdP) authorize(t *testing.T, s *OIDCService) (string, string) {
+	t.Helper()
+	begin, err := s.BeginLogin()
+	if err != nil {
+		t.Fatal(err)
+	}
+	authURL, err := url.Parse(begin.AuthURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	query := authURL.Query()
+	if authURL.Path != "/authorize" || query.Get("client_id") != "aichat" || query.Get("code_challenge_method") != "S256" {
+		t.Fatalf("authorization URL = %s", begin.AuthURL)
+	}
+	idp.challenge = query.Get("code_challenge")
+	idp.nonce = query.Get("nonce")
+	return begin.FlowToken, query.Get("state")
+}
+
+func TestOIDCAuthenticate(t *testing.T) {
+	idp := startTestIdP(t)
+	s := newTestOIDCService(t, idp)
+
+	flowToken, state := idp.authorize(t, s)
+	ext, err := s.authenticate(flowToken, state, "auth-code")
+	if err != nil {
+		t.Fatalf("authenticate: %v", err)
+	}
+	want := &ExternalIdentity{
+		Provider:      idp.server.URL,
+		Subject:       "user-1",
+		Email:         "alice@example.com",
+		EmailVerified: true,
+		Name:          "Alice",
+		Groups:        []string{"staff", "aichat-admins"},
+	}
+	if ext.Provider != want.Provider || ext.Subject != want.Subject || ext.Email != want.Email ||
+		ext.EmailVerified != want.EmailVerified || ext.Name != want.Name || !slices.Equal(ext.Groups, want.Groups) {
+		t.Fatalf("identity = %+v, want %+v", ext, want)
+	}
+}
+
+func TestOIDCAuthenticateRejects(t *testing.T) {
+	idp := startTestIdP(t)
+	s := newTestOIDCService(t, idp)
+	other, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name   string
+		state  func(state string) string
+		code   string
+		claims func(claims jwt.MapClaims)
+		signer *rsa.PrivateKey
+	}{
+		{name: "state mismatch", state: func(string) string { return "forged" }},
+		{name: "missing state", state: func(string) string { return "" }},
+		{name: "wrong code", code: "stolen-code"},
+		{name: "nonce mismatch", claims: func(c jwt.MapClaims) { c["nonce"] = "replayed" }},
+		{name: "wrong audience", claims: func(c jwt.MapClaims) { c["aud"] = "other-client" }},
+		{name: "wrong issuer", claims: func(c jwt.MapClaims) { c["iss"] = "https://evil.example.com" }},
+		{name: "expired", claims: func(c jwt.MapClaims) { c["exp"] = time.Now().Add(-time.Hour).Unix() }},
+		{name: "missing expiry", claims: func(c jwt.MapClaims) { delete(c, "exp") }},
+		{name: "unknown signing key", signer: other},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			idp.claims, idp.signer = tt.claims, idp.key
+			if tt.signer != nil {
+				idp.signer = tt.signer
+			}
+			flowToken, state := idp.authorize(t, s)
+			if tt.state != nil {
+				state = tt.state(state)
+			}
+			code := "auth-code"
+			if tt.code != "" {
+				code = tt.code
+			}
+			if ext, err := s.authenticate(flowToken, state, code); err == nil {
+				t.Fatalf("authenticate accepted: %+v", ext)
+			}
+		})
+	}
+
+	// 另一个流程的令牌不能完成本次回调（PKCE verifier 与授权请求不匹配）
+	idp.claims, idp.signer = nil, idp.key
+	firstFlow, firstState := idp.authorize(t, s)
+	idp.authorize(t, s)
+	if _, err := s.authenticate(firstFlow, firstState, "auth-code"); err == nil {
+		t.Fatal("callback completed with a flow token from another authorization request")
+	}
+}
+
+func TestOIDCAuthenticateUserinfo(t *testing.T) {
+	idp := startTestIdP(t)
+	s := newTestOIDCService(t, idp)
+	idp.claims = func(c jwt.MapClaims) {
+		delete(c, "email")
+		delete(c, "email_verified")
+		delete(c, "name")
+	}
+
+	idp.userinfo = map[string]interface{}{"sub": "user-1", "email": "alice@example.com", "email_verified": true, "name": "Alice"}
+	flowToken, state := idp.authorize(t, s)
+	ext, err := s.authenticate(flowToken, state, "auth-code")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ext.Email != "alice@example.com" || !ext.EmailVerified || ext.Name != "Alice" {
+		t.Fatalf("identity = %+v, want userinfo claims", ext)
+	}
+
+	// userinfo 的 sub 与 ID Token 不一致时忽略
+	idp.userinfo = map[string]interface{}{"sub": "user-2", "email": "mallory@example.com", "email_verified": true}
+	flowToken, state = idp.authorize(t, s)
+	ext, err = s.authenticate(flowToken, state, "auth-code")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ext.Email != "" {
+		t.Fatalf("email = %q taken from another subject's userinfo", ext.Email)
+	}
+}
+
+func TestMapGroupsToRole(t *testing.T) {
+	mapping := map[string]string{
+		"aichat-admins":   common.RoleAdmin,
+		"aichat-auditors": common.RoleAuditor,
+		"staff":           common.RoleUser,
+	}
+	tests := []struct {
+		groups  []string
+		role    string
+		matched bool
+	}{
+		{[]string{"staff"}, common.RoleUser, true},
+		{[]string{"staff", "aichat-admins"}, common.RoleAdmin, true},
+		{[]string{"aichat-admins", "aichat-auditors"}, common.RoleAdmin, true},
+		{[]string{"aichat-auditors", "staff"}, common.RoleAuditor, true},
+		{[]string{"contractors"}, common.RoleUser, true},
+		{[]string{}, common.RoleUser, true},
+		{nil, "", false},
+	}
+	for _, tt := range tests {
+		role, matched := MapGroupsToRole(tt.groups, mapping)
+		if matched != tt.matched || (matched && role != tt.role) {
+			t.Errorf("MapGroupsToRole(%v) = %q, %v; want %q, %v", tt.groups, role, matched, tt.role, tt.matched)
+		}
+	}
+	if _, matched := MapGroupsToRole([]string{"staff"}, nil); matched {
+		t.Error("MapGroupsToRole without mapping should not sync role")
+	}
+}
+
+// TestResolveExternalUserRoleMapping 组映射同步角色，未下发组时保留原角色，没有匹配的组时降为普通用户
+func TestResolveExternalUserRoleMapping(t *testing.T) {
+	db := openTestDB(t)
+	subject := fmt.Sprintf("user-%d", time.Now().UnixNano())
+	ext := &ExternalIdentity{
+		Provider:      "https://idp.example.com",
+		Subject:       subject,
+		Email:         subject + "@example.com",
+		EmailVerified: true,
+		Groups:        []string{"aichat-admins"},
+	}
+	opts := ExternalUserOptions{
+		AutoProvision: true,
+		RoleMapping:   map[string]string{"aichat-admins": common.RoleAdmin, "staff": common.RoleUser},
+	}
+
+	user, err := ResolveExternalUser(db, ext, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		db.Where("user_id = ?", user.ID).Delete(&repository.UserIdentity{})
+		db.Unscoped().Delete(&repository.User{}, user.ID)
+	})
+
+	steps := []struct {
+		groups []string
+		want   string
+	}{
+		{[]string{"aichat-admins"}, common.RoleAdmin},
+		{nil, common.RoleAdmin},
+		{[]string{"contractors"}, common.RoleUser},
+		{[]string{"aichat-admins"}, common.RoleAdmin},
+		{[]string{}, common.RoleUser},
+	}
+	for _, step := range steps {
+		ext.Groups = step.groups
+		if user, err = ResolveExternalUser(db, ext, opts); err != nil {
+			t.Fatal(err)
+		}
+		var stored repository.User
+		if err := db.First(&stored, user.ID).Error; err != nil {
+			t.Fatal(err)
+		}
+		if user.Role != step.want || stored.Role != step.want {
+			t.Fatalf("groups %v: role = %q (stored %q), want %q", step.groups, user.Role, stored.Role, step.want)
+		}
+	}
+}
@@ -0,0 +1,169 @@
+package service
+
+import (
+	"ai-chat/internal/dto"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// 模板变量类型
+const (
+	PromptVariableText   = "text"
+	PromptVariableNumber = "number"
+	PromptVariableSelect = "select"
+)
+
+// maxPromptVariableLength 单个变量取值的最大字符数
+const maxPromptVariableLength = 2000
+
+// ErrPromptTemplate 提示词模板或变量取值无效
+var ErrPromptTemplate = errors.New("提示词模板无效")
+
+// promptPlaceholder 匹配模板中的 {{name}} 和 {{user.name}}，花括号内允许空白
+var promptPlaceholder = regexp.MustCompile(`\{\{\s*([A-Za-z_][A-Za-z0-9_]*(?:\.[A-Za-z_][A-Za-z0-9_]*)?)\s*\}\}`)
+
+// promptVariableName 自定义变量名，不允许包含点号以免与内置变量冲突
+var promptVariableName = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// BuiltinPromptVariables 内置变量，渲染时由服务端填充，不能被自定义变量覆盖
+var BuiltinPromptVariables = []string{"today", "now", "weekday", "user.name", "user.email", "workspace.name"}
+
+// promptTemplateVariables 返回模板中引用的变量名，按首次出现的顺序去重
+func promptTemplateVariables(content string) []string {
+	var names []string
+	for _, match := range promptPlaceholder.FindAllStringSubmatch(content, -1) {
+		if !slices.Contains(names, match[1]) {
+			names = append(names, match[1])
+		}
+	}
+	return names
+}
+
+// validatePromptTemplate 校验变量定义，并确保模板引用的变量都已定义或为内置变量
+func validatePromptTemplate(content string, variables []dto.PromptVariable) error {
+	defined := make(map[string]bool, len(variables))
+	for _, v := range variables {
+		if !promptVariableName.MatchString(v.Name) {
+			return fmt.Errorf("%w: 变量名 %s 只能包含字母、数字和下划线，且不能以数字开头", ErrPromptTemplate, v.Name)
+		}
+		if slices.Contains(BuiltinPromptVariables, v.Name) {
+			return fmt.Errorf("%w: 变量名 %s 与内置变量重名", ErrPromptTemplate, v.Name)
+		}
+		if defined[v.Name] {
+			return fmt.Errorf("%w: 变量 %s 重复定义", ErrPromptTemplate, v.Name)
+		}
+		defined[v.Name] = true
+
+		if v.Type == PromptVariableSelect && len(v.Options) == 0 {
+			return fmt.Errorf("%w: 选择类型的变量 %s 需要设置可选值", ErrPromptTemplate, v.Name)
+		}
+		if v.Type != PromptVariableSelect && len(v.Options) > 0 {
+			return fmt.Errorf("%w: 只有选择类型的变量可以设置可选值", ErrPromptTemplate)
+		}
+		if v.Default != nil {
+			if err := checkPromptVariable(v, *v.Default); err != nil {
+				return fmt.Errorf("%w（默认值）", err)
+			}
+		}
+	}
+
+	for _, name := range promptTemplateVariables(content) {
+		if !defined[name] && !slices.Contains(BuiltinPromptVariables, name) {
+			return fmt.Errorf("%w: 模板引用了未定义的变量 %s", ErrPromptTemplate, name)
+		}
+	}
+	return nil
+}
+
+// checkPromptVariable 按变量类型校验取值
+func checkPromptVariable(v dto.PromptVariable, value string) error {
+	if utf8.RuneCountInString(value) > maxPromptVariableLength {
+		return fmt.Errorf("%w: 变量 %s 不能超过 %d 个字符", ErrPromptTemplate, v.Name, maxPromptVariableLength)
+	}
+	switch v.Type {
+	case PromptVariableNumber:
+		if _, err := strconv.ParseFloat(strings.TrimSpace(value), 64); err != nil {
+			return fmt.Errorf("%w: 变量 %s 必须是数字", ErrPromptTemplate, v.Name)
+		}
+	case PromptVariableSelect:
+		if !slices.Contains(v.Options, value) {
+			return fmt.Errorf("%w: 变量 %s 的取值必须是 %s 之一", ErrPromptTemplate, v.Name, strings.Join(v.Options, "、"))
+		}
+	}
+	return nil
+}
+
+// renderPromptTemplate 用变量取值渲染模板，未提供的变量使用默认值，必填变量缺失或取值无效时返回错误
+// 模板中未定义的占位符（定义变量之前保存的旧提示词）保持原样
+func renderPromptTemplate(content string, variables []dto.PromptVariable, values map[string]string, builtins map[string]string) (string, error) {
+	resolved := make(map[string]string, len(variables)+len(builtins))
+	for name, value := range builtins {
+		resolved[name] = value
+	}
+
+	for name := range values {
+		if !slices.ContainsFunc(variables, func(v dto.PromptVariable) bool { return v.Name == name }) {
+			return "", fmt.Errorf("%w: 提示词没有变量 %s", ErrPromptTemplate, name)
+		}
+	}
+
+	for _, v := range variables {
+		value, ok := values[v.Name]
+		if (!ok || strings.TrimSpace(value) == "") && v.Default != nil {
+			value, ok = *v.Default, true
+		}
+		if !ok || strings.TrimSpace(value) == "" {
+			if v.Required {
+				return "", fmt.Errorf("%w: 缺少必填变量 %s", ErrPromptTemplate, v.Name)
+			}
+			resolved[v.Name] = ""
+			continue
+		}
+		if err := checkPromptVariable(v, value); err != nil {
+			return "", err
+		}
+		resolved[v.Name] = value
+	}
+
+	return promptPlaceholder.ReplaceAllStringFunc(content, func(placeholder string) string {
+		name := promptPlaceholder.FindStringSubmatch(placeholder)[1]
+		if value, ok := resolved[name]; ok {
+			return value
+		}
+		return placeholder
+	}), nil
+}
+
+// timePromptVariables 日期相关的内置变量，使用服务器时区
+func timePromptVariables(now time.Time) map[string]string {
+	return map[string]string{
+		"today":   now.Format("2006-01-02"),
+		"now":     now.Format("2006-01-02 15:04"),
+		"weekday": now.Weekday().String(),
+	}
+}
+
+// encodePromptVariables 将变量定义编码为 JSON 数组
+func encodePromptVariables(variables []dto.PromptVariable) string {
+	if variables == nil {
+		variables = []dto.PromptVariable{}
+	}
+	data, _ := json.Marshal(variables)
+	return string(data)
+}
+
+// decodePromptVariables 解析变量定义，格式错误时返回空列表
+func decodePromptVariables(data string) []dto.PromptVariable {
+	variables := []dto.PromptVariable{}
+	if data != "" {
+		_ = json.Unmarshal([]byte(data), &variables)
+	}
+	return variables
+}
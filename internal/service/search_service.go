@@ -0,0 +1,231 @@
+package service
+
+import (
+	"ai-chat/internal/policy"
+	"ai-chat/internal/repository"
+	"fmt"
+	"html"
+	"strings"
+	"time"
+	"unicode"
+
+	"gorm.io/gorm"
+)
+
+const (
+	// maxSearchTerms 查询词数量上限
+	maxSearchTerms = 10
+	// snippetWidth 高亮片段的字符数
+	snippetWidth = 160
+)
+
+// SearchService 消息全文检索服务
+// 检索 messages.search_vector 生成列（见 repository.migrateMessageSearch），高亮片段在应用层生成以正确处理中文
+type SearchService struct {
+	db    *gorm.DB
+	authz *Authorizer
+}
+
+// NewSearchService 创建消息检索服务
+func NewSearchService(db *gorm.DB) *SearchService {
+	return &SearchService{
+		db:    db,
+		authz: NewAuthorizer(db),
+	}
+}
+
+// MessageSearchQuery 消息检索条件，多个查询词之间为“且”关系，每个词内的字符按短语匹配
+type MessageSearchQuery struct {
+	Q              string     `form:"q" binding:"required,max=200"`
+	Role           string     `form:"role" binding:"omitempty,oneof=user assistant system"`
+	Model          string     `form:"model"`
+	ConversationID uint       `form:"conversation_id"`
+	From           *time.Time `form:"from" time_format:"2006-01-02"`
+	To             *time.Time `form:"to" time_format:"2006-01-02"` // 包含当天
+	Page           int        `form:"page,default=1" binding:"min=1"`
+	PageSize       int        `form:"page_size,default=20" binding:"min=1,max=100"`
+}
+
+// MessageSearchResult 消息检索结果
+type MessageSearchResult struct {
+	MessageID        uint      `json:"messageId"`
+	ConversationID   uint      `json:"conversationId"`
+	ConversationName string    `json:"conversationName"`
+	Type             string    `json:"type"`
+	Model            *string   `json:"model"`
+	CreatedAt        time.Time `json:"createdAt"`
+	Rank             float64   `json:"rank"`
+	Field            string    `json:"field"`   // 片段来源：content 或 reasoning
+	Snippet          string    `json:"snippet"` // 已做 HTML 转义，匹配处用 <mark> 标记
+	Anchor           string    `json:"anchor"`  // 会话页面中定位该消息的锚点
+}
+
+// messageSearchRow 检索查询的结果行
+type messageSearchRow struct {
+	ID               uint
+	ConversationID   uint
+	ConversationName string
+	Type             string
+	Model            *string
+	Content          string
+	ReasoningContent string
+	CreatedAt        time.Time
+	Rank             float64
+}
+
+// Search 在当前空间可读的会话中检索消息内容和思考内容，按相关度排序
+func (s *SearchService) Search(p policy.Principal, q *MessageSearchQuery) ([]*MessageSearchResult, int64, error) {
+	if err := s.authz.WorkspaceContent(p, policy.ActionRead); err != nil {
+		return nil, 0, err
+	}
+
+	terms := strings.Fields(q.Q)
+	if len(terms) > maxSearchTerms {
+		terms = terms[:maxSearchTerms]
+	}
+	tsquery, args := searchTSQuery(terms)
+
+	query := s.db.Model(&repository.Message{}).
+		Joins("JOIN conversations ON conversations.id = messages.conversation_id AND conversations.deleted_at IS NULL").
+		Scopes(s.authz.ConversationScope(p)).
+		Where("messages.search_vector @@ ("+tsquery+")", args...)
+
+	if q.Role != "" {
+		query = query.Where("messages.type = ?", q.Role)
+	}
+	if q.Model != "" {
+		query = query.Where("messages.model = ?", q.Model)
+	}
+	if q.ConversationID != 0 {
+		query = query.Where("messages.conversation_id = ?", q.ConversationID)
+	}
+	if q.From != nil {
+		query = query.Where("messages.created_at >= ?", *q.From)
+	}
+	if q.To != nil {
+		query = query.Where("messages.created_at < ?", q.To.AddDate(0, 0, 1))
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, fmt.Errorf("检索消息失败: %w", err)
+	}
+
+	var rows []*messageSearchRow
+	if err := query.
+		Select("messages.id, messages.conversation_id, conversations.name AS conversation_name, "+
+			"messages.type, messages.model, messages.content, messages.reasoning_content, messages.created_at, "+
+			"ts_rank_cd(messages.search_vector, ("+tsquery+")) AS rank", args...).
+		Order("rank DESC, messages.created_at DESC").
+		Offset((q.Page - 1) * q.PageSize).Limit(q.PageSize).
+		Scan(&rows).Error; err != nil {
+		return nil, 0, fmt.Errorf("检索消息失败: %w", err)
+	}
+
+	results := make([]*MessageSearchResult, len(rows))
+	for i, row := range rows {
+		field, snippet := "content", highlightSnippet(row.Content, terms)
+		if !containsAnyFold(row.Content, terms) && containsAnyFold(row.ReasoningContent, terms) {
+			field, snippet = "reasoning", highlightSnippet(row.ReasoningContent, terms)
+		}
+		results[i] = &MessageSearchResult{
+			MessageID:        row.ID,
+			ConversationID:   row.ConversationID,
+			ConversationName: row.ConversationName,
+			Type:             row.Type,
+			Model:            row.Model,
+			CreatedAt:        row.CreatedAt,
+			Rank:             row.Rank,
+			Field:            field,
+			Snippet:          snippet,
+			Anchor:           fmt.Sprintf("message-%d", row.ID),
+		}
+	}
+	return results, total, nil
+}
+
+// searchTSQuery 生成 tsquery 表达式，每个查询词按短语匹配，与索引一致地切分中日韩字符
+func searchTSQuery(terms []string) (string, []interface{}) {
+	parts := make([]string, len(terms))
+	args := make([]interface{}, len(terms))
+	for i, term := range terms {
+		parts[i] = "phraseto_tsquery('simple', search_split_cjk(?))"
+		args[i] = term
+	}
+	return strings.Join(parts, " && "), args
+}
+
+// containsAnyFold 文本是否包含任一查询词（忽略大小写）
+func containsAnyFold(text string, terms []string) bool {
+	lower := strings.ToLower(text)
+	for _, term := range terms {
+		if strings.Contains(lower, strings.ToLower(term)) {
+			return true
+		}
+	}
+	return false
+}
+
+// highlightSnippet 截取第一个匹配附近的片段，转义 HTML 后用 <mark> 标记所有匹配
+func highlightSnippet(text string, terms []string) string {
+	// 换行压缩为空格，逐字符转小写以保持下标对应
+	runes := []rune(text)
+	lower := make([]rune, len(runes))
+	for i, r := range runes {
+		if unicode.IsSpace(r) {
+			runes[i] = ' '
+		}
+		lower[i] = unicode.ToLower(runes[i])
+	}
+
+	marked := make([]bool, len(runes))
+	first := -1
+	for _, term := range terms {
+		needle := []rune(strings.ToLower(term))
+		if len(needle) == 0 {
+			continue
+		}
+		for i := 0; i+len(needle) <= len(lower); i++ {
+			if string(lower[i:i+len(needle)]) != string(needle) {
+				continue
+			}
+			for j := i; j < i+len(needle); j++ {
+				marked[j] = true
+			}
+			if first == -1 || i < first {
+				first = i
+			}
+		}
+	}
+
+	start := 0
+	if first > snippetWidth/3 {
+		start = first - snippetWidth/3
+	}
+	end := start + snippetWidth
+	if end > len(runes) {
+		end = len(runes)
+	}
+
+	var b strings.Builder
+	if start > 0 {
+		b.WriteString("…")
+	}
+	for i := start; i < end; {
+		j := i
+		for j < end && marked[j] == marked[i] {
+			j++
+		}
+		segment := html.EscapeString(string(runes[i:j]))
+		if marked[i] {
+			b.WriteString("<mark>" + segment + "</mark>")
+		} else {
+			b.WriteString(segment)
+		}
+		i = j
+	}
+	if end < len(runes) {
+		b.WriteString("…")
+	}
+	return b.String()
+}
@@ -0,0 +1,435 @@
+package service
+
+import (
+	"ai-chat/internal/embedding"
+	"ai-chat/internal/policy"
+	"ai-chat/internal/repository"
+	"context"
+	"crypto/md5"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	// embeddingBatchSize 每次调用向量接口的消息数量
+	embeddingBatchSize = 32
+	// maxEmbeddingRunes 参与向量化的最大字符数，超出部分截断
+	maxEmbeddingRunes = 8000
+	// semanticSnippetRunes 语义检索结果中返回的内容字符数
+	semanticSnippetRunes = 300
+	// embeddingRetryBase 消息向量计算失败后首次重试的等待时间，之后每次翻倍
+	embeddingRetryBase = time.Minute
+	// embeddingRetryMax 失败重试的最长等待时间
+	embeddingRetryMax = 24 * time.Hour
+)
+
+// SemanticSearchService 基于向量的语义检索服务
+// 后台任务持续为新消息和修改过的消息计算向量；安装了 pgvector 时由数据库排序，否则在进程内逐条计算相似度
+type SemanticSearchService struct {
+	db       *gorm.DB
+	provider embedding.Provider
+	authz    *Authorizer
+	pgvector bool
+}
+
+// NewSemanticSearchService 创建语义检索服务
+func NewSemanticSearchService(db *gorm.DB, provider embedding.Provider) *SemanticSearchService {
+	return &SemanticSearchService{
+		db:       db,
+		provider: provider,
+		authz:    NewAuthorizer(db),
+		pgvector: repository.HasVectorColumn(db),
+	}
+}
+
+// SemanticSearchQuery 语义检索条件
+type SemanticSearchQuery struct {
+	Q     string `form:"q" binding:"required,max=500"`
+	Limit int    `form:"limit,default=10" binding:"min=1,max=50"`
+}
+
+// SemanticSearchResult 语义检索结果
+type SemanticSearchResult struct {
+	MessageID        uint      `json:"messageId"`
+	ConversationID   uint      `json:"conversationId"`
+	ConversationName string    `json:"conversationName"`
+	Type             string    `json:"type"`
+	Model            *string   `json:"model"`
+	Content          string    `json:"content"` // 消息开头部分
+	Score            float64   `json:"score"`   // 余弦相似度
+	CreatedAt        time.Time `json:"createdAt"`
+	Anchor           string    `json:"anchor"`
+}
+
+// vectorHit 向量检索命中的消息
+type vectorHit struct {
+	MessageID uint
+	Score     float64
+}
+
+// Search 在当前空间可读的会话中检索与查询语义最接近的消息
+func (s *SemanticSearchService) Search(ctx context.Context, p policy.Principal, q *SemanticSearchQuery) ([]*SemanticSearchResult, error) {
+	if err := s.authz.WorkspaceContent(p, policy.ActionRead); err != nil {
+		return nil, err
+	}
+
+	vectors, err := s.provider.Embed(ctx, []string{q.Q})
+	if err != nil {
+		return nil, fmt.Errorf("计算查询向量失败: %w", err)
+	}
+	if len(vectors) != 1 || len(vectors[0]) == 0 {
+		return nil, fmt.Errorf("计算查询向量失败: 结果为空")
+	}
+
+	var hits []vectorHit
+	if s.pgvector {
+		hits, err = s.searchPgvector(p, vectors[0], q.Limit)
+	} else {
+		hits, err = s.searchInProcess(p, vectors[0], q.Limit)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return s.loadResults(hits)
+}
+
+// searchPgvector 由 pgvector 按余弦距离排序
+func (s *SemanticSearchService) searchPgvector(p policy.Principal, vector []float32, limit int) ([]vectorHit, error) {
+	literal := vectorLiteral(vector)
+
+	var hits []vectorHit
+	err := s.scopedEmbeddings(p, len(vector)).
+		Where("message_embeddings.embedding IS NOT NULL").
+		Select("message_embeddings.message_id, 1 - (message_embeddings.embedding <=> ?::vector) AS score", literal).
+		Order("score DESC").
+		Limit(limit).
+		Scan(&hits).Error
+	if err != nil {
+		return nil, fmt.Errorf("语义检索失败: %w", err)
+	}
+	return hits, nil
+}
+
+// searchInProcess 读取当前空间的全部向量并在进程内计算相似度
+func (s *SemanticSearchService) searchInProcess(p policy.Principal, vector []float32, limit int) ([]vectorHit, error) {
+	var rows []struct {
+		MessageID uint
+		Vector    []byte
+	}
+	if err := s.scopedEmbeddings(p, len(vector)).
+		Select("message_embeddings.message_id, message_embeddings.vector").
+		Scan(&rows).Error; err != nil {
+		return nil, fmt.Errorf("语义检索失败: %w", err)
+	}
+
+	hits := make([]vectorHit, 0, len(rows))
+	for _, row := range rows {
+		hits = append(hits, vectorHit{
+			MessageID: row.MessageID,
+			Score:     cosineSimilarity(vector, decodeVector(row.Vector)),
+		})
+	}
+	sort.Slice(hits, func(i, j int) bool { return hits[i].Score > hits[j].Score })
+	if len(hits) > limit {
+		hits = hits[:limit]
+	}
+	return hits, nil
+}
+
+// scopedEmbeddings 当前空间中、由当前模型计算的向量
+func (s *SemanticSearchService) scopedEmbeddings(p policy.Principal, dimensions int) *gorm.DB {
+	return s.db.Model(&repository.MessageEmbedding{}).
+		Joins("JOIN messages ON messages.id = message_embeddings.message_id AND messages.deleted_at IS NULL").
+		Joins("JOIN conversations ON conversations.id = messages.conversation_id AND conversations.deleted_at IS NULL").
+		Scopes(s.authz.ConversationScope(p)).
+		Where("message_embeddings.model = ? AND message_embeddings.dimensions = ?", s.provider.Model(), dimensions)
+}
+
+// loadResults 按命中顺序加载消息和会话信息
+func (s *SemanticSearchService) loadResults(hits []vectorHit) ([]*SemanticSearchResult, error) {
+	if len(hits) == 0 {
+		return []*SemanticSearchResult{}, nil
+	}
+
+	ids := make([]uint, len(hits))
+	for i, hit := range hits {
+		ids[i] = hit.MessageID
+	}
+
+	var rows []struct {
+		ID               uint
+		ConversationID   uint
+		ConversationName string
+		Type             string
+		Model            *string
+		Content          string
+		CreatedAt        time.Time
+	}
+	if err := s.db.Model(&repository.Message{}).
+		Joins("JOIN conversations ON conversations.id = messages.conversation_id").
+		Where("messages.id IN ?", ids).
+		Select("messages.id, messages.conversation_id, conversations.name AS conversation_name, " +
+			"messages.type, messages.model, messages.content, messages.created_at").
+		Scan(&rows).Error; err != nil {
+		return nil, fmt.Errorf("查询消息失败: %w", err)
+	}
+
+	byID := make(map[uint]int, len(rows))
+	for i, row := range rows {
+		byID[row.ID] = i
+	}
+
+	results := make([]*SemanticSearchResult, 0, len(hits))
+	for _, hit := range hits {
+		i, ok := byID[hit.MessageID]
+		if !ok {
+			continue
+		}
+		row := rows[i]
+		content := row.Content
+		if runes := []rune(content); len(runes) > semanticSnippetRunes {
+			content = string(runes[:semanticSnippetRunes]) + "…"
+		}
+		results = append(results, &SemanticSearchResult{
+			MessageID:        row.ID,
+			ConversationID:   row.ConversationID,
+			ConversationName: row.ConversationName,
+			Type:             row.Type,
+			Model:            row.Model,
+			Content:          content,
+			Score:            hit.Score,
+			CreatedAt:        row.CreatedAt,
+			Anchor:           fmt.Sprintf("message-%d", row.ID),
+		})
+	}
+	return results, nil
+}
+
+// RunIndexer 后台索引任务，按间隔处理尚未索引或内容已修改的消息，直到 ctx 结束
+func (s *SemanticSearchService) RunIndexer(ctx context.Context, interval time.Duration) {
+	if interval <= 0 {
+		interval = 30 * time.Second
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		for {
+			indexed, err := s.indexBatch(ctx)
+			if err != nil {
+				log.Printf("Semantic search indexing failed: %v", err)
+				break
+			}
+			if indexed < embeddingBatchSize {
+				break
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// indexBatch 为一批消息计算并保存向量，返回处理的消息数
+// 计算失败的消息记录失败次数并推迟重试，不会阻塞其余消息的索引
+func (s *SemanticSearchService) indexBatch(ctx context.Context) (int, error) {
+	model := s.provider.Model()
+	var messages []*repository.Message
+	if err := s.db.Model(&repository.Message{}).
+		Select("messages.*").
+		Joins("LEFT JOIN message_embeddings ON message_embeddings.message_id = messages.id "+
+			"AND message_embeddings.model = ? AND message_embeddings.content_hash = messages.content_hash", model).
+		Joins("LEFT JOIN message_embedding_failures ON message_embedding_failures.message_id = messages.id "+
+			"AND message_embedding_failures.model = ? AND message_embedding_failures.content_hash = messages.content_hash "+
+			"AND message_embedding_failures.retry_at > ?", model, time.Now()).
+		Where("message_embeddings.id IS NULL AND message_embedding_failures.message_id IS NULL").
+		Where("messages.content <> '' AND messages.type IN ?", []string{"user", "assistant"}).
+		Order("messages.id").
+		Limit(embeddingBatchSize).
+		Find(&messages).Error; err != nil {
+		return 0, fmt.Errorf("查询待索引消息失败: %w", err)
+	}
+	if len(messages) == 0 {
+		return 0, nil
+	}
+
+	vectors, err := s.embed(ctx, messages)
+	if err != nil {
+		return 0, err
+	}
+
+	for i, message := range messages {
+		if vectors[i] == nil {
+			continue
+		}
+		row := &repository.MessageEmbedding{
+			MessageID:      message.ID,
+			ConversationID: message.ConversationID,
+			Model:          model,
+			ContentHash:    messageContentHash(message.Content),
+			Dimensions:     len(vectors[i]),
+			Vector:         encodeVector(vectors[i]),
+		}
+
+		err := s.db.Transaction(func(tx *gorm.DB) error {
+			if err := tx.Clauses(clause.OnConflict{
+				Columns:   []clause.Column{{Name: "message_id"}},
+				DoUpdates: clause.AssignmentColumns([]string{"conversation_id", "model", "content_hash", "dimensions", "vector", "updated_at"}),
+			}).Create(row).Error; err != nil {
+				return err
+			}
+			if err := tx.Where("message_id = ?", message.ID).Delete(&repository.MessageEmbeddingFailure{}).Error; err != nil {
+				return err
+			}
+			if s.pgvector {
+				return tx.Exec("UPDATE message_embeddings SET embedding = ?::vector WHERE message_id = ?",
+					vectorLiteral(vectors[i]), message.ID).Error
+			}
+			return nil
+		})
+		if err != nil {
+			return i, fmt.Errorf("保存消息 %d 的向量失败: %w", message.ID, err)
+		}
+	}
+	return len(messages), nil
+}
+
+// embed 计算一批消息的向量，整批失败时逐条重试以找出无法向量化的消息
+// 失败的消息对应位置为 nil 并记录失败；全部失败时（如接口不可用）返回错误，结束本轮索引
+func (s *SemanticSearchService) embed(ctx context.Context, messages []*repository.Message) ([][]float32, error) {
+	texts := make([]string, len(messages))
+	for i, message := range messages {
+		texts[i] = truncateRunes(message.Content, maxEmbeddingRunes)
+	}
+
+	vectors, err := s.embedTexts(ctx, texts)
+	if err == nil || ctx.Err() != nil {
+		return vectors, err
+	}
+
+	vectors = make([][]float32, len(messages))
+	embedded := 0
+	for i, message := range messages {
+		vector, err := s.embedTexts(ctx, texts[i:i+1])
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil, err
+			}
+			s.markFailed(message, err)
+			continue
+		}
+		vectors[i] = vector[0]
+		embedded++
+	}
+	if embedded == 0 {
+		return nil, fmt.Errorf("计算向量失败: %w", err)
+	}
+	return vectors, nil
+}
+
+// embedTexts 调用向量接口并校验返回数量
+func (s *SemanticSearchService) embedTexts(ctx context.Context, texts []string) ([][]float32, error) {
+	vectors, err := s.provider.Embed(ctx, texts)
+	if err != nil {
+		return nil, err
+	}
+	if len(vectors) != len(texts) {
+		return nil, fmt.Errorf("向量数量 %d 与消息数量 %d 不一致", len(vectors), len(texts))
+	}
+	return vectors, nil
+}
+
+// markFailed 记录消息向量计算失败，按连续失败次数指数退避；内容或模型变化后重新计数
+func (s *SemanticSearchService) markFailed(message *repository.Message, cause error) {
+	failure := repository.MessageEmbeddingFailure{MessageID: message.ID}
+	if err := s.db.Where("message_id = ?", message.ID).Limit(1).Find(&failure).Error; err != nil {
+		log.Printf("Failed to load embedding failure of message %d: %v", message.ID, err)
+		return
+	}
+
+	hash := messageContentHash(message.Content)
+	if failure.Model != s.provider.Model() || failure.ContentHash != hash {
+		failure.Attempts = 0
+	}
+	failure.Model = s.provider.Model()
+	failure.ContentHash = hash
+	failure.Attempts++
+	failure.LastError = truncateRunes(cause.Error(), 500)
+	failure.RetryAt = time.Now().Add(retryDelay(failure.Attempts, embeddingRetryBase, embeddingRetryMax))
+
+	if err := s.db.Clauses(clause.OnConflict{UpdateAll: true}).Create(&failure).Error; err != nil {
+		log.Printf("Failed to record embedding failure of message %d: %v", message.ID, err)
+	}
+}
+
+// retryDelay 第 n 次连续失败后的重试等待时间：从 base 开始每次翻倍，不超过 limit
+func retryDelay(attempts int, base, limit time.Duration) time.Duration {
+	delay := base
+	for i := 1; i < attempts && delay < limit; i++ {
+		delay *= 2
+	}
+	return min(delay, limit)
+}
+
+// messageContentHash 消息内容的 md5，与数据库中 messages.content_hash 生成列一致
+func messageContentHash(content string) string {
+	sum := md5.Sum([]byte(content))
+	return hex.EncodeToString(sum[:])
+}
+
+// encodeVector 向量编码为 float32 小端序字节
+func encodeVector(vector []float32) []byte {
+	b := make([]byte, 4*len(vector))
+	for i, v := range vector {
+		binary.LittleEndian.PutUint32(b[4*i:], math.Float32bits(v))
+	}
+	return b
+}
+
+// decodeVector 解码 float32 小端序字节
+func decodeVector(b []byte) []float32 {
+	vector := make([]float32, len(b)/4)
+	for i := range vector {
+		vector[i] = math.Float32frombits(binary.LittleEndian.Uint32(b[4*i:]))
+	}
+	return vector
+}
+
+// vectorLiteral pgvector 的文本格式，如 [0.1,0.2]
+func vectorLiteral(vector []float32) string {
+	parts := make([]string, len(vector))
+	for i, v := range vector {
+		parts[i] = strconv.FormatFloat(float64(v), 'g', -1, 32)
+	}
+	return "[" + strings.Join(parts, ",") + "]"
+}
+
+// cosineSimilarity 余弦相似度，维度不同或任一向量为零时返回 0
+func cosineSimilarity(a, b []float32) float64 {
+	if len(a) != len(b) {
+		return 0
+	}
+	var dot, normA, normB float64
+	for i := range a {
+		dot += float64(a[i]) * float64(b[i])
+		normA += float64(a[i]) * float64(a[i])
+		normB += float64(b[i]) * float64(b[i])
+	}
+	if normA == 0 || normB == 0 {
+		return 0
+	}
+	return dot / (math.Sqrt(normA) * math.Sqrt(normB))
+}
@@ -0,0 +1,149 @@
+package service
+
+import (
+	"ai-chat/internal/repository"
+	"errors"
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const (
+	// sessionTTL 会话有效期，与刷新令牌一致，每次刷新顺延
+	sessionTTL = 7 * 24 * time.Hour
+	// sessionTouchInterval 最近活跃时间的更新间隔，避免每个请求都写库
+	sessionTouchInterval = time.Minute
+)
+
+// ErrSessionRevoked 会话已注销或已过期
+var ErrSessionRevoked = errors.New("会话已失效，请重新登录")
+
+// ClientInfo 发起登录的客户端信息
+type ClientInfo struct {
+	UserAgent string
+	IP        string
+}
+
+// SessionService 登录会话服务
+type SessionService struct {
+	db *gorm.DB
+}
+
+// NewSessionService 创建登录会话服务
+func NewSessionService(db *gorm.DB) *SessionService {
+	return &SessionService{db: db}
+}
+
+// Create 创建会话
+func (s *SessionService) Create(userID uint, client ClientInfo) (*repository.UserSession, error) {
+	return s.create(userID, client, sessionTTL)
+}
+
+// create 创建指定有效期的会话
+func (s *SessionService) create(userID uint, client ClientInfo, ttl time.Duration) (*repository.UserSession, error) {
+	now := time.Now()
+	session := &repository.UserSession{
+		UserID:     userID,
+		UserAgent:  truncateRunes(client.UserAgent, 512),
+		IP:         truncateRunes(client.IP, 64),
+		LastSeenAt: now,
+		ExpiresAt:  now.Add(ttl),
+	}
+	if err := s.db.Create(session).Error; err != nil {
+		return nil, fmt.Errorf("创建会话失败: %w", err)
+	}
+	return session, nil
+}
+
+// ValidateSession 校验会话有效并更新最近活跃时间，供认证中间件调用
+func (s *SessionService) ValidateSession(userID, sessionID uint) error {
+	session, err := s.active(userID, sessionID)
+	if err != nil {
+		return err
+	}
+
+	if time.Since(session.LastSeenAt) > sessionTouchInterval {
+		if err := s.db.Model(session).UpdateColumn("last_seen_at", time.Now()).Error; err != nil {
+			return fmt.Errorf("更新会话失败: %w", err)
+		}
+	}
+	return nil
+}
+
+// Refresh 刷新令牌时校验会话并顺延有效期
+func (s *SessionService) Refresh(userID, sessionID uint, client ClientInfo) error {
+	session, err := s.active(userID, sessionID)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	return s.db.Model(session).Updates(map[string]interface{}{
+		"user_agent":   truncateRunes(client.UserAgent, 512),
+		"ip":           truncateRunes(client.IP, 64),
+		"last_seen_at": now,
+		"expires_at":   now.Add(sessionTTL),
+	}).Error
+}
+
+// List 获取用户的有效会话，最近活跃的在前
+func (s *SessionService) List(userID uint) ([]repository.UserSession, error) {
+	var sessions []repository.UserSession
+	if err := s.db.Where("user_id = ? AND revoked_at IS NULL AND expires_at > ?", userID, time.Now()).
+		Order("last_seen_at DESC").
+		Find(&sessions).Error; err != nil {
+		return nil, fmt.Errorf("获取会话列表失败: %w", err)
+	}
+	return sessions, nil
+}
+
+// Revoke 注销用户的指定会话
+func (s *SessionService) Revoke(userID, sessionID uint) error {
+	result := s.db.Model(&repository.UserSession{}).
+		Where("id = ? AND user_id = ? AND revoked_at IS NULL", sessionID, userID).
+		Update("revoked_at", time.Now())
+	if result.Error != nil {
+		return fmt.Errorf("注销会话失败: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return errors.New("会话不存在")
+	}
+	return nil
+}
+
+// RevokeOthers 注销用户除当前会话外的所有会话，返回注销数量
+func (s *SessionService) RevokeOthers(userID, currentSessionID uint) (int64, error) {
+	result := s.db.Model(&repository.UserSession{}).
+		Where("user_id = ? AND id <> ? AND revoked_at IS NULL", userID, currentSessionID).
+		Update("revoked_at", time.Now())
+	if result.Error != nil {
+		return 0, fmt.Errorf("注销会话失败: %w", result.Error)
+	}
+	return result.RowsAffected, nil
+}
+
+// RevokeAll 注销用户的所有会话，用于重置密码等场景
+func (s *SessionService) RevokeAll(tx *gorm.DB, userID uint) error {
+	if err := tx.Model(&repository.UserSession{}).
+		Where("user_id = ? AND revoked_at IS NULL", userID).
+		Update("revoked_at", time.Now()).Error; err != nil {
+		return fmt.Errorf("注销会话失败: %w", err)
+	}
+	return nil
+}
+
+// active 查找有效会话
+func (s *SessionService) active(userID, sessionID uint) (*repository.UserSession, error) {
+	var session repository.UserSession
+	if err := s.db.Where("id = ? AND user_id = ?", sessionID, userID).First(&session).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrSessionRevoked
+		}
+		return nil, fmt.Errorf("查找会话失败: %w", err)
+	}
+	if session.RevokedAt != nil || time.Now().After(session.ExpiresAt) {
+		return nil, ErrSessionRevoked
+	}
+	return &session, nil
+}
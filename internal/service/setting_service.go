@@ -0,0 +1,89 @@
+package service
+
+import (
+	"ai-chat/internal/repository"
+	"errors"
+	"fmt"
+	"strconv"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// 系统设置项
+const (
+	SettingRequire2FA = "require_2fa" // 是否要求所有用户启用两步验证
+)
+
+// SettingService 系统设置服务
+type SettingService struct {
+	db *gorm.DB
+}
+
+// NewSettingService 创建系统设置服务
+func NewSettingService(db *gorm.DB) *SettingService {
+	return &SettingService{db: db}
+}
+
+// SystemSettings 管理员可修改的系统设置
+type SystemSettings struct {
+	Require2FA bool `json:"require2FA"`
+}
+
+// UpdateSettingsRequest 更新系统设置请求
+type UpdateSettingsRequest struct {
+	Require2FA *bool `json:"require2FA,omitempty"`
+}
+
+// Get 获取全部系统设置
+func (s *SettingService) Get() (*SystemSettings, error) {
+	require2FA, err := s.Bool(SettingRequire2FA, false)
+	if err != nil {
+		return nil, err
+	}
+	return &SystemSettings{Require2FA: require2FA}, nil
+}
+
+// Update 更新系统设置
+func (s *SettingService) Update(adminID uint, req *UpdateSettingsRequest) (*SystemSettings, error) {
+	if req.Require2FA != nil {
+		if err := s.set(adminID, SettingRequire2FA, strconv.FormatBool(*req.Require2FA)); err != nil {
+			return nil, err
+		}
+	}
+	return s.Get()
+}
+
+// Bool 读取布尔类型设置，未设置时返回默认值
+func (s *SettingService) Bool(key string, defaultValue bool) (bool, error) {
+	var setting repository.SystemSetting
+	if err := s.db.Where("key = ?", key).First(&setting).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return defaultValue, nil
+		}
+		return false, fmt.Errorf("读取系统设置失败: %w", err)
+	}
+
+	value, err := strconv.ParseBool(setting.Value)
+	if err != nil {
+		return defaultValue, nil
+	}
+	return value, nil
+}
+
+// set 写入设置（不存在时插入）
+func (s *SettingService) set(adminID uint, key, value string) error {
+	setting := &repository.SystemSetting{
+		Key:       key,
+		Value:     value,
+		UpdatedBy: adminID,
+	}
+	err := s.db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "key"}},
+		DoUpdates: clause.AssignmentColumns([]string{"value", "updated_by", "updated_at"}),
+	}).Create(setting).Error
+	if err != nil {
+		return fmt.Errorf("保存系统设置失败: %w", err)
+	}
+	return nil
+}
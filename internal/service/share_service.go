@@ -0,0 +1,300 @@
+package service
+
+import (
+	"ai-chat/config"
+	"ai-chat/internal/policy"
+	"ai-chat/internal/repository"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+var (
+	// ErrShareNotFound 分享不存在、已撤销、已过期或会话已删除，几种情况不做区分
+	ErrShareNotFound = errors.New("分享不存在或已失效")
+	// ErrSharePasswordRequired 分享需要密码
+	ErrSharePasswordRequired = errors.New("该分享需要密码")
+	// ErrSharePasswordInvalid 分享密码错误
+	ErrSharePasswordInvalid = errors.New("分享密码错误")
+)
+
+// ShareService 会话公开分享服务
+// 分享创建时保存消息快照，访问者凭链接中的随机令牌只读访问，数据库中只保存令牌哈希
+type ShareService struct {
+	db    *gorm.DB
+	cfg   *config.Config
+	authz *Authorizer
+}
+
+// NewShareService 创建分享服务
+func NewShareService(db *gorm.DB, cfg *config.Config) *ShareService {
+	return &ShareService{
+		db:    db,
+		cfg:   cfg,
+		authz: NewAuthorizer(db),
+	}
+}
+
+// CreateShareRequest 创建分享请求，不指定消息范围时分享整个会话
+type CreateShareRequest struct {
+	Title          *string `json:"title,omitempty" binding:"omitempty,min=1,max=255"`
+	FromMessageID  *uint   `json:"fromMessageId,omitempty"`
+	ToMessageID    *uint   `json:"toMessageId,omitempty"`
+	ExpiresInHours *int    `json:"expiresInHours,omitempty" binding:"omitempty,min=1,max=8760"` // 为空表示永不过期
+	Password       string  `json:"password,omitempty" binding:"omitempty,min=4,max=64"`
+	HideReasoning  bool    `json:"hideReasoning"`
+}
+
+// ShareResponse 分享信息
+type ShareResponse struct {
+	ID             uint       `json:"id"`
+	ConversationID uint       `json:"conversationId"`
+	Title          string     `json:"title"`
+	URL            string     `json:"url,omitempty"` // 仅创建时返回，之后无法再次获取
+	MessageCount   int        `json:"messageCount"`
+	HideReasoning  bool       `json:"hideReasoning"`
+	HasPassword    bool       `json:"hasPassword"`
+	ExpiresAt      *time.Time `json:"expiresAt"`
+	RevokedAt      *time.Time `json:"revokedAt"`
+	ViewCount      int        `json:"viewCount"`
+	CreatedAt      time.Time  `json:"createdAt"`
+}
+
+// SharedMessage 分享快照中的消息，不包含系统提示词和内部ID
+type SharedMessage struct {
+	Type             string    `json:"type"`
+	Content          string    `json:"content"`
+	ReasoningContent string    `json:"reasoningContent,omitempty"`
+	Model            *string   `json:"model,omitempty"`
+	CreatedAt        time.Time `json:"createdAt"`
+}
+
+// SharedConversation 访问者看到的分享内容
+type SharedConversation struct {
+	Title     string          `json:"title"`
+	Messages  []SharedMessage `json:"messages"`
+	ExpiresAt *time.Time      `json:"expiresAt"`
+	CreatedAt time.Time       `json:"createdAt"`
+}
+
+// Create 为会话或其中一段消息创建分享，需要会话写权限
+func (s *ShareService) Create(p policy.Principal, conversationID uint, req *CreateShareRequest) (*ShareResponse, error) {
+	conversation, err := s.authz.Conversation(p, policy.ActionWrite, conversationID)
+	if err != nil {
+		return nil, err
+	}
+
+	messages, err := s.snapshotMessages(conversationID, req.FromMessageID, req.ToMessageID)
+	if err != nil {
+		return nil, err
+	}
+	if len(messages) == 0 {
+		return nil, errors.New("没有可分享的消息")
+	}
+	// 隐藏思考内容时直接不写入快照，而不是展示时过滤
+	if req.HideReasoning {
+		for i := range messages {
+			messages[i].ReasoningContent = ""
+		}
+	}
+
+	snapshot, err := json.Marshal(messages)
+	if err != nil {
+		return nil, fmt.Errorf("生成分享快照失败: %w", err)
+	}
+
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return nil, fmt.Errorf("生成分享令牌失败: %w", err)
+	}
+	token := base64.RawURLEncoding.EncodeToString(b)
+
+	share := &repository.ConversationShare{
+		ConversationID: conversationID,
+		UserID:         p.UserID,
+		TokenHash:      hashToken(token),
+		Title:          conversation.Name,
+		Snapshot:       string(snapshot),
+		MessageCount:   len(messages),
+		HideReasoning:  req.HideReasoning,
+	}
+	if req.Title != nil {
+		share.Title = *req.Title
+	}
+	if req.Password != "" {
+		share.PasswordSalt = GenerateSalt()
+		share.PasswordHash = HashPassword(req.Password, share.PasswordSalt)
+	}
+	if req.ExpiresInHours != nil {
+		expiresAt := time.Now().Add(time.Duration(*req.ExpiresInHours) * time.Hour)
+		share.ExpiresAt = &expiresAt
+	}
+
+	if err := s.db.Create(share).Error; err != nil {
+		return nil, fmt.Errorf("保存分享失败: %w", err)
+	}
+
+	resp := s.toResponse(share)
+	resp.URL = s.cfg.AppBaseURL + "/s/" + token
+	return resp, nil
+}
+
+// List 列出会话的全部分享，包括已撤销和已过期的
+func (s *ShareService) List(p policy.Principal, conversationID uint) ([]*ShareResponse, error) {
+	if _, err := s.authz.Conversation(p, policy.ActionWrite, conversationID); err != nil {
+		return nil, err
+	}
+
+	var shares []*repository.ConversationShare
+	if err := s.db.Where("conversation_id = ?", conversationID).
+		Order("created_at DESC").Find(&shares).Error; err != nil {
+		return nil, fmt.Errorf("查询分享列表失败: %w", err)
+	}
+
+	items := make([]*ShareResponse, len(shares))
+	for i, share := range shares {
+		items[i] = s.toResponse(share)
+	}
+	return items, nil
+}
+
+// Revoke 撤销分享，撤销后链接立即失效
+func (s *ShareService) Revoke(p policy.Principal, conversationID, shareID uint) error {
+	if _, err := s.authz.Conversation(p, policy.ActionWrite, conversationID); err != nil {
+		return err
+	}
+
+	result := s.db.Model(&repository.ConversationShare{}).
+		Where("id = ? AND conversation_id = ? AND revoked_at IS NULL", shareID, conversationID).
+		Update("revoked_at", time.Now())
+	if result.Error != nil {
+		return fmt.Errorf("撤销分享失败: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return policy.ErrNotFound
+	}
+	return nil
+}
+
+// View 凭令牌访问分享，设置了密码时需要提供密码
+func (s *ShareService) View(token, password string) (*SharedConversation, error) {
+	if token == "" {
+		return nil, ErrShareNotFound
+	}
+
+	var share repository.ConversationShare
+	if err := s.db.Where("token_hash = ?", hashToken(token)).First(&share).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrShareNotFound
+		}
+		return nil, fmt.Errorf("查找分享失败: %w", err)
+	}
+
+	if share.RevokedAt != nil || (share.ExpiresAt != nil && time.Now().After(*share.ExpiresAt)) {
+		return nil, ErrShareNotFound
+	}
+
+	// 会话删除（包括随工作区删除）后分享一并失效
+	var count int64
+	if err := s.db.Model(&repository.Conversation{}).Where("id = ?", share.ConversationID).Count(&count).Error; err != nil {
+		return nil, fmt.Errorf("查找会话失败: %w", err)
+	}
+	if count == 0 {
+		return nil, ErrShareNotFound
+	}
+
+	if share.PasswordHash != "" {
+		if password == "" {
+			return nil, ErrSharePasswordRequired
+		}
+		if subtle.ConstantTimeCompare([]byte(HashPassword(password, share.PasswordSalt)), []byte(share.PasswordHash)) != 1 {
+			return nil, ErrSharePasswordInvalid
+		}
+	}
+
+	var messages []SharedMessage
+	if err := json.Unmarshal([]byte(share.Snapshot), &messages); err != nil {
+		return nil, fmt.Errorf("读取分享快照失败: %w", err)
+	}
+
+	s.db.Model(&share).UpdateColumn("view_count", gorm.Expr("view_count + 1"))
+
+	return &SharedConversation{
+		Title:     share.Title,
+		Messages:  messages,
+		ExpiresAt: share.ExpiresAt,
+		CreatedAt: share.CreatedAt,
+	}, nil
+}
+
+// snapshotMessages 读取会话中指定范围内的用户和助手消息，系统提示词不对外分享
+func (s *ShareService) snapshotMessages(conversationID uint, fromID, toID *uint) ([]SharedMessage, error) {
+	query := s.db.Model(&repository.Message{}).
+		Where("conversation_id = ? AND type IN ?", conversationID, []string{"user", "assistant"})
+
+	if fromID != nil {
+		sort, err := s.messageSort(conversationID, *fromID)
+		if err != nil {
+			return nil, err
+		}
+		query = query.Where("sort >= ?", sort)
+	}
+	if toID != nil {
+		sort, err := s.messageSort(conversationID, *toID)
+		if err != nil {
+			return nil, err
+		}
+		query = query.Where("sort <= ?", sort)
+	}
+
+	var messages []*repository.Message
+	if err := query.Order("sort ASC").Find(&messages).Error; err != nil {
+		return nil, fmt.Errorf("查询消息失败: %w", err)
+	}
+
+	items := make([]SharedMessage, len(messages))
+	for i, message := range messages {
+		items[i] = SharedMessage{
+			Type:             message.Type,
+			Content:          message.Content,
+			ReasoningContent: message.ReasoningContent,
+			Model:            message.Model,
+			CreatedAt:        message.CreatedAt,
+		}
+	}
+	return items, nil
+}
+
+// messageSort 获取会话内消息的排序值，用于确定分享范围
+func (s *ShareService) messageSort(conversationID, messageID uint) (int, error) {
+	var message repository.Message
+	if err := s.db.Where("id = ? AND conversation_id = ?", messageID, conversationID).First(&message).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return 0, fmt.Errorf("消息 %d 不属于该会话", messageID)
+		}
+		return 0, fmt.Errorf("查找消息失败: %w", err)
+	}
+	return message.Sort, nil
+}
+
+// toResponse 转换为分享信息
+func (s *ShareService) toResponse(share *repository.ConversationShare) *ShareResponse {
+	return &ShareResponse{
+		ID:             share.ID,
+		ConversationID: share.ConversationID,
+		Title:          share.Title,
+		MessageCount:   share.MessageCount,
+		HideReasoning:  share.HideReasoning,
+		HasPassword:    share.PasswordHash != "",
+		ExpiresAt:      share.ExpiresAt,
+		RevokedAt:      share.RevokedAt,
+		ViewCount:      share.ViewCount,
+		CreatedAt:      share.CreatedAt,
+	}
+}
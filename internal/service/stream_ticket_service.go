@@ -0,0 +1,144 @@
+package service
+
+import (
+	"ai-chat/internal/policy"
+	"ai-chat/internal/repository"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const (
+	// TokenPurposeStreamTicket 流式接口的一次性票据
+	TokenPurposeStreamTicket = "stream_ticket"
+	// streamTicketTTL 票据有效期，仅够前端拿到票据后立即建立 EventSource 连接
+	streamTicketTTL = 30 * time.Second
+)
+
+// ErrInvalidStreamTicket 票据无效、已使用或已过期
+var ErrInvalidStreamTicket = errors.New("无效的流式票据")
+
+// StreamTicketService 流式票据服务
+// EventSource 无法设置请求头，用短期一次性票据代替在查询参数中传递访问令牌
+type StreamTicketService struct {
+	db    *gorm.DB
+	authz *Authorizer
+}
+
+// NewStreamTicketService 创建流式票据服务
+func NewStreamTicketService(db *gorm.DB) *StreamTicketService {
+	return &StreamTicketService{
+		db:    db,
+		authz: NewAuthorizer(db),
+	}
+}
+
+// StreamTicket 流式票据
+type StreamTicket struct {
+	Ticket    string    `json:"ticket"`
+	ExpiresAt time.Time `json:"expiresAt"`
+}
+
+// streamTicketClaims 票据保存的访问主体，兑换时原样恢复，与申请票据的请求权限一致
+type streamTicketClaims struct {
+	Role           string `json:"role"`
+	WorkspaceID    *uint  `json:"workspaceId,omitempty"`
+	SessionID      uint   `json:"sessionId,omitempty"`
+	ImpersonatorID uint   `json:"impersonatorId,omitempty"`
+}
+
+// Issue 为主体可以发送消息的会话签发票据
+func (s *StreamTicketService) Issue(p policy.Principal, conversationID uint) (*StreamTicket, error) {
+	if _, err := s.authz.Conversation(p, policy.ActionWrite, conversationID); err != nil {
+		return nil, err
+	}
+
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return nil, fmt.Errorf("生成票据失败: %w", err)
+	}
+	ticket := base64.RawURLEncoding.EncodeToString(b)
+
+	data, err := json.Marshal(streamTicketClaims{
+		Role:           p.Role,
+		WorkspaceID:    p.WorkspaceID,
+		SessionID:      p.SessionID,
+		ImpersonatorID: p.ImpersonatorID,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("生成票据失败: %w", err)
+	}
+	claims := string(data)
+
+	expiresAt := time.Now().Add(streamTicketTTL)
+	if err := s.db.Create(&repository.UserToken{
+		UserID:    p.UserID,
+		Purpose:   TokenPurposeStreamTicket,
+		TokenHash: hashToken(ticket),
+		Scope:     streamTicketScope(conversationID),
+		Claims:    &claims,
+		ExpiresAt: expiresAt,
+	}).Error; err != nil {
+		return nil, fmt.Errorf("保存票据失败: %w", err)
+	}
+
+	return &StreamTicket{Ticket: ticket, ExpiresAt: expiresAt}, nil
+}
+
+// RedeemStreamTicket 消费票据，票据必须与会话匹配，返回申请票据时的访问主体
+func (s *StreamTicketService) RedeemStreamTicket(ticket string, conversationID uint) (*policy.Principal, error) {
+	if ticket == "" {
+		return nil, ErrInvalidStreamTicket
+	}
+
+	var userToken repository.UserToken
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("token_hash = ? AND purpose = ? AND scope = ?",
+			hashToken(ticket), TokenPurposeStreamTicket, streamTicketScope(conversationID)).
+			First(&userToken).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrInvalidStreamTicket
+			}
+			return fmt.Errorf("查找票据失败: %w", err)
+		}
+
+		// 条件更新保证票据只能使用一次
+		result := tx.Model(&repository.UserToken{}).
+			Where("id = ? AND used_at IS NULL AND expires_at > ?", userToken.ID, time.Now()).
+			Update("used_at", time.Now())
+		if result.Error != nil {
+			return fmt.Errorf("更新票据状态失败: %w", result.Error)
+		}
+		if result.RowsAffected == 0 {
+			return ErrInvalidStreamTicket
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	p := &policy.Principal{UserID: userToken.UserID}
+	if userToken.Claims != nil {
+		var claims streamTicketClaims
+		if err := json.Unmarshal([]byte(*userToken.Claims), &claims); err != nil {
+			return nil, ErrInvalidStreamTicket
+		}
+		p.Role = claims.Role
+		p.WorkspaceID = claims.WorkspaceID
+		p.SessionID = claims.SessionID
+		p.ImpersonatorID = claims.ImpersonatorID
+	}
+	return p, nil
+}
+
+// streamTicketScope 票据绑定的会话
+func streamTicketScope(conversationID uint) string {
+	return "conversation:" + strconv.FormatUint(uint64(conversationID), 10)
+}
@@ -0,0 +1,404 @@
+package service
+
+import (
+	"ai-chat/config"
+	"ai-chat/internal/repository"
+	"ai-chat/internal/secret"
+	"ai-chat/internal/totp"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const (
+	// recoveryCodeCount 每次生成的恢复码数量
+	recoveryCodeCount = 10
+	// totpSkew 允许前后各一个时间步的时钟偏差
+	totpSkew = 1
+	// totpIssuer 验证器 App 中显示的发行方名称
+	totpIssuer = "AI Chat"
+)
+
+// ErrInvalidMFACode 验证码或恢复码错误
+var ErrInvalidMFACode = errors.New("验证码错误")
+
+// TwoFactorService 两步验证服务（TOTP + 恢复码）
+type TwoFactorService struct {
+	db          *gorm.DB
+	authService *AuthService
+	settings    *SettingService
+	box         *secret.Box
+}
+
+// NewTwoFactorService 创建两步验证服务
+func NewTwoFactorService(db *gorm.DB, cfg *config.Config, authService *AuthService, settings *SettingService) (*TwoFactorService, error) {
+	box, err := secret.New(cfg.JWTSecret, "totp")
+	if err != nil {
+		return nil, err
+	}
+	return &TwoFactorService{
+		db:          db,
+		authService: authService,
+		settings:    settings,
+		box:         box,
+	}, nil
+}
+
+// TwoFactorStatus 两步验证状态
+type TwoFactorStatus struct {
+	Enabled                bool `json:"enabled"`
+	Required               bool `json:"required"`
+	RemainingRecoveryCodes int  `json:"remainingRecoveryCodes"`
+}
+
+// TwoFactorSetup 两步验证登记信息
+type TwoFactorSetup struct {
+	Secret          string `json:"secret"`
+	ProvisioningURI string `json:"provisioningUri"` // otpauth:// 地址，用于生成二维码
+}
+
+// TwoFactorEnableResult 启用两步验证的结果，恢复码只在此时明文返回一次
+type TwoFactorEnableResult struct {
+	RecoveryCodes []string      `json:"recoveryCodes"`
+	Auth          *AuthResponse `json:"-"` // 通过登录流程启用时附带签发的令牌
+}
+
+// Status 获取两步验证状态
+func (s *TwoFactorService) Status(userID uint) (*TwoFactorStatus, error) {
+	user, err := s.findUser(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	required, err := s.settings.Bool(SettingRequire2FA, false)
+	if err != nil {
+		return nil, err
+	}
+
+	var remaining int64
+	if err := s.db.Model(&repository.RecoveryCode{}).
+		Where("user_id = ? AND used_at IS NULL", userID).
+		Count(&remaining).Error; err != nil {
+		return nil, fmt.Errorf("查询恢复码失败: %w", err)
+	}
+
+	return &TwoFactorStatus{
+		Enabled:                user.TOTPEnabled,
+		Required:               required,
+		RemainingRecoveryCodes: int(remaining),
+	}, nil
+}
+
+// BeginSetup 生成新的 TOTP 密钥，验证通过后才会启用
+func (s *TwoFactorService) BeginSetup(userID uint) (*TwoFactorSetup, error) {
+	user, err := s.findUser(userID)
+	if err != nil {
+		return nil, err
+	}
+	if user.TOTPEnabled {
+		return nil, errors.New("两步验证已启用")
+	}
+
+	key, err := totp.GenerateSecret()
+	if err != nil {
+		return nil, err
+	}
+	encrypted, err := s.box.Encrypt(key)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.db.Model(user).Updates(map[string]interface{}{
+		"totp_secret":    encrypted,
+		"totp_last_step": 0,
+	}).Error; err != nil {
+		return nil, fmt.Errorf("保存两步验证密钥失败: %w", err)
+	}
+
+	return &TwoFactorSetup{
+		Secret:          key,
+		ProvisioningURI: totp.ProvisioningURI(totpIssuer, user.Email, key),
+	}, nil
+}
+
+// Enable 校验验证码并启用两步验证，返回新的恢复码
+func (s *TwoFactorService) Enable(userID uint, code, ip string) (*TwoFactorEnableResult, error) {
+	user, err := s.findUser(userID)
+	if err != nil {
+		return nil, err
+	}
+	if user.TOTPEnabled {
+		return nil, errors.New("两步验证已启用")
+	}
+	if user.TOTPSecret == "" {
+		return nil, errors.New("请先获取两步验证密钥")
+	}
+
+	if err := s.guardedVerify(user, ip, func() error { return s.verifyTOTP(user, code) }); err != nil {
+		return nil, err
+	}
+
+	var codes []string
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(user).Update("totp_enabled", true).Error; err != nil {
+			return fmt.Errorf("启用两步验证失败: %w", err)
+		}
+		codes, err = s.replaceRecoveryCodes(tx, user.ID)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	user.TOTPEnabled = true
+	return &TwoFactorEnableResult{RecoveryCodes: codes}, nil
+}
+
+// Disable 校验验证码后关闭两步验证，管理员要求两步验证时不允许关闭
+func (s *TwoFactorService) Disable(userID uint, code, ip string) error {
+	required, err := s.settings.Bool(SettingRequire2FA, false)
+	if err != nil {
+		return err
+	}
+	if required {
+		return errors.New("管理员要求所有用户启用两步验证，无法关闭")
+	}
+
+	user, err := s.findUser(userID)
+	if err != nil {
+		return err
+	}
+	if !user.TOTPEnabled {
+		return errors.New("两步验证未启用")
+	}
+
+	if err := s.guardedVerify(user, ip, func() error { return s.verifyCode(user, code) }); err != nil {
+		return err
+	}
+
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(user).Updates(map[string]interface{}{
+			"totp_enabled":   false,
+			"totp_secret":    "",
+			"totp_last_step": 0,
+		}).Error; err != nil {
+			return fmt.Errorf("关闭两步验证失败: %w", err)
+		}
+		if err := tx.Where("user_id = ?", user.ID).Delete(&repository.RecoveryCode{}).Error; err != nil {
+			return fmt.Errorf("删除恢复码失败: %w", err)
+		}
+		return nil
+	})
+}
+
+// RegenerateRecoveryCodes 校验验证码后重新生成恢复码，旧恢复码全部作废
+func (s *TwoFactorService) RegenerateRecoveryCodes(userID uint, code, ip string) ([]string, error) {
+	user, err := s.findUser(userID)
+	if err != nil {
+		return nil, err
+	}
+	if !user.TOTPEnabled {
+		return nil, errors.New("两步验证未启用")
+	}
+
+	if err := s.guardedVerify(user, ip, func() error { return s.verifyTOTP(user, code) }); err != nil {
+		return nil, err
+	}
+
+	var codes []string
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		codes, err = s.replaceRecoveryCodes(tx, user.ID)
+		return err
+	})
+	return codes, err
+}
+
+// VerifyLogin 登录第二步：校验验证码或恢复码并签发令牌
+func (s *TwoFactorService) VerifyLogin(mfaToken, code string, client ClientInfo) (*AuthResponse, error) {
+	userID, err := s.authService.ParseMFAToken(mfaToken)
+	if err != nil {
+		return nil, err
+	}
+
+	user, err := s.findUser(userID)
+	if err != nil {
+		return nil, err
+	}
+	if !user.IsActive {
+		return nil, errors.New("账户已被禁用")
+	}
+	if !user.TOTPEnabled {
+		return nil, errors.New("请先完成两步验证设置")
+	}
+
+	if err := s.guardedVerify(user, client.IP, func() error { return s.verifyCode(user, code) }); err != nil {
+		return nil, err
+	}
+
+	token, err := s.authService.GenerateJWT(user, client)
+	if err != nil {
+		return nil, fmt.Errorf("生成令牌失败: %w", err)
+	}
+	return &AuthResponse{User: user, Token: token}, nil
+}
+
+// BeginSetupForLogin 登录时被要求启用两步验证：使用两步验证令牌获取密钥
+func (s *TwoFactorService) BeginSetupForLogin(mfaToken string) (*TwoFactorSetup, error) {
+	userID, err := s.authService.ParseMFAToken(mfaToken)
+	if err != nil {
+		return nil, err
+	}
+	return s.BeginSetup(userID)
+}
+
+// EnableForLogin 登录时被要求启用两步验证：启用成功后直接签发令牌
+func (s *TwoFactorService) EnableForLogin(mfaToken, code string, client ClientInfo) (*TwoFactorEnableResult, error) {
+	userID, err := s.authService.ParseMFAToken(mfaToken)
+	if err != nil {
+		return nil, err
+	}
+
+	result, err := s.Enable(userID, code, client.IP)
+	if err != nil {
+		return nil, err
+	}
+
+	user, err := s.findUser(userID)
+	if err != nil {
+		return nil, err
+	}
+	token, err := s.authService.GenerateJWT(user, client)
+	if err != nil {
+		return nil, fmt.Errorf("生成令牌失败: %w", err)
+	}
+	result.Auth = &AuthResponse{User: user, Token: token}
+	return result, nil
+}
+
+// guardedVerify 按用户计数校验失败次数，登录和已登录时的启用、关闭等操作共用同一锁定
+// 防止持有会话的人暴力破解验证码关闭两步验证
+func (s *TwoFactorService) guardedVerify(user *repository.User, ip string, verify func() error) error {
+	if err := s.authService.guard.CheckMFA(user.ID, ip); err != nil {
+		return err
+	}
+	if err := verify(); err != nil {
+		if errors.Is(err, ErrInvalidMFACode) {
+			s.authService.guard.FailMFA(user.ID, ip)
+		}
+		return err
+	}
+	s.authService.guard.SucceedMFA(user.ID)
+	return nil
+}
+
+// verifyCode 校验 TOTP 验证码，不是 6 位数字时按恢复码处理
+func (s *TwoFactorService) verifyCode(user *repository.User, code string) error {
+	code = strings.TrimSpace(code)
+	if len(code) == totp.Digits {
+		return s.verifyTOTP(user, code)
+	}
+	return s.useRecoveryCode(user.ID, code)
+}
+
+// verifyTOTP 校验 TOTP 验证码，同一时间步的验证码只能使用一次
+func (s *TwoFactorService) verifyTOTP(user *repository.User, code string) error {
+	key, err := s.box.Decrypt(user.TOTPSecret)
+	if err != nil {
+		return fmt.Errorf("读取两步验证密钥失败: %w", err)
+	}
+
+	step, ok := totp.Validate(key, code, time.Now(), totpSkew)
+	if !ok || step <= user.TOTPLastStep {
+		return ErrInvalidMFACode
+	}
+
+	// 条件更新防止并发请求重复使用同一验证码
+	result := s.db.Model(&repository.User{}).
+		Where("id = ? AND totp_last_step < ?", user.ID, step).
+		Update("totp_last_step", step)
+	if result.Error != nil {
+		return fmt.Errorf("更新两步验证状态失败: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return ErrInvalidMFACode
+	}
+	user.TOTPLastStep = step
+	return nil
+}
+
+// useRecoveryCode 消费一个恢复码
+func (s *TwoFactorService) useRecoveryCode(userID uint, code string) error {
+	result := s.db.Model(&repository.RecoveryCode{}).
+		Where("user_id = ? AND code_hash = ? AND used_at IS NULL", userID, hashToken(normalizeRecoveryCode(code))).
+		Update("used_at", time.Now())
+	if result.Error != nil {
+		return fmt.Errorf("校验恢复码失败: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return ErrInvalidMFACode
+	}
+	return nil
+}
+
+// replaceRecoveryCodes 删除旧恢复码并生成新的一组
+func (s *TwoFactorService) replaceRecoveryCodes(tx *gorm.DB, userID uint) ([]string, error) {
+	if err := tx.Where("user_id = ?", userID).Delete(&repository.RecoveryCode{}).Error; err != nil {
+		return nil, fmt.Errorf("删除旧恢复码失败: %w", err)
+	}
+
+	codes := make([]string, recoveryCodeCount)
+	records := make([]repository.RecoveryCode, recoveryCodeCount)
+	for i := range codes {
+		code, err := generateRecoveryCode()
+		if err != nil {
+			return nil, err
+		}
+		codes[i] = code
+		records[i] = repository.RecoveryCode{
+			UserID:   userID,
+			CodeHash: hashToken(normalizeRecoveryCode(code)),
+		}
+	}
+
+	if err := tx.Create(&records).Error; err != nil {
+		return nil, fmt.Errorf("保存恢复码失败: %w", err)
+	}
+	return codes, nil
+}
+
+// findUser 根据ID查找用户
+func (s *TwoFactorService) findUser(userID uint) (*repository.User, error) {
+	var user repository.User
+	if err := s.db.First(&user, userID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New("用户不存在")
+		}
+		return nil, fmt.Errorf("查找用户失败: %w", err)
+	}
+	return &user, nil
+}
+
+// recoveryCodeAlphabet 去掉了容易混淆的字符
+const recoveryCodeAlphabet = "abcdefghjkmnpqrstuvwxyz23456789"
+
+// generateRecoveryCode 生成 xxxxx-xxxxx 格式的恢复码
+func generateRecoveryCode() (string, error) {
+	b := make([]byte, 10)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("生成恢复码失败: %w", err)
+	}
+	for i := range b {
+		b[i] = recoveryCodeAlphabet[int(b[i])%len(recoveryCodeAlphabet)]
+	}
+	return string(b[:5]) + "-" + string(b[5:]), nil
+}
+
+// normalizeRecoveryCode 忽略大小写、空格和连字符
+func normalizeRecoveryCode(code string) string {
+	code = strings.ToLower(code)
+	return strings.NewReplacer("-", "", " ", "").Replace(code)
+}
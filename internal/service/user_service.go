@@ -6,25 +6,27 @@ import (
 	"ai-chat/internal/repository"
 	"errors"
 	"fmt"
+	"time"
 
 	"gorm.io/gorm"
 )
 
 // userService 用户服务结构体（私有）
 type userService struct {
-	db *gorm.DB
+	db             *gorm.DB
+	sessionService *SessionService
 }
 
 // NewUserService 创建用户服务
-func NewUserService(db *gorm.DB) *userService {
-	return &userService{db: db}
+func NewUserService(db *gorm.DB, sessionService *SessionService) *userService {
+	return &userService{db: db, sessionService: sessionService}
 }
 
 // UserService 用户服务接口（在service包中定义）
 type UserService interface {
 	GetProfile(userID uint) (*dto.UserResponse, error)
 	UpdateProfile(userID uint, req *dto.UpdateProfileRequest) (*dto.UserResponse, error)
-	UpdatePassword(userID uint, req *dto.UpdatePasswordRequest) error
+	UpdatePassword(userID, sessionID uint, req *dto.UpdatePasswordRequest) error
 	DeleteAccount(userID uint) error
 	GetUserList(req *dto.GetUsersRequest) ([]*dto.UserResponse, int64, error)
 	GetUserByID(id uint) (*dto.UserResponse, error)
@@ -111,6 +113,19 @@ func (r *userRepository) FindAll(page, pageSize int, q string) ([]*repository.Us
 	return users, total, nil
 }
 
+// newUserResponse 将repository.User转换为dto.UserResponse
+func newUserResponse(user *repository.User) *dto.UserResponse {
+	return &dto.UserResponse{
+		ID:        user.ID,
+		Email:     user.Email,
+		Username:  user.Name,
+		Role:      user.Role,
+		IsActive:  user.IsActive,
+		CreatedAt: user.CreatedAt.Format(common.TimeLayout),
+		UpdatedAt: user.UpdatedAt.Format(common.TimeLayout),
+	}
+}
+
 // 实现UserService接口方法
 
 // GetProfile 获取用户信息
@@ -124,13 +139,7 @@ func (s *userService) GetProfile(userID uint) (*dto.UserResponse, error) {
 		return nil, err
 	}
 
-	return &dto.UserResponse{
-		ID:        user.ID,
-		Email:     user.Email,
-		Username:  user.Name,
-		CreatedAt: user.CreatedAt.Format(common.TimeLayout),
-		UpdatedAt: user.UpdatedAt.Format(common.TimeLayout),
-	}, nil
+	return newUserResponse(&user), nil
 }
 
 // UpdateProfile 更新用户信息
@@ -157,17 +166,11 @@ func (s *userService) UpdateProfile(userID uint, req *dto.UpdateProfileRequest)
 		return nil, err
 	}
 
-	return &dto.UserResponse{
-		ID:        user.ID,
-		Email:     user.Email,
-		Username:  user.Name,
-		CreatedAt: user.CreatedAt.Format(common.TimeLayout),
-		UpdatedAt: user.UpdatedAt.Format(common.TimeLayout),
-	}, nil
+	return newUserResponse(&user), nil
 }
 
-// UpdatePassword 更新用户密码
-func (s *userService) UpdatePassword(userID uint, req *dto.UpdatePasswordRequest) error {
+// UpdatePassword 更新用户密码，并注销除当前会话外的所有会话
+func (s *userService) UpdatePassword(userID, sessionID uint, req *dto.UpdatePasswordRequest) error {
 	// 1. 查找用户
 	var user repository.User
 	err := s.db.First(&user, userID).Error
@@ -195,10 +198,15 @@ func (s *userService) UpdatePassword(userID uint, req *dto.UpdatePasswordRequest
 		return fmt.Errorf("更新密码失败: %w", err)
 	}
 
+	// 5. 注销其他会话，防止旧密码登录的设备继续访问
+	if _, err := s.sessionService.RevokeOthers(userID, sessionID); err != nil {
+		return err
+	}
+
 	return nil
 }
 
-// DeleteAccount 删除用户账户
+// DeleteAccount 删除用户账户，同时注销全部会话并撤销公开分享
 func (s *userService) DeleteAccount(userID uint) error {
 	var user repository.User
 	err := s.db.First(&user, userID).Error
@@ -209,7 +217,21 @@ func (s *userService) DeleteAccount(userID uint) error {
 		return err
 	}
 
-	return s.db.Delete(&user).Error
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Delete(&user).Error; err != nil {
+			return fmt.Errorf("删除账户失败: %w", err)
+		}
+		if err := s.sessionService.RevokeAll(tx, user.ID); err != nil {
+			return err
+		}
+		// 软删除不会删除用户的会话，分享需要显式撤销才会失效
+		if err := tx.Model(&repository.ConversationShare{}).
+			Where("user_id = ? AND revoked_at IS NULL", user.ID).
+			Update("revoked_at", time.Now()).Error; err != nil {
+			return fmt.Errorf("撤销分享失败: %w", err)
+		}
+		return nil
+	})
 }
 
 // GetUserList 获取用户列表
@@ -224,26 +246,26 @@ func (s *userService) GetUserList(req *dto.GetUsersRequest) ([]*dto.UserResponse
 	if req.Keyword != "" {
 		query = query.Where("name LIKE ? OR email LIKE ?", "%"+req.Keyword+"%", "%"+req.Keyword+"%")
 	}
+	if req.Role != "" {
+		query = query.Where("role = ?", req.Role)
+	}
+	if req.Status != "" {
+		query = query.Where("is_active = ?", req.Status == "active")
+	}
 
 	err := query.Count(&total).Error
 	if err != nil {
 		return nil, 0, err
 	}
 
-	err = query.Offset(offset).Limit(req.PageSize).Find(&users).Error
+	err = query.Order("id").Offset(offset).Limit(req.PageSize).Find(&users).Error
 	if err != nil {
 		return nil, 0, err
 	}
 
 	var responses []*dto.UserResponse
 	for _, user := range users {
-		responses = append(responses, &dto.UserResponse{
-			ID:        user.ID,
-			Email:     user.Email,
-			Username:  user.Name,
-			CreatedAt: user.CreatedAt.Format(common.TimeLayout),
-			UpdatedAt: user.UpdatedAt.Format(common.TimeLayout),
-		})
+		responses = append(responses, newUserResponse(user))
 	}
 
 	return responses, total, nil
@@ -260,11 +282,5 @@ func (s *userService) GetUserByID(id uint) (*dto.UserResponse, error) {
 		return nil, err
 	}
 
-	return &dto.UserResponse{
-		ID:        user.ID,
-		Email:     user.Email,
-		Username:  user.Name,
-		CreatedAt: user.CreatedAt.Format(common.TimeLayout),
-		UpdatedAt: user.UpdatedAt.Format(common.TimeLayout),
-	}, nil
+	return newUserResponse(&user), nil
 }
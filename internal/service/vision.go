@@ -0,0 +1,241 @@
+package service
+
+import (
+	"ai-chat/internal/policy"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"mime"
+	"net/url"
+	"path"
+	"strings"
+)
+
+// MaxImagesPerMessage 每条消息最多的图片数
+const MaxImagesPerMessage = 10
+
+// ErrVisionNotSupported 所选模型不支持图片输入
+var ErrVisionNotSupported = errors.New("所选模型不支持图片输入，请切换到支持视觉的模型")
+
+// visionImageTypes 视觉模型普遍支持的图片格式
+var visionImageTypes = map[string]bool{
+	"image/png":  true,
+	"image/jpeg": true,
+	"image/gif":  true,
+	"image/webp": true,
+}
+
+// visionImageTypeList 视觉模型支持的图片格式列表
+func visionImageTypeList() []string {
+	types := make([]string, 0, len(visionImageTypes))
+	for contentType := range visionImageTypes {
+		types = append(types, contentType)
+	}
+	return types
+}
+
+// ContentPart 多段消息内容，格式与 OpenAI Chat Completions 一致
+type ContentPart struct {
+	Type     string    `json:"type"` // text 或 image_url
+	Text     string    `json:"text,omitempty"`
+	ImageURL *ImageURL `json:"image_url,omitempty"`
+}
+
+// ImageURL 图片地址，可以是 https 地址或 data:image/...;base64 形式的内联图片
+type ImageURL struct {
+	URL    string `json:"url"`
+	Detail string `json:"detail,omitempty"` // auto low high
+}
+
+// ImageInput 聊天请求中附带的图片
+type ImageInput struct {
+	URL    string `json:"url" binding:"required"`
+	Detail string `json:"detail,omitempty" binding:"omitempty,oneof=auto low high"`
+}
+
+// InlineImage 解码后的内联图片
+type InlineImage struct {
+	ContentType string
+	Data        []byte
+}
+
+// messageImageMetadata 消息元数据中记录的远程图片地址，内联图片保存为附件
+type messageImageMetadata struct {
+	ImageURLs []string `json:"imageUrls,omitempty"`
+}
+
+// MarshalJSON 包含图片时 content 序列化为多段数组，否则保持字符串以兼容只支持文本的接口
+func (m Message) MarshalJSON() ([]byte, error) {
+	if len(m.Parts) == 0 {
+		return json.Marshal(struct {
+			Role    string `json:"role"`
+			Content string `json:"content"`
+		}{m.Role, m.Content})
+	}
+
+	parts := m.Parts
+	if m.Content != "" {
+		parts = append([]ContentPart{{Type: "text", Text: m.Content}}, parts...)
+	}
+	return json.Marshal(struct {
+		Role    string        `json:"role"`
+		Content []ContentPart `json:"content"`
+	}{m.Role, parts})
+}
+
+// HasImages 消息列表中是否包含图片
+func HasImages(messages []Message) bool {
+	for _, message := range messages {
+		for _, part := range message.Parts {
+			if part.Type == "image_url" {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// SupportsVision 请求最终使用的模型是否支持图片输入，模型名按 VISION_MODELS 中的通配符匹配
+func (s *AIService) SupportsVision(req *ChatRequest) bool {
+	model := s.cfg.Model
+	if req.Endpoint != nil && req.Endpoint.Model != "" {
+		model = req.Endpoint.Model
+	}
+	if req.Model != nil && *req.Model != "" {
+		model = *req.Model
+	}
+
+	model = strings.ToLower(model)
+	for _, pattern := range s.cfg.VisionModels {
+		if ok, _ := path.Match(strings.ToLower(pattern), model); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// ParseImages 校验聊天请求中的图片，返回按顺序排列的消息内容、需要保存为附件的内联图片和远程图片地址
+// 内联图片受附件大小限制和用户存储配额约束
+func (s *AttachmentService) ParseImages(p policy.Principal, images []ImageInput) ([]ContentPart, []*InlineImage, []string, error) {
+	if len(images) > MaxImagesPerMessage {
+		return nil, nil, nil, fmt.Errorf("每条消息最多 %d 张图片", MaxImagesPerMessage)
+	}
+
+	var parts []ContentPart
+	var inline []*InlineImage
+	var remote []string
+	for i, image := range images {
+		if strings.HasPrefix(image.URL, "data:") {
+			decoded, err := decodeDataURL(image.URL, s.cfg.AttachmentMaxSize)
+			if err != nil {
+				return nil, nil, nil, fmt.Errorf("第 %d 张图片无效: %w", i+1, err)
+			}
+			inline = append(inline, decoded)
+		} else {
+			u, err := url.Parse(image.URL)
+			if err != nil || u.Scheme != "https" || u.Host == "" {
+				return nil, nil, nil, fmt.Errorf("第 %d 张图片无效: 只支持 https 地址或 base64 内联图片", i+1)
+			}
+			remote = append(remote, image.URL)
+		}
+		parts = append(parts, ContentPart{Type: "image_url", ImageURL: &ImageURL{URL: image.URL, Detail: image.Detail}})
+	}
+
+	var total int64
+	for _, image := range inline {
+		total += int64(len(image.Data))
+	}
+	if total > 0 {
+		if err := s.checkQuota(s.db, p.UserID, total); err != nil {
+			return nil, nil, nil, err
+		}
+	}
+	return parts, inline, remote, nil
+}
+
+// ImageMetadata 生成记录远程图片地址的消息元数据，没有远程图片时返回 nil
+func ImageMetadata(urls []string) *string {
+	if len(urls) == 0 {
+		return nil
+	}
+	data, _ := json.Marshal(messageImageMetadata{ImageURLs: urls})
+	metadata := string(data)
+	return &metadata
+}
+
+// messageImageURLs 读取消息元数据中的远程图片地址
+func messageImageURLs(metadata *string) []string {
+	if metadata == nil {
+		return nil
+	}
+	var parsed messageImageMetadata
+	if err := json.Unmarshal([]byte(*metadata), &parsed); err != nil {
+		return nil
+	}
+	return parsed.ImageURLs
+}
+
+// decodeDataURL 解码 data:image/...;base64 图片，按内容校验格式
+func decodeDataURL(dataURL string, maxSize int64) (*InlineImage, error) {
+	header, payload, ok := strings.Cut(strings.TrimPrefix(dataURL, "data:"), ",")
+	if !ok || !strings.HasSuffix(header, ";base64") {
+		return nil, errors.New("只支持 base64 编码的 data URL")
+	}
+	if int64(base64.StdEncoding.DecodedLen(len(payload))) > maxSize+2 {
+		return nil, ErrAttachmentTooLarge
+	}
+
+	data, err := base64.StdEncoding.DecodeString(payload)
+	if err != nil {
+		return nil, fmt.Errorf("base64 解码失败: %w", err)
+	}
+	if int64(len(data)) > maxSize {
+		return nil, ErrAttachmentTooLarge
+	}
+
+	contentType := detectContentType(data, "")
+	if !visionImageTypes[contentType] {
+		return nil, fmt.Errorf("%w: %s", ErrAttachmentTypeNotAllowed, contentType)
+	}
+	return &InlineImage{ContentType: contentType, Data: data}, nil
+}
+
+// toProviderFormat 转换为接口要求的图片格式
+// 智谱接口的内联图片需要去掉 data URL 前缀，只传 base64 内容；其他 OpenAI 兼容接口直接使用 data URL
+func toProviderFormat(messages []Message, baseURL string) []Message {
+	if !HasImages(messages) {
+		return messages
+	}
+
+	rawBase64 := false
+	if u, err := url.Parse(baseURL); err == nil && strings.HasSuffix(strings.ToLower(u.Hostname()), "bigmodel.cn") {
+		rawBase64 = true
+	}
+	if !rawBase64 {
+		return messages
+	}
+
+	converted := make([]Message, len(messages))
+	for i, message := range messages {
+		converted[i] = message
+		if len(message.Parts) == 0 {
+			continue
+		}
+		converted[i].Parts = make([]ContentPart, len(message.Parts))
+		for j, part := range message.Parts {
+			if part.ImageURL != nil && strings.HasPrefix(part.ImageURL.URL, "data:") {
+				_, payload, _ := strings.Cut(part.ImageURL.URL, ",")
+				part.ImageURL = &ImageURL{URL: payload, Detail: part.ImageURL.Detail}
+			}
+			converted[i].Parts[j] = part
+		}
+	}
+	return converted
+}
+
+// dataURL 生成 base64 内联图片地址
+func dataURL(contentType string, data []byte) string {
+	mediaType := mime.FormatMediaType(contentType, nil)
+	return "data:" + mediaType + ";base64," + base64.StdEncoding.EncodeToString(data)
+}
@@ -0,0 +1,391 @@
+package service
+
+import (
+	"ai-chat/config"
+	"ai-chat/internal/repository"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/go-webauthn/webauthn/protocol"
+	"github.com/go-webauthn/webauthn/webauthn"
+	"github.com/golang-jwt/jwt/v5"
+	"gorm.io/gorm"
+)
+
+const (
+	// webAuthnSessionTTL 注册/登录仪式的有效期
+	webAuthnSessionTTL = 5 * time.Minute
+	// TokenPurposeWebAuthn 已使用的 WebAuthn 挑战，记录在一次性令牌表中防止重放
+	TokenPurposeWebAuthn = "webauthn_challenge"
+
+	webAuthnRegistration = "webauthn_registration"
+	webAuthnLogin        = "webauthn_login"
+)
+
+// WebAuthnService 通行密钥服务
+// 仪式状态签名后交给客户端保存，服务端不需要会话存储
+type WebAuthnService struct {
+	db          *gorm.DB
+	cfg         *config.Config
+	authService *AuthService
+	webAuthn    *webauthn.WebAuthn
+}
+
+// NewWebAuthnService 创建通行密钥服务
+func NewWebAuthnService(db *gorm.DB, cfg *config.Config, authService *AuthService) (*WebAuthnService, error) {
+	w, err := webauthn.New(&webauthn.Config{
+		RPID:          cfg.WebAuthnRPID,
+		RPDisplayName: cfg.WebAuthnRPName,
+		RPOrigins:     cfg.WebAuthnOrigins,
+		AuthenticatorSelection: protocol.AuthenticatorSelection{
+			ResidentKey:      protocol.ResidentKeyRequirementRequired,
+			UserVerification: protocol.VerificationRequired,
+		},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("初始化 WebAuthn 失败: %w", err)
+	}
+
+	return &WebAuthnService{
+		db:          db,
+		cfg:         cfg,
+		authService: authService,
+		webAuthn:    w,
+	}, nil
+}
+
+// WebAuthnBeginResult 发起注册/登录仪式的结果
+type WebAuthnBeginResult struct {
+	Options      interface{} `json:"options"`      // 传给 navigator.credentials.create/get 的参数
+	SessionToken string      `json:"sessionToken"` // 完成仪式时原样带回
+}
+
+// webAuthnSessionClaims 仪式状态
+type webAuthnSessionClaims struct {
+	UserID  uint                 `json:"uid,omitempty"`
+	Session webauthn.SessionData `json:"session"`
+	jwt.RegisteredClaims
+}
+
+// BeginRegistration 为当前用户发起通行密钥注册
+func (s *WebAuthnService) BeginRegistration(userID uint) (*WebAuthnBeginResult, error) {
+	user, err := s.loadUser(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	exclusions := make([]protocol.CredentialDescriptor, 0, len(user.credentials))
+	for _, cred := range user.credentials {
+		exclusions = append(exclusions, cred.Descriptor())
+	}
+
+	creation, session, err := s.webAuthn.BeginRegistration(user, webauthn.WithExclusions(exclusions))
+	if err != nil {
+		return nil, fmt.Errorf("发起通行密钥注册失败: %w", err)
+	}
+
+	token, err := s.signSession(webAuthnRegistration, userID, session)
+	if err != nil {
+		return nil, err
+	}
+	return &WebAuthnBeginResult{Options: creation, SessionToken: token}, nil
+}
+
+// FinishRegistration 校验验证器返回的注册结果并保存通行密钥
+// body 为 navigator.credentials.create 返回的 PublicKeyCredential JSON
+func (s *WebAuthnService) FinishRegistration(userID uint, sessionToken, name string, body io.Reader) (*repository.WebAuthnCredential, error) {
+	claims, err := s.parseSession(webAuthnRegistration, sessionToken)
+	if err != nil {
+		return nil, err
+	}
+	if claims.UserID != userID {
+		return nil, errors.New("注册流程与当前用户不匹配")
+	}
+
+	parsed, err := protocol.ParseCredentialCreationResponseBody(body)
+	if err != nil {
+		return nil, fmt.Errorf("解析注册结果失败: %w", err)
+	}
+
+	user, err := s.loadUser(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	cred, err := s.webAuthn.CreateCredential(user, claims.Session, parsed)
+	if err != nil {
+		return nil, fmt.Errorf("通行密钥校验失败: %w", err)
+	}
+
+	if name = strings.TrimSpace(name); name == "" {
+		name = "通行密钥"
+	}
+	record := &repository.WebAuthnCredential{
+		UserID:          userID,
+		Name:            name,
+		CredentialID:    cred.ID,
+		PublicKey:       cred.PublicKey,
+		AttestationType: cred.AttestationType,
+		Transports:      joinTransports(cred.Transport),
+		AAGUID:          cred.Authenticator.AAGUID,
+		SignCount:       cred.Authenticator.SignCount,
+		BackupEligible:  cred.Flags.BackupEligible,
+		BackupState:     cred.Flags.BackupState,
+	}
+
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		if err := s.useChallenge(tx, userID, claims); err != nil {
+			return err
+		}
+		if err := tx.Create(record).Error; err != nil {
+			return fmt.Errorf("保存通行密钥失败: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return record, nil
+}
+
+// BeginLogin 发起通行密钥登录，使用可发现凭据，无需先输入邮箱
+func (s *WebAuthnService) BeginLogin() (*WebAuthnBeginResult, error) {
+	assertion, session, err := s.webAuthn.BeginDiscoverableLogin()
+	if err != nil {
+		return nil, fmt.Errorf("发起通行密钥登录失败: %w", err)
+	}
+
+	token, err := s.signSession(webAuthnLogin, 0, session)
+	if err != nil {
+		return nil, err
+	}
+	return &WebAuthnBeginResult{Options: assertion, SessionToken: token}, nil
+}
+
+// FinishLogin 校验验证器返回的断言并签发令牌
+// body 为 navigator.credentials.get 返回的 PublicKeyCredential JSON
+// 通行密钥要求用户验证（生物识别或 PIN），本身即为多因素，不再要求两步验证
+func (s *WebAuthnService) FinishLogin(sessionToken string, body io.Reader, client ClientInfo) (*AuthResponse, error) {
+	claims, err := s.parseSession(webAuthnLogin, sessionToken)
+	if err != nil {
+		return nil, err
+	}
+
+	parsed, err := protocol.ParseCredentialRequestResponseBody(body)
+	if err != nil {
+		return nil, fmt.Errorf("解析登录结果失败: %w", err)
+	}
+
+	var user *webAuthnUser
+	cred, err := s.webAuthn.ValidateDiscoverableLogin(func(rawID, userHandle []byte) (webauthn.User, error) {
+		if len(userHandle) != 8 {
+			return nil, errors.New("无效的用户标识")
+		}
+		user, err = s.loadUser(uint(binary.BigEndian.Uint64(userHandle)))
+		return user, err
+	}, claims.Session, parsed)
+	if err != nil {
+		return nil, errors.New("通行密钥校验失败")
+	}
+	if !user.IsActive {
+		return nil, errors.New("账户已被禁用")
+	}
+	if cred.Authenticator.CloneWarning {
+		return nil, errors.New("检测到通行密钥可能被复制，已拒绝登录")
+	}
+
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		if err := s.useChallenge(tx, user.ID, claims); err != nil {
+			return err
+		}
+		now := time.Now()
+		return tx.Model(&repository.WebAuthnCredential{}).
+			Where("user_id = ? AND credential_id = ?", user.ID, cred.ID).
+			Updates(map[string]interface{}{
+				"sign_count":   cred.Authenticator.SignCount,
+				"backup_state": cred.Flags.BackupState,
+				"last_used_at": now,
+			}).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	token, err := s.authService.GenerateJWT(user.User, client)
+	if err != nil {
+		return nil, fmt.Errorf("生成令牌失败: %w", err)
+	}
+	return &AuthResponse{User: user.User, Token: token}, nil
+}
+
+// ListCredentials 获取用户的通行密钥列表
+func (s *WebAuthnService) ListCredentials(userID uint) ([]repository.WebAuthnCredential, error) {
+	var creds []repository.WebAuthnCredential
+	if err := s.db.Where("user_id = ?", userID).Order("created_at DESC").Find(&creds).Error; err != nil {
+		return nil, fmt.Errorf("获取通行密钥失败: %w", err)
+	}
+	return creds, nil
+}
+
+// DeleteCredential 删除用户的通行密钥
+func (s *WebAuthnService) DeleteCredential(userID, id uint) error {
+	result := s.db.Where("id = ? AND user_id = ?", id, userID).Delete(&repository.WebAuthnCredential{})
+	if result.Error != nil {
+		return fmt.Errorf("删除通行密钥失败: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return errors.New("通行密钥不存在")
+	}
+	return nil
+}
+
+// signSession 签名仪式状态
+func (s *WebAuthnService) signSession(ceremony string, userID uint, session *webauthn.SessionData) (string, error) {
+	now := time.Now()
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, &webAuthnSessionClaims{
+		UserID:  userID,
+		Session: *session,
+		RegisteredClaims: jwt.RegisteredClaims{
+			Subject:   ceremony,
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(webAuthnSessionTTL)),
+		},
+	})
+
+	signed, err := token.SignedString([]byte(s.cfg.JWTSecret))
+	if err != nil {
+		return "", fmt.Errorf("生成会话令牌失败: %w", err)
+	}
+	return signed, nil
+}
+
+// parseSession 校验并解析仪式状态
+func (s *WebAuthnService) parseSession(ceremony, sessionToken string) (*webAuthnSessionClaims, error) {
+	var claims webAuthnSessionClaims
+	_, err := jwt.ParseWithClaims(sessionToken, &claims, func(token *jwt.Token) (interface{}, error) {
+		return []byte(s.cfg.JWTSecret), nil
+	}, jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}), jwt.WithSubject(ceremony))
+	if err != nil {
+		return nil, errors.New("操作已过期，请重试")
+	}
+	return &claims, nil
+}
+
+// useChallenge 记录已使用的挑战，同一挑战只能完成一次仪式
+// 很多通行密钥的签名计数器恒为 0，不能依赖计数器防重放
+func (s *WebAuthnService) useChallenge(tx *gorm.DB, userID uint, claims *webAuthnSessionClaims) error {
+	now := time.Now()
+	used := &repository.UserToken{
+		UserID:    userID,
+		Purpose:   TokenPurposeWebAuthn,
+		TokenHash: hashToken(claims.Subject + "." + claims.Session.Challenge),
+		ExpiresAt: claims.ExpiresAt.Time,
+		UsedAt:    &now,
+	}
+	var count int64
+	if err := tx.Model(&repository.UserToken{}).Where("token_hash = ?", used.TokenHash).Count(&count).Error; err != nil {
+		return fmt.Errorf("校验挑战失败: %w", err)
+	}
+	if count > 0 {
+		return errors.New("操作已完成，请勿重复提交")
+	}
+	if err := tx.Create(used).Error; err != nil {
+		return errors.New("操作已完成，请勿重复提交")
+	}
+	return nil
+}
+
+// loadUser 加载用户及其通行密钥
+func (s *WebAuthnService) loadUser(userID uint) (*webAuthnUser, error) {
+	var user repository.User
+	if err := s.db.First(&user, userID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New("用户不存在")
+		}
+		return nil, fmt.Errorf("查找用户失败: %w", err)
+	}
+
+	var records []repository.WebAuthnCredential
+	if err := s.db.Where("user_id = ?", userID).Find(&records).Error; err != nil {
+		return nil, fmt.Errorf("获取通行密钥失败: %w", err)
+	}
+
+	u := &webAuthnUser{User: &user}
+	for _, r := range records {
+		u.credentials = append(u.credentials, webauthn.Credential{
+			ID:              r.CredentialID,
+			PublicKey:       r.PublicKey,
+			AttestationType: r.AttestationType,
+			Transport:       splitTransports(r.Transports),
+			Flags: webauthn.CredentialFlags{
+				BackupEligible: r.BackupEligible,
+				BackupState:    r.BackupState,
+			},
+			Authenticator: webauthn.Authenticator{
+				AAGUID:    r.AAGUID,
+				SignCount: r.SignCount,
+			},
+		})
+	}
+	return u, nil
+}
+
+// webAuthnUser 适配 webauthn.User 接口
+type webAuthnUser struct {
+	*repository.User
+	credentials []webauthn.Credential
+}
+
+// WebAuthnID 用户句柄，使用 8 字节大端序的用户ID，不包含个人信息
+func (u *webAuthnUser) WebAuthnID() []byte {
+	id := make([]byte, 8)
+	binary.BigEndian.PutUint64(id, uint64(u.ID))
+	return id
+}
+
+// WebAuthnName 账户名
+func (u *webAuthnUser) WebAuthnName() string {
+	return u.Email
+}
+
+// WebAuthnDisplayName 显示名称
+func (u *webAuthnUser) WebAuthnDisplayName() string {
+	if u.Name != "" {
+		return u.Name
+	}
+	return u.Email
+}
+
+// WebAuthnCredentials 用户已注册的通行密钥
+func (u *webAuthnUser) WebAuthnCredentials() []webauthn.Credential {
+	return u.credentials
+}
+
+// WebAuthnIcon 已废弃
+func (u *webAuthnUser) WebAuthnIcon() string {
+	return ""
+}
+
+// joinTransports 将传输方式保存为逗号分隔字符串
+func joinTransports(transports []protocol.AuthenticatorTransport) string {
+	values := make([]string, len(transports))
+	for i, t := range transports {
+		values[i] = string(t)
+	}
+	return strings.Join(values, ",")
+}
+
+// splitTransports 解析逗号分隔的传输方式
+func splitTransports(value string) []protocol.AuthenticatorTransport {
+	var transports []protocol.AuthenticatorTransport
+	for _, t := range strings.Split(value, ",") {
+		if t != "" {
+			transports = append(transports, protocol.AuthenticatorTransport(t))
+		}
+	}
+	return transports
+}
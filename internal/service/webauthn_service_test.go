@@ -0,0 +1,357 @@
+package service
+
+import (
+	"ai-chat/config"
+	"ai-chat/internal/repository"
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/fxamacker/cbor/v2"
+	"github.com/go-webauthn/webauthn/protocol"
+	"github.com/go-webauthn/webauthn/webauthn"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+const (
+	testRPID   = "chat.example.com"
+	testOrigin = "https://chat.example.com"
+)
+
+// 验证器数据标志位
+const (
+	flagUserPresent  = 0x01
+	flagUserVerified = 0x04
+	flagAttestedData = 0x40
+)
+
+// softAuthenticator 软件实现的平台验证器，使用 P-256 密钥和 none 证明
+type softAuthenticator struct {
+	key          *ecdsa.PrivateKey
+	credentialID []byte
+	userHandle   []byte
+	signCount    uint32
+}
+
+// newSoftAuthenticator 创建软件验证器
+func newSoftAuthenticator(t *testing.T) *softAuthenticator {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	id := make([]byte, 16)
+	if _, err := rand.Read(id); err != nil {
+		t.Fatal(err)
+	}
+	return &softAuthenticator{key: key, credentialID: id}
+}
+
+// coseKey EC2 公钥的 COSE 编码
+type coseKey struct {
+	Kty int    `cbor:"1,keyasint"`
+	Alg int    `cbor:"3,keyasint"`
+	Crv int    `cbor:"-1,keyasint"`
+	X   []byte `cbor:"-2,keyasint"`
+	Y   []byte `cbor:"-3,keyasint"`
+}
+
+// attestationObject none 格式的证明对象
+type attestationObject struct {
+	Fmt      string                 `cbor:"fmt"`
+	AttStmt  map[string]interface{} `cbor:"attStmt"`
+	AuthData []byte                 `cbor:"authData"`
+}
+
+// create 模拟 navigator.credentials.create，返回 PublicKeyCredential JSON
+func (a *softAuthenticator) create(t *testing.T, options *protocol.CredentialCreation) []byte {
+	t.Helper()
+	a.userHandle = options.Response.User.ID.(protocol.URLEncodedBase64)
+
+	publicKey, err := cbor.Marshal(coseKey{
+		Kty: 2,  // EC2
+		Alg: -7, // ES256
+		Crv: 1,  // P-256
+		X:   a.key.PublicKey.X.FillBytes(make([]byte, 32)),
+		Y:   a.key.PublicKey.Y.FillBytes(make([]byte, 32)),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	authData := a.authData(options.Response.RelyingParty.ID, flagUserPresent|flagUserVerified|flagAttestedData)
+	authData = append(authData, make([]byte, 16)...) // AAGUID
+	authData = binary.BigEndian.AppendUint16(authData, uint16(len(a.credentialID)))
+	authData = append(authData, a.credentialID...)
+	authData = append(authData, publicKey...)
+
+	object, err := cbor.Marshal(attestationObject{Fmt: "none", AttStmt: map[string]interface{}{}, AuthData: authData})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return a.credential(t, map[string]interface{}{
+		"clientDataJSON":    encode(clientData(t, protocol.CreateCeremony, options.Response.Challenge)),
+		"attestationObject": encode(object),
+		"transports":        []string{"internal"},
+	})
+}
+
+// get 模拟 navigator.credentials.get，返回 PublicKeyCredential JSON
+func (a *softAuthenticator) get(t *testing.T, options *protocol.CredentialAssertion) []byte {
+	t.Helper()
+	a.signCount++
+	authData := a.authData(options.Response.RelyingPartyID, flagUserPresent|flagUserVerified)
+	data := clientData(t, protocol.AssertCeremony, options.Response.Challenge)
+
+	digest := sha256.Sum256(data)
+	signature, err := ecdsa.SignASN1(rand.Reader, a.key, sha256Sum(append(authData, digest[:]...)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return a.credential(t, map[string]interface{}{
+		"clientDataJSON":    encode(data),
+		"authenticatorData": encode(authData),
+		"signature":         encode(signature),
+		"userHandle":        encode(a.userHandle),
+	})
+}
+
+// authData 验证器数据的固定部分：RP ID 哈希、标志位和签名计数
+func (a *softAuthenticator) authData(rpID string, flags byte) []byte {
+	hash := sha256.Sum256([]byte(rpID))
+	data := append(hash[:], flags)
+	return binary.BigEndian.AppendUint32(data, a.signCount)
+}
+
+// credential 包装为 PublicKeyCredential JSON
+func (a *softAuthenticator) credential(t *testing.T, response map[string]interface{}) []byte {
+	t.Helper()
+	body, err := json.Marshal(map[string]interface{}{
+		"id":       encode(a.credentialID),
+		"rawId":    encode(a.credentialID),
+		"type":     "public-key",
+		"response": response,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return body
+}
+
+// clientData 浏览器生成的 clientDataJSON
+func clientData(t *testing.T, ceremony protocol.CeremonyType, challenge protocol.URLEncodedBase64) []byte {
+	t.Helper()
+	data, err := json.Marshal(protocol.CollectedClientData{
+		Type:      ceremony,
+		Challenge: challenge.String(),
+		Origin:    testOrigin,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+func encode(b []byte) string {
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+func sha256Sum(b []byte) []byte {
+	sum := sha256.Sum256(b)
+	return sum[:]
+}
+
+// newTestWebAuthnService 创建使用测试依赖方配置的通行密钥服务
+func newTestWebAuthnService(t *testing.T, db *gorm.DB) *WebAuthnService {
+	t.Helper()
+	cfg := &config.Config{
+		JWTSecret:       "test-secret",
+		WebAuthnRPID:    testRPID,
+		WebAuthnRPName:  "AI Chat",
+		WebAuthnOrigins: []string{testOrigin},
+	}
+	var authService *AuthService
+	if db != nil {
+		authService = NewAuthService(db, cfg, NewSettingService(db), nil)
+	}
+	s, err := NewWebAuthnService(db, cfg, authService)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+// TestWebAuthnCeremony 不依赖数据库，校验软件验证器在服务的依赖方配置下完成注册和登录
+func TestWebAuthnCeremony(t *testing.T) {
+	s := newTestWebAuthnService(t, nil)
+	user := &webAuthnUser{User: &repository.User{ID: 42, Email: "alice@example.com", IsActive: true}}
+	auth := newSoftAuthenticator(t)
+
+	creation, session, err := s.webAuthn.BeginRegistration(user)
+	if err != nil {
+		t.Fatal(err)
+	}
+	token, err := s.signSession(webAuthnRegistration, user.ID, session)
+	if err != nil {
+		t.Fatal(err)
+	}
+	claims, err := s.parseSession(webAuthnRegistration, token)
+	if err != nil || claims.UserID != user.ID {
+		t.Fatalf("parseSession = %+v, %v", claims, err)
+	}
+	if _, err := s.parseSession(webAuthnLogin, token); err == nil {
+		t.Fatal("registration token accepted for login ceremony")
+	}
+
+	parsed, err := protocol.ParseCredentialCreationResponseBody(bytes.NewReader(auth.create(t, creation)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	cred, err := s.webAuthn.CreateCredential(user, claims.Session, parsed)
+	if err != nil {
+		t.Fatalf("CreateCredential: %v", err)
+	}
+	if !bytes.Equal(cred.ID, auth.credentialID) {
+		t.Fatalf("credential ID = %x, want %x", cred.ID, auth.credentialID)
+	}
+	user.credentials = append(user.credentials, *cred)
+
+	assertion, session, err := s.webAuthn.BeginDiscoverableLogin()
+	if err != nil {
+		t.Fatal(err)
+	}
+	validate := func(body []byte, session webauthn.SessionData) error {
+		parsed, err := protocol.ParseCredentialRequestResponseBody(bytes.NewReader(body))
+		if err != nil {
+			return err
+		}
+		_, err = s.webAuthn.ValidateDiscoverableLogin(func(rawID, userHandle []byte) (webauthn.User, error) {
+			if !bytes.Equal(userHandle, user.WebAuthnID()) {
+				return nil, fmt.Errorf("unexpected user handle %x", userHandle)
+			}
+			return user, nil
+		}, session, parsed)
+		return err
+	}
+
+	body := auth.get(t, assertion)
+	if err := validate(body, *session); err != nil {
+		t.Fatalf("ValidateDiscoverableLogin: %v", err)
+	}
+
+	// 旧的断言不能用于新的挑战
+	_, next, err := s.webAuthn.BeginDiscoverableLogin()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := validate(body, *next); err == nil {
+		t.Fatal("assertion for a previous challenge was accepted")
+	}
+}
+
+// openTestDB 连接 TEST_DATABASE_URL 指定的 PostgreSQL 测试库，未设置时跳过
+func openTestDB(t *testing.T) *gorm.DB {
+	t.Helper()
+	dsn := os.Getenv("TEST_DATABASE_URL")
+	if dsn == "" {
+		t.Skip("TEST_DATABASE_URL not set")
+	}
+	db, err := gorm.Open(postgres.Open(dsn), &gorm.Config{Logger: logger.Default.LogMode(logger.Silent)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := repository.AutoMigrate(db); err != nil {
+		t.Fatal(err)
+	}
+	return db
+}
+
+// TestWebAuthnService 完整的注册和登录流程，同一挑战重复提交时拒绝
+func TestWebAuthnService(t *testing.T) {
+	db := openTestDB(t)
+	s := newTestWebAuthnService(t, db)
+
+	user := &repository.User{
+		Name:     "Alice",
+		Email:    fmt.Sprintf("webauthn-%d@example.com", time.Now().UnixNano()),
+		Password: "-",
+		Salt:     "-",
+		IsActive: true,
+		Role:     "user",
+	}
+	if err := db.Create(user).Error; err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		db.Where("user_id = ?", user.ID).Delete(&repository.WebAuthnCredential{})
+		db.Where("user_id = ?", user.ID).Delete(&repository.UserToken{})
+		db.Where("user_id = ?", user.ID).Delete(&repository.UserSession{})
+		db.Unscoped().Delete(user)
+	})
+
+	auth := newSoftAuthenticator(t)
+
+	// 注册
+	begin, err := s.BeginRegistration(user.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	creation := begin.Options.(*protocol.CredentialCreation)
+	record, err := s.FinishRegistration(user.ID, begin.SessionToken, " Laptop ", bytes.NewReader(auth.create(t, creation)))
+	if err != nil {
+		t.Fatalf("FinishRegistration: %v", err)
+	}
+	if record.Name != "Laptop" || !bytes.Equal(record.CredentialID, auth.credentialID) {
+		t.Fatalf("record = %+v", record)
+	}
+	if _, err := s.FinishRegistration(user.ID+1, begin.SessionToken, "", bytes.NewReader(auth.create(t, creation))); err == nil {
+		t.Fatal("registration finished for another user")
+	}
+
+	// 同一挑战注册第二个验证器
+	replay := newSoftAuthenticator(t)
+	if _, err := s.FinishRegistration(user.ID, begin.SessionToken, "", bytes.NewReader(replay.create(t, creation))); err == nil || !strings.Contains(err.Error(), "重复提交") {
+		t.Fatalf("replayed registration: err = %v", err)
+	}
+
+	// 登录
+	login, err := s.BeginLogin()
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertion := login.Options.(*protocol.CredentialAssertion)
+	resp, err := s.FinishLogin(login.SessionToken, bytes.NewReader(auth.get(t, assertion)), ClientInfo{UserAgent: "test", IP: "127.0.0.1"})
+	if err != nil {
+		t.Fatalf("FinishLogin: %v", err)
+	}
+	if resp.User.ID != user.ID || resp.Token == nil || resp.Token.AccessToken == "" {
+		t.Fatalf("FinishLogin = %+v", resp)
+	}
+
+	var stored repository.WebAuthnCredential
+	if err := db.Where("user_id = ?", user.ID).First(&stored).Error; err != nil {
+		t.Fatal(err)
+	}
+	if stored.SignCount != auth.signCount || stored.LastUsedAt == nil {
+		t.Fatalf("stored credential = %+v", stored)
+	}
+
+	// 签名计数递增的新断言仍不能重用同一挑战
+	if _, err := s.FinishLogin(login.SessionToken, bytes.NewReader(auth.get(t, assertion)), ClientInfo{}); err == nil || !strings.Contains(err.Error(), "重复提交") {
+		t.Fatalf("replayed login: err = %v", err)
+	}
+}
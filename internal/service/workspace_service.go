@@ -0,0 +1,465 @@
+package service
+
+import (
+	"ai-chat/config"
+	"ai-chat/internal/common"
+	"ai-chat/internal/policy"
+	"ai-chat/internal/repository"
+	"ai-chat/internal/secret"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// WorkspaceService 工作区服务
+type WorkspaceService struct {
+	db    *gorm.DB
+	cfg   *config.Config
+	authz *Authorizer
+	box   *secret.Box // 加密工作区的模型接口密钥
+}
+
+// NewWorkspaceService 创建工作区服务
+func NewWorkspaceService(db *gorm.DB, cfg *config.Config) (*WorkspaceService, error) {
+	box, err := secret.New(cfg.JWTSecret, "workspace_provider_key")
+	if err != nil {
+		return nil, err
+	}
+	return &WorkspaceService{
+		db:    db,
+		cfg:   cfg,
+		authz: NewAuthorizer(db),
+		box:   box,
+	}, nil
+}
+
+// CreateWorkspaceRequest 创建工作区请求
+type CreateWorkspaceRequest struct {
+	Name string `json:"name" binding:"required,min=1,max=100"`
+}
+
+// UpdateWorkspaceRequest 更新工作区请求，空字符串表示恢复使用系统配置
+type UpdateWorkspaceRequest struct {
+	Name            *string `json:"name,omitempty" binding:"omitempty,min=1,max=100"`
+	DefaultModel    *string `json:"defaultModel,omitempty" binding:"omitempty,max=100"`
+	ProviderBaseURL *string `json:"providerBaseUrl,omitempty" binding:"omitempty,max=255"`
+	ProviderAPIKey  *string `json:"providerApiKey,omitempty" binding:"omitempty,max=256"`
+}
+
+// AddWorkspaceMemberRequest 添加成员请求
+type AddWorkspaceMemberRequest struct {
+	Email string `json:"email" binding:"required,email"`
+	Role  string `json:"role" binding:"required,oneof=admin member viewer"`
+}
+
+// UpdateWorkspaceMemberRequest 修改成员角色请求
+type UpdateWorkspaceMemberRequest struct {
+	Role string `json:"role" binding:"required,oneof=admin member viewer"`
+}
+
+// WorkspaceResponse 工作区响应
+type WorkspaceResponse struct {
+	ID              uint      `json:"id"`
+	Name            string    `json:"name"`
+	OwnerID         uint      `json:"ownerId"`
+	Role            string    `json:"role,omitempty"` // 当前用户在工作区中的角色
+	DefaultModel    *string   `json:"defaultModel"`
+	ProviderBaseURL *string   `json:"providerBaseUrl"`
+	HasProviderKey  bool      `json:"hasProviderKey"` // 密钥不返回给客户端
+	CreatedAt       time.Time `json:"createdAt"`
+	UpdatedAt       time.Time `json:"updatedAt"`
+}
+
+// WorkspaceMemberResponse 工作区成员响应
+type WorkspaceMemberResponse struct {
+	UserID   uint      `json:"userId"`
+	Name     string    `json:"name"`
+	Email    string    `json:"email"`
+	Role     string    `json:"role"`
+	JoinedAt time.Time `json:"joinedAt"`
+}
+
+// Create 创建工作区，创建者成为所有者
+func (s *WorkspaceService) Create(p policy.Principal, req *CreateWorkspaceRequest) (*WorkspaceResponse, error) {
+	workspace := &repository.Workspace{
+		Name:    strings.TrimSpace(req.Name),
+		OwnerID: p.UserID,
+	}
+
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(workspace).Error; err != nil {
+			return fmt.Errorf("创建工作区失败: %w", err)
+		}
+		if err := tx.Create(&repository.WorkspaceMember{
+			WorkspaceID: workspace.ID,
+			UserID:      p.UserID,
+			Role:        common.WorkspaceRoleOwner,
+		}).Error; err != nil {
+			return fmt.Errorf("添加工作区成员失败: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return s.toResponse(workspace, common.WorkspaceRoleOwner), nil
+}
+
+// List 获取主体加入的全部工作区，用于工作区切换
+func (s *WorkspaceService) List(p policy.Principal) ([]*WorkspaceResponse, error) {
+	var members []repository.WorkspaceMember
+	if err := s.db.Where("user_id = ?", p.UserID).Find(&members).Error; err != nil {
+		return nil, fmt.Errorf("查询工作区失败: %w", err)
+	}
+	if len(members) == 0 {
+		return []*WorkspaceResponse{}, nil
+	}
+
+	roles := make(map[uint]string, len(members))
+	ids := make([]uint, 0, len(members))
+	for _, m := range members {
+		roles[m.WorkspaceID] = m.Role
+		ids = append(ids, m.WorkspaceID)
+	}
+
+	var workspaces []*repository.Workspace
+	if err := s.db.Where("id IN ?", ids).Order("name").Find(&workspaces).Error; err != nil {
+		return nil, fmt.Errorf("查询工作区失败: %w", err)
+	}
+
+	items := make([]*WorkspaceResponse, len(workspaces))
+	for i, w := range workspaces {
+		items[i] = s.toResponse(w, roles[w.ID])
+	}
+	return items, nil
+}
+
+// Get 获取工作区详情
+func (s *WorkspaceService) Get(p policy.Principal, id uint) (*WorkspaceResponse, error) {
+	workspace, err := s.authz.Workspace(p, policy.ActionRead, id)
+	if err != nil {
+		return nil, err
+	}
+	return s.toResponse(workspace, s.memberRole(id, p.UserID)), nil
+}
+
+// Update 更新工作区名称、默认模型和模型接口配置，需要工作区管理员
+func (s *WorkspaceService) Update(p policy.Principal, id uint, req *UpdateWorkspaceRequest) (*WorkspaceResponse, error) {
+	workspace, err := s.authz.Workspace(p, policy.ActionWrite, id)
+	if err != nil {
+		return nil, err
+	}
+
+	updates := make(map[string]interface{})
+	if req.Name != nil {
+		updates["name"] = strings.TrimSpace(*req.Name)
+	}
+	if req.DefaultModel != nil {
+		updates["default_model"] = nullableString(*req.DefaultModel)
+	}
+
+	hasBaseURL := workspace.ProviderBaseURL != nil
+	if req.ProviderBaseURL != nil {
+		baseURL := strings.TrimRight(strings.TrimSpace(*req.ProviderBaseURL), "/")
+		if baseURL != "" {
+			if err := s.checkProviderBaseURL(baseURL); err != nil {
+				return nil, err
+			}
+		}
+		updates["provider_base_url"] = nullableString(baseURL)
+		hasBaseURL = baseURL != ""
+	}
+	hasKey := workspace.ProviderAPIKey != ""
+	if req.ProviderAPIKey != nil {
+		key := strings.TrimSpace(*req.ProviderAPIKey)
+		if key != "" {
+			if key, err = s.box.Encrypt(key); err != nil {
+				return nil, err
+			}
+		}
+		updates["provider_api_key"] = key
+		hasKey = key != ""
+	}
+	// 系统密钥只发送给系统配置的接口，自定义接口地址必须配置工作区自己的密钥
+	if hasBaseURL && !hasKey {
+		return nil, errors.New("使用自定义模型接口地址时必须填写接口密钥")
+	}
+
+	if len(updates) > 0 {
+		if err := s.db.Model(workspace).Updates(updates).Error; err != nil {
+			return nil, fmt.Errorf("更新工作区失败: %w", err)
+		}
+		s.db.First(workspace, id)
+	}
+
+	return s.toResponse(workspace, s.memberRole(id, p.UserID)), nil
+}
+
+// Delete 删除工作区及其中的全部内容，仅所有者可以删除
+// 消息附件随消息软删除后由附件清理任务删除记录和文件
+func (s *WorkspaceService) Delete(p policy.Principal, id uint) error {
+	if _, err := s.authz.Workspace(p, policy.ActionDelete, id); err != nil {
+		return err
+	}
+
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		// 子查询在执行时求值，包含已软删除的内容，按依赖顺序先删除关联再删除主体
+		owned := func(model interface{}) *gorm.DB {
+			return tx.Unscoped().Model(model).Select("id").Where("workspace_id = ?", id)
+		}
+		conversations := owned(&repository.Conversation{})
+		fixedPrompts := owned(&repository.FixedPrompt{})
+		assistants := owned(&repository.Assistant{})
+		knowledgeBases := owned(&repository.KnowledgeBase{})
+		documents := owned(&repository.Document{})
+
+		cascade := []struct {
+			model interface{}
+			where string
+			arg   interface{}
+			name  string
+		}{
+			{&repository.ConversationShare{}, "conversation_id IN (?)", conversations, "会话分享"},
+			{&repository.ConversationKnowledgeBase{}, "conversation_id IN (?)", conversations, "会话知识库关联"},
+			{&repository.MessageEmbedding{}, "conversation_id IN (?)", conversations, "消息向量"},
+			{&repository.Message{}, "conversation_id IN (?)", conversations, "消息"},
+			{&repository.Conversation{}, "workspace_id = ?", id, "会话"},
+			{&repository.FixedPromptVersion{}, "fixed_prompt_id IN (?)", fixedPrompts, "提示词版本"},
+			{&repository.FixedPromptKnowledgeBase{}, "fixed_prompt_id IN (?)", fixedPrompts, "提示词知识库关联"},
+			{&repository.FixedPrompt{}, "workspace_id = ?", id, "提示词"},
+			{&repository.AssistantKnowledgeBase{}, "assistant_id IN (?)", assistants, "助手知识库关联"},
+			{&repository.Assistant{}, "workspace_id = ?", id, "助手"},
+			{&repository.KnowledgeBaseDocument{}, "knowledge_base_id IN (?)", knowledgeBases, "知识库文档"},
+			{&repository.ConversationKnowledgeBase{}, "knowledge_base_id IN (?)", knowledgeBases, "会话知识库关联"},
+			{&repository.FixedPromptKnowledgeBase{}, "knowledge_base_id IN (?)", knowledgeBases, "提示词知识库关联"},
+			{&repository.AssistantKnowledgeBase{}, "knowledge_base_id IN (?)", knowledgeBases, "助手知识库关联"},
+			{&repository.KnowledgeBase{}, "workspace_id = ?", id, "知识库"},
+			{&repository.KnowledgeBaseDocument{}, "document_id IN (?)", documents, "知识库文档"},
+			{&repository.DocumentChunk{}, "document_id IN (?)", documents, "文档片段"},
+			{&repository.Document{}, "workspace_id = ?", id, "文档"},
+			{&repository.WorkspaceMember{}, "workspace_id = ?", id, "成员"},
+		}
+		for _, step := range cascade {
+			if err := tx.Where(step.where, step.arg).Delete(step.model).Error; err != nil {
+				return fmt.Errorf("删除工作区%s失败: %w", step.name, err)
+			}
+		}
+
+		if err := tx.Delete(&repository.Workspace{}, id).Error; err != nil {
+			return fmt.Errorf("删除工作区失败: %w", err)
+		}
+		return nil
+	})
+}
+
+// ListMembers 获取工作区成员
+func (s *WorkspaceService) ListMembers(p policy.Principal, id uint) ([]*WorkspaceMemberResponse, error) {
+	if _, err := s.authz.Workspace(p, policy.ActionRead, id); err != nil {
+		return nil, err
+	}
+
+	var rows []struct {
+		repository.WorkspaceMember
+		Name  string
+		Email string
+	}
+	if err := s.db.Model(&repository.WorkspaceMember{}).
+		Select("workspace_members.*, users.name, users.email").
+		Joins("JOIN users ON users.id = workspace_members.user_id AND users.deleted_at IS NULL").
+		Where("workspace_members.workspace_id = ?", id).
+		Order("workspace_members.created_at").
+		Scan(&rows).Error; err != nil {
+		return nil, fmt.Errorf("查询工作区成员失败: %w", err)
+	}
+
+	items := make([]*WorkspaceMemberResponse, len(rows))
+	for i, row := range rows {
+		items[i] = &WorkspaceMemberResponse{
+			UserID:   row.UserID,
+			Name:     row.Name,
+			Email:    row.Email,
+			Role:     row.Role,
+			JoinedAt: row.CreatedAt,
+		}
+	}
+	return items, nil
+}
+
+// AddMember 按邮箱添加成员，需要工作区管理员
+func (s *WorkspaceService) AddMember(p policy.Principal, id uint, req *AddWorkspaceMemberRequest) error {
+	if _, err := s.authz.Workspace(p, policy.ActionWrite, id); err != nil {
+		return err
+	}
+
+	var user repository.User
+	if err := s.db.Where("email = ?", strings.TrimSpace(req.Email)).First(&user).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return errors.New("用户不存在")
+		}
+		return fmt.Errorf("查找用户失败: %w", err)
+	}
+
+	var count int64
+	if err := s.db.Model(&repository.WorkspaceMember{}).
+		Where("workspace_id = ? AND user_id = ?", id, user.ID).
+		Count(&count).Error; err != nil {
+		return fmt.Errorf("查找工作区成员失败: %w", err)
+	}
+	if count > 0 {
+		return errors.New("该用户已是工作区成员")
+	}
+
+	if err := s.db.Create(&repository.WorkspaceMember{
+		WorkspaceID: id,
+		UserID:      user.ID,
+		Role:        req.Role,
+	}).Error; err != nil {
+		return fmt.Errorf("添加工作区成员失败: %w", err)
+	}
+	return nil
+}
+
+// UpdateMember 修改成员角色，需要工作区管理员，所有者的角色不能修改
+func (s *WorkspaceService) UpdateMember(p policy.Principal, id, userID uint, role string) error {
+	if _, err := s.authz.Workspace(p, policy.ActionWrite, id); err != nil {
+		return err
+	}
+
+	member, err := s.findMember(id, userID)
+	if err != nil {
+		return err
+	}
+	if member.Role == common.WorkspaceRoleOwner {
+		return errors.New("不能修改工作区所有者的角色")
+	}
+
+	if err := s.db.Model(member).Update("role", role).Error; err != nil {
+		return fmt.Errorf("修改成员角色失败: %w", err)
+	}
+	return nil
+}
+
+// RemoveMember 移除成员，成员可以移除自己（退出工作区），所有者不能退出
+func (s *WorkspaceService) RemoveMember(p policy.Principal, id, userID uint) error {
+	action := policy.ActionWrite
+	if userID == p.UserID {
+		action = policy.ActionRead
+	}
+	if _, err := s.authz.Workspace(p, action, id); err != nil {
+		return err
+	}
+
+	member, err := s.findMember(id, userID)
+	if err != nil {
+		return err
+	}
+	if member.Role == common.WorkspaceRoleOwner {
+		return errors.New("不能移除工作区所有者")
+	}
+
+	if err := s.db.Delete(member).Error; err != nil {
+		return fmt.Errorf("移除工作区成员失败: %w", err)
+	}
+	return nil
+}
+
+// AIEndpoint 返回会话所属工作区的模型接口配置，个人会话或工作区未配置时返回 nil
+func (s *WorkspaceService) AIEndpoint(conversationID uint) (*AIEndpoint, error) {
+	var conversation repository.Conversation
+	if err := s.db.Select("id", "workspace_id").First(&conversation, conversationID).Error; err != nil {
+		return nil, notFoundOr(err, "查找会话失败")
+	}
+	return s.WorkspaceAIEndpoint(conversation.WorkspaceID)
+}
+
+// WorkspaceAIEndpoint 返回工作区的模型接口配置，workspaceID 为空或工作区未配置时返回 nil
+func (s *WorkspaceService) WorkspaceAIEndpoint(workspaceID *uint) (*AIEndpoint, error) {
+	if workspaceID == nil {
+		return nil, nil
+	}
+
+	var workspace repository.Workspace
+	if err := s.db.First(&workspace, *workspaceID).Error; err != nil {
+		return nil, notFoundOr(err, "查找工作区失败")
+	}
+
+	endpoint := &AIEndpoint{}
+	if workspace.DefaultModel != nil {
+		endpoint.Model = *workspace.DefaultModel
+	}
+	if workspace.ProviderBaseURL != nil {
+		endpoint.BaseURL = *workspace.ProviderBaseURL
+	}
+	if workspace.ProviderAPIKey != "" {
+		key, err := s.box.Decrypt(workspace.ProviderAPIKey)
+		if err != nil {
+			return nil, fmt.Errorf("解密工作区密钥失败: %w", err)
+		}
+		endpoint.APIKey = key
+	}
+	return endpoint, nil
+}
+
+// checkProviderBaseURL 工作区只能使用允许列表中的 https 接口，避免把服务端请求指向内网地址
+func (s *WorkspaceService) checkProviderBaseURL(baseURL string) error {
+	u, err := url.Parse(baseURL)
+	if err != nil || u.Scheme != "https" || u.Host == "" {
+		return errors.New("模型接口地址必须是 https 地址")
+	}
+	for _, host := range s.cfg.WorkspaceProviderHosts {
+		if strings.EqualFold(u.Hostname(), host) {
+			return nil
+		}
+	}
+	return errors.New("不允许使用该模型接口地址")
+}
+
+// findMember 查找工作区成员
+func (s *WorkspaceService) findMember(workspaceID, userID uint) (*repository.WorkspaceMember, error) {
+	var member repository.WorkspaceMember
+	if err := s.db.Where("workspace_id = ? AND user_id = ?", workspaceID, userID).First(&member).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New("成员不存在")
+		}
+		return nil, fmt.Errorf("查找工作区成员失败: %w", err)
+	}
+	return &member, nil
+}
+
+// memberRole 用户在工作区中的角色，不是成员（如系统管理员）时为空
+func (s *WorkspaceService) memberRole(workspaceID, userID uint) string {
+	var member repository.WorkspaceMember
+	if err := s.db.Where("workspace_id = ? AND user_id = ?", workspaceID, userID).First(&member).Error; err != nil {
+		return ""
+	}
+	return member.Role
+}
+
+// toResponse 转换为响应结构
+func (s *WorkspaceService) toResponse(w *repository.Workspace, role string) *WorkspaceResponse {
+	return &WorkspaceResponse{
+		ID:              w.ID,
+		Name:            w.Name,
+		OwnerID:         w.OwnerID,
+		Role:            role,
+		DefaultModel:    w.DefaultModel,
+		ProviderBaseURL: w.ProviderBaseURL,
+		HasProviderKey:  w.ProviderAPIKey != "",
+		CreatedAt:       w.CreatedAt,
+		UpdatedAt:       w.UpdatedAt,
+	}
+}
+
+// nullableString 空字符串转为 nil
+func nullableString(s string) *string {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return nil
+	}
+	return &s
+}
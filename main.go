@@ -1,10 +1,15 @@
 package main
 
 import (
+	"context"
 	"log"
+	"time"
 
 	"ai-chat/config"
+	"ai-chat/internal/blobstore"
+	"ai-chat/internal/embedding"
 	"ai-chat/internal/handler"
+	"ai-chat/internal/mailer"
 	"ai-chat/internal/repository"
 	"ai-chat/internal/router"
 	"ai-chat/internal/service"
@@ -28,27 +33,139 @@ func main() {
 		log.Fatal("Failed to migrate database:", err)
 	}
 
+	// 初始化邮件发送器
+	mail, err := mailer.New(cfg)
+	if err != nil {
+		log.Fatal("Failed to init mailer:", err)
+	}
+
 	// 初始化服务层
-	userService := service.NewUserService(db)
-	authService := service.NewAuthService(db, cfg)
+	authenticators, err := service.NewAuthenticators(db, cfg)
+	if err != nil {
+		log.Fatal("Failed to init authenticators:", err)
+	}
+	settingService := service.NewSettingService(db)
+	sessionService := service.NewSessionService(db)
+	userService := service.NewUserService(db, sessionService)
+	authService := service.NewAuthService(db, cfg, settingService, sessionService, authenticators...)
+	twoFactorService, err := service.NewTwoFactorService(db, cfg, authService, settingService)
+	if err != nil {
+		log.Fatal("Failed to init two-factor service:", err)
+	}
+	webAuthnService, err := service.NewWebAuthnService(db, cfg, authService)
+	if err != nil {
+		log.Fatal("Failed to init WebAuthn:", err)
+	}
+	accountService := service.NewAccountService(db, cfg, mail, authService)
+	adminService := service.NewAdminService(db, authService)
 	conversationService := service.NewConversationService(db)
 	messageService := service.NewMessageService(db)
 	fixedPromptService := service.NewFixedPromptService(db)
 	aiService := service.NewAIService(db, cfg)
+	streamTicketService := service.NewStreamTicketService(db)
+	shareService := service.NewShareService(db, cfg)
+	exportService := service.NewExportService(db)
+	historyImportService := service.NewHistoryImportService(db)
+	searchService := service.NewSearchService(db)
+	blobStore, err := blobstore.New(cfg)
+	if err != nil {
+		log.Fatal("Failed to init attachment store:", err)
+	}
+	attachmentService := service.NewAttachmentService(db, cfg, blobStore)
+	if cfg.AttachmentCleanupInterval > 0 {
+		go attachmentService.RunCleaner(context.Background(), time.Duration(cfg.AttachmentCleanupInterval)*time.Second)
+	}
+	documentService := service.NewDocumentService(db, cfg)
+	knowledgeBaseService := service.NewKnowledgeBaseService(db, cfg)
+	memoryService := service.NewMemoryService(db, cfg, aiService)
+	assistantService := service.NewAssistantService(db)
+	if cfg.MemoryExtractInterval > 0 {
+		go memoryService.RunExtractor(context.Background(), time.Duration(cfg.MemoryExtractInterval)*time.Second)
+	}
+	workspaceService, err := service.NewWorkspaceService(db, cfg)
+	if err != nil {
+		log.Fatal("Failed to init workspace service:", err)
+	}
+
+	// 语义检索为可选功能，启用后在后台为消息计算向量
+	var semanticSearchService *service.SemanticSearchService
+	if cfg.SemanticSearchEnabled() {
+		provider, err := embedding.New(cfg)
+		if err != nil {
+			log.Fatal("Failed to init embedding provider:", err)
+		}
+		semanticSearchService = service.NewSemanticSearchService(db, provider)
+		go semanticSearchService.RunIndexer(context.Background(), time.Duration(cfg.EmbeddingIndexInterval)*time.Second)
+	}
+
+	// 单点登录为可选功能
+	var oidcService *service.OIDCService
+	if cfg.OIDCEnabled() {
+		oidcService, err = service.NewOIDCService(db, cfg, authService)
+		if err != nil {
+			log.Fatal("Failed to init OIDC:", err)
+		}
+	}
 
 	// 初始化处理器
 	authHandler := handler.NewAuthHandler(authService)
+	accountHandler := handler.NewAccountHandler(accountService)
+	var oidcHandler *handler.OIDCHandler
+	if oidcService != nil {
+		oidcHandler = handler.NewOIDCHandler(oidcService, cfg.AppBaseURL)
+	}
+	twoFactorHandler := handler.NewTwoFactorHandler(twoFactorService)
+	settingHandler := handler.NewSettingHandler(settingService)
+	webAuthnHandler := handler.NewWebAuthnHandler(webAuthnService)
+	sessionHandler := handler.NewSessionHandler(sessionService)
+	streamTicketHandler := handler.NewStreamTicketHandler(streamTicketService)
 	userHandler := handler.NewUserHandler(userService)
+	adminHandler := handler.NewAdminHandler(adminService)
 	conversationHandler := handler.NewConversationHandler(conversationService, messageService)
 	messageHandler := handler.NewMessageHandler(messageService)
 	fixedPromptHandler := handler.NewFixedPromptHandler(fixedPromptService)
-	aiHandler := handler.NewAIHandler(aiService, conversationService, messageService, fixedPromptService)
+	workspaceHandler := handler.NewWorkspaceHandler(workspaceService)
+	shareHandler := handler.NewShareHandler(shareService)
+	exportHandler := handler.NewExportHandler(exportService)
+	importHandler := handler.NewImportHandler(historyImportService)
+	searchHandler := handler.NewSearchHandler(searchService, semanticSearchService)
+	attachmentHandler := handler.NewAttachmentHandler(attachmentService, cfg.AttachmentMaxSize)
+	documentHandler := handler.NewDocumentHandler(documentService, cfg.DocumentMaxSize)
+	knowledgeHandler := handler.NewKnowledgeBaseHandler(knowledgeBaseService)
+	memoryHandler := handler.NewMemoryHandler(memoryService)
+	assistantHandler := handler.NewAssistantHandler(assistantService, knowledgeBaseService)
+	aiHandler := handler.NewAIHandler(aiService, conversationService, messageService, fixedPromptService, workspaceService, attachmentService, documentService, knowledgeBaseService, memoryService, assistantService)
 
 	// 创建路由配置
 	routerConfig := &router.RouterConfig{
 		JWTSecret:           cfg.JWTSecret,
+		Sessions:            sessionService,
+		StreamTickets:       streamTicketService,
+		ImpersonationAudit:  adminService,
+		RateLimit:           cfg.RateLimitLimit,
+		RateLimitWindow:     time.Duration(cfg.RateLimitTTL) * time.Second,
+		MailRateLimit:       cfg.MailRateLimitLimit,
+		MailRateLimitWindow: time.Duration(cfg.MailRateLimitTTL) * time.Second,
 		AuthHandler:         authHandler,
+		AccountHandler:      accountHandler,
+		TwoFactorHandler:    twoFactorHandler,
+		SettingHandler:      settingHandler,
+		WebAuthnHandler:     webAuthnHandler,
+		SessionHandler:      sessionHandler,
+		StreamTicketHandler: streamTicketHandler,
+		OIDCHandler:         oidcHandler,
 		UserHandler:         userHandler,
+		AdminHandler:        adminHandler,
+		WorkspaceHandler:    workspaceHandler,
+		ShareHandler:        shareHandler,
+		ExportHandler:       exportHandler,
+		ImportHandler:       importHandler,
+		SearchHandler:       searchHandler,
+		AttachmentHandler:   attachmentHandler,
+		DocumentHandler:     documentHandler,
+		KnowledgeHandler:    knowledgeHandler,
+		MemoryHandler:       memoryHandler,
+		AssistantHandler:    assistantHandler,
 		ConversationHandler: conversationHandler,
 		MessageHandler:      messageHandler,
 		FixedPromptHandler:  fixedPromptHandler,